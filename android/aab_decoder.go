@@ -0,0 +1,424 @@
+package android
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"context"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/frantjc/momo/apktool"
+	"github.com/frantjc/momo/bundletool"
+	xslice "github.com/frantjc/x/slice"
+)
+
+const (
+	ContentTypeAAB = "application/x-android-app-bundle"
+	// AABManifestName is the path to the protobuf AndroidManifest.xml of the base module of an .aab.
+	AABManifestName = "base/manifest/" + AndroidManifestName
+	// UniversalAPKName is the name of the universal APK in the .apks that bundletool builds.
+	UniversalAPKName = "universal.apk"
+)
+
+// AABDecoder decodes an Android App Bundle. Its manifest, metadata, icons, ABIs and densities
+// are read from the .aab itself. Everything else is read from the universal APK that bundletool
+// builds from it, as the .aab is not signed the way that the APKs that are installed are.
+type AABDecoder struct {
+	Name string
+
+	bundletool string
+	buildOpts  bundletool.BuildAPKsOpts
+	apkOpts    []APKDecoderOpt
+	dir        string
+	zipr       *zip.ReadCloser
+	manifest   *Manifest
+	metadata   *apktool.Metadata
+	universal  string
+	apk        *APKDecoder
+}
+
+type AABDecoderOpt func(*AABDecoder)
+
+func WithBundletool(b string) AABDecoderOpt {
+	return func(a *AABDecoder) {
+		a.bundletool = b
+	}
+}
+
+// WithKeyStore makes the AABDecoder sign the universal APK with the key alias from
+// the keystore at ks. The passwords are either file:<path> or env:<name>.
+func WithKeyStore(ks, alias, ksPass, keyPass string) AABDecoderOpt {
+	return func(a *AABDecoder) {
+		a.buildOpts.KeyStore = ks
+		a.buildOpts.KeyAlias = alias
+		a.buildOpts.KeyStorePass = ksPass
+		a.buildOpts.KeyPass = keyPass
+	}
+}
+
+// WithAPKDecoderOpts passes opts to the APKDecoder of the universal APK.
+func WithAPKDecoderOpts(opts ...APKDecoderOpt) AABDecoderOpt {
+	return func(a *AABDecoder) {
+		a.apkOpts = append(a.apkOpts, opts...)
+	}
+}
+
+func NewAABDecoder(name string, opts ...AABDecoderOpt) *AABDecoder {
+	ad := &AABDecoder{Name: name, bundletool: "bundletool"}
+
+	for _, opt := range opts {
+		opt(ad)
+	}
+
+	return ad
+}
+
+func (a *AABDecoder) zipReader() (*zip.Reader, error) {
+	if a.zipr != nil {
+		return &a.zipr.Reader, nil
+	}
+
+	zr, err := zip.OpenReader(a.Name)
+	if err != nil {
+		return nil, err
+	}
+	a.zipr = zr
+
+	return &zr.Reader, nil
+}
+
+func (a *AABDecoder) readZipFile(name string) ([]byte, error) {
+	zr, err := a.zipReader()
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := zr.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+
+	return io.ReadAll(f)
+}
+
+// entries returns the paths of the files in the .aab with their
+// module directory trimmed, so that they are laid out like an .apk's.
+func (a *AABDecoder) entries() ([]string, error) {
+	zr, err := a.zipReader()
+	if err != nil {
+		return nil, err
+	}
+
+	names := []string{}
+	for _, f := range zr.File {
+		if _, name, ok := strings.Cut(f.Name, "/"); ok {
+			names = append(names, name)
+		}
+	}
+
+	return names, nil
+}
+
+// UniversalAPK builds a universal APK from the .aab with bundletool
+// and returns the path to it. It is removed by Close.
+func (a *AABDecoder) UniversalAPK(ctx context.Context) (_ string, err error) {
+	ctx, span := tracer.Start(ctx, "bundletool.BuildAPKs")
+	defer func() {
+		endSpan(span, err)
+	}()
+
+	if a.universal != "" {
+		return a.universal, nil
+	}
+
+	if a.dir == "" {
+		if a.dir, err = os.MkdirTemp(filepath.Dir(a.Name), "*"); err != nil {
+			return "", err
+		}
+	}
+
+	var (
+		apks = filepath.Join(a.dir, "universal.apks")
+		opts = a.buildOpts
+	)
+	opts.Output = apks
+	opts.Mode = bundletool.ModeUniversal
+	opts.Overwrite = true
+
+	if err := bundletool.Command(a.bundletool).BuildAPKs(ctx, a.Name, &opts); err != nil {
+		return "", err
+	}
+
+	zr, err := zip.OpenReader(apks)
+	if err != nil {
+		return "", err
+	}
+	defer func() {
+		_ = zr.Close()
+	}()
+
+	r, err := zr.Open(UniversalAPKName)
+	if err != nil {
+		return "", err
+	}
+	defer func() {
+		_ = r.Close()
+	}()
+
+	universal := filepath.Join(a.dir, UniversalAPKName)
+
+	f, err := os.Create(universal)
+	if err != nil {
+		return "", err
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+
+	if _, err = io.Copy(f, r); err != nil {
+		return "", err
+	}
+
+	if err = f.Close(); err != nil {
+		return "", err
+	}
+
+	a.universal = universal
+
+	return universal, nil
+}
+
+func (a *AABDecoder) universalAPKDecoder(ctx context.Context) (*APKDecoder, error) {
+	if a.apk != nil {
+		return a.apk, nil
+	}
+
+	universal, err := a.UniversalAPK(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	a.apk = NewAPKDecoder(universal, append([]APKDecoderOpt{WithDir(filepath.Join(a.dir, "universal"))}, a.apkOpts...)...)
+
+	return a.apk, nil
+}
+
+func (a *AABDecoder) Manifest(ctx context.Context) (_ *Manifest, err error) {
+	_, span := tracer.Start(ctx, "AABDecoder.Manifest")
+	defer func() {
+		endSpan(span, err)
+	}()
+
+	if a.manifest != nil {
+		return a.manifest, nil
+	}
+
+	b, err := a.readZipFile(AABManifestName)
+	if err != nil {
+		return nil, err
+	}
+
+	a.manifest, err = decodeProtoManifest(b)
+	return a.manifest, err
+}
+
+func (a *AABDecoder) Metadata(ctx context.Context) (_ *apktool.Metadata, err error) {
+	ctx, span := tracer.Start(ctx, "AABDecoder.Metadata")
+	defer func() {
+		endSpan(span, err)
+	}()
+
+	if a.metadata != nil {
+		return a.metadata, nil
+	}
+
+	manifest, err := a.Manifest(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	a.metadata, err = metadataFromManifest(manifest, func(value string) (string, error) {
+		// A versionName that references a resource is left unset
+		// so that the versionCode is used for the version instead.
+		if strings.HasPrefix(value, "@") {
+			return "", nil
+		}
+
+		return value, nil
+	})
+	return a.metadata, err
+}
+
+// SHA256CertFingerprints returns the fingerprints of the certificate that the universal APK is signed with.
+func (a *AABDecoder) SHA256CertFingerprints(ctx context.Context) (_ string, err error) {
+	ctx, span := tracer.Start(ctx, "AABDecoder.SHA256CertFingerprints")
+	defer func() {
+		endSpan(span, err)
+	}()
+
+	apk, err := a.universalAPKDecoder(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	return apk.SHA256CertFingerprints(ctx)
+}
+
+// Label returns the app's android:label, resolved from the universal APK's resources.
+func (a *AABDecoder) Label(ctx context.Context) (_ string, err error) {
+	ctx, span := tracer.Start(ctx, "AABDecoder.Label")
+	defer func() {
+		endSpan(span, err)
+	}()
+
+	apk, err := a.universalAPKDecoder(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	return apk.Label(ctx)
+}
+
+// ABIs returns the ABIs that the .aab's modules have native libraries for.
+func (a *AABDecoder) ABIs(ctx context.Context) (_ []string, err error) {
+	_, span := tracer.Start(ctx, "AABDecoder.ABIs")
+	defer func() {
+		endSpan(span, err)
+	}()
+
+	names, err := a.entries()
+	if err != nil {
+		return nil, err
+	}
+
+	return abisFromEntries(names), nil
+}
+
+// Densities returns the screen densities that the .aab's modules have resources for.
+func (a *AABDecoder) Densities(ctx context.Context) (_ []string, err error) {
+	_, span := tracer.Start(ctx, "AABDecoder.Densities")
+	defer func() {
+		endSpan(span, err)
+	}()
+
+	names, err := a.entries()
+	if err != nil {
+		return nil, err
+	}
+
+	return densitiesFromEntries(names), nil
+}
+
+// Icons returns a tarball of the images of the icon and roundIcon in the base module's resources.
+func (a *AABDecoder) Icons(ctx context.Context) (io.Reader, error) {
+	ctx, span := tracer.Start(ctx, "AABDecoder.Icons")
+
+	manifest, err := a.Manifest(ctx)
+	if err != nil {
+		endSpan(span, err)
+		return nil, err
+	}
+
+	zr, err := a.zipReader()
+	if err != nil {
+		endSpan(span, err)
+		return nil, err
+	}
+
+	iconNames := []string{}
+
+	for _, attr := range manifest.Application.Attrs {
+		if attr.Name.Space == NamespaceAndroid && xslice.Includes([]string{"icon", "roundIcon"}, attr.Name.Local) {
+			iconNames = append(iconNames, parseIconName(attr.Value))
+		}
+	}
+
+	var (
+		pr, pw = io.Pipe()
+		tw     = tar.NewWriter(pw)
+	)
+
+	go func() {
+		err := func() error {
+			for _, f := range zr.File {
+				if !strings.HasPrefix(f.Name, "base/res/") {
+					continue
+				}
+
+				var (
+					base = path.Base(f.Name)
+					ext  = strings.ToLower(path.Ext(base))
+				)
+
+				if !xslice.Includes([]string{".png", ".jpg", ".jpeg"}, ext) || !xslice.Includes(iconNames, strings.TrimSuffix(base, path.Ext(base))) {
+					continue
+				}
+
+				if err := tw.WriteHeader(&tar.Header{
+					Name:     base,
+					Mode:     0o644,
+					Size:     int64(f.UncompressedSize64),
+					ModTime:  time.Now(),
+					Typeflag: tar.TypeReg,
+				}); err != nil {
+					return err
+				}
+
+				rc, err := f.Open()
+				if err != nil {
+					return err
+				}
+
+				_, err = io.Copy(tw, rc)
+				_ = rc.Close()
+				if err != nil {
+					return err
+				}
+			}
+
+			return nil
+		}()
+
+		_ = tw.Close()
+		_ = pw.CloseWithError(err)
+		endSpan(span, err)
+	}()
+
+	return pr, nil
+}
+
+func (a *AABDecoder) Close() error {
+	if a.apk != nil {
+		if err := a.apk.Close(); err != nil {
+			return err
+		}
+	}
+
+	if a.zipr != nil {
+		if err := a.zipr.Close(); err != nil {
+			return err
+		}
+	}
+
+	if a.dir != "" {
+		if err := os.RemoveAll(a.dir); err != nil {
+			return err
+		}
+	}
+
+	a.dir = ""
+	a.zipr = nil
+	a.manifest = nil
+	a.metadata = nil
+	a.universal = ""
+	a.apk = nil
+
+	return nil
+}
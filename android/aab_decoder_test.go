@@ -0,0 +1,168 @@
+package android_test
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/frantjc/momo/android"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// protoAttr encodes an aapt.pb.XmlAttribute, with item as its compiled_item if set.
+func protoAttr(ns, name, value string, item []byte) []byte {
+	b := []byte{}
+	if ns != "" {
+		b = protowire.AppendTag(b, 1, protowire.BytesType)
+		b = protowire.AppendString(b, ns)
+	}
+	b = protowire.AppendTag(b, 2, protowire.BytesType)
+	b = protowire.AppendString(b, name)
+	if value != "" {
+		b = protowire.AppendTag(b, 3, protowire.BytesType)
+		b = protowire.AppendString(b, value)
+	}
+	if item != nil {
+		b = protowire.AppendTag(b, 6, protowire.BytesType)
+		b = protowire.AppendBytes(b, item)
+	}
+	return b
+}
+
+// protoElement encodes an aapt.pb.XmlNode holding an XmlElement.
+func protoElement(name string, attrs [][]byte, children ...[]byte) []byte {
+	el := protowire.AppendTag(nil, 3, protowire.BytesType)
+	el = protowire.AppendString(el, name)
+	for _, attr := range attrs {
+		el = protowire.AppendTag(el, 4, protowire.BytesType)
+		el = protowire.AppendBytes(el, attr)
+	}
+	for _, child := range children {
+		el = protowire.AppendTag(el, 5, protowire.BytesType)
+		el = protowire.AppendBytes(el, child)
+	}
+	return protowire.AppendBytes(protowire.AppendTag(nil, 1, protowire.BytesType), el)
+}
+
+func TestAABDecoder(t *testing.T) {
+	var (
+		ctx = context.Background()
+		// An aapt.pb.Item whose ref is named mipmap/ic_launcher.
+		iconRef = protowire.AppendBytes(
+			protowire.AppendTag(nil, 1, protowire.BytesType),
+			protowire.AppendString(protowire.AppendTag(nil, 3, protowire.BytesType), "mipmap/ic_launcher"),
+		)
+		// An aapt.pb.Item whose prim is the int 34.
+		sdkPrim = protowire.AppendBytes(
+			protowire.AppendTag(nil, 7, protowire.BytesType),
+			protowire.AppendVarint(protowire.AppendTag(nil, 6, protowire.VarintType), 34),
+		)
+		manifest = protoElement("manifest",
+			[][]byte{
+				protoAttr("", "package", "com.example.bundle", nil),
+				protoAttr(android.NamespaceAndroid, "versionCode", "7", nil),
+				protoAttr(android.NamespaceAndroid, "versionName", "1.2.3", nil),
+			},
+			protoElement("uses-sdk", [][]byte{protoAttr(android.NamespaceAndroid, "targetSdkVersion", "", sdkPrim)}),
+			protoElement("application", [][]byte{protoAttr(android.NamespaceAndroid, "icon", "", iconRef)}),
+		)
+		name = filepath.Join(t.TempDir(), "app.aab")
+	)
+
+	f, err := os.Create(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	zw := zip.NewWriter(f)
+	for entry, b := range map[string][]byte{
+		android.AABManifestName:                     manifest,
+		"base/res/mipmap-xxhdpi-v4/ic_launcher.png": []byte("png"),
+		"base/res/drawable-mdpi-v4/background.png":  []byte("png"),
+		"base/lib/arm64-v8a/libnative.so":           []byte("so"),
+		"feature/lib/x86_64/libfeature.so":          []byte("so"),
+	} {
+		w, err := zw.Create(entry)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err = w.Write(b); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err = zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = f.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	dec := android.NewAABDecoder(name)
+	defer func() {
+		_ = dec.Close()
+	}()
+
+	m, err := dec.Manifest(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if m.Package() != "com.example.bundle" {
+		t.Fatalf("expected package com.example.bundle, got %s", m.Package())
+	}
+
+	metadata, err := dec.Metadata(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if metadata.VersionInfo.VersionCode != 7 || metadata.VersionInfo.VersionName != "1.2.3" {
+		t.Fatalf("expected version 1.2.3 (7), got %s (%d)", metadata.VersionInfo.VersionName, metadata.VersionInfo.VersionCode)
+	}
+
+	if metadata.SDKInfo.TargetSDKVersion != 34 {
+		t.Fatalf("expected targetSdkVersion 34, got %d", metadata.SDKInfo.TargetSDKVersion)
+	}
+
+	abis, err := dec.ABIs(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !slices.Equal(abis, []string{"arm64-v8a", "x86_64"}) {
+		t.Fatalf("expected ABIs arm64-v8a and x86_64, got %v", abis)
+	}
+
+	icons, err := dec.Icons(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var (
+		tr    = tar.NewReader(icons)
+		names = []string{}
+	)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			t.Fatal(err)
+		}
+
+		names = append(names, hdr.Name)
+	}
+
+	if !slices.Equal(names, []string{"ic_launcher.png"}) {
+		t.Fatalf("expected icon ic_launcher.png, got %v", names)
+	}
+}
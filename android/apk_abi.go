@@ -0,0 +1,166 @@
+package android
+
+import (
+	"context"
+	"io/fs"
+	"path"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+var (
+	// densities are the named screen densities in ascending order.
+	densities = []string{"ldpi", "mdpi", "tvdpi", "hdpi", "xhdpi", "xxhdpi", "xxxhdpi"}
+)
+
+// entries returns the slash-separated paths of the files in the .apk.
+func (a *APKDecoder) entries(ctx context.Context) ([]string, error) {
+	if a.readerAt != nil {
+		zr, err := a.zipReader()
+		if err != nil {
+			return nil, err
+		}
+
+		names := make([]string, len(zr.File))
+		for i, f := range zr.File {
+			names[i] = f.Name
+		}
+
+		return names, nil
+	}
+
+	if err := a.decode(ctx); err != nil {
+		return nil, err
+	}
+
+	names := []string{}
+
+	return names, filepath.WalkDir(a.dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+
+		rel, err := filepath.Rel(a.dir, path)
+		if err != nil {
+			return err
+		}
+
+		names = append(names, filepath.ToSlash(rel))
+		return nil
+	})
+}
+
+// ABIs returns the ABIs that the .apk has native libraries for, from its lib/<abi>/
+// directories. An .apk without native libraries runs on any ABI, so it has none.
+func (a *APKDecoder) ABIs(ctx context.Context) (_ []string, err error) {
+	ctx, span := tracer.Start(ctx, "APKDecoder.ABIs")
+	defer func() {
+		endSpan(span, err)
+	}()
+
+	names, err := a.entries(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return abisFromEntries(names), nil
+}
+
+// Densities returns the screen densities, e.g. xhdpi, that the .apk has resources for.
+func (a *APKDecoder) Densities(ctx context.Context) (_ []string, err error) {
+	ctx, span := tracer.Start(ctx, "APKDecoder.Densities")
+	defer func() {
+		endSpan(span, err)
+	}()
+
+	names, err := a.entries(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return densitiesFromEntries(names), nil
+}
+
+func abisFromEntries(names []string) []string {
+	abis := []string{}
+
+	for _, name := range names {
+		if rest, ok := strings.CutPrefix(name, "lib/"); ok {
+			if abi, file, ok := strings.Cut(rest, "/"); ok && abi != "" && path.Ext(file) == ".so" && !slices.Contains(abis, abi) {
+				abis = append(abis, abi)
+			}
+		}
+	}
+
+	slices.Sort(abis)
+
+	return abis
+}
+
+func densitiesFromEntries(names []string) []string {
+	found := []string{}
+
+	for _, name := range names {
+		rest, ok := strings.CutPrefix(name, "res/")
+		if !ok {
+			continue
+		}
+
+		dir, _, ok := strings.Cut(rest, "/")
+		if !ok {
+			continue
+		}
+
+		for _, qualifier := range strings.Split(dir, "-")[1:] {
+			if isDensity(qualifier) && !slices.Contains(found, qualifier) {
+				found = append(found, qualifier)
+			}
+		}
+	}
+
+	slices.SortFunc(found, func(a, b string) int {
+		return densityDPI(a) - densityDPI(b)
+	})
+
+	return found
+}
+
+// isDensity reports whether the resource qualifier is a screen density,
+// either named or in dpi. nodpi and anydpi are not densities.
+func isDensity(qualifier string) bool {
+	if slices.Contains(densities, qualifier) {
+		return true
+	}
+
+	dpi, ok := strings.CutSuffix(qualifier, "dpi")
+	if !ok {
+		return false
+	}
+
+	_, err := strconv.Atoi(dpi)
+	return err == nil
+}
+
+func densityDPI(density string) int {
+	switch density {
+	case "ldpi":
+		return 120
+	case "mdpi":
+		return 160
+	case "tvdpi":
+		return 213
+	case "hdpi":
+		return 240
+	case "xhdpi":
+		return 320
+	case "xxhdpi":
+		return 480
+	case "xxxhdpi":
+		return 640
+	}
+
+	dpi, _ := strconv.Atoi(strings.TrimSuffix(density, "dpi"))
+	return dpi
+}
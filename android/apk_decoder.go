@@ -2,8 +2,10 @@ package android
 
 import (
 	"archive/tar"
+	"archive/zip"
 	"context"
 	"encoding/xml"
+	"fmt"
 	"io"
 	"io/fs"
 	"os"
@@ -13,6 +15,7 @@ import (
 	"github.com/frantjc/momo/apktool"
 	"github.com/frantjc/momo/keytool"
 	xslice "github.com/frantjc/x/slice"
+	"github.com/shogo82148/androidbinary"
 	"gopkg.in/yaml.v3"
 )
 
@@ -23,13 +26,17 @@ const (
 type APKDecoder struct {
 	Name string
 
-	apktool  string
-	keytool  string
-	dir      string
-	decoded  bool
-	manifest *Manifest
-	metadata *apktool.Metadata
-	icons    map[int]string
+	apktool   string
+	keytool   string
+	dir       string
+	decoded   bool
+	manifest  *Manifest
+	metadata  *apktool.Metadata
+	icons     map[int]string
+	readerAt  io.ReaderAt
+	size      int64
+	zipr      *zip.Reader
+	resources *androidbinary.TableFile
 }
 
 type APKDecoderOpt func(*APKDecoder)
@@ -62,10 +69,17 @@ func NewAPKDecoder(name string, opts ...APKDecoderOpt) *APKDecoder {
 	return ad
 }
 
-func (a *APKDecoder) decode(ctx context.Context) error {
+func (a *APKDecoder) decode(ctx context.Context) (err error) {
 	if a.decoded {
 		return nil
-	} else if a.dir == "" {
+	}
+
+	ctx, span := tracer.Start(ctx, "apktool.Decode")
+	defer func() {
+		endSpan(span, err)
+	}()
+
+	if a.dir == "" {
 		var err error
 		a.dir, err = os.MkdirTemp(filepath.Dir(a.Name), "*")
 		if err != nil {
@@ -88,15 +102,25 @@ func (a *APKDecoder) decode(ctx context.Context) error {
 	return nil
 }
 
-func (a *APKDecoder) Manifest(ctx context.Context) (*Manifest, error) {
-	if err := a.decode(ctx); err != nil {
-		return nil, err
-	}
+func (a *APKDecoder) Manifest(ctx context.Context) (_ *Manifest, err error) {
+	ctx, span := tracer.Start(ctx, "APKDecoder.Manifest")
+	defer func() {
+		endSpan(span, err)
+	}()
 
 	if a.manifest != nil {
 		return a.manifest, nil
 	}
 
+	if a.readerAt != nil {
+		a.manifest, err = a.manifestFromZip()
+		return a.manifest, err
+	}
+
+	if err := a.decode(ctx); err != nil {
+		return nil, err
+	}
+
 	file, err := os.Open(filepath.Join(a.dir, AndroidManifestName))
 	if err != nil {
 		return nil, err
@@ -111,15 +135,30 @@ func (a *APKDecoder) Manifest(ctx context.Context) (*Manifest, error) {
 	return manifest, err
 }
 
-func (a *APKDecoder) Metadata(ctx context.Context) (*apktool.Metadata, error) {
-	if err := a.decode(ctx); err != nil {
-		return nil, err
-	}
+func (a *APKDecoder) Metadata(ctx context.Context) (_ *apktool.Metadata, err error) {
+	ctx, span := tracer.Start(ctx, "APKDecoder.Metadata")
+	defer func() {
+		endSpan(span, err)
+	}()
 
 	if a.metadata != nil {
 		return a.metadata, nil
 	}
 
+	if a.readerAt != nil {
+		manifest, err := a.Manifest(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		a.metadata, err = a.metadataFromZip(manifest)
+		return a.metadata, err
+	}
+
+	if err := a.decode(ctx); err != nil {
+		return nil, err
+	}
+
 	f, err := os.Open(filepath.Join(a.dir, "apktool.yml"))
 	if err != nil {
 		return nil, err
@@ -129,28 +168,101 @@ func (a *APKDecoder) Metadata(ctx context.Context) (*apktool.Metadata, error) {
 	}()
 
 	a.metadata = &apktool.Metadata{}
-	return a.metadata, yaml.NewDecoder(f).Decode(a.metadata)
+	err = yaml.NewDecoder(f).Decode(a.metadata)
+	return a.metadata, err
 }
 
-func (a *APKDecoder) SHA256CertFingerprints(ctx context.Context) (string, error) {
+func (a *APKDecoder) SHA256CertFingerprints(ctx context.Context) (_ string, err error) {
+	ctx, span := tracer.Start(ctx, "APKDecoder.SHA256CertFingerprints")
+	defer func() {
+		endSpan(span, err)
+	}()
+
+	if a.readerAt != nil {
+		zr, err := a.zipReader()
+		if err != nil {
+			return "", err
+		}
+
+		cert, err := signingCertificate(a.readerAt, a.size, zr)
+		if err != nil {
+			return "", err
+		}
+
+		return fingerprint(cert), nil
+	}
+
 	return keytool.Command(a.keytool).SHA256CertFingerprints(ctx, a.Name)
 }
 
+// Label returns the app's android:label, resolved if it is a string resource.
+func (a *APKDecoder) Label(ctx context.Context) (_ string, err error) {
+	ctx, span := tracer.Start(ctx, "APKDecoder.Label")
+	defer func() {
+		endSpan(span, err)
+	}()
+
+	manifest, err := a.Manifest(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	label := androidAttr(manifest.Application.Attrs, "label")
+
+	if a.readerAt != nil {
+		return a.resolve(label, nil)
+	}
+
+	name, ok := strings.CutPrefix(label, "@string/")
+	if !ok {
+		return label, nil
+	}
+
+	f, err := os.Open(filepath.Join(a.dir, "res", "values", "strings.xml"))
+	if err != nil {
+		return "", err
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+
+	resources := &struct {
+		Strings []struct {
+			Name  string `xml:"name,attr"`
+			Value string `xml:",chardata"`
+		} `xml:"string"`
+	}{}
+	if err := xml.NewDecoder(f).Decode(resources); err != nil {
+		return "", err
+	}
+
+	for _, s := range resources.Strings {
+		if s.Name == name {
+			return s.Value, nil
+		}
+	}
+
+	return "", fmt.Errorf("string resource %s not found", name)
+}
+
 func parseIconName(value string) string {
 	spl := strings.Split(value, "/")
 	return spl[len(spl)-1]
 }
 
 func (a *APKDecoder) Icons(ctx context.Context) (io.Reader, error) {
+	ctx, span := tracer.Start(ctx, "APKDecoder.Icons")
+
 	manifest, err := a.Manifest(ctx)
 	if err != nil {
+		endSpan(span, err)
 		return nil, err
 	}
 
 	iconNames := []string{}
 
 	for _, attr := range manifest.Application.Attrs {
-		if attr.Name.Space == "http://schemas.android.com/apk/res/android" && xslice.Includes([]string{"icon", "roundIcon"}, attr.Name.Local) {
+		if attr.Name.Space == NamespaceAndroid && xslice.Includes([]string{"icon", "roundIcon"}, attr.Name.Local) {
 			iconNames = append(iconNames, parseIconName(attr.Value))
 		}
 	}
@@ -161,6 +273,14 @@ func (a *APKDecoder) Icons(ctx context.Context) (io.Reader, error) {
 	)
 
 	go func() {
+		if a.readerAt != nil {
+			err := a.iconsFromZip(manifest, tw)
+			_ = tw.Close()
+			_ = pw.CloseWithError(err)
+			endSpan(span, err)
+			return
+		}
+
 		err := filepath.WalkDir(a.dir, func(path string, d fs.DirEntry, err error) error {
 			if err != nil {
 				return err
@@ -213,6 +333,7 @@ func (a *APKDecoder) Icons(ctx context.Context) (io.Reader, error) {
 
 		_ = tw.Close()
 		_ = pw.CloseWithError(err)
+		endSpan(span, err)
 	}()
 
 	return pr, nil
@@ -229,6 +350,8 @@ func (a *APKDecoder) Close() error {
 	a.metadata = nil
 	a.manifest = nil
 	a.icons = nil
+	a.zipr = nil
+	a.resources = nil
 
 	return nil
 }
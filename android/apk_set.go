@@ -0,0 +1,504 @@
+package android
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/frantjc/momo/apktool"
+)
+
+const (
+	ContentTypeAPKS = "application/x-apks"
+	ContentTypeXAPK = "application/x-xapk"
+	// XAPKManifestName is the name of the manifest of an .xapk that lists its splits.
+	XAPKManifestName = "manifest.json"
+	// ModuleBase is the name of the module that every split APK set has.
+	ModuleBase = "base"
+)
+
+var (
+	// abis are the ABIs that Android supports, as they are named in lib/<abi>/.
+	abis = []string{"armeabi", "armeabi-v7a", "arm64-v8a", "x86", "x86_64", "mips", "mips64", "riscv64"}
+)
+
+// Split is an APK in a split APK set. A Split with no ABI, Density or
+// Language is the base split of its module, the rest are its config splits.
+type Split struct {
+	// Name is the path to the split in the set.
+	Name     string
+	Module   string
+	ABI      string
+	Density  string
+	Language string
+}
+
+// IsBase reports whether the split is the base split of its module.
+func (s Split) IsBase() bool {
+	return s.ABI == "" && s.Density == "" && s.Language == ""
+}
+
+// APKSetDecoder decodes a split APK set, either an .apks built by bundletool or an .xapk.
+// Everything but its splits, ABIs and densities is read from the base module's base split.
+type APKSetDecoder struct {
+	Name string
+
+	dir       string
+	zipr      *zip.ReadCloser
+	splits    []Split
+	universal string
+	base      *os.File
+	apk       *APKDecoder
+}
+
+func NewAPKSetDecoder(name string) *APKSetDecoder {
+	return &APKSetDecoder{Name: name}
+}
+
+func (a *APKSetDecoder) zipReader() (*zip.Reader, error) {
+	if a.zipr != nil {
+		return &a.zipr.Reader, nil
+	}
+
+	zr, err := zip.OpenReader(a.Name)
+	if err != nil {
+		return nil, err
+	}
+	a.zipr = zr
+
+	return &zr.Reader, nil
+}
+
+// Splits returns the splits in the set.
+func (a *APKSetDecoder) Splits(ctx context.Context) (_ []Split, err error) {
+	_, span := tracer.Start(ctx, "APKSetDecoder.Splits")
+	defer func() {
+		endSpan(span, err)
+	}()
+
+	if a.splits != nil {
+		return a.splits, nil
+	}
+
+	zr, err := a.zipReader()
+	if err != nil {
+		return nil, err
+	}
+
+	a.splits, err = SplitsFromZip(zr)
+	return a.splits, err
+}
+
+// SplitsFromZip returns the splits in the split APK set zr.
+func SplitsFromZip(zr *zip.Reader) ([]Split, error) {
+	splits := []Split{}
+
+	if f, err := zr.Open(XAPKManifestName); err == nil {
+		defer func() {
+			_ = f.Close()
+		}()
+
+		manifest := &struct {
+			SplitAPKs []struct {
+				File string `json:"file"`
+				ID   string `json:"id"`
+			} `json:"split_apks"`
+		}{}
+		if err := json.NewDecoder(f).Decode(manifest); err != nil {
+			return nil, fmt.Errorf("decode %s: %w", XAPKManifestName, err)
+		}
+
+		for _, splitAPK := range manifest.SplitAPKs {
+			splits = append(splits, splitFromID(splitAPK.File, splitAPK.ID))
+		}
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	} else {
+		for _, f := range zr.File {
+			if path.Ext(f.Name) != ".apk" {
+				continue
+			}
+
+			name := strings.TrimSuffix(f.Name, ".apk")
+
+			if rest, ok := strings.CutPrefix(name, "splits/"); ok {
+				// bundletool names splits <module>-master.apk and <module>-<config>.apk.
+				module, config, _ := strings.Cut(rest, "-")
+				split := splitFromConfig(config)
+				split.Name = f.Name
+				split.Module = module
+				splits = append(splits, split)
+			} else if !strings.Contains(name, "/") && name != "universal" {
+				// An .xapk without a manifest.json names its splits by their IDs.
+				splits = append(splits, splitFromID(f.Name, name))
+			}
+		}
+	}
+
+	if !slices.ContainsFunc(splits, func(split Split) bool {
+		return split.Module == ModuleBase && split.IsBase()
+	}) {
+		return nil, fmt.Errorf("no base split found")
+	}
+
+	return splits, nil
+}
+
+// splitFromID parses an .xapk split ID, e.g. base, config.arm64_v8a or feature.config.en.
+func splitFromID(name, id string) Split {
+	module, config := ModuleBase, ""
+
+	if rest, ok := strings.CutPrefix(id, "config."); ok {
+		config = rest
+	} else if before, after, ok := strings.Cut(id, ".config."); ok {
+		module, config = before, after
+	} else if id != ModuleBase && id != "" && !strings.Contains(id, ".") {
+		module = id
+	}
+
+	split := splitFromConfig(config)
+	split.Name = name
+	split.Module = module
+
+	return split
+}
+
+// splitFromConfig classifies a split by its config, e.g. arm64_v8a, xxhdpi or en.
+// master and the empty config are base splits.
+func splitFromConfig(config string) Split {
+	// ABIs have their dashes replaced with underscores in configs, e.g. arm64_v8a.
+	abi := slices.IndexFunc(abis, func(abi string) bool {
+		return strings.ReplaceAll(abi, "-", "_") == config
+	})
+
+	switch {
+	case config == "" || config == "master":
+		return Split{}
+	case abi >= 0:
+		return Split{ABI: abis[abi]}
+	case isDensity(config):
+		return Split{Density: config}
+	}
+
+	return Split{Language: config}
+}
+
+// SelectSplits returns the splits that a device with the given ABI, density and languages needs:
+// the base splits, the splits for its ABI, the closest density to its own and its languages.
+// Splits of a kind that the device does not specify are all selected.
+func SelectSplits(splits []Split, abi, density string, languages []string) []Split {
+	selected := []Split{}
+
+	for _, split := range splits {
+		switch {
+		case split.IsBase():
+		case split.ABI != "":
+			if abi != "" && split.ABI != abi {
+				continue
+			}
+		case split.Density != "":
+			if density != "" && split.Name != closestDensitySplit(splits, split.Module, density).Name {
+				continue
+			}
+		case split.Language != "":
+			if len(languages) > 0 && !slices.ContainsFunc(languages, func(language string) bool {
+				// Match en-US to en, but not the other way around.
+				return strings.EqualFold(split.Language, language) || strings.HasPrefix(strings.ToLower(language), strings.ToLower(split.Language)+"-")
+			}) {
+				continue
+			}
+		}
+
+		selected = append(selected, split)
+	}
+
+	return selected
+}
+
+// closestDensitySplit returns the density split of module with the smallest
+// density that is at least density, or the largest one if there is none.
+func closestDensitySplit(splits []Split, module, density string) Split {
+	var (
+		dpi     = densityDPI(density)
+		closest Split
+	)
+
+	for _, split := range splits {
+		if split.Module != module || split.Density == "" {
+			continue
+		}
+
+		switch d, c := densityDPI(split.Density), densityDPI(closest.Density); {
+		case closest.Name == "":
+			closest = split
+		case c < dpi && d > c:
+			closest = split
+		case d >= dpi && d < c:
+			closest = split
+		}
+	}
+
+	return closest
+}
+
+func (a *APKSetDecoder) extract(name string) (string, error) {
+	zr, err := a.zipReader()
+	if err != nil {
+		return "", err
+	}
+
+	if a.dir == "" {
+		if a.dir, err = os.MkdirTemp(filepath.Dir(a.Name), "*"); err != nil {
+			return "", err
+		}
+	}
+
+	r, err := zr.Open(name)
+	if err != nil {
+		return "", err
+	}
+	defer func() {
+		_ = r.Close()
+	}()
+
+	f, err := os.CreateTemp(a.dir, "*.apk")
+	if err != nil {
+		return "", err
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+
+	if _, err = io.Copy(f, r); err != nil {
+		return "", err
+	}
+
+	return f.Name(), f.Close()
+}
+
+// UniversalAPK returns the path to the universal APK in the set, if it has one,
+// as an .apks that bundletool built in universal mode does. It is removed by Close.
+func (a *APKSetDecoder) UniversalAPK(ctx context.Context) (_ string, err error) {
+	_, span := tracer.Start(ctx, "APKSetDecoder.UniversalAPK")
+	defer func() {
+		endSpan(span, err)
+	}()
+
+	if a.universal != "" {
+		return a.universal, nil
+	}
+
+	zr, err := a.zipReader()
+	if err != nil {
+		return "", err
+	}
+
+	if !slices.ContainsFunc(zr.File, func(f *zip.File) bool {
+		return f.Name == UniversalAPKName
+	}) {
+		return "", nil
+	}
+
+	a.universal, err = a.extract(UniversalAPKName)
+	return a.universal, err
+}
+
+func (a *APKSetDecoder) baseAPKDecoder(ctx context.Context) (*APKDecoder, error) {
+	if a.apk != nil {
+		return a.apk, nil
+	}
+
+	splits, err := a.Splits(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	i := slices.IndexFunc(splits, func(split Split) bool {
+		return split.Module == ModuleBase && split.IsBase()
+	})
+
+	name, err := a.extract(splits[i].Name)
+	if err != nil {
+		return nil, err
+	}
+
+	if a.base, err = os.Open(name); err != nil {
+		return nil, err
+	}
+
+	fi, err := a.base.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	a.apk = NewAPKDecoder(name, WithReaderAt(a.base, fi.Size()))
+
+	return a.apk, nil
+}
+
+func (a *APKSetDecoder) Manifest(ctx context.Context) (_ *Manifest, err error) {
+	ctx, span := tracer.Start(ctx, "APKSetDecoder.Manifest")
+	defer func() {
+		endSpan(span, err)
+	}()
+
+	apk, err := a.baseAPKDecoder(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return apk.Manifest(ctx)
+}
+
+func (a *APKSetDecoder) Metadata(ctx context.Context) (_ *apktool.Metadata, err error) {
+	ctx, span := tracer.Start(ctx, "APKSetDecoder.Metadata")
+	defer func() {
+		endSpan(span, err)
+	}()
+
+	apk, err := a.baseAPKDecoder(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return apk.Metadata(ctx)
+}
+
+func (a *APKSetDecoder) SHA256CertFingerprints(ctx context.Context) (_ string, err error) {
+	ctx, span := tracer.Start(ctx, "APKSetDecoder.SHA256CertFingerprints")
+	defer func() {
+		endSpan(span, err)
+	}()
+
+	apk, err := a.baseAPKDecoder(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	return apk.SHA256CertFingerprints(ctx)
+}
+
+func (a *APKSetDecoder) Label(ctx context.Context) (_ string, err error) {
+	ctx, span := tracer.Start(ctx, "APKSetDecoder.Label")
+	defer func() {
+		endSpan(span, err)
+	}()
+
+	apk, err := a.baseAPKDecoder(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	return apk.Label(ctx)
+}
+
+// ABIs returns the ABIs of the set's ABI splits along with any that its base split has native libraries for.
+func (a *APKSetDecoder) ABIs(ctx context.Context) (_ []string, err error) {
+	ctx, span := tracer.Start(ctx, "APKSetDecoder.ABIs")
+	defer func() {
+		endSpan(span, err)
+	}()
+
+	apk, err := a.baseAPKDecoder(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	found, err := apk.ABIs(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, split := range a.splits {
+		if split.ABI != "" && !slices.Contains(found, split.ABI) {
+			found = append(found, split.ABI)
+		}
+	}
+
+	slices.Sort(found)
+
+	return found, nil
+}
+
+// Densities returns the densities of the set's density splits along with any that its base split has resources for.
+func (a *APKSetDecoder) Densities(ctx context.Context) (_ []string, err error) {
+	ctx, span := tracer.Start(ctx, "APKSetDecoder.Densities")
+	defer func() {
+		endSpan(span, err)
+	}()
+
+	apk, err := a.baseAPKDecoder(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	found, err := apk.Densities(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, split := range a.splits {
+		if split.Density != "" && !slices.Contains(found, split.Density) {
+			found = append(found, split.Density)
+		}
+	}
+
+	slices.SortFunc(found, func(a, b string) int {
+		return densityDPI(a) - densityDPI(b)
+	})
+
+	return found, nil
+}
+
+// Icons returns the icons of the base split. Icons that are only in density splits are not found.
+func (a *APKSetDecoder) Icons(ctx context.Context) (io.Reader, error) {
+	apk, err := a.baseAPKDecoder(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return apk.Icons(ctx)
+}
+
+func (a *APKSetDecoder) Close() error {
+	if a.apk != nil {
+		if err := a.apk.Close(); err != nil {
+			return err
+		}
+	}
+
+	if a.base != nil {
+		if err := a.base.Close(); err != nil {
+			return err
+		}
+	}
+
+	if a.zipr != nil {
+		if err := a.zipr.Close(); err != nil {
+			return err
+		}
+	}
+
+	if a.dir != "" {
+		if err := os.RemoveAll(a.dir); err != nil {
+			return err
+		}
+	}
+
+	a.dir = ""
+	a.zipr = nil
+	a.splits = nil
+	a.universal = ""
+	a.base = nil
+	a.apk = nil
+
+	return nil
+}
@@ -0,0 +1,161 @@
+package android_test
+
+import (
+	"archive/zip"
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/frantjc/momo/android"
+)
+
+func TestAPKSetDecoder(t *testing.T) {
+	var (
+		ctx  = context.Background()
+		name = filepath.Join(t.TempDir(), "app.apks")
+	)
+
+	f, err := os.Create(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	zw := zip.NewWriter(f)
+	for _, entry := range []string{
+		"splits/base-master.apk",
+		"splits/base-arm64_v8a.apk",
+		"splits/base-x86_64.apk",
+		"splits/base-hdpi.apk",
+		"splits/base-xxhdpi.apk",
+		"splits/base-en.apk",
+		"splits/base-de.apk",
+	} {
+		w, err := zw.Create(entry)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err = w.Write(apk); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err = zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = f.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	dec := android.NewAPKSetDecoder(name)
+	defer func() {
+		_ = dec.Close()
+	}()
+
+	manifest, err := dec.Manifest(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if manifest.Package() != "com.example.helloworld" {
+		t.Fatalf("expected package com.example.helloworld, got %s", manifest.Package())
+	}
+
+	splits, err := dec.Splits(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(splits) != 7 {
+		t.Fatalf("expected 7 splits, got %d", len(splits))
+	}
+
+	abis, err := dec.ABIs(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !slices.Equal(abis, []string{"arm64-v8a", "x86_64"}) {
+		t.Fatalf("expected ABIs arm64-v8a and x86_64, got %v", abis)
+	}
+
+	var (
+		selected = android.SelectSplits(splits, "arm64-v8a", "xhdpi", []string{"en-US"})
+		names    = []string{}
+	)
+	for _, split := range selected {
+		names = append(names, split.Name)
+	}
+
+	if !slices.Equal(names, []string{"splits/base-master.apk", "splits/base-arm64_v8a.apk", "splits/base-xxhdpi.apk", "splits/base-en.apk"}) {
+		t.Fatalf("unexpected splits selected: %v", names)
+	}
+
+	universal, err := dec.UniversalAPK(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if universal != "" {
+		t.Fatalf("expected no universal APK, got %s", universal)
+	}
+}
+
+func TestSplitsFromZipXAPK(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "app.xapk")
+
+	f, err := os.Create(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	zw := zip.NewWriter(f)
+	for entry, b := range map[string]string{
+		android.XAPKManifestName: `{"package_name":"com.example","split_apks":[{"file":"com.example.apk","id":"base"},{"file":"config.armeabi_v7a.apk","id":"config.armeabi_v7a"},{"file":"config.mdpi.apk","id":"config.mdpi"},{"file":"feature.config.fr.apk","id":"feature.config.fr"}]}`,
+		"com.example.apk":        "",
+	} {
+		w, err := zw.Create(entry)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err = w.Write([]byte(b)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err = zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = f.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	zr, err := zip.OpenReader(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = zr.Close()
+	}()
+
+	splits, err := android.SplitsFromZip(&zr.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []android.Split{
+		{Name: "com.example.apk", Module: "base"},
+		{Name: "config.armeabi_v7a.apk", Module: "base", ABI: "armeabi-v7a"},
+		{Name: "config.mdpi.apk", Module: "base", Density: "mdpi"},
+		{Name: "feature.config.fr.apk", Module: "feature", Language: "fr"},
+	}
+
+	if !slices.Equal(splits, expected) {
+		t.Fatalf("expected %v, got %v", expected, splits)
+	}
+}
@@ -0,0 +1,231 @@
+package android
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/frantjc/momo/apktool"
+	xslice "github.com/frantjc/x/slice"
+	"github.com/shogo82148/androidbinary"
+)
+
+const (
+	ResourcesName = "resources.arsc"
+)
+
+var (
+	// iconDensities are the screen densities, in dpi, that the
+	// icons of an .apk are looked up for, i.e. ldpi through xxxhdpi.
+	iconDensities = []uint16{120, 160, 240, 320, 480, 640}
+)
+
+// WithReaderAt makes the APKDecoder read the .apk from r, which is size bytes long,
+// instead of decoding the file Name with apktool. The binary AndroidManifest.xml and
+// resources.arsc are decoded in Go instead, so that only the entries that are needed
+// are read, e.g. from a remote .apk.
+func WithReaderAt(r io.ReaderAt, size int64) APKDecoderOpt {
+	return func(a *APKDecoder) {
+		a.readerAt = r
+		a.size = size
+	}
+}
+
+func (a *APKDecoder) zipReader() (*zip.Reader, error) {
+	if a.zipr != nil {
+		return a.zipr, nil
+	}
+
+	zr, err := zip.NewReader(a.readerAt, a.size)
+	if err != nil {
+		return nil, err
+	}
+	a.zipr = zr
+
+	return zr, nil
+}
+
+func (a *APKDecoder) readZipFile(name string) ([]byte, error) {
+	zr, err := a.zipReader()
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := zr.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+
+	return io.ReadAll(f)
+}
+
+func (a *APKDecoder) table() (*androidbinary.TableFile, error) {
+	if a.resources != nil {
+		return a.resources, nil
+	}
+
+	b, err := a.readZipFile(ResourcesName)
+	if err != nil {
+		return nil, err
+	}
+
+	table, err := androidbinary.NewTableFile(bytes.NewReader(b))
+	if err != nil {
+		return nil, fmt.Errorf("decode %s: %w", ResourcesName, err)
+	}
+	a.resources = table
+
+	return table, nil
+}
+
+// resolve returns value, or what it references if it is a resource reference.
+func (a *APKDecoder) resolve(value string, config *androidbinary.ResTableConfig) (string, error) {
+	if !androidbinary.IsResID(value) {
+		return value, nil
+	}
+
+	id, err := androidbinary.ParseResID(value)
+	if err != nil {
+		return "", err
+	}
+
+	table, err := a.table()
+	if err != nil {
+		return "", err
+	}
+
+	resource, err := table.GetResource(id, config)
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprint(resource), nil
+}
+
+func (a *APKDecoder) manifestFromZip() (*Manifest, error) {
+	b, err := a.readZipFile(AndroidManifestName)
+	if err != nil {
+		return nil, err
+	}
+
+	xf, err := androidbinary.NewXMLFile(bytes.NewReader(b))
+	if err != nil {
+		return nil, fmt.Errorf("decode %s: %w", AndroidManifestName, err)
+	}
+
+	manifest := &Manifest{}
+	if err := xml.NewDecoder(xf.Reader()).Decode(manifest); err != nil {
+		return nil, err
+	}
+
+	return manifest, nil
+}
+
+// metadataFromZip builds the subset of the apktool.Metadata
+// that is used from the binary AndroidManifest.xml.
+func (a *APKDecoder) metadataFromZip(manifest *Manifest) (*apktool.Metadata, error) {
+	return metadataFromManifest(manifest, func(value string) (string, error) {
+		return a.resolve(value, nil)
+	})
+}
+
+// metadataFromManifest builds the subset of the apktool.Metadata that is used
+// from manifest, resolving resource references in its versionName with resolve.
+func metadataFromManifest(manifest *Manifest, resolve func(string) (string, error)) (*apktool.Metadata, error) {
+	metadata := &apktool.Metadata{}
+
+	for _, attr := range manifest.Attrs {
+		if attr.Name.Space != NamespaceAndroid {
+			continue
+		}
+
+		switch attr.Name.Local {
+		case "versionCode":
+			versionCode, err := strconv.Atoi(attr.Value)
+			if err != nil {
+				return nil, fmt.Errorf("parse versionCode: %w", err)
+			}
+			metadata.VersionInfo.VersionCode = versionCode
+		case "versionName":
+			versionName, err := resolve(attr.Value)
+			if err != nil {
+				return nil, fmt.Errorf("resolve versionName: %w", err)
+			}
+			metadata.VersionInfo.VersionName = versionName
+		}
+	}
+
+	for _, attr := range manifest.UsesSDK.Attrs {
+		if attr.Name.Space != NamespaceAndroid {
+			continue
+		}
+
+		// These are sometimes codenames, e.g. for previews, in which case they are left unset.
+		switch attr.Name.Local {
+		case "minSdkVersion":
+			metadata.SDKInfo.MinSDKVersion, _ = strconv.Atoi(attr.Value)
+		case "targetSdkVersion":
+			metadata.SDKInfo.TargetSDKVersion, _ = strconv.Atoi(attr.Value)
+		}
+	}
+
+	return metadata, nil
+}
+
+// iconsFromZip writes the icon and roundIcon of the .apk
+// for every density in iconDensities to tw.
+func (a *APKDecoder) iconsFromZip(manifest *Manifest, tw *tar.Writer) error {
+	names := []string{}
+
+	for _, attr := range manifest.Application.Attrs {
+		if attr.Name.Space != NamespaceAndroid || !xslice.Includes([]string{"icon", "roundIcon"}, attr.Name.Local) {
+			continue
+		}
+
+		for _, density := range iconDensities {
+			// Adaptive icons are XML that is only used from Android 8.0 (API level 26),
+			// so look up the icons for the version before it to get images instead.
+			name, err := a.resolve(attr.Value, &androidbinary.ResTableConfig{Density: density, SDKVersion: 25})
+			if err != nil {
+				return err
+			}
+
+			if xslice.Includes([]string{".png", ".jpg", ".jpeg"}, strings.ToLower(path.Ext(name))) {
+				names = append(names, name)
+			}
+		}
+	}
+
+	for _, name := range xslice.Unique(names) {
+		b, err := a.readZipFile(name)
+		if err != nil {
+			return err
+		}
+
+		if err = tw.WriteHeader(&tar.Header{
+			Name:     path.Base(name),
+			Mode:     0o644,
+			Size:     int64(len(b)),
+			ModTime:  time.Now(),
+			Typeflag: tar.TypeReg,
+		}); err != nil {
+			return err
+		}
+
+		if _, err = tw.Write(b); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,136 @@
+package android_test
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"slices"
+	"strings"
+	"testing"
+
+	// Used to embed the bytes of the test .apk.
+	_ "embed"
+
+	"github.com/frantjc/momo/android"
+)
+
+var (
+	//go:embed helloworld.test.apk
+	apk []byte
+)
+
+func TestAPKDecoderWithReaderAt(t *testing.T) {
+	var (
+		ctx = context.Background()
+		dec = android.NewAPKDecoder("", android.WithReaderAt(bytes.NewReader(apk), int64(len(apk))))
+	)
+	defer func() {
+		_ = dec.Close()
+	}()
+
+	manifest, err := dec.Manifest(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if manifest.Package() != "com.example.helloworld" {
+		t.Fatalf("expected package com.example.helloworld, got %s", manifest.Package())
+	}
+
+	metadata, err := dec.Metadata(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if metadata.SDKInfo.TargetSDKVersion != 24 {
+		t.Fatalf("expected targetSdkVersion 24, got %d", metadata.SDKInfo.TargetSDKVersion)
+	}
+
+	if metadata.VersionInfo.VersionName == "" {
+		t.Fatal("expected versionName")
+	}
+
+	icons, err := dec.Icons(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var (
+		tr = tar.NewReader(icons)
+		n  = 0
+	)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			t.Fatal(err)
+		}
+
+		if hdr.Name != "ic_launcher.png" {
+			t.Fatalf("unexpected icon %s", hdr.Name)
+		}
+
+		n++
+	}
+
+	// mdpi, hdpi, xhdpi, xxhdpi and xxxhdpi.
+	if n != 5 {
+		t.Fatalf("expected 5 icons, got %d", n)
+	}
+
+	// This .apk is unsigned.
+	if _, err := dec.SHA256CertFingerprints(ctx); err == nil {
+		t.Fatal("expected an error for an unsigned .apk")
+	}
+}
+
+func TestAPKDecoderLabel(t *testing.T) {
+	var (
+		ctx = context.Background()
+		dec = android.NewAPKDecoder("", android.WithReaderAt(bytes.NewReader(apk), int64(len(apk))))
+	)
+	defer func() {
+		_ = dec.Close()
+	}()
+
+	label, err := dec.Label(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if label == "" || strings.HasPrefix(label, "@") {
+		t.Fatalf("expected a resolved label, got %q", label)
+	}
+}
+
+func TestAPKDecoderABIsAndDensities(t *testing.T) {
+	var (
+		ctx = context.Background()
+		dec = android.NewAPKDecoder("", android.WithReaderAt(bytes.NewReader(apk), int64(len(apk))))
+	)
+	defer func() {
+		_ = dec.Close()
+	}()
+
+	abis, err := dec.ABIs(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// This .apk has no native libraries.
+	if len(abis) != 0 {
+		t.Fatalf("expected no ABIs, got %v", abis)
+	}
+
+	densities, err := dec.Densities(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !slices.Equal(densities, []string{"mdpi", "hdpi", "xhdpi", "xxhdpi", "xxxhdpi"}) {
+		t.Fatalf("unexpected densities %v", densities)
+	}
+}
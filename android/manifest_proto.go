@@ -0,0 +1,255 @@
+package android
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"strconv"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// Field numbers of the aapt.pb messages in Resources.proto that
+// make up the protobuf AndroidManifest.xml of an .aab's modules.
+const (
+	xmlNodeElement = 1
+	xmlNodeText    = 2
+
+	xmlElementNamespaceURI = 2
+	xmlElementName         = 3
+	xmlElementAttribute    = 4
+	xmlElementChild        = 5
+
+	xmlAttributeNamespaceURI = 1
+	xmlAttributeName         = 2
+	xmlAttributeValue        = 3
+	xmlAttributeCompiledItem = 6
+	itemRef                  = 1
+	itemStr                  = 2
+	itemRawStr               = 3
+	itemPrim                 = 7
+	referenceID              = 2
+	referenceName            = 3
+	stringValue              = 1
+	primitiveIntDecimalValue = 6
+	primitiveIntHexadecimal  = 7
+	primitiveBooleanValue    = 8
+)
+
+// decodeProtoManifest decodes a protobuf AndroidManifest.xml, as found in an
+// .aab, into a Manifest by way of its textual XML equivalent.
+func decodeProtoManifest(b []byte) (*Manifest, error) {
+	buf := new(bytes.Buffer)
+	enc := xml.NewEncoder(buf)
+
+	if err := encodeProtoXMLNode(enc, b); err != nil {
+		return nil, fmt.Errorf("decode %s: %w", AndroidManifestName, err)
+	}
+
+	if err := enc.Flush(); err != nil {
+		return nil, err
+	}
+
+	manifest := &Manifest{}
+	if err := xml.NewDecoder(buf).Decode(manifest); err != nil {
+		return nil, err
+	}
+
+	return manifest, nil
+}
+
+// protoFields calls fn with each field of the protobuf message b.
+// Only the wire types used by Resources.proto are supported.
+func protoFields(b []byte, fn func(num protowire.Number, typ protowire.Type, v []byte, n uint64) error) error {
+	for len(b) > 0 {
+		num, typ, l := protowire.ConsumeTag(b)
+		if l < 0 {
+			return protowire.ParseError(l)
+		}
+		b = b[l:]
+
+		var (
+			v []byte
+			n uint64
+		)
+		switch typ {
+		case protowire.BytesType:
+			v, l = protowire.ConsumeBytes(b)
+		case protowire.VarintType:
+			n, l = protowire.ConsumeVarint(b)
+		default:
+			l = protowire.ConsumeFieldValue(num, typ, b)
+		}
+		if l < 0 {
+			return protowire.ParseError(l)
+		}
+		b = b[l:]
+
+		if err := fn(num, typ, v, n); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func encodeProtoXMLNode(enc *xml.Encoder, b []byte) error {
+	return protoFields(b, func(num protowire.Number, typ protowire.Type, v []byte, _ uint64) error {
+		if typ != protowire.BytesType {
+			return nil
+		}
+
+		switch num {
+		case xmlNodeElement:
+			return encodeProtoXMLElement(enc, v)
+		case xmlNodeText:
+			return enc.EncodeToken(xml.CharData(v))
+		}
+
+		return nil
+	})
+}
+
+func encodeProtoXMLElement(enc *xml.Encoder, b []byte) error {
+	var (
+		start    = xml.StartElement{}
+		children = [][]byte{}
+	)
+
+	if err := protoFields(b, func(num protowire.Number, typ protowire.Type, v []byte, _ uint64) error {
+		if typ != protowire.BytesType {
+			return nil
+		}
+
+		switch num {
+		case xmlElementNamespaceURI:
+			start.Name.Space = string(v)
+		case xmlElementName:
+			start.Name.Local = string(v)
+		case xmlElementAttribute:
+			attr, err := decodeProtoXMLAttribute(v)
+			if err != nil {
+				return err
+			}
+
+			start.Attr = append(start.Attr, attr)
+		case xmlElementChild:
+			children = append(children, v)
+		}
+
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	if err := enc.EncodeToken(start); err != nil {
+		return err
+	}
+
+	for _, child := range children {
+		if err := encodeProtoXMLNode(enc, child); err != nil {
+			return err
+		}
+	}
+
+	return enc.EncodeToken(start.End())
+}
+
+func decodeProtoXMLAttribute(b []byte) (xml.Attr, error) {
+	var (
+		attr         = xml.Attr{}
+		compiledItem []byte
+	)
+
+	if err := protoFields(b, func(num protowire.Number, typ protowire.Type, v []byte, _ uint64) error {
+		if typ != protowire.BytesType {
+			return nil
+		}
+
+		switch num {
+		case xmlAttributeNamespaceURI:
+			attr.Name.Space = string(v)
+		case xmlAttributeName:
+			attr.Name.Local = string(v)
+		case xmlAttributeValue:
+			attr.Value = string(v)
+		case xmlAttributeCompiledItem:
+			compiledItem = v
+		}
+
+		return nil
+	}); err != nil {
+		return attr, err
+	}
+
+	if attr.Value == "" && compiledItem != nil {
+		var err error
+		if attr.Value, err = decodeProtoItem(compiledItem); err != nil {
+			return attr, err
+		}
+	}
+
+	return attr, nil
+}
+
+// decodeProtoItem returns the textual form of a compiled aapt.pb.Item.
+// References are returned by name, e.g. @mipmap/ic_launcher.
+func decodeProtoItem(b []byte) (string, error) {
+	value := ""
+
+	return value, protoFields(b, func(num protowire.Number, typ protowire.Type, v []byte, _ uint64) error {
+		if typ != protowire.BytesType {
+			return nil
+		}
+
+		switch num {
+		case itemRef:
+			var (
+				name string
+				id   uint64
+			)
+			if err := protoFields(v, func(num protowire.Number, _ protowire.Type, v []byte, n uint64) error {
+				switch num {
+				case referenceID:
+					id = n
+				case referenceName:
+					name = string(v)
+				}
+				return nil
+			}); err != nil {
+				return err
+			}
+
+			if name != "" {
+				value = "@" + name
+			} else {
+				value = fmt.Sprintf("@0x%08X", id)
+			}
+		case itemStr, itemRawStr:
+			return protoFields(v, func(num protowire.Number, _ protowire.Type, v []byte, _ uint64) error {
+				if num == stringValue {
+					value = string(v)
+				}
+				return nil
+			})
+		case itemPrim:
+			return protoFields(v, func(num protowire.Number, typ protowire.Type, _ []byte, n uint64) error {
+				if typ != protowire.VarintType {
+					return nil
+				}
+
+				switch num {
+				case primitiveIntDecimalValue:
+					value = strconv.Itoa(int(int32(n)))
+				case primitiveIntHexadecimal:
+					value = fmt.Sprintf("0x%08x", uint32(n))
+				case primitiveBooleanValue:
+					value = strconv.FormatBool(n != 0)
+				}
+				return nil
+			})
+		}
+
+		return nil
+	})
+}
@@ -1,15 +1,22 @@
 package android
 
-import "encoding/xml"
+import (
+	"encoding/xml"
+	"slices"
+	"strconv"
+	"strings"
+)
 
 const (
 	AndroidManifestName = "AndroidManifest.xml"
+	NamespaceAndroid    = "http://schemas.android.com/apk/res/android"
 )
 
 type Manifest struct {
 	XMLName        xml.Name                 `xml:"manifest"`
 	UsesPermission []ManifestUsesPermission `xml:"uses-permission"`
 	UsesFeature    []ManifestUsesFeature    `xml:"uses-feature"`
+	UsesSDK        ManifestUsesSDK          `xml:"uses-sdk"`
 	Permission     []ManifestPermission     `xml:"permission"`
 	Application    ManifestApplication      `xml:"application"`
 	Attrs          []xml.Attr               `xml:",any,attr"`
@@ -33,6 +40,10 @@ type ManifestUsesFeature struct {
 	Attrs []xml.Attr `xml:",any,attr"`
 }
 
+type ManifestUsesSDK struct {
+	Attrs []xml.Attr `xml:",any,attr"`
+}
+
 type ManifestPermission struct {
 	Attrs []xml.Attr `xml:",any,attr"`
 }
@@ -61,3 +72,124 @@ type ManifestApplicationIntentFilter struct {
 type ManifestApplicationMetadata struct {
 	Attrs []xml.Attr `xml:",any,attr"`
 }
+
+var (
+	// DangerousPermissions are the platform permissions whose protection level is
+	// dangerous, i.e. that give access to private data or control over the device.
+	DangerousPermissions = []string{
+		"android.permission.ACCEPT_HANDOVER",
+		"android.permission.ACCESS_BACKGROUND_LOCATION",
+		"android.permission.ACCESS_COARSE_LOCATION",
+		"android.permission.ACCESS_FINE_LOCATION",
+		"android.permission.ACCESS_MEDIA_LOCATION",
+		"android.permission.ACTIVITY_RECOGNITION",
+		"android.permission.ADD_VOICEMAIL",
+		"android.permission.ANSWER_PHONE_CALLS",
+		"android.permission.BLUETOOTH_ADVERTISE",
+		"android.permission.BLUETOOTH_CONNECT",
+		"android.permission.BLUETOOTH_SCAN",
+		"android.permission.BODY_SENSORS",
+		"android.permission.BODY_SENSORS_BACKGROUND",
+		"android.permission.CALL_PHONE",
+		"android.permission.CAMERA",
+		"android.permission.GET_ACCOUNTS",
+		"android.permission.NEARBY_WIFI_DEVICES",
+		"android.permission.POST_NOTIFICATIONS",
+		"android.permission.PROCESS_OUTGOING_CALLS",
+		"android.permission.READ_CALENDAR",
+		"android.permission.READ_CALL_LOG",
+		"android.permission.READ_CONTACTS",
+		"android.permission.READ_EXTERNAL_STORAGE",
+		"android.permission.READ_MEDIA_AUDIO",
+		"android.permission.READ_MEDIA_IMAGES",
+		"android.permission.READ_MEDIA_VIDEO",
+		"android.permission.READ_MEDIA_VISUAL_USER_SELECTED",
+		"android.permission.READ_PHONE_NUMBERS",
+		"android.permission.READ_PHONE_STATE",
+		"android.permission.READ_SMS",
+		"android.permission.RECEIVE_MMS",
+		"android.permission.RECEIVE_SMS",
+		"android.permission.RECEIVE_WAP_PUSH",
+		"android.permission.RECORD_AUDIO",
+		"android.permission.SEND_SMS",
+		"android.permission.USE_SIP",
+		"android.permission.UWB_RANGING",
+		"android.permission.WRITE_CALENDAR",
+		"android.permission.WRITE_CALL_LOG",
+		"android.permission.WRITE_CONTACTS",
+		"android.permission.WRITE_EXTERNAL_STORAGE",
+	}
+)
+
+// androidAttr returns the value of the android:<local> attribute in attrs.
+func androidAttr(attrs []xml.Attr, local string) string {
+	for _, attr := range attrs {
+		if attr.Name.Space == NamespaceAndroid && attr.Name.Local == local {
+			return attr.Value
+		}
+	}
+
+	return ""
+}
+
+// Permission is a permission that an app uses.
+type Permission struct {
+	Name      string
+	Dangerous bool
+}
+
+// Permissions returns the permissions that the app uses, flagging those that are
+// dangerous, either because they are in DangerousPermissions or because the app
+// declares them itself with a dangerous protection level.
+func (m *Manifest) Permissions() []Permission {
+	dangerous := slices.Clone(DangerousPermissions)
+
+	for _, permission := range m.Permission {
+		protectionLevel := androidAttr(permission.Attrs, "protectionLevel")
+		// The binary AndroidManifest.xml has the protection level's flags as a
+		// number, the base of which is 1 for dangerous. apktool decodes it to a name.
+		if level, err := strconv.ParseInt(protectionLevel, 0, 64); (err == nil && level&0xf == 1) || strings.Contains(protectionLevel, "dangerous") {
+			dangerous = append(dangerous, androidAttr(permission.Attrs, "name"))
+		}
+	}
+
+	permissions := []Permission{}
+
+	for _, usesPermission := range m.UsesPermission {
+		if name := androidAttr(usesPermission.Attrs, "name"); name != "" {
+			permissions = append(permissions, Permission{
+				Name:      name,
+				Dangerous: slices.Contains(dangerous, name),
+			})
+		}
+	}
+
+	return permissions
+}
+
+// RequiredFeatures returns the names of the features that the
+// app cannot run without, i.e. those that are not android:required="false".
+func (m *Manifest) RequiredFeatures() []string {
+	features := []string{}
+
+	for _, usesFeature := range m.UsesFeature {
+		if name := androidAttr(usesFeature.Attrs, "name"); name != "" && androidAttr(usesFeature.Attrs, "required") != "false" {
+			features = append(features, name)
+		}
+	}
+
+	return features
+}
+
+// Debuggable reports whether the app is android:debuggable.
+func (m *Manifest) Debuggable() bool {
+	debuggable, _ := strconv.ParseBool(androidAttr(m.Application.Attrs, "debuggable"))
+	return debuggable
+}
+
+// TestOnly reports whether the app is android:testOnly,
+// which means that it can only be installed with adb.
+func (m *Manifest) TestOnly() bool {
+	testOnly, _ := strconv.ParseBool(androidAttr(m.Application.Attrs, "testOnly"))
+	return testOnly
+}
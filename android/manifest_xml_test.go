@@ -23,3 +23,23 @@ func TestUnmarshalAndroindManifest(t *testing.T) {
 		t.FailNow()
 	}
 }
+
+func TestManifestPermissions(t *testing.T) {
+	manifest := &android.Manifest{}
+	if err := xml.NewDecoder(bytes.NewReader(data)).Decode(manifest); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, permission := range manifest.Permissions() {
+		switch permission.Name {
+		case "android.permission.GET_ACCOUNTS":
+			if !permission.Dangerous {
+				t.Errorf("expected %s to be dangerous", permission.Name)
+			}
+		case "android.permission.INTERNET":
+			if permission.Dangerous {
+				t.Errorf("expected %s to not be dangerous", permission.Name)
+			}
+		}
+	}
+}
@@ -0,0 +1,227 @@
+package android
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/sha256"
+	"encoding/asn1"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+
+	xslice "github.com/frantjc/x/slice"
+)
+
+const (
+	apkSigningBlockMagic = "APK Sig Block 42"
+	apkSignatureSchemeV2 = 0x7109871a
+	apkSignatureSchemeV3 = 0xf05368c0
+	eocdSignature        = 0x06054b50
+	// eocdMaxSize is the size of the end of central directory
+	// record plus the maximum size of its trailing comment.
+	eocdMaxSize = 22 + 0xffff
+)
+
+var (
+	errNoSignature = errors.New("no signature found in .apk")
+)
+
+// signingCertificate returns the DER-encoded certificate of the first signer of
+// the .apk from its APK Signature Scheme v3 or v2 block or else its v1 JAR signature.
+func signingCertificate(r io.ReaderAt, size int64, zr *zip.Reader) ([]byte, error) {
+	cert, err := signingBlockCertificate(r, size)
+	if errors.Is(err, errNoSignature) {
+		return jarSignatureCertificate(zr)
+	}
+
+	return cert, err
+}
+
+// fingerprint formats the SHA-256 fingerprint of cert the same way that keytool does.
+func fingerprint(cert []byte) string {
+	sum := sha256.Sum256(cert)
+
+	return strings.Join(
+		xslice.Map(sum[:], func(b byte, _ int) string {
+			return fmt.Sprintf("%02X", b)
+		}),
+		":",
+	)
+}
+
+// signingBlockCertificate reads the APK Signing Block, which sits right before
+// the zip central directory, per https://source.android.com/docs/security/features/apksigning/v2.
+func signingBlockCertificate(r io.ReaderAt, size int64) ([]byte, error) {
+	tail := make([]byte, min(size, eocdMaxSize))
+	if _, err := r.ReadAt(tail, size-int64(len(tail))); err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+
+	eocd := -1
+	for i := len(tail) - 22; i >= 0; i-- {
+		if binary.LittleEndian.Uint32(tail[i:]) == eocdSignature {
+			eocd = i
+			break
+		}
+	}
+
+	if eocd < 0 {
+		return nil, fmt.Errorf("end of central directory not found")
+	}
+
+	cdOffset := int64(binary.LittleEndian.Uint32(tail[eocd+16:]))
+	if cdOffset < 32 {
+		return nil, errNoSignature
+	}
+
+	footer := make([]byte, 24)
+	if _, err := r.ReadAt(footer, cdOffset-24); err != nil {
+		return nil, err
+	}
+
+	if string(footer[8:]) != apkSigningBlockMagic {
+		return nil, errNoSignature
+	}
+
+	blockSize := int64(binary.LittleEndian.Uint64(footer))
+	if blockSize < 24 || blockSize > cdOffset-8 {
+		return nil, fmt.Errorf("invalid APK Signing Block size %d", blockSize)
+	}
+
+	// The block is preceded by another copy of its size, which it does not include.
+	pairs := make([]byte, blockSize-24)
+	if _, err := r.ReadAt(pairs, cdOffset-blockSize); err != nil {
+		return nil, err
+	}
+
+	values := map[uint32][]byte{}
+	for len(pairs) >= 12 {
+		n := binary.LittleEndian.Uint64(pairs)
+		if n < 4 || n > uint64(len(pairs)-8) {
+			return nil, fmt.Errorf("invalid APK Signing Block pair length %d", n)
+		}
+
+		values[binary.LittleEndian.Uint32(pairs[8:])] = pairs[12 : 8+n]
+		pairs = pairs[8+n:]
+	}
+
+	for _, id := range []uint32{apkSignatureSchemeV3, apkSignatureSchemeV2} {
+		if value, ok := values[id]; ok {
+			return schemeCertificate(value)
+		}
+	}
+
+	return nil, errNoSignature
+}
+
+// lengthPrefixed splits the uint32-length-prefixed slice off of the front of b.
+func lengthPrefixed(b []byte) ([]byte, []byte, error) {
+	if len(b) < 4 {
+		return nil, nil, io.ErrUnexpectedEOF
+	}
+
+	n := binary.LittleEndian.Uint32(b)
+	if uint64(n) > uint64(len(b)-4) {
+		return nil, nil, io.ErrUnexpectedEOF
+	}
+
+	return b[4 : 4+n], b[4+n:], nil
+}
+
+// schemeCertificate returns the first certificate of the first signer in
+// an APK Signature Scheme v2 or v3 block, which are the same up to that.
+func schemeCertificate(value []byte) ([]byte, error) {
+	signers, _, err := lengthPrefixed(value)
+	if err != nil {
+		return nil, err
+	}
+
+	signer, _, err := lengthPrefixed(signers)
+	if err != nil {
+		return nil, err
+	}
+
+	signedData, _, err := lengthPrefixed(signer)
+	if err != nil {
+		return nil, err
+	}
+
+	// Skip the digests.
+	_, rest, err := lengthPrefixed(signedData)
+	if err != nil {
+		return nil, err
+	}
+
+	certs, _, err := lengthPrefixed(rest)
+	if err != nil {
+		return nil, err
+	}
+
+	cert, _, err := lengthPrefixed(certs)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(cert) == 0 {
+		return nil, errNoSignature
+	}
+
+	return cert, nil
+}
+
+type pkcs7ContentInfo struct {
+	ContentType asn1.ObjectIdentifier
+	Content     asn1.RawValue `asn1:"explicit,tag:0"`
+}
+
+type pkcs7SignedData struct {
+	Version          int
+	DigestAlgorithms asn1.RawValue
+	ContentInfo      asn1.RawValue
+	Certificates     asn1.RawValue `asn1:"optional,tag:0"`
+}
+
+// jarSignatureCertificate returns the first certificate from
+// the PKCS #7 signature block file in the .apk's META-INF/.
+func jarSignatureCertificate(zr *zip.Reader) ([]byte, error) {
+	f := xslice.Find(zr.File, func(f *zip.File, _ int) bool {
+		return path.Dir(f.Name) == "META-INF" &&
+			xslice.Includes([]string{".rsa", ".dsa", ".ec"}, strings.ToLower(path.Ext(f.Name)))
+	})
+	if f == nil {
+		return nil, errNoSignature
+	}
+
+	rc, err := f.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = rc.Close()
+	}()
+
+	b, err := io.ReadAll(rc)
+	if err != nil {
+		return nil, err
+	}
+
+	contentInfo := &pkcs7ContentInfo{}
+	if _, err := asn1.Unmarshal(b, contentInfo); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", f.Name, err)
+	}
+
+	signedData := &pkcs7SignedData{}
+	if _, err := asn1.Unmarshal(contentInfo.Content.Bytes, signedData); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", f.Name, err)
+	}
+
+	cert := &asn1.RawValue{}
+	if _, err := asn1.Unmarshal(signedData.Certificates.Bytes, cert); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", f.Name, err)
+	}
+
+	return bytes.Clone(cert.FullBytes), nil
+}
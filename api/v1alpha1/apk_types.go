@@ -1,16 +1,35 @@
 package v1alpha1
 
 import (
-	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
 // APKSpec defines the desired state of APK.
 type APKSpec struct {
 	// +kubebuilder:validation:Required
-	Bucket corev1.LocalObjectReference `json:"bucket"`
+	Bucket BucketReference `json:"bucket"`
 	// +kubebuilder:validation:Required
 	Key string `json:"key"`
+	// +kubebuilder:validation:Optional
+	Release Release `json:"release,omitempty"`
+}
+
+// Release is what went into a build of an APK or IPA,
+// as told by whatever uploaded it, e.g. a CI pipeline.
+type Release struct {
+	// Notes are the release notes of the build in Markdown.
+	// +kubebuilder:validation:Optional
+	Notes string `json:"notes,omitempty"`
+	// Commit is the SHA of the commit that the build was built from.
+	// +kubebuilder:validation:Optional
+	Commit string `json:"commit,omitempty"`
+	// +kubebuilder:validation:Optional
+	Branch string `json:"branch,omitempty"`
+	// BuildURL is the URL of the CI build that built it.
+	// +kubebuilder:validation:Optional
+	BuildURL string `json:"buildURL,omitempty"`
+	// +kubebuilder:validation:Optional
+	Author string `json:"author,omitempty"`
 }
 
 type AppStatusIcon struct {
@@ -24,6 +43,21 @@ type AppStatusIcon struct {
 	FullSize bool `json:"fullSize,omitempty"`
 }
 
+// ObjectAttributes are the attributes of the object in a Bucket that an APK or IPA
+// was last verified against. While they are unchanged, the object is assumed to
+// still match the digest that it was unpacked with.
+type ObjectAttributes struct {
+	// +kubebuilder:validation:Optional
+	ETag string `json:"etag,omitempty"`
+	// MD5 is the hex-encoded MD5 hash of the object, if the Bucket reports one.
+	// +kubebuilder:validation:Optional
+	MD5 string `json:"md5,omitempty"`
+	// +kubebuilder:validation:Optional
+	Size int64 `json:"size,omitempty"`
+	// +kubebuilder:validation:Optional
+	ModTime *metav1.Time `json:"modTime,omitempty"`
+}
+
 // APKStatus defines the observed state of APK.
 type APKStatus struct {
 	// +kubebuilder:default=Pending
@@ -33,14 +67,83 @@ type APKStatus struct {
 	Conditions []metav1.Condition `json:"conditions,omitempty"`
 	// +kubebuilder:validation:Optional
 	Digest string `json:"digest,omitempty"`
+	// DecoderVersion is the version of the decoder that the APK was last unpacked
+	// with. While it is older than the controller's, the APK is unpacked again.
+	// +kubebuilder:validation:Optional
+	DecoderVersion int `json:"decoderVersion,omitempty"`
+	// Version is the semantic version parsed from VersionName.
 	// +kubebuilder:validation:Optional
 	Version string `json:"version,omitempty"`
+	// VersionName is the APK's versionName as is.
+	// +kubebuilder:validation:Optional
+	VersionName string `json:"versionName,omitempty"`
+	// BuildNumber is the APK's versionCode.
+	// +kubebuilder:validation:Optional
+	BuildNumber string `json:"buildNumber,omitempty"`
 	// +kubebuilder:validation:Optional
 	Package string `json:"package,omitempty"`
 	// +kubebuilder:validation:Optional
 	SHA256CertFingerprints string `json:"sha256CertFingerprints,omitempty"`
 	// +kubebuilder:validation:Optional
+	Label string `json:"label,omitempty"`
+	// +kubebuilder:validation:Optional
+	MinSDKVersion int `json:"minSdkVersion,omitempty"`
+	// +kubebuilder:validation:Optional
+	TargetSDKVersion int `json:"targetSdkVersion,omitempty"`
+	// +kubebuilder:validation:Optional
+	Permissions []APKPermission `json:"permissions,omitempty"`
+	// +kubebuilder:validation:Optional
+	RequiredFeatures []string `json:"requiredFeatures,omitempty"`
+	// +kubebuilder:validation:Optional
+	Debuggable bool `json:"debuggable,omitempty"`
+	// +kubebuilder:validation:Optional
+	TestOnly bool `json:"testOnly,omitempty"`
+	// SupportedABIs are the ABIs that the APK has native libraries for.
+	// If it has none, it runs on any ABI.
+	// +kubebuilder:validation:Optional
+	SupportedABIs []string `json:"supportedABIs,omitempty"`
+	// Densities are the screen densities that the APK has resources for.
+	// +kubebuilder:validation:Optional
+	Densities []string `json:"densities,omitempty"`
+	// UniversalAPKKey is the key of the universal APK that is built
+	// when Key is an Android App Bundle. It is served in place of it.
+	// +kubebuilder:validation:Optional
+	UniversalAPKKey string `json:"universalAPKKey,omitempty"`
+	// Splits are the APKs in Key when it is a split APK set, e.g. an .apks or .xapk.
+	// +kubebuilder:validation:Optional
+	Splits []APKSplit `json:"splits,omitempty"`
+	// +kubebuilder:validation:Optional
 	Icons []AppStatusIcon `json:"icons,omitempty"`
+	// +kubebuilder:validation:Optional
+	ObjectAttributes *ObjectAttributes `json:"objectAttributes,omitempty"`
+	// Findings are what scanning the object found, if anything.
+	// +kubebuilder:validation:Optional
+	Findings []string `json:"findings,omitempty"`
+}
+
+// APKPermission is a permission that an APK uses.
+type APKPermission struct {
+	// +kubebuilder:validation:Required
+	Name string `json:"name"`
+	// Dangerous is whether the permission gives access to private data or control over the device.
+	// +kubebuilder:validation:Optional
+	Dangerous bool `json:"dangerous,omitempty"`
+}
+
+// APKSplit is an APK in a split APK set. A split with no ABI, Density
+// or Language is the base split of its module, the rest are config splits.
+type APKSplit struct {
+	// Name is the path to the split in the set.
+	// +kubebuilder:validation:Required
+	Name string `json:"name"`
+	// +kubebuilder:validation:Required
+	Module string `json:"module"`
+	// +kubebuilder:validation:Optional
+	ABI string `json:"abi,omitempty"`
+	// +kubebuilder:validation:Optional
+	Density string `json:"density,omitempty"`
+	// +kubebuilder:validation:Optional
+	Language string `json:"language,omitempty"`
 }
 
 // +kubebuilder:object:root=true
@@ -64,11 +167,27 @@ func (a APK) GetKey() string {
 	return a.Spec.Key
 }
 
+func (a APK) GetBucket() BucketReference {
+	return a.Spec.Bucket
+}
+
 func (a APK) GetIcons() []AppStatusIcon {
 	return a.Status.Icons
 }
 
-func (a APK) SetPhase(phase string) {
+func (a *APK) GetObjectAttributes() *ObjectAttributes {
+	return a.Status.ObjectAttributes
+}
+
+func (a *APK) SetObjectAttributes(attributes *ObjectAttributes) {
+	a.Status.ObjectAttributes = attributes
+}
+
+func (a *APK) SetFindings(findings []string) {
+	a.Status.Findings = findings
+}
+
+func (a *APK) SetPhase(phase string) {
 	a.Status.Phase = phase
 }
 
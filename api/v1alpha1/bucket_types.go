@@ -1,16 +1,94 @@
 package v1alpha1
 
 import (
+	"strings"
+
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+const (
+	KindBucket        = "Bucket"
+	KindClusterBucket = "ClusterBucket"
 )
 
+// BucketReference refers to a Bucket in the same
+// namespace as the referrer or to a ClusterBucket.
+type BucketReference struct {
+	// +kubebuilder:default=Bucket
+	// +kubebuilder:validation:Enum=Bucket;ClusterBucket
+	// +kubebuilder:validation:Optional
+	Kind string `json:"kind,omitempty"`
+	// +kubebuilder:validation:Required
+	Name string `json:"name"`
+}
+
+// IsClusterBucket reports whether the reference is to a ClusterBucket.
+func (r BucketReference) IsClusterBucket() bool {
+	return r.Kind == KindClusterBucket
+}
+
+// Refers reports whether the reference, made from the given namespace,
+// is to the given Bucket or ClusterBucket.
+func (r BucketReference) Refers(namespace string, bucket BucketObject) bool {
+	switch bucket.(type) {
+	case *ClusterBucket:
+		return r.IsClusterBucket() && r.Name == bucket.GetName()
+	default:
+		return !r.IsClusterBucket() && r.Name == bucket.GetName() && namespace == bucket.GetNamespace()
+	}
+}
+
+func (r BucketReference) String() string {
+	if r.IsClusterBucket() {
+		return KindClusterBucket + "/" + r.Name
+	}
+
+	return r.Name
+}
+
+// ParseBucketReference parses the string form of a BucketReference,
+// which is either the name of a Bucket or ClusterBucket/<name>.
+func ParseBucketReference(s string) BucketReference {
+	if name, ok := strings.CutPrefix(s, KindClusterBucket+"/"); ok {
+		return BucketReference{Kind: KindClusterBucket, Name: name}
+	}
+
+	return BucketReference{Kind: KindBucket, Name: strings.TrimPrefix(s, KindBucket+"/")}
+}
+
+// BucketObject is implemented by Bucket and ClusterBucket.
+// +kubebuilder:object:generate=false
+type BucketObject interface {
+	metav1.Object
+	runtime.Object
+	GetBucketSpec() *BucketSpec
+	GetBucketStatus() *BucketStatus
+	GetConditions() []metav1.Condition
+	SetConditions([]metav1.Condition)
+}
+
 // BucketSpec defines the desired state of Bucket.
 type BucketSpec struct {
 	// +kubebuilder:validation:Optional
 	URL string `json:"url,omitempty"`
 	// +kubebuilder:validation:Optional
 	URLFrom *corev1.EnvVarSource `json:"urlFrom,omitempty"`
+	// Env is passed to the Bucket's driver while opening it, e.g. to pass
+	// credentials such as AWS_SECRET_ACCESS_KEY, GOOGLE_CREDENTIALS or
+	// AZURE_STORAGE_KEY from a Secret, rather than set in momo's environment.
+	// +kubebuilder:validation:Optional
+	Env []corev1.EnvVar `json:"env,omitempty"`
+	// +kubebuilder:validation:Optional
+	Probe BucketSpecProbe `json:"probe,omitempty"`
+}
+
+type BucketSpecProbe struct {
+	// ReadOnly makes the probe only check that the Bucket can be read from,
+	// for credentials that are not allowed to write or delete objects.
+	// +kubebuilder:validation:Optional
+	ReadOnly bool `json:"readOnly,omitempty"`
 }
 
 // BucketStatus defines the observed state of Bucket.
@@ -20,10 +98,25 @@ type BucketStatus struct {
 	Phase string `json:"phase"`
 	// +kubebuilder:validation:Optional
 	Conditions []metav1.Condition `json:"conditions,omitempty"`
+	// +kubebuilder:validation:Optional
+	Usage *BucketStatusUsage `json:"usage,omitempty"`
+}
+
+// BucketStatusUsage is the storage used by the objects
+// in a Bucket that are referenced by APKs and IPAs.
+type BucketStatusUsage struct {
+	// +kubebuilder:validation:Optional
+	Objects int64 `json:"objects"`
+	// +kubebuilder:validation:Optional
+	Bytes int64 `json:"bytes"`
+	// +kubebuilder:validation:Optional
+	LastUpdateTime metav1.Time `json:"lastUpdateTime,omitempty"`
 }
 
 // +kubebuilder:object:root=true
 // +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="Objects",type=integer,JSONPath=`.status.usage.objects`
+// +kubebuilder:printcolumn:name="Bytes",type=integer,JSONPath=`.status.usage.bytes`
 // +kubebuilder:printcolumn:name="Phase",type=string,JSONPath=`.status.phase`
 
 // Bucket is the Schema for the buckets API.
@@ -44,6 +137,14 @@ type BucketList struct {
 	Items           []Bucket `json:"items"`
 }
 
+func (b *Bucket) GetBucketSpec() *BucketSpec {
+	return &b.Spec
+}
+
+func (b *Bucket) GetBucketStatus() *BucketStatus {
+	return &b.Status
+}
+
 func init() {
 	SchemeBuilder.Register(&Bucket{}, &BucketList{})
 }
@@ -0,0 +1,63 @@
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ClusterBucketSpec defines the desired state of ClusterBucket.
+type ClusterBucketSpec struct {
+	BucketSpec `json:",inline"`
+	// ResourceNamespace is the namespace that the ConfigMaps and Secrets
+	// referenced by .spec.urlFrom and .spec.env are read from.
+	// +kubebuilder:validation:Optional
+	ResourceNamespace string `json:"resourceNamespace,omitempty"`
+	// Namespaces are the namespaces whose APKs and IPAs may reference the ClusterBucket.
+	// +kubebuilder:validation:Optional
+	Namespaces []string `json:"namespaces,omitempty"`
+	// NamespaceSelector selects additional namespaces whose APKs and IPAs
+	// may reference the ClusterBucket. An empty selector selects every namespace.
+	// +kubebuilder:validation:Optional
+	NamespaceSelector *metav1.LabelSelector `json:"namespaceSelector,omitempty"`
+	// KeyPrefix is prepended to the namespace of each APK and IPA that references
+	// the ClusterBucket, which is in turn prepended to each of their keys so that
+	// namespaces cannot read each other's objects.
+	// +kubebuilder:validation:Optional
+	KeyPrefix string `json:"keyPrefix,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:resource:scope=Cluster
+// +kubebuilder:printcolumn:name="Objects",type=integer,JSONPath=`.status.usage.objects`
+// +kubebuilder:printcolumn:name="Bytes",type=integer,JSONPath=`.status.usage.bytes`
+// +kubebuilder:printcolumn:name="Phase",type=string,JSONPath=`.status.phase`
+
+// ClusterBucket is the Schema for the clusterbuckets API.
+type ClusterBucket struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   ClusterBucketSpec `json:"spec,omitempty"`
+	Status BucketStatus      `json:"status,omitempty"`
+}
+
+func (c *ClusterBucket) GetBucketSpec() *BucketSpec {
+	return &c.Spec.BucketSpec
+}
+
+func (c *ClusterBucket) GetBucketStatus() *BucketStatus {
+	return &c.Status
+}
+
+// +kubebuilder:object:root=true
+
+// ClusterBucketList contains a list of ClusterBucket.
+type ClusterBucketList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []ClusterBucket `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&ClusterBucket{}, &ClusterBucketList{})
+}
@@ -20,6 +20,14 @@ func (b *Bucket) SetConditions(conditions []metav1.Condition) {
 	b.Status.Conditions = conditions
 }
 
+func (c *ClusterBucket) GetConditions() []metav1.Condition {
+	return c.Status.Conditions
+}
+
+func (c *ClusterBucket) SetConditions(conditions []metav1.Condition) {
+	c.Status.Conditions = conditions
+}
+
 func (i *IPA) GetConditions() []metav1.Condition {
 	return i.Status.Conditions
 }
@@ -1,16 +1,21 @@
 package v1alpha1
 
 import (
-	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
 // IPASpec defines the desired state of IPA.
 type IPASpec struct {
 	// +kubebuilder:validation:Required
-	Bucket corev1.LocalObjectReference `json:"bucket"`
+	Bucket BucketReference `json:"bucket"`
 	// +kubebuilder:validation:Required
 	Key string `json:"key"`
+	// DSYMsKey is the key of a zip of the IPA's debug symbols, e.g.
+	// from the .xcarchive that it was built from, if it has them.
+	// +kubebuilder:validation:Optional
+	DSYMsKey string `json:"dsymsKey,omitempty"`
+	// +kubebuilder:validation:Optional
+	Release Release `json:"release,omitempty"`
 }
 
 // IPAStatus defines the observed state of IPA.
@@ -22,14 +27,41 @@ type IPAStatus struct {
 	Conditions []metav1.Condition `json:"conditions,omitempty"`
 	// +kubebuilder:validation:Optional
 	Digest string `json:"digest,omitempty"`
+	// DecoderVersion is the version of the decoder that the IPA was last unpacked
+	// with. While it is older than the controller's, the IPA is unpacked again.
+	// +kubebuilder:validation:Optional
+	DecoderVersion int `json:"decoderVersion,omitempty"`
+	// Version is the semantic version parsed from VersionName.
 	// +kubebuilder:validation:Optional
 	Version string `json:"version,omitempty"`
+	// VersionName is the IPA's CFBundleShortVersionString as is.
+	// +kubebuilder:validation:Optional
+	VersionName string `json:"versionName,omitempty"`
+	// BuildNumber is the IPA's CFBundleVersion.
+	// +kubebuilder:validation:Optional
+	BuildNumber string `json:"buildNumber,omitempty"`
 	// +kubebuilder:validation:Optional
 	BundleName string `json:"bundleName,omitempty"`
 	// +kubebuilder:validation:Optional
 	BundleIdentifier string `json:"bundleIdentifier,omitempty"`
 	// +kubebuilder:validation:Optional
+	MinimumOSVersion string `json:"minimumOSVersion,omitempty"`
+	// DeviceFamilies are the names of the UIDeviceFamily values, e.g. iphone and ipad.
+	// +kubebuilder:validation:Optional
+	DeviceFamilies []string `json:"deviceFamilies,omitempty"`
+	// +kubebuilder:validation:Optional
+	RequiredDeviceCapabilities []string `json:"requiredDeviceCapabilities,omitempty"`
+	// +kubebuilder:validation:Optional
+	SupportedPlatforms []string `json:"supportedPlatforms,omitempty"`
+	// +kubebuilder:validation:Optional
+	PlatformName string `json:"platformName,omitempty"`
+	// +kubebuilder:validation:Optional
 	Icons []AppStatusIcon `json:"icons,omitempty"`
+	// +kubebuilder:validation:Optional
+	ObjectAttributes *ObjectAttributes `json:"objectAttributes,omitempty"`
+	// Findings are what scanning the object found, if anything.
+	// +kubebuilder:validation:Optional
+	Findings []string `json:"findings,omitempty"`
 }
 
 // +kubebuilder:object:root=true
@@ -53,11 +85,27 @@ func (i IPA) GetKey() string {
 	return i.Spec.Key
 }
 
+func (i IPA) GetBucket() BucketReference {
+	return i.Spec.Bucket
+}
+
 func (i IPA) GetIcons() []AppStatusIcon {
 	return i.Status.Icons
 }
 
-func (i IPA) SetPhase(phase string) {
+func (i *IPA) GetObjectAttributes() *ObjectAttributes {
+	return i.Status.ObjectAttributes
+}
+
+func (i *IPA) SetObjectAttributes(attributes *ObjectAttributes) {
+	i.Status.ObjectAttributes = attributes
+}
+
+func (i *IPA) SetFindings(findings []string) {
+	i.Status.Findings = findings
+}
+
+func (i *IPA) SetPhase(phase string) {
 	i.Status.Phase = phase
 }
 
@@ -12,12 +12,113 @@ const (
 	PhaseFailed  = "Failed"
 )
 
+const (
+	// OrderingSemVer orders versions by their semantic version,
+	// then by their build number, then by their upload time.
+	OrderingSemVer = "SemVer"
+	// OrderingBuildNumber orders versions by their build number,
+	// then by their semantic version, then by their upload time.
+	OrderingBuildNumber = "BuildNumber"
+	// OrderingUploadTime orders versions by their upload time.
+	OrderingUploadTime = "UploadTime"
+)
+
 // MobileAppSpec defines the desired state of MobileApp.
 type MobileAppSpec struct {
 	// +kubebuilder:validation:Required
 	Selector labels.Set `json:"selector"`
 	// +kubebuilder:validation:Optional
 	UniversalLinks MobileAppSpecUniversalLinks `json:"universalLinks,omitempty"`
+	// Retention, if set, is which of the APKs and IPAs that
+	// the MobileApp selects to keep. The rest are deleted.
+	// +kubebuilder:validation:Optional
+	Retention *MobileAppSpecRetention `json:"retention,omitempty"`
+	// Channels are named versions of the MobileApp, e.g. alpha, beta and prod,
+	// in the order that versions are promoted through them.
+	// +kubebuilder:validation:Optional
+	// +listType=map
+	// +listMapKey=name
+	Channels []MobileAppSpecChannel `json:"channels,omitempty"`
+	// Rollout, if set, gradually exposes a version as the latest.
+	// +kubebuilder:validation:Optional
+	Rollout *MobileAppSpecRollout `json:"rollout,omitempty"`
+	// Pinned are the versions to serve as the latest in place of the newest ones.
+	// +kubebuilder:validation:Optional
+	Pinned MobileAppSpecPinned `json:"pinned,omitempty"`
+	// Ordering is how to tell which of two versions is newer.
+	// +kubebuilder:default=SemVer
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Enum=SemVer;BuildNumber;UploadTime
+	Ordering string `json:"ordering,omitempty"`
+}
+
+// MobileAppSpecPinned are the versions to serve as the latest per platform.
+type MobileAppSpecPinned struct {
+	// +kubebuilder:validation:Optional
+	Android string `json:"android,omitempty"`
+	// +kubebuilder:validation:Optional
+	IOS string `json:"ios,omitempty"`
+}
+
+// MobileAppSpecRollout serves Version as the latest to a percentage of users and
+// the version that was the latest before it to the rest. Users are bucketed by their
+// device ID or a cookie, so each user keeps getting the same version at a percentage.
+type MobileAppSpecRollout struct {
+	// +kubebuilder:validation:Required
+	Version string `json:"version"`
+	// Percentage is the percentage of users to serve Version to if there are no Steps.
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Minimum=0
+	// +kubebuilder:validation:Maximum=100
+	Percentage int `json:"percentage,omitempty"`
+	// Steps are the percentages that the rollout advances through on schedule.
+	// +kubebuilder:validation:Optional
+	Steps []MobileAppSpecRolloutStep `json:"steps,omitempty"`
+	// Halted stops the rollout from advancing to its next step.
+	// +kubebuilder:validation:Optional
+	Halted bool `json:"halted,omitempty"`
+	// RolledBack serves the previous version to every user.
+	// +kubebuilder:validation:Optional
+	RolledBack bool `json:"rolledBack,omitempty"`
+}
+
+type MobileAppSpecRolloutStep struct {
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:Minimum=0
+	// +kubebuilder:validation:Maximum=100
+	Percentage int `json:"percentage"`
+	// Duration is how long to stay at this step before advancing to the next one.
+	// +kubebuilder:validation:Optional
+	Duration metav1.Duration `json:"duration,omitempty"`
+}
+
+// MobileAppSpecChannel is a named version of a MobileApp that can
+// be requested in place of a version, e.g. /install/{app}/beta.
+type MobileAppSpecChannel struct {
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:Pattern=`^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`
+	Name string `json:"name"`
+	// Version is the version that the channel serves. If
+	// empty, the channel serves the latest version.
+	// +kubebuilder:validation:Optional
+	Version string `json:"version,omitempty"`
+}
+
+// MobileAppSpecRetention keeps an APK or IPA if it meets any of its criteria.
+// The latest version of each platform, the versions that Channels and the
+// Rollout serve and APKs and IPAs that are annotated with
+// momo.frantj.cc/pinned=true are always kept. APKs and IPAs that are not
+// Ready, e.g. because they failed to unpack, are kept only while fewer than
+// KeepLast versions were uploaded after them or while they are newer than
+// KeepNewerThan.
+type MobileAppSpecRetention struct {
+	// KeepLast is the number of the newest versions to keep per platform.
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Minimum=0
+	KeepLast int `json:"keepLast,omitempty"`
+	// KeepNewerThan keeps APKs and IPAs that were created within it.
+	// +kubebuilder:validation:Optional
+	KeepNewerThan *metav1.Duration `json:"keepNewerThan,omitempty"`
 }
 
 type MobileAppSpecUniversalLinks struct {
@@ -43,19 +144,101 @@ type MobileAppStatus struct {
 	APKs []MobileAppStatusApp `json:"apks,omitempty"`
 	// +kubebuilder:validation:Optional
 	IPAs []MobileAppStatusApp `json:"ipas,omitempty"`
+	// Promotions are the most recent promotions of versions between Channels.
+	// +kubebuilder:validation:Optional
+	Promotions []MobileAppStatusPromotion `json:"promotions,omitempty"`
+	// +kubebuilder:validation:Optional
+	Rollout *MobileAppStatusRollout `json:"rollout,omitempty"`
+	// Stats are how many times each version of the MobileApp was installed and downloaded.
+	// +kubebuilder:validation:Optional
+	Stats []MobileAppStatusStats `json:"stats,omitempty"`
+}
+
+// MobileAppStatusStats are how many times a version of a MobileApp
+// for a platform was installed and downloaded.
+type MobileAppStatusStats struct {
+	// +kubebuilder:validation:Required
+	Version string `json:"version"`
+	// +kubebuilder:validation:Required
+	Platform string `json:"platform"`
+	// Installs are the number of times that installing the version was asked for.
+	// +kubebuilder:validation:Optional
+	Installs int64 `json:"installs,omitempty"`
+	// ManifestFetches are the number of times that the manifest.plist of the version was fetched.
+	// +kubebuilder:validation:Optional
+	ManifestFetches int64 `json:"manifestFetches,omitempty"`
+	// Downloads are the number of times that the APK or IPA of the version was downloaded.
+	// +kubebuilder:validation:Optional
+	Downloads int64 `json:"downloads,omitempty"`
+	// +kubebuilder:validation:Optional
+	LastTime *metav1.Time `json:"lastTime,omitempty"`
+}
+
+// MobileAppStatusRollout is the progress of a MobileApp's Rollout.
+type MobileAppStatusRollout struct {
+	// +kubebuilder:validation:Required
+	Version string `json:"version"`
+	// PreviousVersions are the versions per platform that are served to users that Version is not.
+	// +kubebuilder:validation:Optional
+	PreviousVersions MobileAppStatusRolloutPreviousVersions `json:"previousVersions,omitempty"`
+	// +kubebuilder:validation:Optional
+	Step int `json:"step,omitempty"`
+	// Percentage is the percentage of users that Version is currently served to.
+	// +kubebuilder:validation:Optional
+	Percentage int `json:"percentage,omitempty"`
+	// +kubebuilder:validation:Optional
+	StepStartTime metav1.Time `json:"stepStartTime,omitempty"`
+}
+
+// MobileAppStatusRolloutPreviousVersions are the newest versions per platform before
+// a Rollout's Version, so that a device is never served a version of another platform.
+type MobileAppStatusRolloutPreviousVersions struct {
+	// +kubebuilder:validation:Optional
+	Android string `json:"android,omitempty"`
+	// +kubebuilder:validation:Optional
+	IOS string `json:"ios,omitempty"`
+}
+
+// MobileAppStatusPromotion is the promotion of a version from one Channel to the next.
+type MobileAppStatusPromotion struct {
+	// +kubebuilder:validation:Required
+	Version string `json:"version"`
+	// +kubebuilder:validation:Required
+	From string `json:"from"`
+	// +kubebuilder:validation:Required
+	To string `json:"to"`
+	// +kubebuilder:validation:Optional
+	Time metav1.Time `json:"time,omitempty"`
 }
 
 type MobileAppStatusApp struct {
 	// +kubebuilder:validation:Required
 	Name string `json:"name"`
 	// +kubebuilder:validation:Required
-	Bucket corev1.LocalObjectReference `json:"bucket"`
+	Bucket BucketReference `json:"bucket"`
 	// +kubebuilder:validation:Required
 	Key string `json:"key"`
 	// +kubebuilder:validation:Optional
 	Version string `json:"version,omitempty"`
 	// +kubebuilder:validation:Optional
+	VersionName string `json:"versionName,omitempty"`
+	// +kubebuilder:validation:Optional
+	BuildNumber string `json:"buildNumber,omitempty"`
+	// UploadTime is when the APK or IPA was created.
+	// +kubebuilder:validation:Optional
+	UploadTime *metav1.Time `json:"uploadTime,omitempty"`
+	// Latest is whether this is the version that is served as the latest,
+	// which is the newest one unless another one is pinned.
+	// +kubebuilder:validation:Optional
 	Latest bool `json:"latest,omitempty"`
+	// +kubebuilder:validation:Optional
+	Newest bool `json:"newest,omitempty"`
+	// +kubebuilder:validation:Optional
+	Pinned bool `json:"pinned,omitempty"`
+	// SupportedABIs are the ABIs of an APK, used to choose
+	// between APKs of the same version for different ABIs.
+	// +kubebuilder:validation:Optional
+	SupportedABIs []string `json:"supportedABIs,omitempty"`
 }
 
 // +kubebuilder:object:root=true
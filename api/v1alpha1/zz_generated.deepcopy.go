@@ -8,7 +8,7 @@ import (
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/labels"
-	runtime "k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime"
 )
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
@@ -70,10 +70,26 @@ func (in *APKList) DeepCopyObject() runtime.Object {
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *APKPermission) DeepCopyInto(out *APKPermission) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new APKPermission.
+func (in *APKPermission) DeepCopy() *APKPermission {
+	if in == nil {
+		return nil
+	}
+	out := new(APKPermission)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *APKSpec) DeepCopyInto(out *APKSpec) {
 	*out = *in
 	out.Bucket = in.Bucket
+	out.Release = in.Release
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new APKSpec.
@@ -86,6 +102,21 @@ func (in *APKSpec) DeepCopy() *APKSpec {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *APKSplit) DeepCopyInto(out *APKSplit) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new APKSplit.
+func (in *APKSplit) DeepCopy() *APKSplit {
+	if in == nil {
+		return nil
+	}
+	out := new(APKSplit)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *APKStatus) DeepCopyInto(out *APKStatus) {
 	*out = *in
@@ -96,11 +127,46 @@ func (in *APKStatus) DeepCopyInto(out *APKStatus) {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
+	if in.Permissions != nil {
+		in, out := &in.Permissions, &out.Permissions
+		*out = make([]APKPermission, len(*in))
+		copy(*out, *in)
+	}
+	if in.RequiredFeatures != nil {
+		in, out := &in.RequiredFeatures, &out.RequiredFeatures
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.SupportedABIs != nil {
+		in, out := &in.SupportedABIs, &out.SupportedABIs
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Densities != nil {
+		in, out := &in.Densities, &out.Densities
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Splits != nil {
+		in, out := &in.Splits, &out.Splits
+		*out = make([]APKSplit, len(*in))
+		copy(*out, *in)
+	}
 	if in.Icons != nil {
 		in, out := &in.Icons, &out.Icons
 		*out = make([]AppStatusIcon, len(*in))
 		copy(*out, *in)
 	}
+	if in.ObjectAttributes != nil {
+		in, out := &in.ObjectAttributes, &out.ObjectAttributes
+		*out = new(ObjectAttributes)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Findings != nil {
+		in, out := &in.Findings, &out.Findings
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new APKStatus.
@@ -187,6 +253,21 @@ func (in *BucketList) DeepCopyObject() runtime.Object {
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *BucketReference) DeepCopyInto(out *BucketReference) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BucketReference.
+func (in *BucketReference) DeepCopy() *BucketReference {
+	if in == nil {
+		return nil
+	}
+	out := new(BucketReference)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *BucketSpec) DeepCopyInto(out *BucketSpec) {
 	*out = *in
@@ -195,6 +276,14 @@ func (in *BucketSpec) DeepCopyInto(out *BucketSpec) {
 		*out = new(corev1.EnvVarSource)
 		(*in).DeepCopyInto(*out)
 	}
+	if in.Env != nil {
+		in, out := &in.Env, &out.Env
+		*out = make([]corev1.EnvVar, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	out.Probe = in.Probe
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BucketSpec.
@@ -207,6 +296,21 @@ func (in *BucketSpec) DeepCopy() *BucketSpec {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *BucketSpecProbe) DeepCopyInto(out *BucketSpecProbe) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BucketSpecProbe.
+func (in *BucketSpecProbe) DeepCopy() *BucketSpecProbe {
+	if in == nil {
+		return nil
+	}
+	out := new(BucketSpecProbe)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *BucketStatus) DeepCopyInto(out *BucketStatus) {
 	*out = *in
@@ -217,6 +321,11 @@ func (in *BucketStatus) DeepCopyInto(out *BucketStatus) {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
+	if in.Usage != nil {
+		in, out := &in.Usage, &out.Usage
+		*out = new(BucketStatusUsage)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BucketStatus.
@@ -229,6 +338,107 @@ func (in *BucketStatus) DeepCopy() *BucketStatus {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *BucketStatusUsage) DeepCopyInto(out *BucketStatusUsage) {
+	*out = *in
+	in.LastUpdateTime.DeepCopyInto(&out.LastUpdateTime)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BucketStatusUsage.
+func (in *BucketStatusUsage) DeepCopy() *BucketStatusUsage {
+	if in == nil {
+		return nil
+	}
+	out := new(BucketStatusUsage)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ClusterBucket) DeepCopyInto(out *ClusterBucket) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ClusterBucket.
+func (in *ClusterBucket) DeepCopy() *ClusterBucket {
+	if in == nil {
+		return nil
+	}
+	out := new(ClusterBucket)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *ClusterBucket) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ClusterBucketList) DeepCopyInto(out *ClusterBucketList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]ClusterBucket, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ClusterBucketList.
+func (in *ClusterBucketList) DeepCopy() *ClusterBucketList {
+	if in == nil {
+		return nil
+	}
+	out := new(ClusterBucketList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *ClusterBucketList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ClusterBucketSpec) DeepCopyInto(out *ClusterBucketSpec) {
+	*out = *in
+	in.BucketSpec.DeepCopyInto(&out.BucketSpec)
+	if in.Namespaces != nil {
+		in, out := &in.Namespaces, &out.Namespaces
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.NamespaceSelector != nil {
+		in, out := &in.NamespaceSelector, &out.NamespaceSelector
+		*out = new(v1.LabelSelector)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ClusterBucketSpec.
+func (in *ClusterBucketSpec) DeepCopy() *ClusterBucketSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(ClusterBucketSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *IPA) DeepCopyInto(out *IPA) {
 	*out = *in
@@ -292,6 +502,7 @@ func (in *IPAList) DeepCopyObject() runtime.Object {
 func (in *IPASpec) DeepCopyInto(out *IPASpec) {
 	*out = *in
 	out.Bucket = in.Bucket
+	out.Release = in.Release
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new IPASpec.
@@ -314,11 +525,36 @@ func (in *IPAStatus) DeepCopyInto(out *IPAStatus) {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
+	if in.DeviceFamilies != nil {
+		in, out := &in.DeviceFamilies, &out.DeviceFamilies
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.RequiredDeviceCapabilities != nil {
+		in, out := &in.RequiredDeviceCapabilities, &out.RequiredDeviceCapabilities
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.SupportedPlatforms != nil {
+		in, out := &in.SupportedPlatforms, &out.SupportedPlatforms
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
 	if in.Icons != nil {
 		in, out := &in.Icons, &out.Icons
 		*out = make([]AppStatusIcon, len(*in))
 		copy(*out, *in)
 	}
+	if in.ObjectAttributes != nil {
+		in, out := &in.ObjectAttributes, &out.ObjectAttributes
+		*out = new(ObjectAttributes)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Findings != nil {
+		in, out := &in.Findings, &out.Findings
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new IPAStatus.
@@ -401,6 +637,22 @@ func (in *MobileAppSpec) DeepCopyInto(out *MobileAppSpec) {
 		}
 	}
 	out.UniversalLinks = in.UniversalLinks
+	if in.Retention != nil {
+		in, out := &in.Retention, &out.Retention
+		*out = new(MobileAppSpecRetention)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Channels != nil {
+		in, out := &in.Channels, &out.Channels
+		*out = make([]MobileAppSpecChannel, len(*in))
+		copy(*out, *in)
+	}
+	if in.Rollout != nil {
+		in, out := &in.Rollout, &out.Rollout
+		*out = new(MobileAppSpecRollout)
+		(*in).DeepCopyInto(*out)
+	}
+	out.Pinned = in.Pinned
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MobileAppSpec.
@@ -413,6 +665,92 @@ func (in *MobileAppSpec) DeepCopy() *MobileAppSpec {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MobileAppSpecChannel) DeepCopyInto(out *MobileAppSpecChannel) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MobileAppSpecChannel.
+func (in *MobileAppSpecChannel) DeepCopy() *MobileAppSpecChannel {
+	if in == nil {
+		return nil
+	}
+	out := new(MobileAppSpecChannel)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MobileAppSpecPinned) DeepCopyInto(out *MobileAppSpecPinned) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MobileAppSpecPinned.
+func (in *MobileAppSpecPinned) DeepCopy() *MobileAppSpecPinned {
+	if in == nil {
+		return nil
+	}
+	out := new(MobileAppSpecPinned)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MobileAppSpecRetention) DeepCopyInto(out *MobileAppSpecRetention) {
+	*out = *in
+	if in.KeepNewerThan != nil {
+		in, out := &in.KeepNewerThan, &out.KeepNewerThan
+		*out = new(v1.Duration)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MobileAppSpecRetention.
+func (in *MobileAppSpecRetention) DeepCopy() *MobileAppSpecRetention {
+	if in == nil {
+		return nil
+	}
+	out := new(MobileAppSpecRetention)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MobileAppSpecRollout) DeepCopyInto(out *MobileAppSpecRollout) {
+	*out = *in
+	if in.Steps != nil {
+		in, out := &in.Steps, &out.Steps
+		*out = make([]MobileAppSpecRolloutStep, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MobileAppSpecRollout.
+func (in *MobileAppSpecRollout) DeepCopy() *MobileAppSpecRollout {
+	if in == nil {
+		return nil
+	}
+	out := new(MobileAppSpecRollout)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MobileAppSpecRolloutStep) DeepCopyInto(out *MobileAppSpecRolloutStep) {
+	*out = *in
+	out.Duration = in.Duration
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MobileAppSpecRolloutStep.
+func (in *MobileAppSpecRolloutStep) DeepCopy() *MobileAppSpecRolloutStep {
+	if in == nil {
+		return nil
+	}
+	out := new(MobileAppSpecRolloutStep)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *MobileAppSpecUniversalLinks) DeepCopyInto(out *MobileAppSpecUniversalLinks) {
 	*out = *in
@@ -458,12 +796,35 @@ func (in *MobileAppStatus) DeepCopyInto(out *MobileAppStatus) {
 	if in.APKs != nil {
 		in, out := &in.APKs, &out.APKs
 		*out = make([]MobileAppStatusApp, len(*in))
-		copy(*out, *in)
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
 	}
 	if in.IPAs != nil {
 		in, out := &in.IPAs, &out.IPAs
 		*out = make([]MobileAppStatusApp, len(*in))
-		copy(*out, *in)
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Promotions != nil {
+		in, out := &in.Promotions, &out.Promotions
+		*out = make([]MobileAppStatusPromotion, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Rollout != nil {
+		in, out := &in.Rollout, &out.Rollout
+		*out = new(MobileAppStatusRollout)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Stats != nil {
+		in, out := &in.Stats, &out.Stats
+		*out = make([]MobileAppStatusStats, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
 	}
 }
 
@@ -481,6 +842,15 @@ func (in *MobileAppStatus) DeepCopy() *MobileAppStatus {
 func (in *MobileAppStatusApp) DeepCopyInto(out *MobileAppStatusApp) {
 	*out = *in
 	out.Bucket = in.Bucket
+	if in.UploadTime != nil {
+		in, out := &in.UploadTime, &out.UploadTime
+		*out = (*in).DeepCopy()
+	}
+	if in.SupportedABIs != nil {
+		in, out := &in.SupportedABIs, &out.SupportedABIs
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MobileAppStatusApp.
@@ -492,3 +862,104 @@ func (in *MobileAppStatusApp) DeepCopy() *MobileAppStatusApp {
 	in.DeepCopyInto(out)
 	return out
 }
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MobileAppStatusPromotion) DeepCopyInto(out *MobileAppStatusPromotion) {
+	*out = *in
+	in.Time.DeepCopyInto(&out.Time)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MobileAppStatusPromotion.
+func (in *MobileAppStatusPromotion) DeepCopy() *MobileAppStatusPromotion {
+	if in == nil {
+		return nil
+	}
+	out := new(MobileAppStatusPromotion)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MobileAppStatusRollout) DeepCopyInto(out *MobileAppStatusRollout) {
+	*out = *in
+	out.PreviousVersions = in.PreviousVersions
+	in.StepStartTime.DeepCopyInto(&out.StepStartTime)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MobileAppStatusRollout.
+func (in *MobileAppStatusRollout) DeepCopy() *MobileAppStatusRollout {
+	if in == nil {
+		return nil
+	}
+	out := new(MobileAppStatusRollout)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MobileAppStatusRolloutPreviousVersions) DeepCopyInto(out *MobileAppStatusRolloutPreviousVersions) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MobileAppStatusRolloutPreviousVersions.
+func (in *MobileAppStatusRolloutPreviousVersions) DeepCopy() *MobileAppStatusRolloutPreviousVersions {
+	if in == nil {
+		return nil
+	}
+	out := new(MobileAppStatusRolloutPreviousVersions)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MobileAppStatusStats) DeepCopyInto(out *MobileAppStatusStats) {
+	*out = *in
+	if in.LastTime != nil {
+		in, out := &in.LastTime, &out.LastTime
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MobileAppStatusStats.
+func (in *MobileAppStatusStats) DeepCopy() *MobileAppStatusStats {
+	if in == nil {
+		return nil
+	}
+	out := new(MobileAppStatusStats)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ObjectAttributes) DeepCopyInto(out *ObjectAttributes) {
+	*out = *in
+	if in.ModTime != nil {
+		in, out := &in.ModTime, &out.ModTime
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ObjectAttributes.
+func (in *ObjectAttributes) DeepCopy() *ObjectAttributes {
+	if in == nil {
+		return nil
+	}
+	out := new(ObjectAttributes)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *Release) DeepCopyInto(out *Release) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Release.
+func (in *Release) DeepCopy() *Release {
+	if in == nil {
+		return nil
+	}
+	out := new(Release)
+	in.DeepCopyInto(out)
+	return out
+}
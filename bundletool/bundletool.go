@@ -0,0 +1,161 @@
+package bundletool
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+const (
+	ModeDefault   = "default"
+	ModeUniversal = "universal"
+)
+
+// BuildAPKs finds `bundletool` on the PATH and runs BuildAPKs against it.
+// See Command.BuildAPKs.
+func BuildAPKs(ctx context.Context, bundle string, opts *BuildAPKsOpts) error {
+	return Command("bundletool").BuildAPKs(ctx, bundle, opts)
+}
+
+// Command represents the path to a `bundletool` executable.
+type Command string
+
+func (c Command) String() string {
+	return string(c)
+}
+
+// BuildAPKsOpts represent flags that can be passed to `bundletool build-apks`.
+type BuildAPKsOpts struct {
+	Output    string
+	Mode      string
+	Overwrite bool
+	// KeyStore is the path to the keystore to sign the APKs with. If it is not set,
+	// bundletool signs them with ~/.android/debug.keystore if it exists.
+	KeyStore string
+	KeyAlias string
+	// KeyStorePass and KeyPass are either file:<path> or env:<name>.
+	// See ValidatePassword.
+	KeyStorePass string
+	KeyPass      string
+}
+
+// BuildAPKs executes a command against `bundletool` found at Command.
+// It runs `bundletool build-apks` against the .aab at bundle with flags
+// derived from the given BuildAPKsOpts, producing an .apks at opts.Output.
+func (c Command) BuildAPKs(ctx context.Context, bundle string, opts *BuildAPKsOpts) error {
+	args := []string{"build-apks", "--bundle", bundle}
+
+	if opts != nil {
+		if opts.Output != "" {
+			args = append(args, "--output", opts.Output)
+		}
+
+		if opts.Mode != "" {
+			args = append(args, "--mode", opts.Mode)
+		}
+
+		if opts.Overwrite {
+			args = append(args, "--overwrite")
+		}
+
+		if opts.KeyStore != "" {
+			args = append(args, "--ks", opts.KeyStore)
+		}
+
+		if opts.KeyAlias != "" {
+			args = append(args, "--ks-key-alias", opts.KeyAlias)
+		}
+
+		for flag, password := range map[string]string{
+			"--ks-pass":  opts.KeyStorePass,
+			"--key-pass": opts.KeyPass,
+		} {
+			if password == "" {
+				continue
+			}
+
+			arg, cleanup, err := passwordArg(password)
+			if err != nil {
+				return fmt.Errorf("%s: %w", flag, err)
+			}
+			defer cleanup()
+
+			args = append(args, flag, arg)
+		}
+	}
+
+	var (
+		stderr = new(bytes.Buffer)
+		//nolint:gosec
+		cmd = exec.CommandContext(ctx, c.String(), args...)
+	)
+	cmd.Stderr = stderr
+
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return fmt.Errorf("%w: %s", err, msg)
+		}
+
+		return err
+	}
+
+	return nil
+}
+
+// ValidatePassword checks that password is either file:<path>, to read the
+// password from the file at path, or env:<name>, to read it from the environment
+// variable with the given name. bundletool's pass:<password> is not accepted, as
+// it would expose the password to anything that can see the command line.
+func ValidatePassword(password string) error {
+	if path, ok := strings.CutPrefix(password, "file:"); ok && path != "" {
+		return nil
+	} else if name, ok := strings.CutPrefix(password, "env:"); ok && name != "" {
+		return nil
+	}
+
+	return fmt.Errorf("password must be file:<path> or env:<name>")
+}
+
+// passwordArg returns the bundletool argument for password. As bundletool only
+// reads passwords from its command line or from files, the value of an env:<name>
+// password is written to a temporary file for the returned cleanup to remove.
+func passwordArg(password string) (string, func(), error) {
+	if err := ValidatePassword(password); err != nil {
+		return "", nil, err
+	}
+
+	name, ok := strings.CutPrefix(password, "env:")
+	if !ok {
+		return password, func() {}, nil
+	}
+
+	value, ok := os.LookupEnv(name)
+	if !ok {
+		return "", nil, fmt.Errorf("environment variable %s is not set", name)
+	}
+
+	f, err := os.CreateTemp("", "bundletool-pass-*")
+	if err != nil {
+		return "", nil, err
+	}
+
+	cleanup := func() {
+		_ = os.Remove(f.Name())
+	}
+
+	if _, err = f.WriteString(value); err != nil {
+		_ = f.Close()
+		cleanup()
+		return "", nil, err
+	}
+
+	if err = f.Close(); err != nil {
+		cleanup()
+		return "", nil, err
+	}
+
+	return "file:" + f.Name(), cleanup, nil
+}
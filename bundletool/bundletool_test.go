@@ -0,0 +1,58 @@
+package bundletool
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestValidatePassword(t *testing.T) {
+	for password, valid := range map[string]bool{
+		"file:/etc/momo/keystore-pass": true,
+		"env:KEYSTORE_PASS":            true,
+		"pass:hunter2":                 false,
+		"hunter2":                      false,
+		"file:":                        false,
+		"env:":                         false,
+	} {
+		if err := ValidatePassword(password); (err == nil) != valid {
+			t.Errorf("expected %q to be valid: %t, got %v", password, valid, err)
+		}
+	}
+}
+
+func TestPasswordArg(t *testing.T) {
+	t.Setenv("MOMO_TEST_KEYSTORE_PASS", t.Name())
+
+	arg, cleanup, err := passwordArg("env:MOMO_TEST_KEYSTORE_PASS")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	path, ok := strings.CutPrefix(arg, "file:")
+	if !ok {
+		t.Fatalf("expected the password to be passed to bundletool in a file, got %q", arg)
+	}
+
+	if data, err := os.ReadFile(path); err != nil || string(data) != t.Name() {
+		t.Fatalf("expected %s to contain the password, got %q: %v", path, data, err)
+	}
+
+	cleanup()
+
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("expected %s to be removed, got %v", path, err)
+	}
+
+	if arg, _, err := passwordArg("file:/etc/momo/keystore-pass"); err != nil || arg != "file:/etc/momo/keystore-pass" {
+		t.Errorf("expected file:<path> to be passed as is, got %q: %v", arg, err)
+	}
+
+	if _, _, err := passwordArg("env:MOMO_TEST_UNSET"); err == nil {
+		t.Error("expected an unset environment variable to be an error")
+	}
+
+	if _, _, err := passwordArg("pass:" + t.Name()); err == nil {
+		t.Error("expected pass:<password> to be rejected")
+	}
+}
@@ -0,0 +1,2 @@
+// Package bundletool has functions and types for interacting with the `bundletool` CLI.
+package bundletool
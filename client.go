@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
 	"net/url"
 	"os"
@@ -11,11 +12,15 @@ import (
 	"strings"
 
 	"github.com/frantjc/momo/android"
+	momov1alpha1 "github.com/frantjc/momo/api/v1alpha1"
 	"github.com/frantjc/momo/ios"
 )
 
 const (
 	ExtAPK  = ".apk"
+	ExtAAB  = ".aab"
+	ExtAPKS = ".apks"
+	ExtXAPK = ".xapk"
 	ExtIPA  = ".ipa"
 	ExtPNG  = ".png"
 	ExtJPG  = ".jpg"
@@ -26,12 +31,24 @@ const (
 	FileManifestPlist = "manifest.plist"
 	FileDisplayIcon   = "display.png"
 	FileFullSizeIcon  = "full-size.png"
+	FileDSYMs         = "dsyms.zip"
 )
 
 const (
 	DefaultPort = 8080
 )
 
+const (
+	// HeaderReleaseNotes is the header of a raw upload that has its
+	// release notes in Markdown. They are percent-encoded, as they
+	// are usually multiline.
+	HeaderReleaseNotes = "X-Release-Notes"
+	HeaderCommit       = "X-Commit"
+	HeaderBranch       = "X-Branch"
+	HeaderBuildURL     = "X-Build-URL"
+	HeaderAuthor       = "X-Author"
+)
+
 type Client struct {
 	HTTPClient *http.Client
 	BaseURL    *url.URL
@@ -49,34 +66,113 @@ func (c *Client) init() error {
 	return nil
 }
 
-func (c *Client) UploadApp(ctx context.Context, file, namespace, bucketName, appName string) error {
+// Release is what went into a build of an app, e.g. its release notes in Markdown.
+type Release struct {
+	Notes    string `json:"notes,omitempty"`
+	Commit   string `json:"commit,omitempty"`
+	Branch   string `json:"branch,omitempty"`
+	BuildURL string `json:"buildURL,omitempty"`
+	Author   string `json:"author,omitempty"`
+}
+
+type uploadAppOpts struct {
+	release *Release
+}
+
+type UploadAppOpt func(*uploadAppOpts)
+
+// WithRelease uploads the release notes and build metadata of the app alongside it.
+func WithRelease(release *Release) UploadAppOpt {
+	return func(o *uploadAppOpts) {
+		o.release = release
+	}
+}
+
+func (c *Client) UploadApp(ctx context.Context, file, namespace, bucketName, appName string, opts ...UploadAppOpt) error {
 	if err := c.init(); err != nil {
 		return err
 	}
 
-	f, err := os.Open(file)
-	if err != nil {
-		return err
+	o := &uploadAppOpts{}
+
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	var (
+		ext  = strings.ToLower(filepath.Ext(filepath.Clean(file)))
+		body io.Reader
+	)
+
+	if ext == ios.ExtXCArchive {
+		// An .xcarchive is a directory, so it is tarred for the server to build an .ipa from.
+		pr, pw := io.Pipe()
+		defer func() {
+			_ = pr.Close()
+		}()
+
+		go func() {
+			_ = pw.CloseWithError((&ios.Archive{Dir: file}).WriteTar(pw))
+		}()
+
+		body = pr
+	} else {
+		f, err := os.Open(file)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			_ = f.Close()
+		}()
+
+		body = f
+	}
+
+	var (
+		bucketRef = momov1alpha1.ParseBucketReference(bucketName)
+		uploadURL = c.BaseURL.JoinPath(namespace, "upload", bucketRef.Name, appName)
+	)
+
+	if bucketRef.IsClusterBucket() {
+		uploadURL.RawQuery = url.Values{"kind": {bucketRef.Kind}}.Encode()
 	}
-	defer func() {
-		_ = f.Close()
-	}()
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.BaseURL.JoinPath(namespace, "upload", bucketName, appName).String(), f)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, uploadURL.String(), body)
 	if err != nil {
 		return err
 	}
 
-	ext := strings.ToLower(filepath.Ext(file))
 	switch ext {
 	case ExtIPA:
 		req.Header.Set("Content-Type", ios.ContentTypeIPA)
 	case ExtAPK:
 		req.Header.Set("Content-Type", android.ContentTypeAPK)
+	case ExtAAB:
+		req.Header.Set("Content-Type", android.ContentTypeAAB)
+	case ExtAPKS:
+		req.Header.Set("Content-Type", android.ContentTypeAPKS)
+	case ExtXAPK:
+		req.Header.Set("Content-Type", android.ContentTypeXAPK)
+	case ios.ExtXCArchive:
+		req.Header.Set("Content-Type", "application/x-tar")
 	default:
 		return fmt.Errorf("unrecognized file extension %s", ext)
 	}
 
+	if o.release != nil {
+		for header, value := range map[string]string{
+			HeaderReleaseNotes: url.PathEscape(o.release.Notes),
+			HeaderCommit:       o.release.Commit,
+			HeaderBranch:       o.release.Branch,
+			HeaderBuildURL:     o.release.BuildURL,
+			HeaderAuthor:       o.release.Author,
+		} {
+			if value != "" {
+				req.Header.Set(header, value)
+			}
+		}
+	}
+
 	res, err := c.HTTPClient.Do(req)
 	if err != nil {
 		return err
@@ -99,6 +195,97 @@ func (c *Client) UploadApp(ctx context.Context, file, namespace, bucketName, app
 	return nil
 }
 
+// Promotion is the promotion of a version from one channel of an app to the next.
+type Promotion struct {
+	Version string `json:"version,omitempty"`
+	From    string `json:"from,omitempty"`
+	To      string `json:"to,omitempty"`
+}
+
+func (c *Client) PromoteChannel(ctx context.Context, namespace, appName, channel string) (*Promotion, error) {
+	if err := c.init(); err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.BaseURL.JoinPath(namespace, "apps", appName, "channels", channel, "promote").String(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = res.Body.Close()
+	}()
+
+	if res.StatusCode != http.StatusOK {
+		body := map[string]string{}
+		if err = json.NewDecoder(res.Body).Decode(&body); err == nil {
+			if msg := body["error"]; msg != "" {
+				return nil, fmt.Errorf("http status code %d: %s", res.StatusCode, msg)
+			}
+		}
+
+		return nil, fmt.Errorf("http status code %d", res.StatusCode)
+	}
+
+	promotion := &Promotion{}
+	if err = json.NewDecoder(res.Body).Decode(promotion); err != nil {
+		return nil, err
+	}
+
+	return promotion, nil
+}
+
+// Rollback is the pinning of the version before the latest one of a platform of an app.
+type Rollback struct {
+	Platform string `json:"platform,omitempty"`
+	From     string `json:"from,omitempty"`
+	Version  string `json:"version,omitempty"`
+}
+
+func (c *Client) RollBack(ctx context.Context, namespace, appName string, platforms ...string) ([]Rollback, error) {
+	if err := c.init(); err != nil {
+		return nil, err
+	}
+
+	u := c.BaseURL.JoinPath(namespace, "apps", appName, "rollback")
+	u.RawQuery = url.Values{"platform": platforms}.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = res.Body.Close()
+	}()
+
+	if res.StatusCode != http.StatusOK {
+		body := map[string]string{}
+		if err = json.NewDecoder(res.Body).Decode(&body); err == nil {
+			if msg := body["error"]; msg != "" {
+				return nil, fmt.Errorf("http status code %d: %s", res.StatusCode, msg)
+			}
+		}
+
+		return nil, fmt.Errorf("http status code %d", res.StatusCode)
+	}
+
+	rollbacks := []Rollback{}
+	if err = json.NewDecoder(res.Body).Decode(&rollbacks); err != nil {
+		return nil, err
+	}
+
+	return rollbacks, nil
+}
+
 func (c *Client) Ping(ctx context.Context) error {
 	if err := c.init(); err != nil {
 		return err
@@ -10,7 +10,9 @@ import (
 	_ "github.com/928799934/go-png-cgbi"
 	"github.com/frantjc/momo/command"
 	xos "github.com/frantjc/x/os"
+	_ "gocloud.dev/blob/azureblob"
 	_ "gocloud.dev/blob/fileblob"
+	_ "gocloud.dev/blob/gcsblob"
 	_ "gocloud.dev/blob/s3blob"
 	_ "k8s.io/client-go/plugin/pkg/client/auth"
 )
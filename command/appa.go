@@ -13,6 +13,8 @@ func NewAppa() *cobra.Command {
 
 	cmd.AddCommand(
 		NewUpload(),
+		NewPromote(),
+		NewRollback(),
 	)
 
 	return cmd
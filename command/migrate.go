@@ -0,0 +1,112 @@
+package command
+
+import (
+	"fmt"
+
+	momov1alpha1 "github.com/frantjc/momo/api/v1alpha1"
+	"github.com/frantjc/momo/internal/momoutil"
+	"github.com/spf13/cobra"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// NewMigrate returns the command which acts as
+// the entrypoint for `momo migrate`.
+func NewMigrate() *cobra.Command {
+	var (
+		appLabels map[string]string
+		cfgFlags  = genericclioptions.NewConfigFlags(true)
+		cmd       = &cobra.Command{
+			Use:   "migrate (from-bucket) (to-bucket)",
+			Long:  "Buckets are referred to by name, or by ClusterBucket/<name> for ClusterBuckets.",
+			Short: "Move the APKs and IPAs that reference one Bucket to another",
+			Args:  cobra.ExactArgs(2),
+			RunE: func(cmd *cobra.Command, args []string) error {
+				var (
+					ctx    = cmd.Context()
+					from   = momov1alpha1.ParseBucketReference(args[0])
+					to     = momov1alpha1.ParseBucketReference(args[1])
+					cliCfg = cfgFlags.ToRawKubeConfigLoader()
+				)
+
+				namespace, ok, err := cliCfg.Namespace()
+				if err != nil {
+					return err
+				} else if !ok || namespace == "" {
+					namespace = "default"
+				}
+
+				restCfg, err := cliCfg.ClientConfig()
+				if err != nil {
+					return err
+				}
+
+				scheme, err := momoutil.NewScheme(corev1.AddToScheme, momov1alpha1.AddToScheme)
+				if err != nil {
+					return err
+				}
+
+				cli, err := client.New(restCfg, client.Options{Scheme: scheme})
+				if err != nil {
+					return err
+				}
+
+				var (
+					buckets  = &momoutil.BucketManager{}
+					apks     = &momov1alpha1.APKList{}
+					ipas     = &momov1alpha1.IPAList{}
+					listOpts = &client.ListOptions{
+						Namespace:     namespace,
+						LabelSelector: labels.Set(appLabels).AsSelector(),
+					}
+					objs = []client.Object{}
+				)
+				defer func() {
+					_ = buckets.Close()
+				}()
+
+				if err := cli.List(ctx, apks, listOpts); err != nil {
+					return err
+				}
+
+				for _, apk := range apks.Items {
+					if apk.Spec.Bucket.String() == from.String() {
+						objs = append(objs, &apk)
+					}
+				}
+
+				if err := cli.List(ctx, ipas, listOpts); err != nil {
+					return err
+				}
+
+				for _, ipa := range ipas.Items {
+					if ipa.Spec.Bucket.String() == from.String() {
+						objs = append(objs, &ipa)
+					}
+				}
+
+				for _, obj := range objs {
+					kind := "apk"
+					if _, ok := obj.(*momov1alpha1.IPA); ok {
+						kind = "ipa"
+					}
+
+					if err := momoutil.MigrateApp(ctx, cli, buckets, obj, to); err != nil {
+						return fmt.Errorf("migrate %s/%s: %w", kind, obj.GetName(), err)
+					}
+
+					_, _ = fmt.Fprintf(cmd.OutOrStdout(), "%s/%s migrated to %s\n", kind, obj.GetName(), to)
+				}
+
+				return nil
+			},
+		}
+	)
+
+	cfgFlags.AddFlags(cmd.Flags())
+	cmd.Flags().StringToStringVarP(&appLabels, "label", "l", nil, "Only migrate APKs and IPAs with these labels")
+
+	return cmd
+}
@@ -18,17 +18,27 @@ import (
 	"github.com/frantjc/momo"
 	"github.com/frantjc/momo/android"
 	momov1alpha1 "github.com/frantjc/momo/api/v1alpha1"
+	"github.com/frantjc/momo/bundletool"
 	"github.com/frantjc/momo/internal/api"
 	"github.com/frantjc/momo/internal/controller"
 	"github.com/frantjc/momo/internal/momoutil"
+	"github.com/frantjc/momo/internal/scan"
+	"github.com/go-logr/logr"
 	"github.com/spf13/cobra"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	_ "gocloud.dev/blob/azureblob"
 	_ "gocloud.dev/blob/fileblob"
+	_ "gocloud.dev/blob/gcsblob"
 	_ "gocloud.dev/blob/s3blob"
 	"golang.org/x/sync/errgroup"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
 	_ "k8s.io/client-go/plugin/pkg/client/auth"
+	"k8s.io/client-go/rest"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/certwatcher"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/healthz"
 	"sigs.k8s.io/controller-runtime/pkg/metrics/filters"
 	metricsserver "sigs.k8s.io/controller-runtime/pkg/metrics/server"
@@ -46,6 +56,7 @@ func NewMomo() *cobra.Command {
 		NewControl(),
 		NewServe(),
 		NewUnpack(),
+		NewMigrate(),
 	)
 
 	return cmd
@@ -60,6 +71,16 @@ func NewControl() *cobra.Command {
 		probeAddr                                        string
 		secureMetrics                                    bool
 		enableHTTP2                                      bool
+		verifyDigests                                    bool
+		rangeReads                                       bool
+		unpackImage                                      string
+		unpackCPULimit, unpackMemoryLimit                string
+		unpackTimeout                                    time.Duration
+		bucketUsageInterval                              time.Duration
+		scannerURL                                       string
+		bundletoolCmd                                    string
+		keyStore, keyAlias, keyStorePass, keyPass        string
+		tracingFlags                                     *TracingFlags
 		cmd                                              = &cobra.Command{
 			Use: "ctrl",
 			RunE: func(cmd *cobra.Command, args []string) error {
@@ -68,6 +89,14 @@ func NewControl() *cobra.Command {
 					return err
 				}
 
+				shutdown, err := tracingFlags.Setup(cmd.Context(), "momo-ctrl")
+				if err != nil {
+					return err
+				}
+				defer func() {
+					_ = shutdown(context.WithoutCancel(cmd.Context()))
+				}()
+
 				var (
 					ctx     = cmd.Context()
 					tlsOpts []func(*tls.Config)
@@ -156,15 +185,73 @@ func NewControl() *cobra.Command {
 					return err
 				}
 
-				if err = (&controller.BucketReconciler{}).SetupWithManager(mgr); err != nil {
+				buckets := &momoutil.BucketManager{}
+
+				if err := mgr.Add(buckets); err != nil {
+					return err
+				}
+
+				if err = (&controller.BucketReconciler{Buckets: buckets, UsageInterval: bucketUsageInterval}).SetupWithManager(mgr); err != nil {
 					return err
 				}
 
-				if err = (&controller.IPAReconciler{}).SetupWithManager(mgr); err != nil {
+				if err = (&controller.ClusterBucketReconciler{Buckets: buckets, UsageInterval: bucketUsageInterval}).SetupWithManager(mgr); err != nil {
+					return err
+				}
+
+				var unpackJob *controller.UnpackJobOpts
+
+				if unpackImage != "" {
+					cpu, err := resource.ParseQuantity(unpackCPULimit)
+					if err != nil {
+						return fmt.Errorf("parse --unpack-cpu-limit: %w", err)
+					}
+
+					memory, err := resource.ParseQuantity(unpackMemoryLimit)
+					if err != nil {
+						return fmt.Errorf("parse --unpack-memory-limit: %w", err)
+					}
+
+					unpackJob = &controller.UnpackJobOpts{
+						Image: unpackImage,
+						Resources: corev1.ResourceRequirements{
+							Limits: corev1.ResourceList{
+								corev1.ResourceCPU:    cpu,
+								corev1.ResourceMemory: memory,
+							},
+						},
+						Timeout: unpackTimeout,
+					}
+				}
+
+				var scanner scan.Scanner
+
+				if scannerURL != "" {
+					if scanner, err = scan.New(scannerURL); err != nil {
+						return fmt.Errorf("parse --scanner: %w", err)
+					}
+				}
+
+				if err = (&controller.IPAReconciler{Buckets: buckets, VerifyDigests: verifyDigests, RangeReads: rangeReads, UnpackJob: unpackJob, Scanner: scanner}).SetupWithManager(mgr); err != nil {
 					return err
 				}
 
-				if err = (&controller.APKReconciler{}).SetupWithManager(mgr); err != nil {
+				aabDecoderOpts := []android.AABDecoderOpt{android.WithBundletool(bundletoolCmd)}
+				if keyStore != "" {
+					for flag, password := range map[string]string{"--keystore-pass": keyStorePass, "--keystore-key-pass": keyPass} {
+						if password == "" {
+							continue
+						}
+
+						if err := bundletool.ValidatePassword(password); err != nil {
+							return fmt.Errorf("parse %s: %w", flag, err)
+						}
+					}
+
+					aabDecoderOpts = append(aabDecoderOpts, android.WithKeyStore(keyStore, keyAlias, keyStorePass, keyPass))
+				}
+
+				if err = (&controller.APKReconciler{Buckets: buckets, VerifyDigests: verifyDigests, RangeReads: rangeReads, UnpackJob: unpackJob, Scanner: scanner, AABDecoderOpts: aabDecoderOpts}).SetupWithManager(mgr); err != nil {
 					return err
 				}
 
@@ -206,19 +293,59 @@ func NewControl() *cobra.Command {
 	cmd.Flags().StringVar(&metricsCertKey, "metrics-cert-key", "tls.key", "The name of the metrics server key file")
 	cmd.Flags().BoolVar(&enableHTTP2, "enable-http2", false,
 		"If set, HTTP/2 will be enabled for the metrics and webhook servers")
+	cmd.Flags().BoolVar(&verifyDigests, "verify-digests", false,
+		"If set, APKs and IPAs are downloaded and hashed on every reconcile instead of only when their objects' attributes change")
+	cmd.Flags().BoolVar(&rangeReads, "range-reads", false,
+		"If set, APKs and IPAs are decoded by reading only the parts of them that are needed from their Buckets instead of downloading them to disk, "+
+			"which decodes APKs in Go instead of with apktool. They are identified by their objects' ETags or MD5s instead of by hashing their contents, "+
+			"unless --verify-digests is set or their Buckets do not report either")
+	cmd.Flags().StringVar(&unpackImage, "unpack-image", "",
+		"If set, APKs and IPAs are unpacked by running `momo unpack` from this image in Jobs instead of in the controller")
+	cmd.Flags().StringVar(&unpackCPULimit, "unpack-cpu-limit", "1", "The CPU limit of unpack Jobs")
+	cmd.Flags().StringVar(&unpackMemoryLimit, "unpack-memory-limit", "1Gi", "The memory limit of unpack Jobs")
+	cmd.Flags().DurationVar(&unpackTimeout, "unpack-timeout", time.Minute*10, "How long unpack Jobs may run for")
+	cmd.Flags().DurationVar(&bucketUsageInterval, "bucket-usage-interval", controller.DefaultBucketUsageInterval,
+		"How often the storage used by the objects of APKs and IPAs in each Bucket and ClusterBucket is computed, which lists every object in them, or 0 to not")
+	cmd.Flags().StringVar(&scannerURL, "scanner", "",
+		"If set, APKs and IPAs must be scanned clean by this scanner before they are Ready, "+
+			"e.g. unix:///var/run/clamav/clamd.sock, tcp://clamd:3310, exec:///usr/bin/scan?arg=- or https://scanner/scan")
+	cmd.Flags().StringVar(&bundletoolCmd, "bundletool", "bundletool", "The bundletool to build universal APKs from Android App Bundles with")
+	cmd.Flags().StringVar(&keyStore, "keystore", "",
+		"If set, universal APKs built from Android App Bundles are signed with a key from this keystore instead of bundletool's debug keystore")
+	cmd.Flags().StringVar(&keyAlias, "keystore-key-alias", "", "The alias of the key in --keystore")
+	cmd.Flags().StringVar(&keyStorePass, "keystore-pass", "",
+		"The password of --keystore, either file:<path> to read it from a file or env:<name> to read it from an environment variable")
+	cmd.Flags().StringVar(&keyPass, "keystore-key-pass", "",
+		"The password of the key in --keystore, either file:<path> to read it from a file or env:<name> to read it from an environment variable")
+
+	_, tracingFlags = SetTracingFlags(cmd)
 
 	return cmd
 }
 
 func NewServe() *cobra.Command {
 	var (
-		port int
-		opts = &api.Opts{
+		port              int
+		metricsAddr       string
+		secureMetrics     bool
+		appEvents         string
+		appEventsInterval time.Duration
+		tracingFlags      *TracingFlags
+		opts              = &api.Opts{
 			Swagger: true,
+			Buckets: &momoutil.BucketManager{},
 		}
 		cmd = &cobra.Command{
 			Use: "srv",
 			RunE: func(cmd *cobra.Command, args []string) error {
+				shutdown, err := tracingFlags.Setup(cmd.Context(), "momo-srv")
+				if err != nil {
+					return err
+				}
+				defer func() {
+					_ = shutdown(context.WithoutCancel(cmd.Context()))
+				}()
+
 				l, err := net.Listen("tcp", fmt.Sprintf(":%d", port))
 				if err != nil {
 					return err
@@ -226,9 +353,76 @@ func NewServe() *cobra.Command {
 				defer func() {
 					_ = l.Close()
 				}()
+				defer func() {
+					_ = opts.Buckets.Close()
+				}()
 
 				eg, ctx := errgroup.WithContext(cmd.Context())
 
+				if metricsAddr != "0" {
+					var (
+						metricsServerOptions = metricsserver.Options{
+							BindAddress:   metricsAddr,
+							SecureServing: secureMetrics,
+						}
+						cfg        *rest.Config
+						httpClient *http.Client
+					)
+
+					if secureMetrics {
+						metricsServerOptions.FilterProvider = filters.WithAuthenticationAndAuthorization
+
+						if cfg, err = ctrl.GetConfig(); err != nil {
+							return err
+						}
+
+						if httpClient, err = rest.HTTPClientFor(cfg); err != nil {
+							return err
+						}
+					}
+
+					metricsServer, err := metricsserver.NewServer(metricsServerOptions, cfg, httpClient)
+					if err != nil {
+						return err
+					}
+
+					eg.Go(func() error {
+						return metricsServer.Start(ctx)
+					})
+				}
+
+				switch appEvents {
+				case "status":
+					scheme, err := momoutil.NewScheme(momov1alpha1.AddToScheme)
+					if err != nil {
+						return err
+					}
+
+					cfg, err := ctrl.GetConfig()
+					if err != nil {
+						return err
+					}
+
+					cli, err := client.New(cfg, client.Options{Scheme: scheme})
+					if err != nil {
+						return err
+					}
+
+					sink := &momoutil.StatusAppEventSink{Client: cli, Interval: appEventsInterval}
+					opts.Events = sink
+
+					eg.Go(func() error {
+						return sink.Start(ctx)
+					})
+				case "log":
+					opts.Events = &momoutil.LogAppEventSink{Logger: logr.FromContextOrDiscard(ctx).WithName("app-events")}
+				case "trace":
+					opts.Events = momoutil.TraceAppEventSink{}
+				case "none", "":
+				default:
+					return fmt.Errorf("unsupported --app-events %s", appEvents)
+				}
+
 				if len(args) > 0 {
 					var ex *exec.Cmd
 					opts.Fallback, ex, err = momoutil.NewExecHandlerWithPortFromEnv(ctx, args[0], args[1:]...)
@@ -260,7 +454,7 @@ func NewServe() *cobra.Command {
 
 				srv := &http.Server{
 					ReadHeaderTimeout: time.Second * 5,
-					Handler:           handler,
+					Handler:           otelhttp.NewHandler(handler, "momo"),
 					BaseContext: func(_ net.Listener) context.Context {
 						return context.WithoutCancel(cmd.Context())
 					},
@@ -285,6 +479,17 @@ func NewServe() *cobra.Command {
 
 	cmd.Flags().IntVarP(&port, "port", "p", momo.DefaultPort, "The port for momo to listen on")
 	cmd.Flags().StringVar(&opts.Path, "path", "", "The base URL path for momo")
+	cmd.Flags().StringVar(&metricsAddr, "metrics-bind-address", ":8081", "The address the metrics endpoint binds to, separately from momo's own port, "+
+		"or 0 to disable the metrics service")
+	cmd.Flags().BoolVar(&secureMetrics, "metrics-secure", true,
+		"If set, the metrics endpoint is served securely via HTTPS. Use --metrics-secure=false to use HTTP instead")
+	cmd.Flags().StringVar(&appEvents, "app-events", "log",
+		"Where to record installs and downloads of apps: log to log them at the info level, "+
+			"status to count them in MobileApps' statuses, which requires permission to update mobileapps/status, "+
+			"trace to add them to the requests' spans exported via OTLP, or none")
+	cmd.Flags().DurationVar(&appEventsInterval, "app-events-flush-interval", time.Minute,
+		"How often counts of installs and downloads are flushed to MobileApps' statuses with --app-events=status")
+	_, tracingFlags = SetTracingFlags(cmd)
 
 	return cmd
 }
@@ -297,6 +502,8 @@ func NewUnpack() *cobra.Command {
 	cmd.AddCommand(
 		NewUnpackManifest(),
 		NewUnpackMetadata(),
+		NewUnpackAPK(),
+		NewUnpackIPA(),
 	)
 
 	return cmd
@@ -0,0 +1,103 @@
+package command
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/frantjc/momo"
+	momov1alpha1 "github.com/frantjc/momo/api/v1alpha1"
+	"github.com/frantjc/momo/internal/momoutil"
+	"github.com/spf13/cobra"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// NewPromote returns the command which acts as
+// the entrypoint for `appa promote`.
+func NewPromote() *cobra.Command {
+	var (
+		addr     string
+		cfgFlags = genericclioptions.NewConfigFlags(true)
+		cmd      = &cobra.Command{
+			Use:   "promote (app) (channel)",
+			Short: "Promote the version of a channel of an app to the next channel",
+			Args:  cobra.ExactArgs(2),
+			RunE: func(cmd *cobra.Command, args []string) error {
+				var (
+					ctx     = cmd.Context()
+					appName = args[0]
+					channel = args[1]
+				)
+
+				cli, kubeCli, namespace, err := newAppClients(cfgFlags, addr)
+				if err != nil {
+					return err
+				}
+
+				promotion, err := cli.PromoteChannel(ctx, namespace, appName, channel)
+				if err != nil {
+					if cmd.Flag("addr").Changed || kubeCli == nil {
+						return err
+					}
+
+					p, err := momoutil.PromoteChannel(ctx, kubeCli, client.ObjectKey{Namespace: namespace, Name: appName}, channel)
+					if err != nil {
+						return err
+					}
+
+					promotion = &momo.Promotion{Version: p.Version, From: p.From, To: p.To}
+				}
+
+				_, err = fmt.Fprintf(cmd.OutOrStdout(), "promoted %s from %s to %s\n", promotion.Version, promotion.From, promotion.To)
+				return err
+			},
+		}
+	)
+
+	cfgFlags.AddFlags(cmd.Flags())
+	cmd.Flags().StringVarP(&addr, "addr", "a", "", "")
+
+	return cmd
+}
+
+// newAppClients returns a client for momo at addr and, if there is a kubeconfig,
+// a client for Kubernetes to fall back to, as well as the namespace to act in.
+func newAppClients(cfgFlags *genericclioptions.ConfigFlags, addr string) (*momo.Client, client.Client, string, error) {
+	var (
+		cliCfg  = cfgFlags.ToRawKubeConfigLoader()
+		cli     = new(momo.Client)
+		kubeCli client.Client
+	)
+
+	namespace, ok, err := cliCfg.Namespace()
+	if err != nil {
+		return nil, nil, "", err
+	} else if !ok || namespace == "" {
+		namespace = "default"
+	}
+
+	if addr != "" {
+		if cli.BaseURL, err = url.Parse(addr); err != nil {
+			return nil, nil, "", err
+		}
+	}
+
+	restCfg, err := cliCfg.ClientConfig()
+	if err == nil {
+		cli.HTTPClient = http.DefaultClient
+		cli.HTTPClient.Transport = &kubeAuthTransport{RestConfig: restCfg}
+
+		scheme, err := momoutil.NewScheme(corev1.AddToScheme, momov1alpha1.AddToScheme)
+		if err != nil {
+			return nil, nil, "", err
+		}
+
+		if kubeCli, err = client.New(restCfg, client.Options{Scheme: scheme}); err != nil {
+			return nil, nil, "", err
+		}
+	}
+
+	return cli, kubeCli, namespace, nil
+}
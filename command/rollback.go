@@ -0,0 +1,69 @@
+package command
+
+import (
+	"fmt"
+
+	"github.com/frantjc/momo"
+	"github.com/frantjc/momo/internal/momoutil"
+	xslice "github.com/frantjc/x/slice"
+	"github.com/spf13/cobra"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// NewRollback returns the command which acts as
+// the entrypoint for `appa rollback`.
+func NewRollback() *cobra.Command {
+	var (
+		addr      string
+		platforms []string
+		cfgFlags  = genericclioptions.NewConfigFlags(true)
+		cmd       = &cobra.Command{
+			Use:   "rollback (app)",
+			Short: "Pin the version before the latest one of an app",
+			Long:  "Unset the app's spec.pinned to serve the newest version again.",
+			Args:  cobra.ExactArgs(1),
+			RunE: func(cmd *cobra.Command, args []string) error {
+				var (
+					ctx     = cmd.Context()
+					appName = args[0]
+				)
+
+				cli, kubeCli, namespace, err := newAppClients(cfgFlags, addr)
+				if err != nil {
+					return err
+				}
+
+				rollbacks, err := cli.RollBack(ctx, namespace, appName, platforms...)
+				if err != nil {
+					if cmd.Flag("addr").Changed || kubeCli == nil {
+						return err
+					}
+
+					rbs, err := momoutil.RollBack(ctx, kubeCli, client.ObjectKey{Namespace: namespace, Name: appName}, platforms...)
+					if err != nil {
+						return err
+					}
+
+					rollbacks = xslice.Map(rbs, func(rollback momoutil.Rollback, _ int) momo.Rollback {
+						return momo.Rollback(rollback)
+					})
+				}
+
+				for _, rollback := range rollbacks {
+					if _, err = fmt.Fprintf(cmd.OutOrStdout(), "rolled %s back from %s to %s\n", rollback.Platform, rollback.From, rollback.Version); err != nil {
+						return err
+					}
+				}
+
+				return nil
+			},
+		}
+	)
+
+	cfgFlags.AddFlags(cmd.Flags())
+	cmd.Flags().StringVarP(&addr, "addr", "a", "", "")
+	cmd.Flags().StringSliceVarP(&platforms, "platform", "p", nil, "android or ios")
+
+	return cmd
+}
@@ -0,0 +1,72 @@
+package command
+
+import (
+	"context"
+
+	"github.com/spf13/cobra"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// TracingFlags configure exporting traces via OTLP.
+type TracingFlags struct {
+	Endpoint    string
+	Insecure    bool
+	Headers     map[string]string
+	SampleRatio float64
+}
+
+func SetTracingFlags(cmd *cobra.Command) (*cobra.Command, *TracingFlags) {
+	tracingFlags := &TracingFlags{}
+	cmd.Flags().StringVar(&tracingFlags.Endpoint, "otlp-endpoint", "", "The host:port of the OTLP gRPC endpoint to export traces to, or leave empty to disable tracing")
+	cmd.Flags().BoolVar(&tracingFlags.Insecure, "otlp-insecure", false, "If set, traces are exported to the OTLP endpoint without TLS")
+	cmd.Flags().StringToStringVar(&tracingFlags.Headers, "otlp-header", nil, "Headers to send to the OTLP endpoint, e.g. for authentication")
+	cmd.Flags().Float64Var(&tracingFlags.SampleRatio, "trace-sample-ratio", 1, "The ratio of traces to sample when not continuing a sampled trace")
+	return cmd, tracingFlags
+}
+
+// Setup sets the global TracerProvider and TextMapPropagator. The returned
+// function flushes and stops the exporting of traces and must be called.
+func (f *TracingFlags) Setup(ctx context.Context, serviceName string) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	if f.Endpoint == "" {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	opts := []otlptracegrpc.Option{
+		otlptracegrpc.WithEndpoint(f.Endpoint),
+		otlptracegrpc.WithHeaders(f.Headers),
+	}
+
+	if f.Insecure {
+		opts = append(opts, otlptracegrpc.WithInsecure())
+	}
+
+	exporter, err := otlptracegrpc.New(ctx, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := resource.Merge(
+		resource.Default(),
+		resource.NewSchemaless(attribute.String("service.name", serviceName)),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(f.SampleRatio))),
+	)
+
+	otel.SetTracerProvider(tp)
+
+	return tp.Shutdown, nil
+}
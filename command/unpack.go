@@ -0,0 +1,166 @@
+package command
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+
+	"github.com/frantjc/momo"
+	"github.com/frantjc/momo/android"
+	"github.com/frantjc/momo/internal/momoutil"
+	"github.com/frantjc/momo/ios"
+	"github.com/spf13/cobra"
+)
+
+func NewUnpackAPK() *cobra.Command {
+	var (
+		url, resultURL string
+		cmd            = &cobra.Command{
+			Use:   "apk [.apk]",
+			Short: "Unpack everything that momo needs from an .apk",
+			Args:  cobra.MaximumNArgs(1),
+			RunE: func(cmd *cobra.Command, args []string) error {
+				return unpack(cmd, args, url, resultURL, momo.ExtAPK, func(ctx context.Context, name string) (*momoutil.UnpackResult, error) {
+					apk := android.NewAPKDecoder(name)
+					defer func() {
+						_ = apk.Close()
+					}()
+
+					return momoutil.UnpackAPK(ctx, apk)
+				})
+			},
+		}
+	)
+
+	setUnpackFlags(cmd, &url, &resultURL)
+
+	return cmd
+}
+
+func NewUnpackIPA() *cobra.Command {
+	var (
+		url, resultURL string
+		cmd            = &cobra.Command{
+			Use:   "ipa [.ipa]",
+			Short: "Unpack everything that momo needs from an .ipa",
+			Args:  cobra.MaximumNArgs(1),
+			RunE: func(cmd *cobra.Command, args []string) error {
+				return unpack(cmd, args, url, resultURL, momo.ExtIPA, func(ctx context.Context, name string) (*momoutil.UnpackResult, error) {
+					// Not closed because that would remove the .ipa.
+					return momoutil.UnpackIPA(ctx, ios.NewIPADecoder(name))
+				})
+			},
+		}
+	)
+
+	setUnpackFlags(cmd, &url, &resultURL)
+
+	return cmd
+}
+
+func setUnpackFlags(cmd *cobra.Command, url, resultURL *string) {
+	cmd.Flags().StringVar(url, "url", "", "A URL to download the app from instead of reading it from a path")
+	cmd.Flags().StringVar(resultURL, "result-url", "", "A URL to PUT the result to instead of writing it to stdout")
+}
+
+// unpack runs fn on the app at the path in args or downloaded from url and then writes
+// the result to resultURL or stdout. The result is written even if fn fails, so that
+// the error can be read back from it, e.g. by a reconciler that ran this in a Job.
+func unpack(cmd *cobra.Command, args []string, url, resultURL, ext string, fn func(context.Context, string) (*momoutil.UnpackResult, error)) error {
+	var (
+		ctx  = cmd.Context()
+		name string
+	)
+
+	switch {
+	case url != "":
+		tmp, err := download(ctx, url, ext)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			_ = os.Remove(tmp)
+		}()
+
+		name = tmp
+	case len(args) > 0:
+		name = args[0]
+	default:
+		return fmt.Errorf("either a path or --url is required")
+	}
+
+	result, err := fn(ctx, name)
+
+	b, merr := json.Marshal(result)
+	if merr != nil {
+		return merr
+	}
+
+	if resultURL == "" {
+		if _, werr := cmd.OutOrStdout().Write(append(b, '\n')); werr != nil {
+			return werr
+		}
+
+		return err
+	}
+
+	req, rerr := http.NewRequestWithContext(ctx, http.MethodPut, resultURL, bytes.NewReader(b))
+	if rerr != nil {
+		return rerr
+	}
+	req.Header.Set("Content-Type", "application/json")
+	// Required by Azure Blob Storage and ignored elsewhere.
+	req.Header.Set("X-Ms-Blob-Type", "BlockBlob")
+
+	res, rerr := http.DefaultClient.Do(req)
+	if rerr != nil {
+		return rerr
+	}
+	defer func() {
+		_ = res.Body.Close()
+	}()
+
+	if res.StatusCode >= 300 {
+		return fmt.Errorf("put result: %s", res.Status)
+	}
+
+	return err
+}
+
+func download(ctx context.Context, url, ext string) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return "", err
+	}
+
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer func() {
+		_ = res.Body.Close()
+	}()
+
+	if res.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("download: %s", res.Status)
+	}
+
+	tmp, err := os.CreateTemp("", "*"+ext)
+	if err != nil {
+		return "", err
+	}
+	defer func() {
+		_ = tmp.Close()
+	}()
+
+	if _, err = io.Copy(tmp, res.Body); err != nil {
+		_ = os.Remove(tmp.Name())
+		return "", err
+	}
+
+	return tmp.Name(), tmp.Close()
+}
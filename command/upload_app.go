@@ -1,10 +1,13 @@
 package command
 
 import (
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
 	"net/url"
 	"os"
+	"os/exec"
 	"path/filepath"
 	"strings"
 
@@ -41,6 +44,9 @@ func newUploadApp(name string) *cobra.Command {
 		bucketName   string
 		isIPA, isAPK bool
 		bucketLabels map[string]string
+		release      = &momo.Release{}
+		notesFile    string
+		notesGitLog  string
 		cfgFlags     = genericclioptions.NewConfigFlags(true)
 		cmd          = &cobra.Command{
 			Use: name,
@@ -118,32 +124,92 @@ func newUploadApp(name string) *cobra.Command {
 					return fmt.Errorf("--bucket is required")
 				}
 
-				if err := cli.UploadApp(ctx, file, namespace, bucketName, appName); err != nil {
+				if release.Notes == "" {
+					if release.Notes, err = readReleaseNotes(cmd, notesFile, notesGitLog); err != nil {
+						return err
+					}
+				}
+
+				if err := cli.UploadApp(ctx, file, namespace, bucketName, appName, momo.WithRelease(release)); err != nil {
 					if !cmd.Flag("addr").Changed && kubeCli != nil {
 						mediaType := ios.ContentTypeIPA
 						if isAPK {
 							mediaType = android.ContentTypeAPK
 						} else if !isIPA {
-							ext := filepath.Ext(file)
+							ext := filepath.Ext(filepath.Clean(file))
 							switch ext {
 							case momo.ExtAPK:
 								mediaType = android.ContentTypeAPK
-							case momo.ExtIPA:
+							case momo.ExtAAB:
+								mediaType = android.ContentTypeAAB
+							case momo.ExtAPKS:
+								mediaType = android.ContentTypeAPKS
+							case momo.ExtXAPK:
+								mediaType = android.ContentTypeXAPK
+							case momo.ExtIPA, ios.ExtXCArchive:
 								mediaType = ios.ContentTypeIPA
 							default:
-								return fmt.Errorf("unable to determine if %s is an %s or %s", file, momo.ExtAPK, momo.ExtIPA)
+								return fmt.Errorf("unable to determine if %s is an %s, %s, %s, %s, %s or %s", file, momo.ExtAPK, momo.ExtAAB, momo.ExtAPKS, momo.ExtXAPK, momo.ExtIPA, ios.ExtXCArchive)
 							}
 						}
 
-						f, err := os.Open(file)
-						if err != nil {
-							return err
+						var (
+							r    io.Reader
+							opts = []momoutil.UploadAppOpt{
+								momoutil.WithRelease(momov1alpha1.Release(*release)),
+							}
+						)
+
+						if filepath.Ext(filepath.Clean(file)) == ios.ExtXCArchive {
+							archive := &ios.Archive{Dir: filepath.Clean(file)}
+
+							dsyms, err := os.CreateTemp("", "*"+momo.FileDSYMs)
+							if err != nil {
+								return err
+							}
+							defer func() {
+								_ = dsyms.Close()
+								_ = os.Remove(dsyms.Name())
+							}()
+
+							if err := archive.WriteDSYMs(dsyms); err == nil {
+								if _, err := dsyms.Seek(0, io.SeekStart); err != nil {
+									return err
+								}
+
+								opts = append(opts, momoutil.WithDSYMs(dsyms))
+							} else if !errors.Is(err, ios.ErrNoDSYMs) {
+								return err
+							}
+
+							pr, pw := io.Pipe()
+							defer func() {
+								_ = pr.Close()
+							}()
+
+							go func() {
+								_ = pw.CloseWithError(archive.WriteIPA(pw))
+							}()
+
+							r = pr
+						} else {
+							f, err := os.Open(file)
+							if err != nil {
+								return err
+							}
+							defer func() {
+								_ = f.Close()
+							}()
+
+							r = f
 						}
+
+						buckets := &momoutil.BucketManager{}
 						defer func() {
-							_ = f.Close()
+							_ = buckets.Close()
 						}()
 
-						if err := momoutil.UploadApp(ctx, kubeCli, namespace, appName, bucketName, mediaType, f); err != nil {
+						if err := momoutil.UploadApp(ctx, kubeCli, buckets, namespace, appName, momov1alpha1.ParseBucketReference(bucketName), mediaType, r, opts...); err != nil {
 							return err
 						}
 					}
@@ -162,6 +228,40 @@ func newUploadApp(name string) *cobra.Command {
 	cmd.Flags().StringToStringVarP(&bucketLabels, "bucket-label", "l", nil, "")
 	cmd.Flags().BoolVar(&isAPK, "apk", false, "")
 	cmd.Flags().BoolVar(&isIPA, "ipa", false, "")
+	cmd.Flags().StringVar(&release.Notes, "notes", "", "Release notes in Markdown")
+	cmd.Flags().StringVar(&notesFile, "notes-file", "", "File to read release notes from, - for stdin")
+	cmd.Flags().StringVar(&notesGitLog, "notes-from-git-log", "", "Git revision range to make release notes from the log of, e.g. v1.0.0..HEAD")
+	cmd.MarkFlagsMutuallyExclusive("notes", "notes-file", "notes-from-git-log")
+	cmd.Flags().StringVar(&release.Commit, "commit", "", "Commit SHA that the app was built from")
+	cmd.Flags().StringVar(&release.Branch, "branch", "", "Branch that the app was built from")
+	cmd.Flags().StringVar(&release.BuildURL, "build-url", "", "URL of the CI build that built the app")
+	cmd.Flags().StringVar(&release.Author, "author", "", "Author of the build")
 
 	return cmd
 }
+
+// readReleaseNotes reads release notes from notesFile, or stdin if it is "-",
+// or makes them from the subjects of the commits in the git revision range
+// gitLog, if either is set.
+func readReleaseNotes(cmd *cobra.Command, notesFile, gitLog string) (string, error) {
+	switch {
+	case notesFile == "-":
+		notes, err := io.ReadAll(cmd.InOrStdin())
+		return string(notes), err
+	case notesFile != "":
+		notes, err := os.ReadFile(notesFile)
+		return string(notes), err
+	case gitLog != "":
+		git := exec.CommandContext(cmd.Context(), "git", "log", "--pretty=format:- %s", gitLog)
+		git.Stderr = cmd.ErrOrStderr()
+
+		notes, err := git.Output()
+		if err != nil {
+			return "", fmt.Errorf("git log %s: %w", gitLog, err)
+		}
+
+		return string(notes), nil
+	}
+
+	return "", nil
+}
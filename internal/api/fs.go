@@ -10,4 +10,6 @@ var (
 	questionMarkPNG []byte
 	//go:embed swagger.json
 	swaggerJSON []byte
+	//go:embed incompatible.html
+	incompatibleHTML string
 )
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
+	"html/template"
 	"image"
 	"image/jpeg"
 	"io"
@@ -11,8 +12,10 @@ import (
 	"net/url"
 	"path"
 	"path/filepath"
+	"slices"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/frantjc/momo"
 	"github.com/frantjc/momo/android"
@@ -22,11 +25,13 @@ import (
 	xslice "github.com/frantjc/x/slice"
 	"github.com/go-chi/chi"
 	"github.com/go-chi/chi/middleware"
+	"github.com/google/uuid"
 	swagger "github.com/swaggo/http-swagger/v2"
 	"github.com/timewasted/go-accept-headers"
 	"gocloud.dev/gcerrors"
 	"howett.net/plist"
 	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
@@ -39,12 +44,19 @@ const (
 	paramApp       = `{app:[a-z0-9]([-a-z0-9]*[a-z0-9])?}`
 	paramVersion   = `{version}`
 	paramFile      = `{file}`
+	paramChannel   = `{channel:[a-z0-9]([-a-z0-9]*[a-z0-9])?}`
 )
 
 type Opts struct {
 	Path     string
 	Swagger  bool
 	Fallback http.Handler
+	Buckets  *momoutil.BucketManager
+	// Events, if set, records installs and downloads of apps.
+	Events momoutil.AppEventSink
+	// Client, if set, is used for the requests that momo makes as itself
+	// instead of a client from the kubeconfig.
+	Client client.Client
 }
 
 type Opt interface {
@@ -63,6 +75,15 @@ func (o *Opts) Apply(opts *Opts) {
 			if o.Fallback != nil {
 				opts.Fallback = o.Fallback
 			}
+			if o.Buckets != nil {
+				opts.Buckets = o.Buckets
+			}
+			if o.Events != nil {
+				opts.Events = o.Events
+			}
+			if o.Client != nil {
+				opts.Client = o.Client
+			}
 		}
 	}
 }
@@ -78,11 +99,18 @@ func newOpts(opts ...Opt) *Opts {
 }
 
 type handler struct {
-	Path   string
-	Scheme *runtime.Scheme
+	Path    string
+	Scheme  *runtime.Scheme
+	Buckets *momoutil.BucketManager
+	Events  momoutil.AppEventSink
+	Client  client.Client
 }
 
 func (h *handler) init() error {
+	if h.Buckets == nil {
+		h.Buckets = &momoutil.BucketManager{}
+	}
+
 	if h.Scheme == nil {
 		var err error
 		h.Scheme, err = momoutil.NewScheme(corev1.AddToScheme, momov1alpha1.AddToScheme)
@@ -98,7 +126,7 @@ func NewHandler(opts ...Opt) (http.Handler, error) {
 	o := newOpts(opts...)
 
 	var (
-		h = &handler{Path: o.Path}
+		h = &handler{Path: o.Path, Buckets: o.Buckets, Events: o.Events, Client: o.Client}
 		r = chi.NewRouter()
 	)
 
@@ -140,6 +168,16 @@ func NewHandler(opts ...Opt) (http.Handler, error) {
 			handleErr(h.handleFiles),
 		)
 
+		r.Get(
+			fmt.Sprintf("/%s/splits/%s", paramNamespace, paramApp),
+			handleErr(h.handleSplits),
+		)
+
+		r.Get(
+			fmt.Sprintf("/%s/splits/%s/%s", paramNamespace, paramApp, paramVersion),
+			handleErr(h.handleSplits),
+		)
+
 		r.Get(
 			fmt.Sprintf("/%s/apps", paramNamespace),
 			handleErr(h.handleApps),
@@ -149,6 +187,31 @@ func NewHandler(opts ...Opt) (http.Handler, error) {
 			fmt.Sprintf("/%s/apps/%s", paramNamespace, paramApp),
 			handleErr(h.handleApp),
 		)
+
+		r.Get(
+			fmt.Sprintf("/%s/apps/%s/versions", paramNamespace, paramApp),
+			handleErr(h.handleVersions),
+		)
+
+		r.Get(
+			fmt.Sprintf("/%s/apps/%s/versions/%s/dsyms", paramNamespace, paramApp, paramVersion),
+			handleErr(h.handleDSYMs),
+		)
+
+		r.Get(
+			fmt.Sprintf("/%s/apps/%s/stats", paramNamespace, paramApp),
+			handleErr(h.handleStats),
+		)
+
+		r.Post(
+			fmt.Sprintf("/%s/apps/%s/channels/%s/promote", paramNamespace, paramApp, paramChannel),
+			handleErr(h.handlePromote),
+		)
+
+		r.Post(
+			fmt.Sprintf("/%s/apps/%s/rollback", paramNamespace, paramApp),
+			handleErr(h.handleRollback),
+		)
 	})
 
 	r.NotFound(o.Fallback.ServeHTTP)
@@ -176,7 +239,7 @@ func negotiate(w http.ResponseWriter, r *http.Request, contentType string) error
 		return momoutil.NewHTTPStatusCodeError(err, http.StatusUnsupportedMediaType)
 	}
 
-	w.Header().Set("Vary", "Accept")
+	w.Header().Add("Vary", "Accept")
 
 	// if acceptEncoding := r.Header.Get("Accept-Encoding"); acceptEncoding != "" && xslice.Every([]string{"identity", "*"}, func(s string, _ int) bool {
 	// 	return !strings.Contains(acceptEncoding, s)
@@ -204,6 +267,170 @@ func appFromMobileApp(mobileApp momov1alpha1.MobileApp) *App {
 	return &App{Name: mobileApp.Name}
 }
 
+type Version struct {
+	Name        string          `json:"name,omitempty"`
+	Platform    string          `json:"platform,omitempty"`
+	Version     string          `json:"version,omitempty"`
+	VersionName string          `json:"versionName,omitempty"`
+	BuildNumber string          `json:"buildNumber,omitempty"`
+	UploadTime  *time.Time      `json:"uploadTime,omitempty"`
+	Latest      bool            `json:"latest,omitempty"`
+	Newest      bool            `json:"newest,omitempty"`
+	Pinned      bool            `json:"pinned,omitempty"`
+	Channels    []string        `json:"channels,omitempty"`
+	Release     *Release        `json:"release,omitempty"`
+	Android     *AndroidVersion `json:"android,omitempty"`
+	IOS         *IOSVersion     `json:"ios,omitempty"`
+}
+
+// Release is what went into a build of a version, e.g. its release notes in Markdown.
+type Release struct {
+	Notes    string `json:"notes,omitempty"`
+	Commit   string `json:"commit,omitempty"`
+	Branch   string `json:"branch,omitempty"`
+	BuildURL string `json:"buildURL,omitempty"`
+	Author   string `json:"author,omitempty"`
+}
+
+func releaseFromSpec(release momov1alpha1.Release) *Release {
+	if release == (momov1alpha1.Release{}) {
+		return nil
+	}
+
+	return (*Release)(&release)
+}
+
+type AndroidVersion struct {
+	Package          string       `json:"package,omitempty"`
+	Label            string       `json:"label,omitempty"`
+	MinSDKVersion    int          `json:"minSdkVersion,omitempty"`
+	TargetSDKVersion int          `json:"targetSdkVersion,omitempty"`
+	Permissions      []Permission `json:"permissions,omitempty"`
+	RequiredFeatures []string     `json:"requiredFeatures,omitempty"`
+	Debuggable       bool         `json:"debuggable,omitempty"`
+	TestOnly         bool         `json:"testOnly,omitempty"`
+	SupportedABIs    []string     `json:"supportedABIs,omitempty"`
+	Densities        []string     `json:"densities,omitempty"`
+	Splits           []Split      `json:"splits,omitempty"`
+}
+
+// Split is an APK in a split APK set. A split with no ABI, density
+// or language is the base split of its module.
+type Split struct {
+	Name     string `json:"name,omitempty"`
+	Module   string `json:"module,omitempty"`
+	ABI      string `json:"abi,omitempty"`
+	Density  string `json:"density,omitempty"`
+	Language string `json:"language,omitempty"`
+}
+
+type IOSVersion struct {
+	BundleIdentifier           string   `json:"bundleIdentifier,omitempty"`
+	BundleName                 string   `json:"bundleName,omitempty"`
+	MinimumOSVersion           string   `json:"minimumOSVersion,omitempty"`
+	DeviceFamilies             []string `json:"deviceFamilies,omitempty"`
+	RequiredDeviceCapabilities []string `json:"requiredDeviceCapabilities,omitempty"`
+	SupportedPlatforms         []string `json:"supportedPlatforms,omitempty"`
+	PlatformName               string   `json:"platformName,omitempty"`
+}
+
+// Rollback is the pinning of the version before the latest one of a platform.
+type Rollback struct {
+	Platform string `json:"platform,omitempty"`
+	From     string `json:"from,omitempty"`
+	Version  string `json:"version,omitempty"`
+}
+
+// Promotion is the promotion of a version from one channel to the next.
+type Promotion struct {
+	Version string `json:"version,omitempty"`
+	From    string `json:"from,omitempty"`
+	To      string `json:"to,omitempty"`
+}
+
+type Permission struct {
+	Name      string `json:"name,omitempty"`
+	Dangerous bool   `json:"dangerous,omitempty"`
+}
+
+// channelsServing returns the names of the channels of mobileApp that serve app.
+func channelsServing(mobileApp *momov1alpha1.MobileApp, app momov1alpha1.MobileAppStatusApp) []string {
+	return xslice.Map(
+		xslice.Filter(mobileApp.Spec.Channels, func(channel momov1alpha1.MobileAppSpecChannel, _ int) bool {
+			return (channel.Version == "" && app.Latest) || momoutil.MatchesVersion(app, channel.Version)
+		}),
+		func(channel momov1alpha1.MobileAppSpecChannel, _ int) string {
+			return channel.Name
+		},
+	)
+}
+
+func uploadTime(app momov1alpha1.MobileAppStatusApp) *time.Time {
+	if app.UploadTime == nil {
+		return nil
+	}
+
+	return &app.UploadTime.Time
+}
+
+func versionFromAPK(mobileApp *momov1alpha1.MobileApp, app momov1alpha1.MobileAppStatusApp, apk *momov1alpha1.APK) *Version {
+	return &Version{
+		Name:        app.Name,
+		Platform:    momoutil.PlatformAndroid,
+		Version:     app.Version,
+		VersionName: app.VersionName,
+		BuildNumber: app.BuildNumber,
+		UploadTime:  uploadTime(app),
+		Latest:      app.Latest,
+		Newest:      app.Newest,
+		Pinned:      app.Pinned,
+		Channels:    channelsServing(mobileApp, app),
+		Release:     releaseFromSpec(apk.Spec.Release),
+		Android: &AndroidVersion{
+			Package:          apk.Status.Package,
+			Label:            apk.Status.Label,
+			MinSDKVersion:    apk.Status.MinSDKVersion,
+			TargetSDKVersion: apk.Status.TargetSDKVersion,
+			Permissions: xslice.Map(apk.Status.Permissions, func(permission momov1alpha1.APKPermission, _ int) Permission {
+				return Permission{Name: permission.Name, Dangerous: permission.Dangerous}
+			}),
+			RequiredFeatures: apk.Status.RequiredFeatures,
+			Debuggable:       apk.Status.Debuggable,
+			TestOnly:         apk.Status.TestOnly,
+			SupportedABIs:    apk.Status.SupportedABIs,
+			Densities:        apk.Status.Densities,
+			Splits: xslice.Map(apk.Status.Splits, func(split momov1alpha1.APKSplit, _ int) Split {
+				return Split(split)
+			}),
+		},
+	}
+}
+
+func versionFromIPA(mobileApp *momov1alpha1.MobileApp, app momov1alpha1.MobileAppStatusApp, ipa *momov1alpha1.IPA) *Version {
+	return &Version{
+		Name:        app.Name,
+		Platform:    momoutil.PlatformIOS,
+		Version:     app.Version,
+		VersionName: app.VersionName,
+		BuildNumber: app.BuildNumber,
+		UploadTime:  uploadTime(app),
+		Latest:      app.Latest,
+		Newest:      app.Newest,
+		Pinned:      app.Pinned,
+		Channels:    channelsServing(mobileApp, app),
+		Release:     releaseFromSpec(ipa.Spec.Release),
+		IOS: &IOSVersion{
+			BundleIdentifier:           ipa.Status.BundleIdentifier,
+			BundleName:                 ipa.Status.BundleName,
+			MinimumOSVersion:           ipa.Status.MinimumOSVersion,
+			DeviceFamilies:             ipa.Status.DeviceFamilies,
+			RequiredDeviceCapabilities: ipa.Status.RequiredDeviceCapabilities,
+			SupportedPlatforms:         ipa.Status.SupportedPlatforms,
+			PlatformName:               ipa.Status.PlatformName,
+		},
+	}
+}
+
 // @Summary	Upload a mobile app
 // @Tags		upload
 // @Accept		multipart/form-data
@@ -211,12 +438,21 @@ func appFromMobileApp(mobileApp momov1alpha1.MobileApp) *App {
 // @Accept		application/x-tar
 // @Accept		application/octet-stream
 // @Accept		application/vnd.android.package-archive
+// @Accept		application/x-android-app-bundle
+// @Accept		application/x-apks
+// @Accept		application/x-xapk
 // @Accept		application/gzip
 // @Accept		application/x-gtar
 // @Accept		application/x-tgz
 // @Param		namespace	path		string	true	"Namespace"
 // @Param		bucket		path		string	true	"Bucket"
 // @Param		app			path		string	true	"App"
+// @Param		kind		query		string	false	"Kind of the Bucket, Bucket or ClusterBucket"
+// @Param		X-Release-Notes	header	string	false	"Percent-encoded release notes in Markdown, or the notes form field"
+// @Param		X-Commit	header		string	false	"Commit SHA that the app was built from, or the commit form field"
+// @Param		X-Branch	header		string	false	"Branch that the app was built from, or the branch form field"
+// @Param		X-Build-URL	header		string	false	"URL of the CI build that built the app, or the buildURL form field"
+// @Param		X-Author	header		string	false	"Author of the build, or the author form field"
 // @Success	201			{object}	App
 // @Success	307
 // @Failure	406	{object}	Error
@@ -224,27 +460,61 @@ func appFromMobileApp(mobileApp momov1alpha1.MobileApp) *App {
 // @Failure	500	{object}	Error
 // @Router		/{namespace}/uploads/{bucket}/{app} [post]
 func (h *handler) handleUpload(w http.ResponseWriter, r *http.Request) error {
+	start := time.Now()
+
 	cli, err := h.newClient(r)
 	if err != nil {
 		return err
 	}
 
-	rc, mediaType, err := reqToApp(r)
+	app, mediaType, err := reqToApp(r)
 	if err != nil {
 		return err
 	}
 	defer func() {
-		_ = rc.Close()
+		_ = app.Close()
 	}()
 
 	var (
-		ctx        = r.Context()
-		namespace  = chi.URLParam(r, "namespace")
-		bucketName = chi.URLParam(r, "bucket")
-		appName    = chi.URLParam(r, "app")
+		ctx       = r.Context()
+		namespace = chi.URLParam(r, "namespace")
+		bucketRef = momov1alpha1.BucketReference{
+			Kind: xslice.Coalesce(r.URL.Query().Get("kind"), momov1alpha1.KindBucket),
+			Name: chi.URLParam(r, "bucket"),
+		}
+		appName  = chi.URLParam(r, "app")
+		body     = &momoutil.CountingReader{Reader: app}
+		platform string
+		code     = http.StatusCreated
 	)
 
-	if err := momoutil.UploadApp(ctx, cli, namespace, appName, bucketName, mediaType, rc); err != nil {
+	switch mediaType {
+	case android.ContentTypeAPK, android.ContentTypeAAB, android.ContentTypeAPKS, android.ContentTypeXAPK:
+		platform = momoutil.PlatformAndroid
+	case ios.ContentTypeIPA:
+		platform = momoutil.PlatformIOS
+	}
+
+	opts := []momoutil.UploadAppOpt{momoutil.WithRelease(app.Release)}
+	if app.DSYMs != nil {
+		opts = append(opts, momoutil.WithDSYMs(app.DSYMs))
+	}
+
+	if bucketRef.IsClusterBucket() {
+		serverCli, err := h.newClient(nil)
+		if err != nil {
+			return err
+		}
+
+		opts = append(opts, momoutil.WithClusterBucketClient(serverCli))
+	}
+
+	err = momoutil.UploadApp(ctx, cli, h.Buckets, namespace, appName, bucketRef, mediaType, body, opts...)
+	if err != nil {
+		code = momoutil.HTTPStatusCode(err)
+	}
+	momoutil.ObserveUpload(platform, code, body.N, start)
+	if err != nil {
 		return err
 	}
 
@@ -274,9 +544,11 @@ func (h *handler) handleUpload(w http.ResponseWriter, r *http.Request) error {
 // @Produce	json
 // @Param		namespace	path	string	true	"Namespace"
 // @Param		app			path	string	true	"App"
-// @Param		version		path	string	false	"Version"
+// @Param		version		path	string	false	"Version or channel"
+// @Param		device		query	string	false	"ID of the device, used to bucket it into a rollout"
 // @Success	301
 // @Failure	404	{object}	Error
+// @Failure	422	{object}	Error
 // @Failure	500	{object}	Error
 // @Router		/{namespace}/install/{app} [get]
 // @Router		/{namespace}/install/{app}/{version} [get]
@@ -298,6 +570,26 @@ func (h *handler) handleInstall(w http.ResponseWriter, r *http.Request) error {
 		return err
 	}
 
+	version, resolved := resolveVersion(w, r, mobileApp, version, momoutil.PlatformIOS)
+
+	app := findStatusApp(mobileApp.Status.IPAs, version)
+
+	// Check that the requesting device can run the IPA before telling it to install it,
+	// because iOS does not say why if it cannot and instead silently fails the install.
+	if device := ios.ParseUserAgent(r.UserAgent()); device != nil {
+		if app.Name != "" {
+			ipa := &momov1alpha1.IPA{}
+
+			if err := cli.Get(ctx, client.ObjectKey{Namespace: namespace, Name: app.Name}, ipa); err != nil {
+				return err
+			}
+
+			if reasons := device.Incompatibilities(ipa.Status.PlatformName, ipa.Status.MinimumOSVersion, ipa.Status.DeviceFamilies); len(reasons) > 0 {
+				return respondIncompatible(w, r, appName, app.Version, reasons)
+			}
+		}
+	}
+
 	baseURL, err := urlFromReq(r)
 	if err != nil {
 		return err
@@ -311,14 +603,184 @@ func (h *handler) handleInstall(w http.ResponseWriter, r *http.Request) error {
 		values.Add("url", baseURL.JoinPath("/", h.Path, namespace, "files", appName, version, momo.FileManifestPlist).String())
 	}
 
+	h.recordAppEvent(ctx, r, momoutil.AppEventInstall, namespace, appName, app.Version, momoutil.PlatformIOS)
+
+	code := http.StatusMovedPermanently
+	if resolved {
+		// The channel or rollout may serve another version by the next install.
+		w.Header().Set("Cache-Control", "no-store")
+		code = http.StatusFound
+	}
+
 	http.Redirect(w, r,
 		(&url.URL{Scheme: ios.SchemeITMSServices, RawQuery: values.Encode()}).String(),
-		http.StatusMovedPermanently,
+		code,
 	)
 
 	return nil
 }
 
+var (
+	incompatibleTemplate = template.Must(template.New("incompatible.html").Parse(incompatibleHTML))
+)
+
+// respondIncompatible responds with a page explaining why the app cannot be
+// installed on the requesting device, or with an Error if HTML is not accepted.
+func respondIncompatible(w http.ResponseWriter, r *http.Request, appName, version string, reasons []string) error {
+	if _, err := accept.Negotiate(r.Header.Get("Accept"), "text/html"); err != nil {
+		return momoutil.NewHTTPStatusCodeError(
+			fmt.Errorf("%s cannot be installed on this device: %s", appName, strings.Join(reasons, " ")),
+			http.StatusUnprocessableEntity,
+		)
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Vary", "Accept, User-Agent")
+	w.WriteHeader(http.StatusUnprocessableEntity)
+
+	return incompatibleTemplate.Execute(w, map[string]any{
+		"App":     appName,
+		"Version": version,
+		"Reasons": reasons,
+	})
+}
+
+// resolveVersion returns the version that the channel of mobileApp named
+// version serves, if there is such a channel, or else version itself. If
+// that is the latest version and none is pinned for platform, it returns
+// the version that mobileApp's rollout serves to the requesting device,
+// if it has one in progress. It also reports whether the version was resolved
+// by a channel or rollout, which may resolve it differently later, in which case
+// the response is marked to be revalidated, and privately if it depends on the device.
+func resolveVersion(w http.ResponseWriter, r *http.Request, mobileApp *momov1alpha1.MobileApp, version, platform string) (string, bool) {
+	resolved := false
+	if channelVersion, ok := momoutil.ChannelVersion(mobileApp, version); ok {
+		version = channelVersion
+		resolved = true
+		w.Header().Set("Cache-Control", "no-cache")
+	}
+
+	if version == "" && mobileApp.Status.Rollout != nil && momoutil.PinnedVersion(mobileApp, platform) == "" {
+		if !slices.Contains(w.Header().Values("Vary"), "Cookie") {
+			w.Header().Add("Vary", "Cookie")
+			w.Header().Add("Vary", "X-Device-ID")
+		}
+		w.Header().Set("Cache-Control", "private, no-cache")
+
+		return momoutil.RolloutVersion(mobileApp, deviceIDFromReq(w, r), platform), true
+	}
+
+	return version, resolved
+}
+
+const (
+	cookieDevice = "momo-device"
+)
+
+// deviceIDFromReq returns the ID of the requesting device, either by the device
+// query parameter, the X-Device-ID header or a cookie. If there is none, it makes
+// one up and sets the cookie to it so that the device keeps getting the same one.
+func deviceIDFromReq(w http.ResponseWriter, r *http.Request) string {
+	if id := r.URL.Query().Get("device"); id != "" {
+		return id
+	}
+
+	if id := r.Header.Get("X-Device-ID"); id != "" {
+		return id
+	}
+
+	if cookie, err := r.Cookie(cookieDevice); err == nil && cookie.Value != "" {
+		return cookie.Value
+	}
+
+	cookie := &http.Cookie{
+		Name:     cookieDevice,
+		Value:    uuid.NewString(),
+		Path:     "/",
+		MaxAge:   int((time.Hour * 24 * 365).Seconds()),
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	}
+
+	http.SetCookie(w, cookie)
+	// So that the same ID is gotten again for the rest of the request.
+	r.AddCookie(cookie)
+
+	return cookie.Value
+}
+
+// findStatusApp finds the newest app with the given version, version name or build
+// number, or the latest one if version is empty, falling back to the newest app if
+// there is no such one. It relies on the MobileApp's status keeping apps newest first.
+func findStatusApp(apps []momov1alpha1.MobileAppStatusApp, version string) momov1alpha1.MobileAppStatusApp {
+	find := func(app momov1alpha1.MobileAppStatusApp) bool {
+		return app.Latest
+	}
+	if version != "" {
+		find = func(app momov1alpha1.MobileAppStatusApp) bool {
+			return momoutil.MatchesVersion(app, version)
+		}
+	}
+
+	if i := slices.IndexFunc(apps, find); i >= 0 {
+		return apps[i]
+	} else if len(apps) > 0 {
+		return apps[0]
+	}
+
+	return momov1alpha1.MobileAppStatusApp{}
+}
+
+// findAPK finds the APK like findStatusApp does, but then prefers
+// the APK of the same version that supports the given ABI, if any.
+func findAPK(apks []momov1alpha1.MobileAppStatusApp, version, abi string) momov1alpha1.MobileAppStatusApp {
+	apk := findStatusApp(apks, version)
+	if abi == "" || len(apk.SupportedABIs) == 0 || xslice.Includes(apk.SupportedABIs, abi) {
+		return apk
+	}
+
+	if i := slices.IndexFunc(apks, func(app momov1alpha1.MobileAppStatusApp) bool {
+		return momoutil.SameBuild(app, apk) && xslice.Includes(app.SupportedABIs, abi)
+	}); i >= 0 {
+		return apks[i]
+	}
+
+	// An APK without native libraries runs on any ABI.
+	if i := slices.IndexFunc(apks, func(app momov1alpha1.MobileAppStatusApp) bool {
+		return momoutil.SameBuild(app, apk) && len(app.SupportedABIs) == 0
+	}); i >= 0 {
+		return apks[i]
+	}
+
+	return apk
+}
+
+// abiFromReq returns the Android ABI that the request asks for, either by the abi
+// query parameter or by the Sec-CH-UA-Arch and Sec-CH-UA-Bitness client hints.
+func abiFromReq(r *http.Request) string {
+	if abi := r.URL.Query().Get("abi"); abi != "" {
+		return abi
+	}
+
+	var (
+		arch    = strings.Trim(r.Header.Get("Sec-CH-UA-Arch"), `"`)
+		bitness = strings.Trim(r.Header.Get("Sec-CH-UA-Bitness"), `"`)
+	)
+
+	switch {
+	case arch == "arm" && bitness == "64":
+		return "arm64-v8a"
+	case arch == "arm":
+		return "armeabi-v7a"
+	case arch == "x86" && bitness == "64":
+		return "x86_64"
+	case arch == "x86":
+		return "x86"
+	}
+
+	return ""
+}
+
 func (h *handler) handleFiles(w http.ResponseWriter, r *http.Request) error {
 	var (
 		ctx       = r.Context()
@@ -330,6 +792,10 @@ func (h *handler) handleFiles(w http.ResponseWriter, r *http.Request) error {
 		ext       = filepath.Ext(file)
 	)
 
+	// Ask browsers for the client hints that abiFromReq uses.
+	w.Header().Set("Accept-CH", "Sec-CH-UA-Arch, Sec-CH-UA-Bitness")
+	w.Header().Add("Vary", "Sec-CH-UA-Arch, Sec-CH-UA-Bitness")
+
 	cli, err := h.newClient(nil)
 	if err != nil {
 		return err
@@ -340,62 +806,72 @@ func (h *handler) handleFiles(w http.ResponseWriter, r *http.Request) error {
 	}
 
 	var (
-		findAny = func(app momov1alpha1.MobileAppStatusApp, _ int) bool {
-			return true
-		}
-		findLatest = func(app momov1alpha1.MobileAppStatusApp, _ int) bool {
-			return app.Latest
-		}
-		find = findLatest
-	)
-	if version != "" {
-		find = func(app momov1alpha1.MobileAppStatusApp, _ int) bool {
-			return strings.EqualFold(app.Version, version)
-		}
-	}
-
-	var (
-		ipa = xslice.Coalesce(
-			xslice.Find(mobileApp.Status.IPAs, find),
-			xslice.Find(mobileApp.Status.IPAs, findAny),
-		)
-		apk = xslice.Coalesce(
-			xslice.Find(mobileApp.Status.APKs, find),
-			xslice.Find(mobileApp.Status.APKs, findAny),
-		)
-		key         string
-		bucketName  string
-		contentType string
+		ipaVersion, _ = resolveVersion(w, r, mobileApp, version, momoutil.PlatformIOS)
+		apkVersion, _ = resolveVersion(w, r, mobileApp, version, momoutil.PlatformAndroid)
+		ipa           = findStatusApp(mobileApp.Status.IPAs, ipaVersion)
+		apk           = findAPK(mobileApp.Status.APKs, apkVersion, abiFromReq(r))
+		key           string
+		bucketRef     momov1alpha1.BucketReference
+		contentType   string
 	)
 	switch ext {
 	case momo.ExtAPK:
 		if apk.Key == "" {
 			return fmt.Errorf("app does not have an %s", momo.ExtAPK)
+		} else if !strings.EqualFold(filepath.Ext(apk.Key), momo.ExtAPK) {
+			// A split APK set without a universal APK cannot be served as one.
+			return momoutil.NewHTTPStatusCodeError(
+				fmt.Errorf("app is a split APK set, get its splits from /%s/splits/%s instead", namespace, appName),
+				http.StatusNotFound,
+			)
 		}
 		key = apk.Key
-		bucketName = apk.Bucket.Name
+		bucketRef = apk.Bucket
 		contentType = android.ContentTypeAPK
+	case momo.ExtAAB, momo.ExtAPKS, momo.ExtXAPK:
+		// The APK's own object, e.g. for installers of split APK sets like SAI.
+		if apk.Name == "" {
+			return fmt.Errorf("app does not have an %s", ext)
+		}
+
+		cr := &momov1alpha1.APK{}
+
+		if err = cli.Get(ctx, client.ObjectKey{Namespace: namespace, Name: apk.Name}, cr); err != nil {
+			return err
+		}
+
+		if !strings.EqualFold(filepath.Ext(cr.Spec.Key), ext) {
+			return fmt.Errorf("app does not have an %s", ext)
+		}
+
+		key = cr.Spec.Key
+		bucketRef = cr.Spec.Bucket
+		contentType = map[string]string{
+			momo.ExtAAB:  android.ContentTypeAAB,
+			momo.ExtAPKS: android.ContentTypeAPKS,
+			momo.ExtXAPK: android.ContentTypeXAPK,
+		}[ext]
 	case momo.ExtIPA:
 		if ipa.Key == "" {
 			return fmt.Errorf("app does not have an %s", momo.ExtIPA)
 		}
 		key = ipa.Key
-		bucketName = ipa.Bucket.Name
+		bucketRef = ipa.Bucket
 		contentType = ios.ContentTypeIPA
 	case momo.ExtPNG, momo.ExtJPG, momo.ExtJPEG:
-		type iconAndBucketName struct {
-			icon       momov1alpha1.AppStatusIcon
-			bucketName string
+		type iconAndBucketRef struct {
+			icon      momov1alpha1.AppStatusIcon
+			bucketRef momov1alpha1.BucketReference
 		}
 
 		var (
-			findAny = func(_ iconAndBucketName, _ int) bool {
+			findAny = func(_ iconAndBucketRef, _ int) bool {
 				return true
 			}
-			find = func(icon iconAndBucketName, _ int) bool {
+			find = func(icon iconAndBucketRef, _ int) bool {
 				return strings.TrimSuffix(filepath.Base(icon.icon.Key), filepath.Ext(icon.icon.Key)) == strings.TrimSuffix(filepath.Base(file), filepath.Ext(file))
 			}
-			icons = []iconAndBucketName{}
+			icons = []iconAndBucketRef{}
 		)
 
 		switch ext {
@@ -406,11 +882,11 @@ func (h *handler) handleFiles(w http.ResponseWriter, r *http.Request) error {
 
 			switch file {
 			case momo.FileDisplayIcon:
-				find = func(icon iconAndBucketName, _ int) bool {
+				find = func(icon iconAndBucketRef, _ int) bool {
 					return icon.icon.Display
 				}
 			case momo.FileFullSizeIcon:
-				find = func(icon iconAndBucketName, _ int) bool {
+				find = func(icon iconAndBucketRef, _ int) bool {
 					return icon.icon.FullSize
 				}
 			}
@@ -423,8 +899,8 @@ func (h *handler) handleFiles(w http.ResponseWriter, r *http.Request) error {
 				return err
 			}
 
-			icons = append(icons, xslice.Map(cr.Status.Icons, func(icon momov1alpha1.AppStatusIcon, _ int) iconAndBucketName {
-				return iconAndBucketName{icon: icon, bucketName: cr.Spec.Bucket.Name}
+			icons = append(icons, xslice.Map(cr.Status.Icons, func(icon momov1alpha1.AppStatusIcon, _ int) iconAndBucketRef {
+				return iconAndBucketRef{icon: icon, bucketRef: cr.Spec.Bucket}
 			})...)
 		}
 
@@ -435,8 +911,8 @@ func (h *handler) handleFiles(w http.ResponseWriter, r *http.Request) error {
 				return err
 			}
 
-			icons = append(icons, xslice.Map(cr.Status.Icons, func(icon momov1alpha1.AppStatusIcon, _ int) iconAndBucketName {
-				return iconAndBucketName{icon: icon, bucketName: cr.Spec.Bucket.Name}
+			icons = append(icons, xslice.Map(cr.Status.Icons, func(icon momov1alpha1.AppStatusIcon, _ int) iconAndBucketRef {
+				return iconAndBucketRef{icon: icon, bucketRef: cr.Spec.Bucket}
 			})...)
 		}
 
@@ -446,7 +922,7 @@ func (h *handler) handleFiles(w http.ResponseWriter, r *http.Request) error {
 				xslice.Find(icons, findAny),
 			)
 		key = icon.icon.Key
-		bucketName = icon.bucketName
+		bucketRef = icon.bucketRef
 	default:
 		if file == momo.FileManifestPlist {
 			if ipa.Key == "" {
@@ -459,7 +935,7 @@ func (h *handler) handleFiles(w http.ResponseWriter, r *http.Request) error {
 				return err
 			}
 
-			if cr.Status.BundleIdentifier == "" || cr.Status.BundleName == "" || cr.Status.Version == "" {
+			if cr.Status.BundleIdentifier == "" || cr.Status.BundleName == "" || xslice.Coalesce(cr.Status.VersionName, cr.Status.Version) == "" {
 				return momoutil.NewHTTPStatusCodeError(fmt.Errorf("mobileapp is in phase %s", mobileApp.Status.Phase), http.StatusPreconditionFailed)
 			}
 
@@ -483,22 +959,22 @@ func (h *handler) handleFiles(w http.ResponseWriter, r *http.Request) error {
 						Assets: []ios.ManifestItemAsset{
 							{
 								Kind: "software-package",
-								URL:  baseURL.JoinPath("/", h.Path, namespace, "files", appName, version, strings.ToLower(fmt.Sprintf("%s%s", cr.Status.BundleName, momo.ExtIPA))).String(),
+								URL:  baseURL.JoinPath("/", h.Path, namespace, "files", appName, ipa.Version, strings.ToLower(fmt.Sprintf("%s%s", cr.Status.BundleName, momo.ExtIPA))).String(),
 							},
 							{
 								Kind: "full-size-image",
-								URL:  baseURL.JoinPath("/", h.Path, namespace, "files", appName, version, momo.FileFullSizeIcon).String(),
+								URL:  baseURL.JoinPath("/", h.Path, namespace, "files", appName, ipa.Version, momo.FileFullSizeIcon).String(),
 							},
 							{
 								Kind: "display-image",
-								URL:  baseURL.JoinPath("/", h.Path, namespace, "files", appName, version, momo.FileDisplayIcon).String(),
+								URL:  baseURL.JoinPath("/", h.Path, namespace, "files", appName, ipa.Version, momo.FileDisplayIcon).String(),
 							},
 						},
 						Metadata: &ios.ManifestItemMetadata{
 							Kind:               "software",
 							PlatformIdentifier: "com.apple.platform.iphoneos",
 							BundleIdentifier:   cr.Status.BundleIdentifier,
-							BundleVersion:      cr.Status.Version,
+							BundleVersion:      xslice.Coalesce(cr.Status.VersionName, cr.Status.Version),
 							Title:              cr.Status.BundleName,
 						},
 					},
@@ -507,6 +983,8 @@ func (h *handler) handleFiles(w http.ResponseWriter, r *http.Request) error {
 				return err
 			}
 
+			h.recordAppEvent(ctx, r, momoutil.AppEventManifest, namespace, appName, ipa.Version, momoutil.PlatformIOS)
+
 			return nil
 		}
 
@@ -515,21 +993,25 @@ func (h *handler) handleFiles(w http.ResponseWriter, r *http.Request) error {
 		return nil
 	}
 
-	bucket, err := momoutil.GetBucket(ctx, cli, client.ObjectKey{Namespace: namespace, Name: bucketName})
+	bucket, err := momoutil.GetBucketReference(ctx, cli, namespace, bucketRef)
 	if err != nil {
 		return err
 	}
 
-	b, err := momoutil.OpenBucket(ctx, cli, bucket)
+	b, err := h.Buckets.OpenBucketFor(ctx, cli, bucket, namespace)
 	if err != nil {
 		return err
 	}
+	defer func() {
+		_ = b.Close()
+	}()
 
 	if err := negotiate(w, r, contentType); err != nil {
 		return err
 	}
 
 	rc, err := b.NewReader(ctx, key, nil)
+	_ = momoutil.ObserveBucketError(b.Driver, "read", err)
 	if gcerrors.Code(err) == gcerrors.NotFound || rc == nil {
 		switch ext {
 		case momo.ExtPNG:
@@ -591,11 +1073,22 @@ func (h *handler) handleFiles(w http.ResponseWriter, r *http.Request) error {
 		return nil
 	}
 
-	if _, err := io.Copy(w, rc); err != nil {
-		return err
+	n, err := io.Copy(w, rc)
+
+	switch ext {
+	case momo.ExtAPK, momo.ExtAAB, momo.ExtAPKS, momo.ExtXAPK:
+		momoutil.ObserveDownload(namespace, appName, momoutil.PlatformAndroid, n)
+		if err == nil {
+			h.recordAppEvent(ctx, r, momoutil.AppEventDownload, namespace, appName, apk.Version, momoutil.PlatformAndroid)
+		}
+	case momo.ExtIPA:
+		momoutil.ObserveDownload(namespace, appName, momoutil.PlatformIOS, n)
+		if err == nil {
+			h.recordAppEvent(ctx, r, momoutil.AppEventDownload, namespace, appName, ipa.Version, momoutil.PlatformIOS)
+		}
 	}
 
-	return nil
+	return err
 }
 
 // @Summary	List apps
@@ -677,6 +1170,220 @@ func (h *handler) handleApp(w http.ResponseWriter, r *http.Request) error {
 	return nil
 }
 
+// @Summary	List the versions of an app
+// @Tags		apps
+// @Produce	json
+// @Param		namespace	path		string	true	"Namespace"
+// @Param		app			path		string	true	"App"
+// @Success	200			{object}	[]Version
+// @Failure	404			{object}	Error
+// @Failure	500			{object}	Error
+// @Router		/{namespace}/apps/{app}/versions [get]
+func (h *handler) handleVersions(w http.ResponseWriter, r *http.Request) error {
+	cli, err := h.newClient(nil)
+	if err != nil {
+		return err
+	}
+
+	var (
+		ctx       = r.Context()
+		namespace = chi.URLParam(r, "namespace")
+		appName   = chi.URLParam(r, "app")
+		mobileApp = &momov1alpha1.MobileApp{}
+		versions  = []Version{}
+	)
+
+	if err := cli.Get(ctx, client.ObjectKey{Namespace: namespace, Name: appName}, mobileApp); err != nil {
+		return err
+	}
+
+	for _, app := range mobileApp.Status.APKs {
+		apk := &momov1alpha1.APK{}
+
+		if err := cli.Get(ctx, client.ObjectKey{Namespace: namespace, Name: app.Name}, apk); err != nil {
+			if apierrors.IsNotFound(err) {
+				continue
+			}
+
+			return err
+		}
+
+		versions = append(versions, *versionFromAPK(mobileApp, app, apk))
+	}
+
+	for _, app := range mobileApp.Status.IPAs {
+		ipa := &momov1alpha1.IPA{}
+
+		if err := cli.Get(ctx, client.ObjectKey{Namespace: namespace, Name: app.Name}, ipa); err != nil {
+			if apierrors.IsNotFound(err) {
+				continue
+			}
+
+			return err
+		}
+
+		versions = append(versions, *versionFromIPA(mobileApp, app, ipa))
+	}
+
+	return respondJSON(w, r, versions)
+}
+
+// @Summary	Download the dSYMs of a version of an app
+// @Tags		apps
+// @Produce	application/zip
+// @Param		namespace	path		string	true	"Namespace"
+// @Param		app			path		string	true	"App"
+// @Param		version		path		string	true	"Version"
+// @Success	200			{file}		binary
+// @Failure	404			{object}	Error
+// @Failure	500			{object}	Error
+// @Router		/{namespace}/apps/{app}/versions/{version}/dsyms [get]
+func (h *handler) handleDSYMs(w http.ResponseWriter, r *http.Request) error {
+	// Unlike the files that apps are installed from, dSYMs are
+	// only served to those who can get the IPA that they belong to.
+	cli, err := h.newClient(r)
+	if err != nil {
+		return err
+	}
+
+	var (
+		ctx       = r.Context()
+		namespace = chi.URLParam(r, "namespace")
+		appName   = chi.URLParam(r, "app")
+		version   = chi.URLParam(r, "version")
+		mobileApp = &momov1alpha1.MobileApp{}
+		ipa       = &momov1alpha1.IPA{}
+	)
+
+	if err := cli.Get(ctx, client.ObjectKey{Namespace: namespace, Name: appName}, mobileApp); err != nil {
+		return err
+	}
+
+	app := findStatusApp(mobileApp.Status.IPAs, version)
+	if app.Name == "" || !momoutil.MatchesVersion(app, version) {
+		return momoutil.NewHTTPStatusCodeError(fmt.Errorf("app does not have an %s of version %s", momo.ExtIPA, version), http.StatusNotFound)
+	}
+
+	if err := cli.Get(ctx, client.ObjectKey{Namespace: namespace, Name: app.Name}, ipa); err != nil {
+		return err
+	}
+
+	if ipa.Spec.DSYMsKey == "" {
+		return momoutil.NewHTTPStatusCodeError(fmt.Errorf("app does not have dSYMs"), http.StatusNotFound)
+	}
+
+	// Getting the IPA authorizes the caller in its namespace, so a
+	// ClusterBucket, which tenants cannot get, is opened by momo itself.
+	bucketCli := cli
+	if ipa.Spec.Bucket.IsClusterBucket() {
+		if bucketCli, err = h.newClient(nil); err != nil {
+			return err
+		}
+	}
+
+	bucket, err := momoutil.GetBucketReference(ctx, bucketCli, namespace, ipa.Spec.Bucket)
+	if err != nil {
+		return err
+	}
+
+	b, err := h.Buckets.OpenBucketFor(ctx, bucketCli, bucket, namespace)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = b.Close()
+	}()
+
+	if err := negotiate(w, r, "application/zip"); err != nil {
+		return err
+	}
+
+	rc, err := b.NewReader(ctx, ipa.Spec.DSYMsKey, nil)
+	_ = momoutil.ObserveBucketError(b.Driver, "read", err)
+	if gcerrors.Code(err) == gcerrors.NotFound {
+		return momoutil.NewHTTPStatusCodeError(err, http.StatusNotFound)
+	} else if err != nil {
+		return err
+	}
+	defer func() {
+		_ = rc.Close()
+	}()
+
+	_, err = io.Copy(w, rc)
+
+	return err
+}
+
+// @Summary	Promote the version of a channel to the next channel
+// @Tags		apps
+// @Produce	json
+// @Param		namespace	path		string	true	"Namespace"
+// @Param		app			path		string	true	"App"
+// @Param		channel		path		string	true	"Channel"
+// @Success	200			{object}	Promotion
+// @Failure	404			{object}	Error
+// @Failure	412			{object}	Error
+// @Failure	422			{object}	Error
+// @Failure	500			{object}	Error
+// @Router		/{namespace}/apps/{app}/channels/{channel}/promote [post]
+func (h *handler) handlePromote(w http.ResponseWriter, r *http.Request) error {
+	cli, err := h.newClient(r)
+	if err != nil {
+		return err
+	}
+
+	var (
+		namespace = chi.URLParam(r, "namespace")
+		appName   = chi.URLParam(r, "app")
+		channel   = chi.URLParam(r, "channel")
+	)
+
+	promotion, err := momoutil.PromoteChannel(r.Context(), cli, client.ObjectKey{Namespace: namespace, Name: appName}, channel)
+	if err != nil {
+		return err
+	}
+
+	return respondJSON(w, r, &Promotion{
+		Version: promotion.Version,
+		From:    promotion.From,
+		To:      promotion.To,
+	})
+}
+
+// @Summary	Roll back an app by pinning the version before its latest one
+// @Tags		apps
+// @Produce	json
+// @Param		namespace	path		string		true	"Namespace"
+// @Param		app			path		string		true	"App"
+// @Param		platform	query		[]string	false	"Platforms to roll back, android or ios, default all"
+// @Success	200			{object}	[]Rollback
+// @Failure	400			{object}	Error
+// @Failure	404			{object}	Error
+// @Failure	412			{object}	Error
+// @Failure	500			{object}	Error
+// @Router		/{namespace}/apps/{app}/rollback [post]
+func (h *handler) handleRollback(w http.ResponseWriter, r *http.Request) error {
+	cli, err := h.newClient(r)
+	if err != nil {
+		return err
+	}
+
+	var (
+		namespace = chi.URLParam(r, "namespace")
+		appName   = chi.URLParam(r, "app")
+		platforms = r.URL.Query()["platform"]
+	)
+
+	rollbacks, err := momoutil.RollBack(r.Context(), cli, client.ObjectKey{Namespace: namespace, Name: appName}, platforms...)
+	if err != nil {
+		return err
+	}
+
+	return respondJSON(w, r, xslice.Map(rollbacks, func(rollback momoutil.Rollback, _ int) Rollback {
+		return Rollback(rollback)
+	}))
+}
+
 func urlFromReq(r *http.Request) (*url.URL, error) {
 	if origin := r.Header.Get("Origin"); origin != "" {
 		return url.Parse(origin)
@@ -744,6 +1451,10 @@ func (h *handler) newClient(r *http.Request) (client.Client, error) {
 		return nil, err
 	}
 
+	if r == nil && h.Client != nil {
+		return h.Client, nil
+	}
+
 	cfg, err := config.GetConfig()
 	if err != nil {
 		return nil, err
@@ -0,0 +1,82 @@
+package api_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+
+	momov1alpha1 "github.com/frantjc/momo/api/v1alpha1"
+	"github.com/frantjc/momo/internal/api"
+	"github.com/frantjc/momo/internal/momoutil"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestHandleInstallCaching(t *testing.T) {
+	scheme, err := momoutil.NewScheme(corev1.AddToScheme, momov1alpha1.AddToScheme)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var (
+		ipas = []momov1alpha1.MobileAppStatusApp{
+			{Name: "app-new", Version: "v1.1.0", Latest: true, Newest: true},
+			{Name: "app-old", Version: "v1.0.0"},
+		}
+		mobileApp = &momov1alpha1.MobileApp{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "app"},
+			Spec: momov1alpha1.MobileAppSpec{
+				Channels: []momov1alpha1.MobileAppSpecChannel{{Name: "beta", Version: "v1.1.0"}},
+			},
+			Status: momov1alpha1.MobileAppStatus{IPAs: ipas},
+		}
+		rollingOut = &momov1alpha1.MobileApp{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "rolling-out"},
+			Status: momov1alpha1.MobileAppStatus{
+				IPAs:    ipas,
+				Rollout: &momov1alpha1.MobileAppStatusRollout{Version: "v1.1.0", PreviousVersions: momov1alpha1.MobileAppStatusRolloutPreviousVersions{IOS: "v1.0.0"}, Percentage: 50},
+			},
+		}
+		cli = fake.NewClientBuilder().WithScheme(scheme).WithObjects(mobileApp, rollingOut).Build()
+	)
+
+	handler, err := api.NewHandler(&api.Opts{Client: cli})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		path         string
+		code         int
+		cacheControl string
+		varyCookie   bool
+	}{
+		{path: "/default/install/app", code: http.StatusMovedPermanently},
+		{path: "/default/install/app/v1.0.0", code: http.StatusMovedPermanently},
+		{path: "/default/install/app/beta", code: http.StatusFound, cacheControl: "no-store"},
+		{path: "/default/install/rolling-out", code: http.StatusFound, cacheControl: "no-store", varyCookie: true},
+	} {
+		t.Run(tc.path, func(t *testing.T) {
+			var (
+				rec = httptest.NewRecorder()
+				req = httptest.NewRequest(http.MethodGet, tc.path, nil)
+			)
+
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tc.code {
+				t.Fatalf("expected %d, got %d: %s", tc.code, rec.Code, rec.Body.String())
+			}
+
+			if cacheControl := rec.Header().Get("Cache-Control"); cacheControl != tc.cacheControl {
+				t.Errorf("expected Cache-Control %q, got %q", tc.cacheControl, cacheControl)
+			}
+
+			if varyCookie := slices.Contains(rec.Header().Values("Vary"), "Cookie"); varyCookie != tc.varyCookie {
+				t.Errorf("expected Vary to include Cookie: %t, got %v", tc.varyCookie, rec.Header().Values("Vary"))
+			}
+		})
+	}
+}
@@ -0,0 +1,157 @@
+package api
+
+import (
+	"archive/zip"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/frantjc/momo"
+	"github.com/frantjc/momo/android"
+	momov1alpha1 "github.com/frantjc/momo/api/v1alpha1"
+	"github.com/frantjc/momo/internal/momoutil"
+	xslice "github.com/frantjc/x/slice"
+	"github.com/go-chi/chi"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// languagesFromReq returns the languages that the request asks for, either by
+// the lang query parameters or by the Accept-Language header, most preferred first.
+func languagesFromReq(r *http.Request) []string {
+	if languages := r.URL.Query()["lang"]; len(languages) > 0 {
+		return languages
+	}
+
+	languages := []string{}
+
+	for _, language := range strings.Split(r.Header.Get("Accept-Language"), ",") {
+		language, _, _ = strings.Cut(language, ";")
+		if language = strings.TrimSpace(language); language != "" && language != "*" {
+			languages = append(languages, language)
+		}
+	}
+
+	return languages
+}
+
+// @Summary	Download the splits of a split APK set that a device needs
+// @Description	The whole set is at /{namespace}/files/{app}/{version}/{app}.apks or .xapk instead.
+// @Tags		apps
+// @Produce	application/x-apks
+// @Param		namespace	path		string	true	"Namespace"
+// @Param		app			path		string	true	"App"
+// @Param		version		path		string	false	"Version or channel"
+// @Param		device		query		string	false	"ID of the device, used to bucket it into a rollout"
+// @Param		abi			query		string	false	"ABI of the device, e.g. arm64-v8a"
+// @Param		density		query		string	false	"Screen density of the device, e.g. xxhdpi or 480dpi"
+// @Param		lang		query		[]string	false	"Languages of the device, e.g. en-US"
+// @Success	200
+// @Failure	404			{object}	Error
+// @Failure	500			{object}	Error
+// @Router		/{namespace}/splits/{app}/{version} [get]
+func (h *handler) handleSplits(w http.ResponseWriter, r *http.Request) error {
+	var (
+		ctx       = r.Context()
+		mobileApp = &momov1alpha1.MobileApp{}
+		namespace = strings.ToLower(chi.URLParam(r, "namespace"))
+		appName   = strings.ToLower(chi.URLParam(r, "app"))
+		version   = chi.URLParam(r, "version")
+		abi       = abiFromReq(r)
+	)
+
+	// Ask browsers for the client hints that abiFromReq uses.
+	w.Header().Set("Accept-CH", "Sec-CH-UA-Arch, Sec-CH-UA-Bitness")
+
+	cli, err := h.newClient(nil)
+	if err != nil {
+		return err
+	}
+
+	if err = cli.Get(ctx, client.ObjectKey{Namespace: namespace, Name: appName}, mobileApp); err != nil {
+		return err
+	}
+
+	version, _ = resolveVersion(w, r, mobileApp, version, momoutil.PlatformAndroid)
+
+	apk := findAPK(mobileApp.Status.APKs, version, abi)
+	if apk.Name == "" {
+		return fmt.Errorf("app does not have an %s", momo.ExtAPK)
+	}
+
+	cr := &momov1alpha1.APK{}
+
+	if err = cli.Get(ctx, client.ObjectKey{Namespace: namespace, Name: apk.Name}, cr); err != nil {
+		return err
+	}
+
+	if len(cr.Status.Splits) == 0 {
+		return momoutil.NewHTTPStatusCodeError(fmt.Errorf("app is not a split APK set"), http.StatusNotFound)
+	}
+
+	splits := android.SelectSplits(
+		xslice.Map(cr.Status.Splits, func(split momov1alpha1.APKSplit, _ int) android.Split {
+			return android.Split(split)
+		}),
+		abi,
+		r.URL.Query().Get("density"),
+		languagesFromReq(r),
+	)
+
+	bucket, err := momoutil.GetBucketReference(ctx, cli, namespace, cr.Spec.Bucket)
+	if err != nil {
+		return err
+	}
+
+	b, err := h.Buckets.OpenBucketFor(ctx, cli, bucket, namespace)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = b.Close()
+	}()
+
+	ra, err := momoutil.NewBucketReaderAt(ctx, b, cr.Spec.Key)
+	if err != nil {
+		return err
+	}
+
+	zr, err := zip.NewReader(ra, ra.Size())
+	if err != nil {
+		return err
+	}
+
+	if err := negotiate(w, r, android.ContentTypeAPKS); err != nil {
+		return err
+	}
+
+	w.Header().Add("Vary", "Accept-Language, Sec-CH-UA-Arch, Sec-CH-UA-Bitness")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", appName+momo.ExtAPKS))
+
+	var (
+		cw = &momoutil.CountingWriter{Writer: w}
+		zw = zip.NewWriter(cw)
+	)
+
+	for _, f := range zr.File {
+		if !slices.ContainsFunc(splits, func(split android.Split) bool {
+			return split.Name == f.Name
+		}) {
+			continue
+		}
+
+		// The splits are copied without being decompressed and compressed again.
+		if err := zw.Copy(f); err != nil {
+			return err
+		}
+	}
+
+	if err := zw.Close(); err != nil {
+		return err
+	}
+
+	momoutil.ObserveDownload(namespace, appName, momoutil.PlatformAndroid, cw.N)
+	h.recordAppEvent(ctx, r, momoutil.AppEventDownload, namespace, appName, apk.Version, momoutil.PlatformAndroid)
+
+	return nil
+}
@@ -0,0 +1,133 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	momov1alpha1 "github.com/frantjc/momo/api/v1alpha1"
+	"github.com/frantjc/momo/internal/momoutil"
+	"github.com/frantjc/momo/ios"
+	xslice "github.com/frantjc/x/slice"
+	"github.com/go-chi/chi"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// Stat is how many times a version of an app was installed and downloaded.
+type Stat struct {
+	Version         string     `json:"version,omitempty"`
+	Platform        string     `json:"platform,omitempty"`
+	Installs        int64      `json:"installs,omitempty"`
+	ManifestFetches int64      `json:"manifestFetches,omitempty"`
+	Downloads       int64      `json:"downloads,omitempty"`
+	LastTime        *time.Time `json:"lastTime,omitempty"`
+}
+
+// @Summary	Get how many times each version of an app was installed and downloaded
+// @Tags		apps
+// @Produce	json
+// @Param		namespace	path		string	true	"Namespace"
+// @Param		app			path		string	true	"App"
+// @Success	200			{array}		Stat
+// @Failure	404			{object}	Error
+// @Failure	500			{object}	Error
+// @Router		/{namespace}/apps/{app}/stats [get]
+func (h *handler) handleStats(w http.ResponseWriter, r *http.Request) error {
+	cli, err := h.newClient(nil)
+	if err != nil {
+		return err
+	}
+
+	var (
+		ctx       = r.Context()
+		mobileApp = &momov1alpha1.MobileApp{}
+		namespace = chi.URLParam(r, "namespace")
+		appName   = chi.URLParam(r, "app")
+	)
+
+	if err := cli.Get(ctx, client.ObjectKey{Namespace: namespace, Name: appName}, mobileApp); err != nil {
+		return err
+	}
+
+	return respondJSON(w, r, xslice.Map(mobileApp.Status.Stats, func(stats momov1alpha1.MobileAppStatusStats, _ int) Stat {
+		stat := Stat{
+			Version:         stats.Version,
+			Platform:        stats.Platform,
+			Installs:        stats.Installs,
+			ManifestFetches: stats.ManifestFetches,
+			Downloads:       stats.Downloads,
+		}
+		if stats.LastTime != nil {
+			stat.LastTime = &stats.LastTime.Time
+		}
+
+		return stat
+	}))
+}
+
+// recordAppEvent records an event of the given type of the given version of an app
+// to the handler's AppEventSink, if any, along with coarse info about the client.
+func (h *handler) recordAppEvent(ctx context.Context, r *http.Request, eventType, namespace, appName, version, platform string) {
+	if h.Events == nil {
+		return
+	}
+
+	h.Events.RecordAppEvent(ctx, momoutil.AppEvent{
+		Type:      eventType,
+		Namespace: namespace,
+		App:       appName,
+		Version:   version,
+		Platform:  platform,
+		Client:    clientFromUserAgent(r.UserAgent()),
+		Network:   networkFromRemoteAddr(r.RemoteAddr),
+		Time:      time.Now(),
+	})
+}
+
+var (
+	androidUserAgentRegexp = regexp.MustCompile(`Android (\d+)`)
+)
+
+// clientFromUserAgent returns the kind of device and major OS version of
+// the given User-Agent, e.g. "iPhone iOS 17" or "Android 14", or "Other".
+func clientFromUserAgent(userAgent string) string {
+	if device := ios.ParseUserAgent(userAgent); device != nil {
+		family := "iPhone"
+		if device.Family == ios.DeviceFamilyIPad {
+			family = "iPad"
+		}
+
+		major, _, _ := strings.Cut(device.OSVersion, ".")
+		return fmt.Sprintf("%s iOS %s", family, major)
+	}
+
+	if matches := androidUserAgentRegexp.FindStringSubmatch(userAgent); matches != nil {
+		return "Android " + matches[1]
+	}
+
+	return "Other"
+}
+
+// networkFromRemoteAddr returns the /24 IPv4 or /48 IPv6 network of the given remote
+// address, which middleware.RealIP sets to the client's IP address without a port.
+func networkFromRemoteAddr(remoteAddr string) string {
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		host = remoteAddr
+	}
+
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return ""
+	}
+
+	if ip4 := ip.To4(); ip4 != nil {
+		return (&net.IPNet{IP: ip4.Mask(net.CIDRMask(24, 32)), Mask: net.CIDRMask(24, 32)}).String()
+	}
+
+	return (&net.IPNet{IP: ip.Mask(net.CIDRMask(48, 128)), Mask: net.CIDRMask(48, 128)}).String()
+}
@@ -0,0 +1,102 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	momov1alpha1 "github.com/frantjc/momo/api/v1alpha1"
+	"github.com/frantjc/momo/internal/momoutil"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestHandleStats(t *testing.T) {
+	scheme, err := momoutil.NewScheme(corev1.AddToScheme, momov1alpha1.AddToScheme)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var (
+		lastTime  = time.Now().Truncate(time.Second)
+		mobileApp = &momov1alpha1.MobileApp{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "app"},
+			Status: momov1alpha1.MobileAppStatus{
+				Stats: []momov1alpha1.MobileAppStatusStats{
+					{Version: "v1.0.0", Platform: momoutil.PlatformIOS, Installs: 2, ManifestFetches: 1, LastTime: &metav1.Time{Time: lastTime}},
+					{Version: "v1.0.0", Platform: momoutil.PlatformAndroid, Downloads: 3},
+				},
+			},
+		}
+		cli = fake.NewClientBuilder().WithScheme(scheme).WithObjects(mobileApp).Build()
+	)
+
+	handler, err := NewHandler(&Opts{Client: cli})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/default/apps/app/stats", nil))
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected %d, got %d: %s", http.StatusOK, rec.Code, rec.Body.String())
+	}
+
+	stats := []Stat{}
+	if err := json.NewDecoder(rec.Body).Decode(&stats); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(stats) != 2 {
+		t.Fatalf("expected stats of 2 versions, got %d", len(stats))
+	}
+
+	if stat := stats[0]; stat.Platform != momoutil.PlatformIOS || stat.Installs != 2 || stat.ManifestFetches != 1 || stat.LastTime == nil || !stat.LastTime.Equal(lastTime) {
+		t.Errorf("unexpected iOS stat %+v", stat)
+	}
+
+	if stat := stats[1]; stat.Platform != momoutil.PlatformAndroid || stat.Downloads != 3 || stat.LastTime != nil {
+		t.Errorf("unexpected Android stat %+v", stat)
+	}
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/default/apps/missing/stats", nil))
+
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("expected %d for a missing app, got %d", http.StatusNotFound, rec.Code)
+	}
+}
+
+func TestClientFromUserAgent(t *testing.T) {
+	for userAgent, expected := range map[string]string{
+		"Mozilla/5.0 (iPhone; CPU iPhone OS 17_2 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.2 Mobile/15E148 Safari/604.1": "iPhone iOS 17",
+		"Mozilla/5.0 (iPad; CPU OS 16_6_1 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/16.6 Mobile/15E148 Safari/604.1":        "iPad iOS 16",
+		"Mozilla/5.0 (Linux; Android 14; Pixel 8) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Mobile Safari/537.36":                   "Android 14",
+		"Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.2 Safari/605.1.15":                   "Other",
+		"": "Other",
+	} {
+		if client := clientFromUserAgent(userAgent); client != expected {
+			t.Errorf("expected %q for %q, got %q", expected, userAgent, client)
+		}
+	}
+}
+
+func TestNetworkFromRemoteAddr(t *testing.T) {
+	for remoteAddr, expected := range map[string]string{
+		"203.0.113.7":           "203.0.113.0/24",
+		"203.0.113.7:443":       "203.0.113.0/24",
+		"2001:db8:1:2::7":       "2001:db8:1::/48",
+		"[2001:db8:1:2::7]:443": "2001:db8:1::/48",
+		"::ffff:203.0.113.7":    "203.0.113.0/24",
+		"not an address":        "",
+		"":                      "",
+	} {
+		if network := networkFromRemoteAddr(remoteAddr); network != expected {
+			t.Errorf("expected %q for %q, got %q", expected, remoteAddr, network)
+		}
+	}
+}
@@ -10,14 +10,18 @@ import (
 	"mime"
 	"mime/multipart"
 	"net/http"
+	"net/url"
+	"os"
 	"path"
+	"path/filepath"
 	"strings"
 
 	"github.com/frantjc/momo"
 	"github.com/frantjc/momo/android"
+	momov1alpha1 "github.com/frantjc/momo/api/v1alpha1"
 	"github.com/frantjc/momo/internal/momoutil"
 	"github.com/frantjc/momo/ios"
-	xio "github.com/frantjc/x/io"
+	xslice "github.com/frantjc/x/slice"
 )
 
 type fileOpts struct {
@@ -58,7 +62,53 @@ func decodeContent(req *http.Request) (io.ReadCloser, error) {
 	return rc, nil
 }
 
-func reqToApp(req *http.Request, opts ...fileOpt) (io.ReadCloser, string, error) {
+// uploadedApp is an app read from an upload request.
+type uploadedApp struct {
+	io.Reader
+	// DSYMs are the debug symbols that were uploaded with the app, if any.
+	DSYMs io.Reader
+	// Release is the release notes and build metadata
+	// that were uploaded with the app, if any.
+	Release momov1alpha1.Release
+	closers []io.Closer
+}
+
+// Close closes everything that the app was read through, innermost first.
+func (a *uploadedApp) Close() error {
+	errs := []error{}
+
+	for _, closer := range a.closers {
+		errs = append(errs, closer.Close())
+	}
+
+	return errors.Join(errs...)
+}
+
+// reqToApp reads the app from the body of an upload request, along with its release
+// notes and build metadata from its headers and form fields, the latter taking precedence.
+func reqToApp(req *http.Request, opts ...fileOpt) (*uploadedApp, string, error) {
+	release, err := releaseFromHeader(req.Header)
+	if err != nil {
+		return nil, "", err
+	}
+
+	app, mediaType, err := bodyToApp(req, opts...)
+	if err != nil {
+		return nil, "", err
+	}
+
+	app.Release = momov1alpha1.Release{
+		Notes:    xslice.Coalesce(app.Release.Notes, release.Notes),
+		Commit:   xslice.Coalesce(app.Release.Commit, release.Commit),
+		Branch:   xslice.Coalesce(app.Release.Branch, release.Branch),
+		BuildURL: xslice.Coalesce(app.Release.BuildURL, release.BuildURL),
+		Author:   xslice.Coalesce(app.Release.Author, release.Author),
+	}
+
+	return app, mediaType, nil
+}
+
+func bodyToApp(req *http.Request, opts ...fileOpt) (*uploadedApp, string, error) {
 	mediaType, params, err := mime.ParseMediaType(req.Header.Get("Content-Type"))
 	if err != nil {
 		return nil, "", err
@@ -70,10 +120,10 @@ func reqToApp(req *http.Request, opts ...fileOpt) (io.ReadCloser, string, error)
 	}
 
 	switch mediaType {
-	case android.ContentTypeAPK:
-		return rc, mediaType, nil
+	case android.ContentTypeAPK, android.ContentTypeAAB, android.ContentTypeAPKS, android.ContentTypeXAPK:
+		return &uploadedApp{Reader: rc, closers: []io.Closer{rc}}, mediaType, nil
 	case ios.ContentTypeIPA:
-		return rc, mediaType, nil
+		return &uploadedApp{Reader: rc, closers: []io.Closer{rc}}, mediaType, nil
 	case "multipart/form-data":
 		boundary := params["boundary"]
 		if boundary == "" {
@@ -83,40 +133,34 @@ func reqToApp(req *http.Request, opts ...fileOpt) (io.ReadCloser, string, error)
 			)
 		}
 
-		r, mediaType, err := multipartToApp(rc, params["boundary"])
+		app, mediaType, err := multipartToApp(rc, params["boundary"])
 		if err != nil {
 			return nil, "", err
 		}
+		app.closers = append(app.closers, rc)
 
-		return xio.ReadCloser{
-			Reader: r,
-			Closer: rc,
-		}, mediaType, nil
+		return app, mediaType, nil
 	case "application/tar", "application/x-tar":
-		r, mediaType, err := tarToApp(rc, opts...)
+		app, mediaType, err := tarToApp(rc, opts...)
 		if err != nil {
 			return nil, "", err
 		}
+		app.closers = append(app.closers, rc)
 
-		return xio.ReadCloser{
-			Reader: r,
-			Closer: rc,
-		}, mediaType, nil
+		return app, mediaType, nil
 	case "application/gzip", "application/x-gtar", "application/x-tgz":
 		zr, err := gzip.NewReader(rc)
 		if err != nil {
 			return nil, "", err
 		}
 
-		r, mediaType, err := tarToApp(zr, opts...)
+		app, mediaType, err := tarToApp(zr, opts...)
 		if err != nil {
 			return nil, "", err
 		}
+		app.closers = append(app.closers, zr)
 
-		return xio.ReadCloser{
-			Reader: r,
-			Closer: zr,
-		}, mediaType, nil
+		return app, mediaType, nil
 	}
 
 	return nil, "", momoutil.NewHTTPStatusCodeError(
@@ -125,7 +169,7 @@ func reqToApp(req *http.Request, opts ...fileOpt) (io.ReadCloser, string, error)
 	)
 }
 
-func tarToApp(r io.Reader, opts ...fileOpt) (io.Reader, string, error) {
+func tarToApp(r io.Reader, opts ...fileOpt) (*uploadedApp, string, error) {
 	o := &fileOpts{
 		Mode: 0777,
 	}
@@ -144,19 +188,155 @@ func tarToApp(r io.Reader, opts ...fileOpt) (io.Reader, string, error) {
 			return nil, "", err
 		}
 
+		if root, ok := ios.ArchiveRoot(hdr.Name); ok {
+			app, err := xcarchiveToApp(tr, hdr, root)
+			if err != nil {
+				return nil, "", err
+			}
+
+			return app, ios.ContentTypeIPA, nil
+		}
+
 		switch strings.ToLower(path.Ext(hdr.Name)) {
 		case momo.ExtAPK:
-			return tr, android.ContentTypeAPK, nil
+			return &uploadedApp{Reader: tr}, android.ContentTypeAPK, nil
+		case momo.ExtAAB:
+			return &uploadedApp{Reader: tr}, android.ContentTypeAAB, nil
+		case momo.ExtAPKS:
+			return &uploadedApp{Reader: tr}, android.ContentTypeAPKS, nil
+		case momo.ExtXAPK:
+			return &uploadedApp{Reader: tr}, android.ContentTypeXAPK, nil
 		case momo.ExtIPA:
-			return tr, ios.ContentTypeIPA, nil
+			return &uploadedApp{Reader: tr}, ios.ContentTypeIPA, nil
 		}
 	}
 
 	return nil, "", fmt.Errorf("no app found before %w", io.EOF)
 }
 
-func multipartToApp(r io.Reader, boundary string) (io.Reader, string, error) {
-	mr := multipart.NewReader(r, boundary)
+// xcarchiveToApp extracts the .xcarchive at root from tr, starting with hdr,
+// and builds an .ipa of its app, keeping its dSYMs as the uploadedApp's DSYMs.
+func xcarchiveToApp(tr *tar.Reader, hdr *tar.Header, root string) (_ *uploadedApp, err error) {
+	tmp, err := os.MkdirTemp("", "*"+ios.ExtXCArchive)
+	if err != nil {
+		return nil, err
+	}
+
+	app := &uploadedApp{closers: []io.Closer{removeAll(tmp)}}
+	defer func() {
+		if err != nil {
+			_ = app.Close()
+		}
+	}()
+
+	archive, err := ios.ExtractArchive(tr, hdr, root, filepath.Join(tmp, path.Base(root)))
+	if err != nil {
+		return nil, momoutil.NewHTTPStatusCodeError(err, http.StatusBadRequest)
+	}
+
+	if _, err = archive.Info(); err != nil {
+		return nil, momoutil.NewHTTPStatusCodeError(err, http.StatusBadRequest)
+	}
+
+	ipa, err := os.Create(filepath.Join(tmp, "app"+momo.ExtIPA))
+	if err != nil {
+		return nil, err
+	}
+	app.closers = append([]io.Closer{ipa}, app.closers...)
+
+	if err = archive.WriteIPA(ipa); err != nil {
+		return nil, err
+	}
+
+	if _, err = ipa.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	app.Reader = ipa
+
+	dsyms, err := os.Create(filepath.Join(tmp, momo.FileDSYMs))
+	if err != nil {
+		return nil, err
+	}
+	app.closers = append([]io.Closer{dsyms}, app.closers...)
+
+	if err = archive.WriteDSYMs(dsyms); errors.Is(err, ios.ErrNoDSYMs) {
+		return app, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	if _, err = dsyms.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	app.DSYMs = dsyms
+
+	return app, nil
+}
+
+// removeAll is an io.Closer that removes a directory and everything in it.
+type removeAll string
+
+func (r removeAll) Close() error {
+	return os.RemoveAll(string(r))
+}
+
+const (
+	// maxReleaseNotesSize is the most bytes of release notes that an upload may
+	// have, as they are stored on the APK or IPA and objects' sizes are limited.
+	maxReleaseNotesSize = 64 << 10
+)
+
+// releaseFromHeader reads the release notes and build metadata of a raw upload from
+// its headers. The release notes are percent-encoded, as they are usually multiline.
+func releaseFromHeader(header http.Header) (momov1alpha1.Release, error) {
+	notes, err := url.PathUnescape(header.Get(momo.HeaderReleaseNotes))
+	if err != nil {
+		return momov1alpha1.Release{}, momoutil.NewHTTPStatusCodeError(
+			fmt.Errorf("decode %s: %w", momo.HeaderReleaseNotes, err),
+			http.StatusBadRequest,
+		)
+	} else if len(notes) > maxReleaseNotesSize {
+		return momov1alpha1.Release{}, momoutil.NewHTTPStatusCodeError(
+			fmt.Errorf("release notes are larger than %d bytes", maxReleaseNotesSize),
+			http.StatusRequestEntityTooLarge,
+		)
+	}
+
+	return momov1alpha1.Release{
+		Notes:    notes,
+		Commit:   header.Get(momo.HeaderCommit),
+		Branch:   header.Get(momo.HeaderBranch),
+		BuildURL: header.Get(momo.HeaderBuildURL),
+		Author:   header.Get(momo.HeaderAuthor),
+	}, nil
+}
+
+// releaseField returns the field of release that the form field
+// with the given name sets, if any.
+func releaseField(release *momov1alpha1.Release, name string) *string {
+	switch name {
+	case "notes":
+		return &release.Notes
+	case "commit":
+		return &release.Commit
+	case "branch":
+		return &release.Branch
+	case "buildURL":
+		return &release.BuildURL
+	case "author":
+		return &release.Author
+	}
+
+	return nil
+}
+
+// multipartToApp reads the app from the file field of a multipart form. The form fields
+// that set its release notes and build metadata must come before the file field.
+func multipartToApp(r io.Reader, boundary string) (*uploadedApp, string, error) {
+	var (
+		mr      = multipart.NewReader(r, boundary)
+		release = momov1alpha1.Release{}
+	)
 
 	for {
 		p, err := mr.NextPart()
@@ -169,17 +349,72 @@ func multipartToApp(r io.Reader, boundary string) (io.Reader, string, error) {
 			_ = p.Close()
 		}()
 
+		if field := releaseField(&release, p.FormName()); field != nil && p.FileName() == "" {
+			value, err := io.ReadAll(io.LimitReader(p, maxReleaseNotesSize+1))
+			if err != nil {
+				return nil, "", err
+			} else if len(value) > maxReleaseNotesSize {
+				return nil, "", momoutil.NewHTTPStatusCodeError(
+					fmt.Errorf("form field %s is larger than %d bytes", p.FormName(), maxReleaseNotesSize),
+					http.StatusRequestEntityTooLarge,
+				)
+			}
+
+			*field = string(value)
+			continue
+		}
+
 		if p.FormName() == "file" {
-			ext := strings.ToLower(path.Ext(p.FileName()))
+			app, mediaType, err := multipartFileToApp(p)
+			if err != nil {
+				return nil, "", err
+			} else if app != nil {
+				app.Release = release
 
-			switch ext {
-			case momo.ExtAPK:
-				return p, android.ContentTypeAPK, nil
-			case momo.ExtIPA:
-				return p, ios.ContentTypeIPA, nil
+				return app, mediaType, nil
 			}
 		}
 	}
 
 	return nil, "", fmt.Errorf("no app found before %w", io.EOF)
 }
+
+// multipartFileToApp reads the app from the file field p, returning
+// no app if the file's extension is not that of a supported one.
+func multipartFileToApp(p *multipart.Part) (*uploadedApp, string, error) {
+	var (
+		fileName = strings.ToLower(p.FileName())
+		ext      = path.Ext(fileName)
+	)
+
+	switch ext {
+	case momo.ExtAPK:
+		return &uploadedApp{Reader: p}, android.ContentTypeAPK, nil
+	case momo.ExtAAB:
+		return &uploadedApp{Reader: p}, android.ContentTypeAAB, nil
+	case momo.ExtAPKS:
+		return &uploadedApp{Reader: p}, android.ContentTypeAPKS, nil
+	case momo.ExtXAPK:
+		return &uploadedApp{Reader: p}, android.ContentTypeXAPK, nil
+	case momo.ExtIPA:
+		return &uploadedApp{Reader: p}, ios.ContentTypeIPA, nil
+	case ".tar":
+		// E.g. a tarred .xcarchive.
+		return tarToApp(p)
+	case ".tgz", ".gz":
+		zr, err := gzip.NewReader(p)
+		if err != nil {
+			return nil, "", err
+		}
+
+		app, mediaType, err := tarToApp(zr)
+		if err != nil {
+			return nil, "", err
+		}
+		app.closers = append(app.closers, zr)
+
+		return app, mediaType, nil
+	}
+
+	return nil, "", nil
+}
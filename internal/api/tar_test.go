@@ -0,0 +1,154 @@
+package api
+
+import (
+	"bytes"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/frantjc/momo"
+	"github.com/frantjc/momo/android"
+	"github.com/frantjc/momo/internal/momoutil"
+)
+
+func TestReleaseFromHeader(t *testing.T) {
+	header := http.Header{}
+	header.Set(momo.HeaderReleaseNotes, "Fixed%20a%20crash.%0AAdded%20dark%20mode.")
+	header.Set(momo.HeaderCommit, "abc123")
+
+	release, err := releaseFromHeader(header)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if release.Notes != "Fixed a crash.\nAdded dark mode." {
+		t.Errorf("expected the release notes to be percent-decoded, got %q", release.Notes)
+	}
+
+	if release.Commit != "abc123" {
+		t.Errorf("expected commit abc123, got %q", release.Commit)
+	}
+
+	header.Set(momo.HeaderReleaseNotes, "100%")
+
+	if _, err := releaseFromHeader(header); momoutil.HTTPStatusCode(err) != http.StatusBadRequest {
+		t.Errorf("expected %d for release notes that are not percent-encoded, got %v", http.StatusBadRequest, err)
+	}
+
+	header.Set(momo.HeaderReleaseNotes, strings.Repeat("a", maxReleaseNotesSize+1))
+
+	if _, err := releaseFromHeader(header); momoutil.HTTPStatusCode(err) != http.StatusRequestEntityTooLarge {
+		t.Errorf("expected %d for release notes that are too large, got %v", http.StatusRequestEntityTooLarge, err)
+	}
+}
+
+// multipartField is a field of a multipart form, a file if fileName is set.
+type multipartField struct {
+	name, fileName, value string
+}
+
+func newMultipartReq(t *testing.T, fields ...multipartField) *http.Request {
+	t.Helper()
+
+	var (
+		buf = new(bytes.Buffer)
+		mw  = multipart.NewWriter(buf)
+	)
+
+	for _, field := range fields {
+		if field.fileName != "" {
+			w, err := mw.CreateFormFile(field.name, field.fileName)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if _, err := w.Write([]byte(field.value)); err != nil {
+				t.Fatal(err)
+			}
+		} else if err := mw.WriteField(field.name, field.value); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := mw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/default/upload/app", buf)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+
+	return req
+}
+
+func TestReqToAppMultipart(t *testing.T) {
+	t.Run("form fields override headers", func(t *testing.T) {
+		req := newMultipartReq(t,
+			multipartField{name: "notes", value: "From the form."},
+			multipartField{name: "file", fileName: "app.apk", value: t.Name()},
+		)
+		req.Header.Set(momo.HeaderReleaseNotes, "From%20the%20header.")
+		req.Header.Set(momo.HeaderBranch, "main")
+
+		app, mediaType, err := reqToApp(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() {
+			_ = app.Close()
+		}()
+
+		if mediaType != android.ContentTypeAPK {
+			t.Errorf("expected media type %s, got %s", android.ContentTypeAPK, mediaType)
+		}
+
+		if app.Release.Notes != "From the form." {
+			t.Errorf("expected the form field to take precedence over the header, got %q", app.Release.Notes)
+		}
+
+		if app.Release.Branch != "main" {
+			t.Errorf("expected the header to be used without a form field, got %q", app.Release.Branch)
+		}
+	})
+
+	t.Run("form fields after the file are ignored", func(t *testing.T) {
+		req := newMultipartReq(t,
+			multipartField{name: "file", fileName: "app.apk", value: t.Name()},
+			multipartField{name: "notes", value: "After the file."},
+		)
+		req.Header.Set(momo.HeaderReleaseNotes, "From%20the%20header.")
+
+		app, _, err := reqToApp(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() {
+			_ = app.Close()
+		}()
+
+		if app.Release.Notes != "From the header." {
+			t.Errorf("expected the form field after the file to be ignored, got %q", app.Release.Notes)
+		}
+	})
+
+	t.Run("oversized form field", func(t *testing.T) {
+		req := newMultipartReq(t,
+			multipartField{name: "notes", value: strings.Repeat("a", maxReleaseNotesSize+1)},
+			multipartField{name: "file", fileName: "app.apk", value: t.Name()},
+		)
+
+		if _, _, err := reqToApp(req); momoutil.HTTPStatusCode(err) != http.StatusRequestEntityTooLarge {
+			t.Errorf("expected %d for a form field that is too large, got %v", http.StatusRequestEntityTooLarge, err)
+		}
+	})
+
+	t.Run("oversized header", func(t *testing.T) {
+		req := newMultipartReq(t, multipartField{name: "file", fileName: "app.apk", value: t.Name()})
+		req.Header.Set(momo.HeaderReleaseNotes, strings.Repeat("a", maxReleaseNotesSize+1))
+
+		if _, _, err := reqToApp(req); momoutil.HTTPStatusCode(err) != http.StatusRequestEntityTooLarge {
+			t.Errorf("expected %d for a header that is too large, got %v", http.StatusRequestEntityTooLarge, err)
+		}
+	})
+}
@@ -3,16 +3,23 @@ package controller
 import (
 	"context"
 	"fmt"
+	"io"
 	"os"
+	"path/filepath"
+	"strings"
 	"time"
 
 	"github.com/frantjc/momo"
 	"github.com/frantjc/momo/android"
 	momov1alpha1 "github.com/frantjc/momo/api/v1alpha1"
+	"github.com/frantjc/momo/apktool"
 	"github.com/frantjc/momo/internal/momoutil"
+	"github.com/frantjc/momo/internal/scan"
 	xslice "github.com/frantjc/x/slice"
 	xstrings "github.com/frantjc/x/strings"
+	"github.com/opencontainers/go-digest"
 	"golang.org/x/mod/semver"
+	batchv1 "k8s.io/api/batch/v1"
 	corev1 "k8s.io/api/core/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -30,17 +37,47 @@ import (
 type APKReconciler struct {
 	client.Client
 	record.EventRecorder
-	TmpDir string
+	Buckets *momoutil.BucketManager
+	TmpDir  string
+	// VerifyDigests makes the APKReconciler download and hash the APK's
+	// object on every reconcile, even if its attributes have not changed.
+	VerifyDigests bool
+	// RangeReads makes the APKReconciler decode the APK's object by reading only
+	// the parts of it that it needs from its Bucket instead of downloading it to TmpDir.
+	RangeReads bool
+	// UnpackJob, if set, makes the APKReconciler decode the
+	// APK's object in a Job instead of in its own process.
+	UnpackJob *UnpackJobOpts
+	// Scanner, if set, must find the APK's object to be clean before it is Ready.
+	Scanner scan.Scanner
+	// AABDecoderOpts configure how Android App Bundles are decoded, e.g. the
+	// keystore that the universal APKs that are built from them are signed with.
+	AABDecoderOpts []android.AABDecoderOpt
+}
+
+// apkUnpacker is implemented by *android.APKDecoder and by
+// *momoutil.UnpackResult when unpacking happens in a Job.
+type apkUnpacker interface {
+	SHA256CertFingerprints(context.Context) (string, error)
+	Metadata(context.Context) (*apktool.Metadata, error)
+	Manifest(context.Context) (*android.Manifest, error)
+	Label(context.Context) (string, error)
+	ABIs(context.Context) ([]string, error)
+	Densities(context.Context) ([]string, error)
+	IconDecoder
+	io.Closer
 }
 
 // +kubebuilder:rbac:groups=momo.frantj.cc,resources=apks,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=momo.frantj.cc,resources=apks/status,verbs=get;update;patch
 // +kubebuilder:rbac:groups=momo.frantj.cc,resources=apks/finalizers,verbs=update
+// +kubebuilder:rbac:groups=batch,resources=jobs,verbs=get;list;watch;create;delete
+// +kubebuilder:rbac:groups="",resources=secrets,verbs=create
 // +kubebuilder:rbac:groups=momo.frantj.cc,resources=buckets,verbs=get;list;watch;
 
 // Reconcile is part of the main kubernetes reconciliation loop which aims to
 // move the current state of the cluster closer to the desired state.
-func (r *APKReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+func (r *APKReconciler) Reconcile(ctx context.Context, req ctrl.Request) (_ ctrl.Result, err error) {
 	var (
 		_   = log.FromContext(ctx)
 		apk = &momov1alpha1.APK{}
@@ -50,6 +87,11 @@ func (r *APKReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.R
 		return ctrl.Result{}, ignoreNotFound(err)
 	}
 
+	ctx, span := startSpan(ctx, "APKReconciler.Reconcile", apk, apk.Status.Digest != "")
+	defer func() {
+		momoutil.EndSpan(span, err)
+	}()
+
 	if apk.Status.Phase != momov1alpha1.PhasePending {
 		apk.Status.Phase = momov1alpha1.PhasePending
 
@@ -58,58 +100,146 @@ func (r *APKReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.R
 		}
 	}
 
-	bucket := &momov1alpha1.Bucket{}
-
-	if err := r.Get(ctx,
-		client.ObjectKey{
-			Namespace: apk.Namespace,
-			Name:      apk.Spec.Bucket.Name,
-		},
-		bucket,
-	); err != nil {
+	bucket, err := momoutil.GetBucketReference(ctx, r.Client, apk.Namespace, apk.Spec.Bucket)
+	if err != nil {
 		if apierrors.IsNotFound(err) {
-			r.Eventf(apk, corev1.EventTypeWarning, "BucketNotFound", "Bucket %s is not found", bucket.Name)
+			r.Eventf(apk, corev1.EventTypeWarning, "BucketNotFound", "Bucket %s is not found", apk.Spec.Bucket)
+			return ctrl.Result{}, nil
+		} else if apierrors.IsForbidden(err) {
+			r.Eventf(apk, corev1.EventTypeWarning, "BucketForbidden", "Bucket %s is not allowed: %v", apk.Spec.Bucket, err)
 			return ctrl.Result{}, nil
 		}
 
 		return ctrl.Result{}, err
 	}
 
-	if bucket.Status.Phase != momov1alpha1.PhaseReady {
-		r.Eventf(apk, corev1.EventTypeNormal, "BucketNotReady", "Bucket %s is not ready", bucket.Name)
+	if bucket.GetBucketStatus().Phase != momov1alpha1.PhaseReady {
+		r.Eventf(apk, corev1.EventTypeNormal, "BucketNotReady", "Bucket %s is not ready", apk.Spec.Bucket)
 		return ctrl.Result{}, nil
 	}
 
-	cli, err := momoutil.OpenBucket(ctx, r.Client, bucket)
+	cli, err := r.Buckets.OpenBucketFor(ctx, r.Client, bucket, apk.Namespace)
 	if err != nil {
 		// If the Bucket is Ready, then this error
 		// is likely temporary and will resolve itself.
 		return ctrl.Result{Requeue: true}, nil
 	}
+	defer func() {
+		_ = cli.Close()
+	}()
 
 	if !apk.DeletionTimestamp.IsZero() {
 		return finalize(ctx, r, r, cli, apk)
 	}
 
-	path, dig, err := downloadAndSumObject(ctx, r, cli, apk, momo.ExtAPK, r.TmpDir)
+	if to := apk.Annotations[momoutil.AnnotationMigrateTo]; to != "" && apk.Status.Digest != "" {
+		return migrate(ctx, r, r, r.Buckets, apk, momov1alpha1.ParseBucketReference(to))
+	}
+
+	// An error here is surfaced by downloadAndSumObject.
+	attrs, _ := objectAttributes(ctx, cli, apk)
+
+	// Whether the object has changed or not, it is unpacked again if
+	// it was last decoded into fewer fields than are decoded now.
+	current := apk.Status.DecoderVersion >= APKDecoderVersion
+
+	if current && !r.VerifyDigests && apk.Status.Digest != "" && objectUnchanged(apk, attrs) {
+		return ready(ctx, r, r, r.Scanner, cli, apk)
+	}
+
+	var (
+		path       string
+		dig        digest.Digest
+		job        *batchv1.Job
+		ext        = momo.ExtAPK
+		rangeReads = r.RangeReads
+		unpackJob  = r.UnpackJob
+	)
+	if archiveExt := apkArchiveExt(apk); archiveExt != momo.ExtAPK {
+		// Android App Bundles and split APK sets are always decoded from disk here, as
+		// the APKs in or built from them must be extracted and uploaded alongside them.
+		ext = archiveExt
+		rangeReads = false
+		unpackJob = nil
+	}
+
+	if unpackJob != nil {
+		if job, err = getUnpackJob(ctx, r, apk); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+
+	if job != nil {
+		// The object was already summed when the Job was created.
+		dig = digest.Digest(job.Annotations[AnnotationDigest])
+	} else if rangeReads && unpackJob == nil && !r.VerifyDigests && attributesIdentify(attrs) {
+		// The object is only read in part to be decoded, so it is not read in full to be hashed either.
+		dig = attributesDigest(attrs)
+	} else if rangeReads || unpackJob != nil {
+		dig, err = sumObject(ctx, r, cli, apk, ext)
+	} else {
+		path, dig, err = downloadAndSumObject(ctx, r, cli, apk, ext, r.TmpDir)
+	}
 	if err != nil {
 		return ctrl.Result{}, ignoreNotFound(err)
+	} else if dig == "" {
+		// The failure to get the object has been recorded in the status.
+		return ctrl.Result{}, nil
 	}
 	defer func() {
-		_ = os.Remove(path)
+		if path != "" {
+			_ = os.Remove(path)
+		}
 	}()
 
-	if dig.String() == apk.Status.Digest {
-		apk.Status.Phase = momov1alpha1.PhaseReady
-		return ctrl.Result{}, ignoreNotFound(r.Client.Status().Update(ctx, apk))
+	if current && dig.String() == apk.Status.Digest {
+		apk.Status.ObjectAttributes = attrs
+		return ready(ctx, r, r, r.Scanner, cli, apk)
 	}
 
-	apkDecoder := android.NewAPKDecoder(path)
+	var apkDecoder apkUnpacker
+	if ext == momo.ExtAAB {
+		apkDecoder = android.NewAABDecoder(path, r.AABDecoderOpts...)
+	} else if ext == momo.ExtAPKS || ext == momo.ExtXAPK {
+		apkDecoder = android.NewAPKSetDecoder(path)
+	} else if unpackJob != nil {
+		result, err := unpackInJob(ctx, r, cli, unpackJob, apk, job, momoutil.PlatformAndroid, dig)
+		if err != nil {
+			apk.Status.Phase = momov1alpha1.PhaseFailed
+			setCondition(apk, metav1.Condition{
+				Type:    "UnpackAPK",
+				Reason:  "UnpackJob",
+				Status:  metav1.ConditionFalse,
+				Message: err.Error(),
+			})
+
+			return ctrl.Result{}, ignoreNotFound(r.Status().Update(ctx, apk))
+		} else if result == nil {
+			// The Job is running and will trigger another reconcile when it finishes.
+			return ctrl.Result{RequeueAfter: unpackJob.Timeout}, nil
+		}
+
+		apkDecoder = result
+	} else {
+		opts := []android.APKDecoderOpt{}
+		if rangeReads {
+			ra, err := momoutil.NewBucketReaderAt(ctx, cli, apk.Spec.Key)
+			if err != nil {
+				return ctrl.Result{}, err
+			}
+
+			opts = append(opts, android.WithReaderAt(ra, ra.Size()))
+		}
+
+		apkDecoder = android.NewAPKDecoder(path, opts...)
+	}
 	defer func() {
 		_ = apkDecoder.Close()
 	}()
 
+	start := time.Now()
 	sha256CertFingerprints, err := apkDecoder.SHA256CertFingerprints(ctx)
+	momoutil.ObserveDecode(momoutil.PlatformAndroid, momoutil.DecodeStepKeytool, start, err)
 	if err != nil {
 		apk.Status.Phase = momov1alpha1.PhaseFailed
 		setCondition(apk, metav1.Condition{
@@ -124,7 +254,9 @@ func (r *APKReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.R
 
 	apk.Status.SHA256CertFingerprints = sha256CertFingerprints
 
+	start = time.Now()
 	metadata, err := apkDecoder.Metadata(ctx)
+	momoutil.ObserveDecode(momoutil.PlatformAndroid, momoutil.DecodeStepAPKTool, start, err)
 	if err != nil {
 		apk.Status.Phase = momov1alpha1.PhaseFailed
 		setCondition(apk, metav1.Condition{
@@ -137,14 +269,21 @@ func (r *APKReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.R
 		return ctrl.Result{}, ignoreNotFound(r.Status().Update(ctx, apk))
 	}
 
+	apk.Status.VersionName = xslice.Coalesce(metadata.VersionInfo.VersionName, metadata.Version)
+	apk.Status.BuildNumber = ""
+	if metadata.VersionInfo.VersionCode > 0 {
+		apk.Status.BuildNumber = fmt.Sprint(metadata.VersionInfo.VersionCode)
+	}
 	apk.Status.Version = semver.Canonical(
 		xstrings.EnsurePrefix(
-			xslice.Coalesce(metadata.VersionInfo.VersionName, metadata.Version, fmt.Sprint(metadata.VersionInfo.VersionCode)),
+			xslice.Coalesce(apk.Status.VersionName, apk.Status.BuildNumber),
 			"v",
 		),
 	)
 
+	start = time.Now()
 	manifest, err := apkDecoder.Manifest(ctx)
+	momoutil.ObserveDecode(momoutil.PlatformAndroid, momoutil.DecodeStepAPKTool, start, err)
 	if err != nil {
 		apk.Status.Phase = momov1alpha1.PhaseFailed
 		setCondition(apk, metav1.Condition{
@@ -158,6 +297,79 @@ func (r *APKReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.R
 	}
 
 	apk.Status.Package = manifest.Package()
+	apk.Status.MinSDKVersion = metadata.SDKInfo.MinSDKVersion
+	apk.Status.TargetSDKVersion = metadata.SDKInfo.TargetSDKVersion
+	apk.Status.Permissions = xslice.Map(manifest.Permissions(), func(permission android.Permission, _ int) momov1alpha1.APKPermission {
+		return momov1alpha1.APKPermission{Name: permission.Name, Dangerous: permission.Dangerous}
+	})
+	apk.Status.RequiredFeatures = manifest.RequiredFeatures()
+	apk.Status.Debuggable = manifest.Debuggable()
+	apk.Status.TestOnly = manifest.TestOnly()
+
+	// The label is only informational, so failing to resolve it does not fail the APK.
+	if apk.Status.Label, err = apkDecoder.Label(ctx); err != nil {
+		r.Eventf(apk, corev1.EventTypeWarning, "Label", "Failed to resolve label: %v", err)
+	}
+
+	if apk.Status.SupportedABIs, err = apkDecoder.ABIs(ctx); err != nil {
+		apk.Status.Phase = momov1alpha1.PhaseFailed
+		setCondition(apk, metav1.Condition{
+			Type:    "UnpackAPK",
+			Reason:  "ABIs",
+			Status:  metav1.ConditionFalse,
+			Message: err.Error(),
+		})
+
+		return ctrl.Result{}, ignoreNotFound(r.Status().Update(ctx, apk))
+	}
+
+	if apk.Status.Densities, err = apkDecoder.Densities(ctx); err != nil {
+		apk.Status.Phase = momov1alpha1.PhaseFailed
+		setCondition(apk, metav1.Condition{
+			Type:    "UnpackAPK",
+			Reason:  "Densities",
+			Status:  metav1.ConditionFalse,
+			Message: err.Error(),
+		})
+
+		return ctrl.Result{}, ignoreNotFound(r.Status().Update(ctx, apk))
+	}
+
+	if dec, ok := apkDecoder.(*android.APKSetDecoder); ok {
+		splits, err := dec.Splits(ctx)
+		if err != nil {
+			apk.Status.Phase = momov1alpha1.PhaseFailed
+			setCondition(apk, metav1.Condition{
+				Type:    "UnpackAPK",
+				Reason:  "Splits",
+				Status:  metav1.ConditionFalse,
+				Message: err.Error(),
+			})
+
+			return ctrl.Result{}, ignoreNotFound(r.Status().Update(ctx, apk))
+		}
+
+		apk.Status.Splits = xslice.Map(splits, func(split android.Split, _ int) momov1alpha1.APKSplit {
+			return momov1alpha1.APKSplit(split)
+		})
+	}
+
+	if dec, ok := apkDecoder.(universalAPKDecoder); ok {
+		start = time.Now()
+		apk.Status.UniversalAPKKey, err = uploadUniversalAPK(ctx, cli, dec, apk)
+		momoutil.ObserveDecode(momoutil.PlatformAndroid, momoutil.DecodeStepBundletool, start, err)
+		if err != nil {
+			apk.Status.Phase = momov1alpha1.PhaseFailed
+			setCondition(apk, metav1.Condition{
+				Type:    "UnpackAPK",
+				Reason:  "UniversalAPK",
+				Status:  metav1.ConditionFalse,
+				Message: err.Error(),
+			})
+
+			return ctrl.Result{}, ignoreNotFound(r.Status().Update(ctx, apk))
+		}
+	}
 
 	if err := r.Client.Status().Update(ctx, apk); err != nil {
 		return ctrl.Result{}, ignoreNotFound(err)
@@ -169,7 +381,9 @@ func (r *APKReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.R
 		}
 	}
 
+	start = time.Now()
 	apk.Status.Icons, err = unpackIcons(ctx, cli, apkDecoder, apk, r.EventRecorder)
+	momoutil.ObserveDecode(momoutil.PlatformAndroid, momoutil.DecodeStepIcons, start, err)
 	if err != nil {
 		apk.Status.Phase = momov1alpha1.PhaseFailed
 		setCondition(apk, metav1.Condition{
@@ -183,42 +397,102 @@ func (r *APKReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.R
 	}
 
 	apk.Status.Digest = dig.String()
-	apk.Status.Phase = momov1alpha1.PhaseReady
+	apk.Status.ObjectAttributes = attrs
+	apk.Status.DecoderVersion = APKDecoderVersion
+	resetScanned(apk)
 	setCondition(apk, metav1.Condition{
 		Type:   "UnpackAPK",
 		Reason: "Unpacked",
 		Status: metav1.ConditionTrue,
 	})
 
-	if err := r.Client.Status().Update(ctx, apk); err != nil {
-		return ctrl.Result{}, ignoreNotFound(err)
+	if res, err := ready(ctx, r, r, r.Scanner, cli, apk); err != nil || apk.Status.Phase != momov1alpha1.PhaseReady {
+		return res, err
 	}
 
 	return ctrl.Result{RequeueAfter: time.Minute * 9}, nil
 }
 
+// apkArchiveExt returns the extension of apk's object if it is an Android
+// App Bundle or a split APK set, or momo.ExtAPK if it is neither.
+func apkArchiveExt(apk *momov1alpha1.APK) string {
+	ext := strings.ToLower(filepath.Ext(apk.Spec.Key))
+	switch ext {
+	case momo.ExtAAB, momo.ExtAPKS, momo.ExtXAPK:
+		return ext
+	}
+
+	return momo.ExtAPK
+}
+
+// universalAPKDecoder is implemented by *android.AABDecoder and *android.APKSetDecoder.
+type universalAPKDecoder interface {
+	UniversalAPK(context.Context) (string, error)
+}
+
+// uploadUniversalAPK uploads the universal APK that dec builds or finds next to apk's
+// object, returning its key. If there is no universal APK, it returns an empty key.
+func uploadUniversalAPK(ctx context.Context, cli *momoutil.BucketHandle, dec universalAPKDecoder, apk *momov1alpha1.APK) (_ string, err error) {
+	ctx, span := momoutil.Tracer.Start(ctx, "uploadUniversalAPK")
+	defer func() {
+		momoutil.EndSpan(span, err)
+	}()
+
+	universal, err := dec.UniversalAPK(ctx)
+	if err != nil || universal == "" {
+		return "", err
+	}
+
+	key := strings.TrimSuffix(apk.Spec.Key, filepath.Ext(apk.Spec.Key)) + momo.ExtAPK
+
+	if err := momoutil.UploadFile(ctx, cli, key, android.ContentTypeAPK, universal); err != nil {
+		return "", err
+	}
+
+	return key, nil
+}
+
 // SetupWithManager sets up the controller with the Manager.
 func (r *APKReconciler) SetupWithManager(mgr ctrl.Manager) error {
 	r.Client = mgr.GetClient()
 	r.EventRecorder = mgr.GetEventRecorderFor("momo")
+	if r.Buckets == nil {
+		r.Buckets = &momoutil.BucketManager{}
+	}
+	if err := indexBucketName(mgr, &momov1alpha1.APK{}); err != nil {
+		return err
+	}
 	return ctrl.NewControllerManagedBy(mgr).
-		For(&momov1alpha1.APK{}, builder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		For(&momov1alpha1.APK{}, builder.WithPredicates(
+			// Annotations are watched for momoutil.AnnotationMigrateTo.
+			predicate.Or(predicate.GenerationChangedPredicate{}, predicate.AnnotationChangedPredicate{}),
+		)).
+		Owns(&batchv1.Job{}).
 		Watches(&momov1alpha1.Bucket{}, r.EventHandler()).
+		Watches(&momov1alpha1.ClusterBucket{}, r.EventHandler()).
 		Named("apk").
 		Complete(r)
 }
 
 func (r *APKReconciler) EventHandler() handler.EventHandler {
 	return handler.EnqueueRequestsFromMapFunc(func(ctx context.Context, obj client.Object) []ctrl.Request {
-		apks := &momov1alpha1.APKList{}
+		var (
+			apks       = &momov1alpha1.APKList{}
+			bucket, ok = obj.(momov1alpha1.BucketObject)
+		)
+
+		if !ok {
+			return []ctrl.Request{}
+		}
 
-		if err := r.List(ctx, apks, &client.ListOptions{Namespace: obj.GetNamespace()}); err != nil {
+		// ClusterBuckets are not namespaced, so this lists APKs in every namespace for them.
+		if err := r.List(ctx, apks, client.InNamespace(obj.GetNamespace()), client.MatchingFields{IndexBucketName: bucket.GetName()}); err != nil {
 			return []ctrl.Request{}
 		}
 
 		return xslice.Map(
 			xslice.Filter(apks.Items, func(apk momov1alpha1.APK, _ int) bool {
-				return apk.Spec.Bucket.Name == obj.GetName()
+				return apk.Spec.Bucket.Refers(apk.Namespace, bucket)
 			}),
 			func(apk momov1alpha1.APK, _ int) ctrl.Request {
 				return ctrl.Request{NamespacedName: client.ObjectKeyFromObject(&apk)}
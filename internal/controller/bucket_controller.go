@@ -1,7 +1,12 @@
 package controller
 
 import (
+	"bytes"
 	"context"
+	"errors"
+	"fmt"
+	"io"
+	"path"
 	"time"
 
 	momov1alpha1 "github.com/frantjc/momo/api/v1alpha1"
@@ -23,11 +28,27 @@ import (
 type BucketReconciler struct {
 	client.Client
 	record.EventRecorder
+	Buckets *momoutil.BucketManager
+	// UsageInterval is how often the storage used by the objects in each
+	// Bucket is computed. If it is zero, it is not, as doing so lists
+	// every object in the Bucket.
+	UsageInterval time.Duration
 }
 
+const (
+	// DefaultBucketUsageInterval is the default UsageInterval
+	// of BucketReconcilers and ClusterBucketReconcilers.
+	DefaultBucketUsageInterval = time.Hour
+)
+
+const (
+	probeKeyPrefix = ".momo/probe"
+)
+
 // +kubebuilder:rbac:groups=momo.frantj.cc,resources=buckets,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=momo.frantj.cc,resources=buckets/status,verbs=get;update;patch
 // +kubebuilder:rbac:groups="",resources=configmaps;secrets,verbs=get;list;watch
+// +kubebuilder:rbac:groups=momo.frantj.cc,resources=apks;ipas,verbs=get;list;watch;update;patch
 
 // Reconcile is part of the main kubernetes reconciliation loop which aims to
 // move the current state of the cluster closer to the desired state.
@@ -38,23 +59,52 @@ func (r *BucketReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctr
 	)
 
 	if err := r.Get(ctx, req.NamespacedName, bucket); err != nil {
+		if apierrors.IsNotFound(err) {
+			r.Buckets.Evict(req.NamespacedName)
+		}
+
 		return ctrl.Result{}, ignoreNotFound(err)
 	}
 
-	if bucket.Status.Phase != momov1alpha1.PhasePending {
-		bucket.Status.Phase = momov1alpha1.PhasePending
+	return reconcileBucket(ctx, r.Client, r.EventRecorder, r.Buckets, r.UsageInterval, bucket)
+}
 
-		if err := r.Client.Status().Update(ctx, bucket); err != nil {
+// reconcileBucket opens and probes a Bucket or ClusterBucket, computing its usage and
+// handing off the migration of the APKs and IPAs that reference it if requested.
+func reconcileBucket(ctx context.Context, cli client.Client, recorder record.EventRecorder, buckets *momoutil.BucketManager, usageInterval time.Duration, bucket momov1alpha1.BucketObject) (_ ctrl.Result, err error) {
+	var (
+		status = bucket.GetBucketStatus()
+	)
+
+	spanName := "BucketReconciler.Reconcile"
+	if _, ok := bucket.(*momov1alpha1.ClusterBucket); ok {
+		spanName = "ClusterBucketReconciler.Reconcile"
+	}
+
+	ctx, span := startSpan(ctx, spanName, bucket, true)
+	defer func() {
+		momoutil.EndSpan(span, err)
+	}()
+
+	// This Bucket or the ConfigMap or Secret that it references may have changed,
+	// so make sure that it gets opened with its latest URL.
+	buckets.Invalidate(client.ObjectKeyFromObject(bucket))
+
+	if status.Phase != momov1alpha1.PhasePending {
+		status.Phase = momov1alpha1.PhasePending
+
+		if err := cli.Status().Update(ctx, bucket); err != nil {
 			return ctrl.Result{}, ignoreNotFound(err)
 		}
 	}
 
-	if _, err := momoutil.OpenBucket(ctx, r.Client, bucket); err != nil {
+	b, err := buckets.OpenBucket(ctx, cli, bucket)
+	if err != nil {
 		if apierrors.IsNotFound(err) {
 			return ctrl.Result{}, nil
 		}
 
-		bucket.Status.Phase = momov1alpha1.PhaseFailed
+		status.Phase = momov1alpha1.PhaseFailed
 		setCondition(bucket, metav1.Condition{
 			Type:    "Opened",
 			Reason:  "FailedToOpen",
@@ -62,57 +112,370 @@ func (r *BucketReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctr
 			Message: err.Error(),
 		})
 
-		return ctrl.Result{}, ignoreNotFound(r.Client.Status().Update(ctx, bucket))
+		return ctrl.Result{}, ignoreNotFound(cli.Status().Update(ctx, bucket))
 	}
+	defer func() {
+		_ = b.Close()
+	}()
 
-	bucket.Status.Phase = momov1alpha1.PhaseReady
 	setCondition(bucket, metav1.Condition{
 		Type:   "Opened",
 		Reason: "BucketOpened",
 		Status: metav1.ConditionTrue,
 	})
 
-	if err := r.Client.Status().Update(ctx, bucket); err != nil {
+	if !probeBucket(ctx, bucket, b) {
+		status.Phase = momov1alpha1.PhaseFailed
+
+		if err := cli.Status().Update(ctx, bucket); err != nil {
+			return ctrl.Result{}, ignoreNotFound(err)
+		}
+
+		return ctrl.Result{RequeueAfter: time.Minute}, nil
+	}
+
+	if usageInterval > 0 && (status.Usage == nil || time.Since(status.Usage.LastUpdateTime.Time) >= usageInterval) {
+		usage, err := bucketUsage(ctx, cli, bucket, b)
+		if err != nil {
+			recorder.Eventf(bucket, corev1.EventTypeWarning, "ComputeUsage", "Computing usage: %v", err)
+		} else {
+			status.Usage = usage
+		}
+	}
+
+	status.Phase = momov1alpha1.PhaseReady
+
+	if err := cli.Status().Update(ctx, bucket); err != nil {
 		return ctrl.Result{}, ignoreNotFound(err)
 	}
 
+	if to := bucket.GetAnnotations()[momoutil.AnnotationMigrateTo]; to != "" {
+		if err := migrateBucket(ctx, cli, recorder, bucket, momov1alpha1.ParseBucketReference(to)); err != nil {
+			return ctrl.Result{}, ignoreNotFound(err)
+		}
+	}
+
 	return ctrl.Result{RequeueAfter: time.Minute * 9}, nil
 }
 
+// appsReferencing lists the APKs and IPAs that reference the Bucket or ClusterBucket.
+func appsReferencing(ctx context.Context, cli client.Client, bucket momov1alpha1.BucketObject) ([]client.Object, error) {
+	var (
+		apks = &momov1alpha1.APKList{}
+		ipas = &momov1alpha1.IPAList{}
+		objs = []client.Object{}
+	)
+
+	// ClusterBuckets are not namespaced, so this lists APKs and IPAs in every namespace for them.
+	// The IndexBucketName index is set up by the APKReconciler and IPAReconciler.
+	if err := cli.List(ctx, apks, client.InNamespace(bucket.GetNamespace()), client.MatchingFields{IndexBucketName: bucket.GetName()}); err != nil {
+		return nil, err
+	}
+
+	for _, apk := range apks.Items {
+		if apk.Spec.Bucket.Refers(apk.Namespace, bucket) {
+			objs = append(objs, &apk)
+		}
+	}
+
+	if err := cli.List(ctx, ipas, client.InNamespace(bucket.GetNamespace()), client.MatchingFields{IndexBucketName: bucket.GetName()}); err != nil {
+		return nil, err
+	}
+
+	for _, ipa := range ipas.Items {
+		if ipa.Spec.Bucket.Refers(ipa.Namespace, bucket) {
+			objs = append(objs, &ipa)
+		}
+	}
+
+	return objs, nil
+}
+
+// migrateBucket hands the migration of every APK and IPA that references the Bucket off
+// to their respective controllers by annotating each of them with the target Bucket.
+func migrateBucket(ctx context.Context, cli client.Client, recorder record.EventRecorder, bucket momov1alpha1.BucketObject, to momov1alpha1.BucketReference) error {
+	objs, err := appsReferencing(ctx, cli, bucket)
+	if err != nil {
+		return err
+	}
+
+	for _, obj := range objs {
+		patch := client.MergeFrom(obj.DeepCopyObject().(client.Object))
+		annotations := obj.GetAnnotations()
+		if annotations == nil {
+			annotations = map[string]string{}
+		}
+		annotations[momoutil.AnnotationMigrateTo] = to.String()
+		obj.SetAnnotations(annotations)
+
+		if err := cli.Patch(ctx, obj, patch); ignoreNotFound(err) != nil {
+			return err
+		}
+	}
+
+	recorder.Eventf(bucket, corev1.EventTypeNormal, "Migrating", "Migrating %d APKs and IPAs to Bucket %s", len(objs), to)
+
+	patch := client.MergeFrom(bucket.DeepCopyObject().(client.Object))
+	annotations := bucket.GetAnnotations()
+	delete(annotations, momoutil.AnnotationMigrateTo)
+	bucket.SetAnnotations(annotations)
+
+	return cli.Patch(ctx, bucket, patch)
+}
+
+// probeBucket checks that the Bucket can actually be used, since opening one
+// does not touch the network for most drivers. It sets the Readable and Writable
+// conditions on the Bucket and returns whether or not the probe succeeded.
+func probeBucket(ctx context.Context, bucket momov1alpha1.BucketObject, b *momoutil.BucketHandle) bool {
+	start := time.Now()
+	ok, err := b.IsAccessible(ctx)
+	momoutil.ObserveBucketProbe(b.Driver, "read", start)
+
+	if err != nil || !ok {
+		if err = momoutil.ObserveBucketError(b.Driver, "read", err); err == nil {
+			err = fmt.Errorf("bucket does not exist")
+		}
+
+		setCondition(bucket, metav1.Condition{
+			Type:    "Readable",
+			Reason:  "NotAccessible",
+			Status:  metav1.ConditionFalse,
+			Message: err.Error(),
+		})
+
+		return false
+	}
+
+	setCondition(bucket, metav1.Condition{
+		Type:    "Readable",
+		Reason:  "Accessible",
+		Status:  metav1.ConditionTrue,
+		Message: fmt.Sprintf("Accessed in under %s", latency(start)),
+	})
+
+	if bucket.GetBucketSpec().Probe.ReadOnly {
+		setCondition(bucket, metav1.Condition{
+			Type:   "Writable",
+			Reason: "ReadOnly",
+			Status: metav1.ConditionUnknown,
+		})
+
+		return true
+	}
+
+	var (
+		key  = path.Join(probeKeyPrefix, string(bucket.GetUID()))
+		data = []byte(bucket.GetName())
+	)
+
+	start = time.Now()
+	defer momoutil.ObserveBucketProbe(b.Driver, "write", start)
+
+	if err := b.WriteAll(ctx, key, data, nil); err != nil {
+		_ = momoutil.ObserveBucketError(b.Driver, "write", err)
+		setCondition(bucket, metav1.Condition{
+			Type:    "Writable",
+			Reason:  "WriteObject",
+			Status:  metav1.ConditionFalse,
+			Message: err.Error(),
+		})
+
+		return false
+	}
+
+	if read, err := b.ReadAll(ctx, key); err != nil {
+		_ = momoutil.ObserveBucketError(b.Driver, "read", err)
+		setCondition(bucket, metav1.Condition{
+			Type:    "Writable",
+			Reason:  "ReadObject",
+			Status:  metav1.ConditionFalse,
+			Message: err.Error(),
+		})
+
+		return false
+	} else if !bytes.Equal(read, data) {
+		setCondition(bucket, metav1.Condition{
+			Type:    "Writable",
+			Reason:  "ReadObject",
+			Status:  metav1.ConditionFalse,
+			Message: fmt.Sprintf("read different bytes from %s than were written", key),
+		})
+
+		return false
+	}
+
+	if err := b.Delete(ctx, key); err != nil {
+		_ = momoutil.ObserveBucketError(b.Driver, "delete", err)
+		setCondition(bucket, metav1.Condition{
+			Type:    "Writable",
+			Reason:  "DeleteObject",
+			Status:  metav1.ConditionFalse,
+			Message: err.Error(),
+		})
+
+		return false
+	}
+
+	setCondition(bucket, metav1.Condition{
+		Type:    "Writable",
+		Reason:  "Probed",
+		Status:  metav1.ConditionTrue,
+		Message: fmt.Sprintf("Wrote, read and deleted %s in under %s", key, latency(start)),
+	})
+
+	return true
+}
+
+// latencyBuckets are the upper bounds that latency rounds up to.
+var latencyBuckets = []time.Duration{
+	time.Millisecond * 10,
+	time.Millisecond * 50,
+	time.Millisecond * 100,
+	time.Millisecond * 250,
+	time.Millisecond * 500,
+	time.Second,
+	time.Second * 5,
+	time.Second * 10,
+}
+
+// latency rounds the time since start up to the nearest of latencyBuckets so that
+// it reads well in a condition's message, and so that the message only changes,
+// updating the Bucket's status, when the latency changes significantly.
+func latency(start time.Time) time.Duration {
+	elapsed := time.Since(start)
+
+	for _, upper := range latencyBuckets {
+		if elapsed < upper {
+			return upper
+		}
+	}
+
+	return elapsed.Truncate(time.Second*10) + time.Second*10
+}
+
+// bucketUsage computes the number and size of the objects in the Bucket
+// that are referenced by APKs and IPAs, i.e. the ones that momo manages.
+func bucketUsage(ctx context.Context, cli client.Client, bucket momov1alpha1.BucketObject, b *momoutil.BucketHandle) (*momov1alpha1.BucketStatusUsage, error) {
+	objs, err := appsReferencing(ctx, cli, bucket)
+	if err != nil {
+		return nil, err
+	}
+
+	keys := map[string]bool{}
+
+	for _, obj := range objs {
+		prefix := momoutil.BucketKeyPrefix(bucket, obj.GetNamespace())
+
+		switch app := obj.(type) {
+		case *momov1alpha1.APK:
+			keys[prefix+app.Spec.Key] = true
+			if app.Status.UniversalAPKKey != "" {
+				keys[prefix+app.Status.UniversalAPKKey] = true
+			}
+			for _, icon := range app.Status.Icons {
+				keys[prefix+icon.Key] = true
+			}
+		case *momov1alpha1.IPA:
+			keys[prefix+app.Spec.Key] = true
+			if app.Spec.DSYMsKey != "" {
+				keys[prefix+app.Spec.DSYMsKey] = true
+			}
+			for _, icon := range app.Status.Icons {
+				keys[prefix+icon.Key] = true
+			}
+		}
+	}
+
+	var (
+		usage = &momov1alpha1.BucketStatusUsage{LastUpdateTime: metav1.Now()}
+		iter  = b.List(nil)
+	)
+
+	for {
+		obj, err := iter.Next(ctx)
+		if errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return nil, momoutil.ObserveBucketError(b.Driver, "list", err)
+		}
+
+		if keys[obj.Key] {
+			usage.Objects++
+			usage.Bytes += obj.Size
+		}
+	}
+
+	return usage, nil
+}
+
 // SetupWithManager sets up the controller with the Manager.
 func (r *BucketReconciler) SetupWithManager(mgr ctrl.Manager) error {
 	r.Client = mgr.GetClient()
 	r.EventRecorder = mgr.GetEventRecorderFor("momo")
+	if r.Buckets == nil {
+		r.Buckets = &momoutil.BucketManager{}
+	}
+	if err := indexBucketRefs(mgr, &momov1alpha1.Bucket{}); err != nil {
+		return err
+	}
 	return ctrl.NewControllerManagedBy(mgr).
-		For(&momov1alpha1.Bucket{}, builder.WithPredicates(predicate.GenerationChangedPredicate{})).
-		Watches(&corev1.Secret{}, r.EventHandler(func(bucket momov1alpha1.Bucket, obj client.Object) bool {
-			return bucket.Namespace == obj.GetNamespace() &&
-				bucket.Spec.URLFrom != nil &&
-				bucket.Spec.URLFrom.SecretKeyRef != nil &&
-				bucket.Spec.URLFrom.SecretKeyRef.Name == obj.GetName()
-		})).
-		Watches(&corev1.ConfigMap{}, r.EventHandler(func(bucket momov1alpha1.Bucket, obj client.Object) bool {
-			return bucket.Namespace == obj.GetNamespace() &&
-				bucket.Spec.URLFrom != nil &&
-				bucket.Spec.URLFrom.ConfigMapKeyRef != nil &&
-				bucket.Spec.URLFrom.ConfigMapKeyRef.Name == obj.GetName()
-		})).
+		For(&momov1alpha1.Bucket{}, builder.WithPredicates(
+			// Annotations are watched for momoutil.AnnotationMigrateTo.
+			predicate.Or(predicate.GenerationChangedPredicate{}, predicate.AnnotationChangedPredicate{}),
+		)).
+		Watches(&corev1.Secret{}, r.EventHandler(IndexSecretNames)).
+		Watches(&corev1.ConfigMap{}, r.EventHandler(IndexConfigMapNames)).
 		Named("bucket").
 		Complete(r)
 }
 
-func (r *BucketReconciler) EventHandler(filter func(bucket momov1alpha1.Bucket, obj client.Object) bool) handler.EventHandler {
+// bucketSecretNames returns the names of the Secrets
+// that the Bucket's URL or env are read from.
+func bucketSecretNames(spec *momov1alpha1.BucketSpec) []string {
+	names := []string{}
+
+	if spec.URLFrom != nil && spec.URLFrom.SecretKeyRef != nil {
+		names = append(names, spec.URLFrom.SecretKeyRef.Name)
+	}
+
+	for _, env := range spec.Env {
+		if env.ValueFrom != nil && env.ValueFrom.SecretKeyRef != nil {
+			names = append(names, env.ValueFrom.SecretKeyRef.Name)
+		}
+	}
+
+	return xslice.Unique(names)
+}
+
+// bucketConfigMapNames returns the names of the ConfigMaps
+// that the Bucket's URL or env are read from.
+func bucketConfigMapNames(spec *momov1alpha1.BucketSpec) []string {
+	names := []string{}
+
+	if spec.URLFrom != nil && spec.URLFrom.ConfigMapKeyRef != nil {
+		names = append(names, spec.URLFrom.ConfigMapKeyRef.Name)
+	}
+
+	for _, env := range spec.Env {
+		if env.ValueFrom != nil && env.ValueFrom.ConfigMapKeyRef != nil {
+			names = append(names, env.ValueFrom.ConfigMapKeyRef.Name)
+		}
+	}
+
+	return xslice.Unique(names)
+}
+
+// EventHandler enqueues the Buckets that reference obj, looked up by obj's name in the given index.
+func (r *BucketReconciler) EventHandler(index string) handler.EventHandler {
 	return handler.EnqueueRequestsFromMapFunc(func(ctx context.Context, obj client.Object) []ctrl.Request {
 		buckets := &momov1alpha1.BucketList{}
 
-		if err := r.List(ctx, buckets, &client.ListOptions{Namespace: obj.GetNamespace()}); err != nil {
+		if err := r.List(ctx, buckets, client.InNamespace(obj.GetNamespace()), client.MatchingFields{index: obj.GetName()}); err != nil {
 			return []ctrl.Request{}
 		}
 
 		return xslice.Map(
-			xslice.Filter(buckets.Items, func(bucket momov1alpha1.Bucket, _ int) bool {
-				return filter(bucket, obj)
-			}),
+			buckets.Items,
 			func(bucket momov1alpha1.Bucket, _ int) ctrl.Request {
 				return ctrl.Request{NamespacedName: client.ObjectKeyFromObject(&bucket)}
 			},
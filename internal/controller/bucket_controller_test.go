@@ -0,0 +1,173 @@
+package controller
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	momov1alpha1 "github.com/frantjc/momo/api/v1alpha1"
+	"github.com/frantjc/momo/internal/momoutil"
+	_ "gocloud.dev/blob/memblob"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestProbeBucket(t *testing.T) {
+	scheme, err := momoutil.NewScheme(corev1.AddToScheme, momov1alpha1.AddToScheme)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var (
+		ctx    = context.Background()
+		bucket = &momov1alpha1.Bucket{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "probe", UID: "probe"},
+			Spec:       momov1alpha1.BucketSpec{URL: "mem://probe"},
+		}
+		cli     = fake.NewClientBuilder().WithScheme(scheme).WithObjects(bucket).Build()
+		buckets = &momoutil.BucketManager{}
+	)
+	defer func() {
+		_ = buckets.Close()
+	}()
+
+	b, err := buckets.OpenBucket(ctx, cli, bucket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = b.Close()
+	}()
+
+	if !probeBucket(ctx, bucket, b) {
+		t.Fatalf("expected the probe to succeed, got conditions %+v", bucket.Status.Conditions)
+	}
+
+	for _, conditionType := range []string{"Readable", "Writable"} {
+		if !meta.IsStatusConditionTrue(bucket.Status.Conditions, conditionType) {
+			t.Errorf("expected condition %s to be true, got %+v", conditionType, meta.FindStatusCondition(bucket.Status.Conditions, conditionType))
+		}
+	}
+
+	if ok, err := b.Exists(ctx, probeKeyPrefix+"/"+string(bucket.UID)); err != nil || ok {
+		t.Errorf("expected the probe's object to be deleted, got %t, %v", ok, err)
+	}
+
+	// Conditions' messages round latency up, so that probing does not
+	// update the Bucket's status unless it changes significantly.
+	conditions := bucket.DeepCopy().Status.Conditions
+
+	if !probeBucket(ctx, bucket, b) {
+		t.Fatalf("expected the probe to succeed again, got conditions %+v", bucket.Status.Conditions)
+	}
+
+	for _, condition := range conditions {
+		if probed := meta.FindStatusCondition(bucket.Status.Conditions, condition.Type); probed == nil || probed.Message != condition.Message {
+			t.Errorf("expected the message of condition %s to be stable, got %q and then %+v", condition.Type, condition.Message, probed)
+		}
+	}
+
+	bucket.Spec.Probe.ReadOnly = true
+
+	if !probeBucket(ctx, bucket, b) {
+		t.Fatalf("expected the read-only probe to succeed, got conditions %+v", bucket.Status.Conditions)
+	}
+
+	if writable := meta.FindStatusCondition(bucket.Status.Conditions, "Writable"); writable == nil || writable.Status != metav1.ConditionUnknown {
+		t.Errorf("expected a read-only Bucket to not be known to be writable, got %+v", writable)
+	}
+}
+
+func TestBucketUsage(t *testing.T) {
+	scheme, err := momoutil.NewScheme(corev1.AddToScheme, momov1alpha1.AddToScheme)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var (
+		ctx    = context.Background()
+		bucket = &momov1alpha1.Bucket{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "usage"},
+			Spec:       momov1alpha1.BucketSpec{URL: "mem://usage"},
+		}
+		apk = &momov1alpha1.APK{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "app"},
+			Spec:       momov1alpha1.APKSpec{Bucket: momov1alpha1.BucketReference{Name: bucket.Name}, Key: "app.apk"},
+			Status: momov1alpha1.APKStatus{
+				UniversalAPKKey: "default/app/universal.apk",
+				Icons:           []momov1alpha1.AppStatusIcon{{Key: "default/app/icon.png", Size: 57}},
+			},
+		}
+		ipa = &momov1alpha1.IPA{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "app"},
+			Spec:       momov1alpha1.IPASpec{Bucket: momov1alpha1.BucketReference{Name: bucket.Name}, Key: "app.ipa", DSYMsKey: "app.dSYMs.zip"},
+		}
+		indexBucketName = func(obj client.Object) []string {
+			return []string{obj.(BinaryObject).GetBucket().Name}
+		}
+		cli = fake.NewClientBuilder().
+			WithScheme(scheme).
+			WithObjects(bucket, apk, ipa).
+			WithStatusSubresource(bucket).
+			WithIndex(&momov1alpha1.APK{}, IndexBucketName, indexBucketName).
+			WithIndex(&momov1alpha1.IPA{}, IndexBucketName, indexBucketName).
+			Build()
+		buckets = &momoutil.BucketManager{}
+	)
+	defer func() {
+		_ = buckets.Close()
+	}()
+
+	b, err := buckets.OpenBucket(ctx, cli, bucket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = b.Close()
+	}()
+
+	for key, data := range map[string]string{
+		apk.Spec.Key:               "apk",
+		apk.Status.UniversalAPKKey: "universal",
+		apk.Status.Icons[0].Key:    "icon",
+		ipa.Spec.Key:               "ipa",
+		ipa.Spec.DSYMsKey:          "dsyms",
+		"not-referenced-by-an-app": "other",
+	} {
+		if err := b.WriteAll(ctx, key, []byte(data), nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	usage, err := bucketUsage(ctx, cli, bucket, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if usage.Objects != 5 || usage.Bytes != int64(len("apk")+len("universal")+len("icon")+len("ipa")+len("dsyms")) {
+		t.Errorf("expected the usage of the 5 objects of apps, got %+v", usage)
+	}
+
+	// Usage is not computed if it is opted out of, as it lists every object in the Bucket.
+	recorder := record.NewFakeRecorder(10)
+
+	if _, err := reconcileBucket(ctx, cli, recorder, buckets, 0, bucket); err != nil {
+		t.Fatal(err)
+	}
+
+	if bucket.Status.Usage != nil {
+		t.Errorf("expected no usage without a usage interval, got %+v", bucket.Status.Usage)
+	}
+
+	if _, err := reconcileBucket(ctx, cli, recorder, buckets, time.Hour, bucket); err != nil {
+		t.Fatal(err)
+	}
+
+	if bucket.Status.Usage == nil || bucket.Status.Usage.Objects != 5 {
+		t.Errorf("expected the usage of the 5 objects of apps, got %+v", bucket.Status.Usage)
+	}
+}
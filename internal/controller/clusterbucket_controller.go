@@ -0,0 +1,94 @@
+package controller
+
+import (
+	"context"
+	"time"
+
+	momov1alpha1 "github.com/frantjc/momo/api/v1alpha1"
+	"github.com/frantjc/momo/internal/momoutil"
+	xslice "github.com/frantjc/x/slice"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+)
+
+// ClusterBucketReconciler reconciles a ClusterBucket object
+type ClusterBucketReconciler struct {
+	client.Client
+	record.EventRecorder
+	Buckets *momoutil.BucketManager
+	// UsageInterval is how often the storage used by the objects in each
+	// ClusterBucket is computed. If it is zero, it is not, as doing so lists
+	// every object in the ClusterBucket.
+	UsageInterval time.Duration
+}
+
+// +kubebuilder:rbac:groups=momo.frantj.cc,resources=clusterbuckets,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=momo.frantj.cc,resources=clusterbuckets/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups="",resources=namespaces,verbs=get;list;watch
+
+// Reconcile is part of the main kubernetes reconciliation loop which aims to
+// move the current state of the cluster closer to the desired state.
+func (r *ClusterBucketReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	var (
+		_      = log.FromContext(ctx)
+		bucket = &momov1alpha1.ClusterBucket{}
+	)
+
+	if err := r.Get(ctx, req.NamespacedName, bucket); err != nil {
+		if apierrors.IsNotFound(err) {
+			r.Buckets.Evict(req.NamespacedName)
+		}
+
+		return ctrl.Result{}, ignoreNotFound(err)
+	}
+
+	return reconcileBucket(ctx, r.Client, r.EventRecorder, r.Buckets, r.UsageInterval, bucket)
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *ClusterBucketReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	r.Client = mgr.GetClient()
+	r.EventRecorder = mgr.GetEventRecorderFor("momo")
+	if r.Buckets == nil {
+		r.Buckets = &momoutil.BucketManager{}
+	}
+	if err := indexBucketRefs(mgr, &momov1alpha1.ClusterBucket{}); err != nil {
+		return err
+	}
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&momov1alpha1.ClusterBucket{}, builder.WithPredicates(
+			// Annotations are watched for momoutil.AnnotationMigrateTo.
+			predicate.Or(predicate.GenerationChangedPredicate{}, predicate.AnnotationChangedPredicate{}),
+		)).
+		Watches(&corev1.Secret{}, r.EventHandler(IndexSecretNames)).
+		Watches(&corev1.ConfigMap{}, r.EventHandler(IndexConfigMapNames)).
+		Named("clusterbucket").
+		Complete(r)
+}
+
+// EventHandler enqueues the ClusterBuckets that reference obj, looked up by obj's name in the given index.
+func (r *ClusterBucketReconciler) EventHandler(index string) handler.EventHandler {
+	return handler.EnqueueRequestsFromMapFunc(func(ctx context.Context, obj client.Object) []ctrl.Request {
+		buckets := &momov1alpha1.ClusterBucketList{}
+
+		if err := r.List(ctx, buckets, client.MatchingFields{index: obj.GetName()}); err != nil {
+			return []ctrl.Request{}
+		}
+
+		return xslice.Map(
+			xslice.Filter(buckets.Items, func(bucket momov1alpha1.ClusterBucket, _ int) bool {
+				return bucket.Spec.ResourceNamespace == obj.GetNamespace()
+			}),
+			func(bucket momov1alpha1.ClusterBucket, _ int) ctrl.Request {
+				return ctrl.Request{NamespacedName: client.ObjectKeyFromObject(&bucket)}
+			},
+		)
+	})
+}
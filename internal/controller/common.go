@@ -3,6 +3,7 @@ package controller
 import (
 	"archive/tar"
 	"context"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"image"
@@ -16,7 +17,8 @@ import (
 	momov1alpha1 "github.com/frantjc/momo/api/v1alpha1"
 	"github.com/frantjc/momo/internal/momoutil"
 	"github.com/opencontainers/go-digest"
-	"gocloud.dev/blob"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 	"gocloud.dev/gcerrors"
 	corev1 "k8s.io/api/core/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
@@ -70,7 +72,11 @@ func setCondition(obj Object, condition metav1.Condition) bool {
 
 type BinaryObject interface {
 	GetKey() string
+	GetBucket() momov1alpha1.BucketReference
 	GetIcons() []momov1alpha1.AppStatusIcon
+	GetObjectAttributes() *momov1alpha1.ObjectAttributes
+	SetObjectAttributes(*momov1alpha1.ObjectAttributes)
+	SetFindings([]string)
 	SetPhase(string)
 	Object
 }
@@ -79,7 +85,12 @@ type IconDecoder interface {
 	Icons(context.Context) (io.Reader, error)
 }
 
-func unpackIcons(ctx context.Context, cli *blob.Bucket, dec IconDecoder, obj BinaryObject, r record.EventRecorder) ([]momov1alpha1.AppStatusIcon, error) {
+func unpackIcons(ctx context.Context, cli *momoutil.BucketHandle, dec IconDecoder, obj BinaryObject, r record.EventRecorder) (_ []momov1alpha1.AppStatusIcon, err error) {
+	ctx, span := momoutil.Tracer.Start(ctx, "unpackIcons")
+	defer func() {
+		momoutil.EndSpan(span, err)
+	}()
+
 	icons, err := dec.Icons(ctx)
 	if err != nil {
 		return nil, err
@@ -132,8 +143,8 @@ func unpackIcons(ctx context.Context, cli *blob.Bucket, dec IconDecoder, obj Bin
 			)
 		)
 
-		if err = momoutil.UploadImage(ctx, cli, key, img); err != nil {
-			return nil, err
+		if err = momoutil.UploadImage(ctx, cli.Bucket, key, img); err != nil {
+			return nil, momoutil.ObserveBucketError(cli.Driver, "write", err)
 		}
 
 		status = append(status, momov1alpha1.AppStatusIcon{
@@ -145,6 +156,45 @@ func unpackIcons(ctx context.Context, cli *blob.Bucket, dec IconDecoder, obj Bin
 	return status, nil
 }
 
+// startSpan starts a span for reconciling obj. Until obj has been processed, the
+// span continues the trace that obj was created in, e.g. that of the upload of an
+// APK or IPA. Afterwards, it starts a new trace that only links to that one so that
+// periodic reconciles do not keep growing it.
+func startSpan(ctx context.Context, name string, obj client.Object, processed bool) (context.Context, trace.Span) {
+	var (
+		opts = []trace.SpanStartOption{
+			trace.WithAttributes(
+				attribute.String("k8s.namespace.name", obj.GetNamespace()),
+				attribute.String("momo.name", obj.GetName()),
+			),
+		}
+		parent = trace.SpanContextFromContext(momoutil.ExtractTraceContext(ctx, obj))
+	)
+
+	if parent.IsValid() {
+		if processed {
+			opts = append(opts, trace.WithNewRoot(), trace.WithLinks(trace.Link{SpanContext: parent}))
+		} else {
+			ctx = trace.ContextWithRemoteSpanContext(ctx, parent)
+		}
+	}
+
+	return momoutil.Tracer.Start(ctx, name, opts...)
+}
+
+// minRequeueAfter returns the shortest of durations that is not 0, or 0 if they all are.
+func minRequeueAfter(durations ...time.Duration) time.Duration {
+	var requeueAfter time.Duration
+
+	for _, d := range durations {
+		if d > 0 && (requeueAfter == 0 || d < requeueAfter) {
+			requeueAfter = d
+		}
+	}
+
+	return requeueAfter
+}
+
 func ignoreNotFound(err error) error {
 	if err == nil || apierrors.IsNotFound(err) {
 		return nil
@@ -153,13 +203,181 @@ func ignoreNotFound(err error) error {
 	return err
 }
 
-func downloadAndSumObject(ctx context.Context, cli client.Client, bucket *blob.Bucket, obj BinaryObject, ext, dir string) (string, digest.Digest, error) {
+// objectAttributes gets the attributes of obj's object in bucket.
+func objectAttributes(ctx context.Context, bucket *momoutil.BucketHandle, obj BinaryObject) (*momov1alpha1.ObjectAttributes, error) {
+	attrs, err := bucket.Attributes(ctx, obj.GetKey())
+	if err != nil {
+		return nil, momoutil.ObserveBucketError(bucket.Driver, "attributes", err)
+	}
+
+	objectAttributes := &momov1alpha1.ObjectAttributes{
+		ETag: attrs.ETag,
+		Size: attrs.Size,
+	}
+
+	if len(attrs.MD5) > 0 {
+		objectAttributes.MD5 = hex.EncodeToString(attrs.MD5)
+	}
+
+	if !attrs.ModTime.IsZero() {
+		// metav1.Time is serialized with second precision, so truncate
+		// to that to be able to compare with what is read back.
+		objectAttributes.ModTime = &metav1.Time{Time: attrs.ModTime.Truncate(time.Second)}
+	}
+
+	return objectAttributes, nil
+}
+
+const (
+	// APKDecoderVersion and IPADecoderVersion are bumped whenever APKs or IPAs are
+	// decoded into fields that ones that were unpacked before do not have, e.g. an
+	// APK's permissions and SDK levels or an IPA's device requirements, so that those
+	// are unpacked again even though their objects have not changed.
+	APKDecoderVersion = 1
+	IPADecoderVersion = 1
+)
+
+// objectUnchanged reports whether attrs match the attributes that obj
+// was last verified against, in which case its object does not need
+// to be downloaded and hashed again.
+func objectUnchanged(obj BinaryObject, attrs *momov1alpha1.ObjectAttributes) bool {
+	verified := obj.GetObjectAttributes()
+
+	if verified == nil || attrs == nil {
+		return false
+	}
+
+	// Size alone is not enough to tell that an object has not changed.
+	if verified.ETag == "" && verified.MD5 == "" && verified.ModTime == nil {
+		return false
+	}
+
+	return verified.ETag == attrs.ETag &&
+		verified.MD5 == attrs.MD5 &&
+		verified.Size == attrs.Size &&
+		verified.ModTime.Equal(attrs.ModTime)
+}
+
+const (
+	// AlgorithmObjectAttributes is the algorithm of digests of objects' attributes
+	// rather than their contents, which identify APKs and IPAs that are decoded with
+	// range reads so that their objects are not downloaded in full just to be hashed.
+	AlgorithmObjectAttributes digest.Algorithm = "attributes"
+)
+
+// attributesIdentify reports whether attrs identify the contents of
+// their object well enough for it to be identified by attributesDigest.
+func attributesIdentify(attrs *momov1alpha1.ObjectAttributes) bool {
+	return attrs != nil && (attrs.ETag != "" || attrs.MD5 != "")
+}
+
+// attributesDigest returns a digest of attrs with AlgorithmObjectAttributes.
+func attributesDigest(attrs *momov1alpha1.ObjectAttributes) digest.Digest {
+	return digest.NewDigestFromEncoded(
+		AlgorithmObjectAttributes,
+		digest.FromString(fmt.Sprintf("%s\n%s\n%d", attrs.ETag, attrs.MD5, attrs.Size)).Encoded(),
+	)
+}
+
+// sumObject digests obj's object by streaming it from bucket instead of downloading
+// it to disk first, for when it is to be decoded with range requests to bucket.
+func sumObject(ctx context.Context, cli client.Client, bucket *momoutil.BucketHandle, obj BinaryObject, ext string) (_ digest.Digest, err error) {
+	ctx, span := momoutil.Tracer.Start(ctx, "sumObject", trace.WithAttributes(
+		attribute.String("momo.key", obj.GetKey()),
+	))
+	defer func() {
+		momoutil.EndSpan(span, err)
+	}()
+
 	var (
-		upper = strings.ToUpper(strings.TrimPrefix(ext, "."))
+		upper    = strings.ToUpper(strings.TrimPrefix(ext, "."))
+		platform = momoutil.PlatformIOS
 	)
 
+	if ext != momo.ExtIPA {
+		platform = momoutil.PlatformAndroid
+	}
+
 	rc, err := bucket.NewReader(ctx, obj.GetKey(), nil)
 	if err != nil {
+		_ = momoutil.ObserveBucketError(bucket.Driver, "read", err)
+
+		obj.SetPhase(momov1alpha1.PhaseFailed)
+		setCondition(obj, metav1.Condition{
+			Type:    fmt.Sprintf("Get%s", upper),
+			Reason:  "ReadObject",
+			Status:  metav1.ConditionFalse,
+			Message: err.Error(),
+		})
+
+		return "", cli.Status().Update(ctx, obj)
+	}
+	defer func() {
+		_ = rc.Close()
+	}()
+
+	cr := &momoutil.CountingReader{Reader: rc}
+	dig, err := digest.FromReader(cr)
+	momoutil.ObserveDigestBytes(platform, cr.N)
+	span.SetAttributes(attribute.Int64("momo.bytes", cr.N))
+	if err != nil {
+		obj.SetPhase(momov1alpha1.PhaseFailed)
+		setCondition(obj, metav1.Condition{
+			Type:    fmt.Sprintf("Get%s", upper),
+			Reason:  "SumObject",
+			Status:  metav1.ConditionFalse,
+			Message: err.Error(),
+		})
+
+		return "", cli.Status().Update(ctx, obj)
+	}
+
+	if err = rc.Close(); err != nil {
+		obj.SetPhase(momov1alpha1.PhaseFailed)
+		setCondition(obj, metav1.Condition{
+			Type:    fmt.Sprintf("Get%s", upper),
+			Reason:  "CloseObject",
+			Status:  metav1.ConditionFalse,
+			Message: err.Error(),
+		})
+
+		return "", cli.Status().Update(ctx, obj)
+	}
+
+	if setCondition(obj, metav1.Condition{
+		Type:   fmt.Sprintf("Get%s", upper),
+		Reason: "Summed",
+		Status: metav1.ConditionTrue,
+	}) {
+		if err := cli.Status().Update(ctx, obj); err != nil {
+			return "", err
+		}
+	}
+
+	return dig, nil
+}
+
+func downloadAndSumObject(ctx context.Context, cli client.Client, bucket *momoutil.BucketHandle, obj BinaryObject, ext, dir string) (_ string, _ digest.Digest, err error) {
+	ctx, span := momoutil.Tracer.Start(ctx, "downloadAndSumObject", trace.WithAttributes(
+		attribute.String("momo.key", obj.GetKey()),
+	))
+	defer func() {
+		momoutil.EndSpan(span, err)
+	}()
+
+	var (
+		upper    = strings.ToUpper(strings.TrimPrefix(ext, "."))
+		platform = momoutil.PlatformIOS
+	)
+
+	if ext != momo.ExtIPA {
+		platform = momoutil.PlatformAndroid
+	}
+
+	rc, err := bucket.NewReader(ctx, obj.GetKey(), nil)
+	if err != nil {
+		_ = momoutil.ObserveBucketError(bucket.Driver, "read", err)
+
 		obj.SetPhase(momov1alpha1.PhaseFailed)
 		setCondition(obj, metav1.Condition{
 			Type:    fmt.Sprintf("Get%s", upper),
@@ -190,7 +408,10 @@ func downloadAndSumObject(ctx context.Context, cli client.Client, bucket *blob.B
 		_ = tmp.Close()
 	}()
 
-	if _, err := io.Copy(tmp, rc); err != nil {
+	n, err := io.Copy(tmp, rc)
+	momoutil.ObserveDigestBytes(platform, n)
+	span.SetAttributes(attribute.Int64("momo.bytes", n))
+	if err != nil {
 		obj.SetPhase(momov1alpha1.PhaseFailed)
 		setCondition(obj, metav1.Condition{
 			Type:    fmt.Sprintf("Get%s", upper),
@@ -252,20 +473,95 @@ func downloadAndSumObject(ctx context.Context, cli client.Client, bucket *blob.B
 	return tmp.Name(), dig, nil
 }
 
-func finalize(ctx context.Context, cli client.Client, recorder record.EventRecorder, bucket *blob.Bucket, obj BinaryObject) (ctrl.Result, error) {
+func finalize(ctx context.Context, cli client.Client, recorder record.EventRecorder, bucket *momoutil.BucketHandle, obj BinaryObject) (ctrl.Result, error) {
+	ctx, span := momoutil.Tracer.Start(ctx, "finalize")
+	defer span.End()
+
 	for _, icon := range obj.GetIcons() {
 		err := bucket.Delete(ctx, icon.Key)
 		switch gcerrors.Code(err) {
 		case gcerrors.NotFound, gcerrors.OK:
 		default:
+			_ = momoutil.ObserveBucketError(bucket.Driver, "delete", err)
 			recorder.Eventf(obj, corev1.EventTypeWarning, "DeleteObject", "Deleting icon %s: %v", icon.Key, err)
 			return ctrl.Result{RequeueAfter: time.Minute * 9}, nil
 		}
 	}
 
+	// Only the objects of APKs and IPAs that were pruned are deleted, as the
+	// objects of others may have been put in their Bucket by something else.
+	if _, ok := obj.GetAnnotations()[AnnotationPruned]; ok {
+		for _, key := range objectKeys(obj) {
+			err := bucket.Delete(ctx, key)
+			switch gcerrors.Code(err) {
+			case gcerrors.NotFound, gcerrors.OK:
+			default:
+				_ = momoutil.ObserveBucketError(bucket.Driver, "delete", err)
+				recorder.Eventf(obj, corev1.EventTypeWarning, "DeleteObject", "Deleting object %s: %v", key, err)
+				return ctrl.Result{RequeueAfter: time.Minute * 9}, nil
+			}
+		}
+	}
+
 	if controllerutil.RemoveFinalizer(obj, Finalizer) {
 		return ctrl.Result{}, ignoreNotFound(cli.Update(ctx, obj))
 	}
 
 	return ctrl.Result{}, nil
 }
+
+// objectKeys returns the key of obj's object and the keys
+// of the objects that were built from or uploaded with it.
+func objectKeys(obj BinaryObject) []string {
+	keys := []string{}
+
+	switch app := obj.(type) {
+	case *momov1alpha1.APK:
+		if app.Status.UniversalAPKKey != "" {
+			keys = append(keys, app.Status.UniversalAPKKey)
+		}
+	case *momov1alpha1.IPA:
+		if app.Spec.DSYMsKey != "" {
+			keys = append(keys, app.Spec.DSYMsKey)
+		}
+	}
+
+	return append(keys, obj.GetKey())
+}
+
+// migrate moves obj to the Bucket named by its momoutil.AnnotationMigrateTo annotation.
+func migrate(ctx context.Context, cli client.Client, recorder record.EventRecorder, buckets *momoutil.BucketManager, obj Object, to momov1alpha1.BucketReference) (ctrl.Result, error) {
+	mctx, span := momoutil.Tracer.Start(ctx, "MigrateApp", trace.WithAttributes(
+		attribute.String("momo.bucket", to.String()),
+	))
+	err := momoutil.MigrateApp(mctx, cli, buckets, obj, to)
+	momoutil.EndSpan(span, err)
+	if errors.Is(err, momoutil.ErrObjectsLeftBehind) {
+		// The APK or IPA now references the new Bucket, so it was migrated all the same.
+		recorder.Eventf(obj, corev1.EventTypeWarning, "Migrate", "Migrated to Bucket %s, but %v", to, err)
+	} else if err != nil {
+		recorder.Eventf(obj, corev1.EventTypeWarning, "Migrate", "Migrating to Bucket %s: %v", to, err)
+		setCondition(obj, metav1.Condition{
+			Type:    "Migrated",
+			Reason:  "FailedToMigrate",
+			Status:  metav1.ConditionFalse,
+			Message: err.Error(),
+		})
+
+		if err := cli.Status().Update(ctx, obj); err != nil {
+			return ctrl.Result{}, ignoreNotFound(err)
+		}
+
+		return ctrl.Result{RequeueAfter: time.Minute}, nil
+	}
+
+	recorder.Eventf(obj, corev1.EventTypeNormal, "Migrated", "Migrated to Bucket %s", to)
+	setCondition(obj, metav1.Condition{
+		Type:    "Migrated",
+		Reason:  "Migrated",
+		Status:  metav1.ConditionTrue,
+		Message: fmt.Sprintf("Migrated to Bucket %s", to),
+	})
+
+	return ctrl.Result{Requeue: true}, ignoreNotFound(cli.Status().Update(ctx, obj))
+}
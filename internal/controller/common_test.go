@@ -0,0 +1,34 @@
+package controller
+
+import (
+	"testing"
+
+	momov1alpha1 "github.com/frantjc/momo/api/v1alpha1"
+)
+
+func TestAttributesDigest(t *testing.T) {
+	var (
+		attrs = &momov1alpha1.ObjectAttributes{ETag: `"abc"`, Size: 1}
+		dig   = attributesDigest(attrs)
+	)
+
+	if !attributesIdentify(attrs) {
+		t.Fatal("expected attributes with an ETag to identify their object")
+	}
+
+	if attributesIdentify(&momov1alpha1.ObjectAttributes{Size: 1}) {
+		t.Fatal("expected attributes with only a size to not identify their object")
+	}
+
+	if err := dig.Validate(); err == nil || dig.Algorithm() != AlgorithmObjectAttributes {
+		t.Fatalf("expected a digest with algorithm %s that cannot be verified against contents, got %s", AlgorithmObjectAttributes, dig)
+	}
+
+	if attributesDigest(attrs) != dig {
+		t.Error("expected the digest of the same attributes to be stable")
+	}
+
+	if attributesDigest(&momov1alpha1.ObjectAttributes{ETag: `"def"`, Size: 1}) == dig {
+		t.Error("expected the digests of different ETags to differ")
+	}
+}
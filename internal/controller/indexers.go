@@ -0,0 +1,122 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+
+	momov1alpha1 "github.com/frantjc/momo/api/v1alpha1"
+	xslice "github.com/frantjc/x/slice"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// IndexBucketName indexes APKs and IPAs by the name of the Bucket or ClusterBucket that they reference.
+	IndexBucketName = "spec.bucket.name"
+	// IndexSecretNames indexes Buckets and ClusterBuckets by the names of the Secrets that they read from.
+	IndexSecretNames = "spec.secretNames"
+	// IndexConfigMapNames indexes Buckets and ClusterBuckets by the names of the ConfigMaps that they read from.
+	IndexConfigMapNames = "spec.configMapNames"
+	// IndexSelector indexes MobileApps by each key=value pair of their selectors.
+	IndexSelector = "spec.selector"
+	// IndexIssuerName indexes MobileApps by the name of the Issuer or ClusterIssuer of their Ingresses.
+	IndexIssuerName = "spec.universalLinks.ingress.issuer.name"
+)
+
+// indexBucketName indexes obj, an APK or IPA, with IndexBucketName.
+func indexBucketName(mgr ctrl.Manager, obj BinaryObject) error {
+	return mgr.GetFieldIndexer().IndexField(context.Background(), obj, IndexBucketName, func(obj client.Object) []string {
+		if binary, ok := obj.(BinaryObject); ok {
+			return []string{binary.GetBucket().Name}
+		}
+
+		return nil
+	})
+}
+
+// indexBucketRefs indexes obj, a Bucket or ClusterBucket, with IndexSecretNames and IndexConfigMapNames.
+func indexBucketRefs(mgr ctrl.Manager, obj momov1alpha1.BucketObject) error {
+	if err := mgr.GetFieldIndexer().IndexField(context.Background(), obj, IndexSecretNames, func(obj client.Object) []string {
+		if bucket, ok := obj.(momov1alpha1.BucketObject); ok {
+			return bucketSecretNames(bucket.GetBucketSpec())
+		}
+
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	return mgr.GetFieldIndexer().IndexField(context.Background(), obj, IndexConfigMapNames, func(obj client.Object) []string {
+		if bucket, ok := obj.(momov1alpha1.BucketObject); ok {
+			return bucketConfigMapNames(bucket.GetBucketSpec())
+		}
+
+		return nil
+	})
+}
+
+// indexMobileApp indexes MobileApps with IndexSelector and IndexIssuerName.
+func indexMobileApp(mgr ctrl.Manager) error {
+	if err := mgr.GetFieldIndexer().IndexField(context.Background(), &momov1alpha1.MobileApp{}, IndexSelector, func(obj client.Object) []string {
+		if mobileApp, ok := obj.(*momov1alpha1.MobileApp); ok {
+			return selectorIndexValues(mobileApp.Spec.Selector)
+		}
+
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	return mgr.GetFieldIndexer().IndexField(context.Background(), &momov1alpha1.MobileApp{}, IndexIssuerName, func(obj client.Object) []string {
+		if mobileApp, ok := obj.(*momov1alpha1.MobileApp); ok && mobileApp.Spec.UniversalLinks.Ingress.Issuer.Name != "" {
+			return []string{mobileApp.Spec.UniversalLinks.Ingress.Issuer.Name}
+		}
+
+		return nil
+	})
+}
+
+// selectorIndexValues returns the values that a MobileApp with the given selector is indexed by
+// with IndexSelector. An empty selector matches everything, so it is indexed by the empty string,
+// which every lookup includes.
+func selectorIndexValues(selector map[string]string) []string {
+	if len(selector) == 0 {
+		return []string{""}
+	}
+
+	values := []string{}
+	for key, value := range selector {
+		values = append(values, fmt.Sprintf("%s=%s", key, value))
+	}
+
+	return values
+}
+
+// listMobileAppsSelecting lists the MobileApps in the namespace whose selectors may match lbls by
+// looking up each of lbls in the IndexSelector index. The result still has to be filtered by
+// whether each MobileApp's selector matches all of lbls.
+func listMobileAppsSelecting(ctx context.Context, cli client.Client, namespace string, lbls map[string]string) ([]momov1alpha1.MobileApp, error) {
+	var (
+		mobileApps = []momov1alpha1.MobileApp{}
+		seen       = map[string]bool{}
+	)
+
+	for _, value := range append(selectorIndexValues(lbls), "") {
+		list := &momov1alpha1.MobileAppList{}
+
+		if err := cli.List(ctx, list, client.InNamespace(namespace), client.MatchingFields{IndexSelector: value}); err != nil {
+			return nil, err
+		}
+
+		mobileApps = append(mobileApps, xslice.Filter(list.Items, func(mobileApp momov1alpha1.MobileApp, _ int) bool {
+			if seen[mobileApp.Name] {
+				return false
+			}
+
+			seen[mobileApp.Name] = true
+			return true
+		})...)
+	}
+
+	return mobileApps, nil
+}
@@ -2,6 +2,7 @@ package controller
 
 import (
 	"context"
+	"io"
 	"math"
 	"os"
 	"time"
@@ -9,10 +10,13 @@ import (
 	"github.com/frantjc/momo"
 	momov1alpha1 "github.com/frantjc/momo/api/v1alpha1"
 	"github.com/frantjc/momo/internal/momoutil"
+	"github.com/frantjc/momo/internal/scan"
 	"github.com/frantjc/momo/ios"
 	xslice "github.com/frantjc/x/slice"
 	xstrings "github.com/frantjc/x/strings"
+	"github.com/opencontainers/go-digest"
 	"golang.org/x/mod/semver"
+	batchv1 "k8s.io/api/batch/v1"
 	corev1 "k8s.io/api/core/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -30,17 +34,39 @@ import (
 type IPAReconciler struct {
 	client.Client
 	record.EventRecorder
-	TmpDir string
+	Buckets *momoutil.BucketManager
+	TmpDir  string
+	// VerifyDigests makes the IPAReconciler download and hash the IPA's
+	// object on every reconcile, even if its attributes have not changed.
+	VerifyDigests bool
+	// RangeReads makes the IPAReconciler decode the IPA's object by reading only
+	// the parts of it that it needs from its Bucket instead of downloading it to TmpDir.
+	RangeReads bool
+	// UnpackJob, if set, makes the IPAReconciler decode the
+	// IPA's object in a Job instead of in its own process.
+	UnpackJob *UnpackJobOpts
+	// Scanner, if set, must find the IPA's object to be clean before it is Ready.
+	Scanner scan.Scanner
+}
+
+// ipaUnpacker is implemented by *ios.IPADecoder and by
+// *momoutil.UnpackResult when unpacking happens in a Job.
+type ipaUnpacker interface {
+	Info(context.Context) (*ios.Info, error)
+	IconDecoder
+	io.Closer
 }
 
 // +kubebuilder:rbac:groups=momo.frantj.cc,resources=ipas,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=momo.frantj.cc,resources=ipas/status,verbs=get;update;patch
 // +kubebuilder:rbac:groups=momo.frantj.cc,resources=ipas/finalizers,verbs=update
+// +kubebuilder:rbac:groups=batch,resources=jobs,verbs=get;list;watch;create;delete
+// +kubebuilder:rbac:groups="",resources=secrets,verbs=create
 // +kubebuilder:rbac:groups=momo.frantj.cc,resources=buckets,verbs=get;list;watch
 
 // Reconcile is part of the main kubernetes reconciliation loop which aims to
 // move the current state of the cluster closer to the desired state.
-func (r *IPAReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+func (r *IPAReconciler) Reconcile(ctx context.Context, req ctrl.Request) (_ ctrl.Result, err error) {
 	var (
 		_   = log.FromContext(ctx)
 		ipa = &momov1alpha1.IPA{}
@@ -54,6 +80,11 @@ func (r *IPAReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.R
 		return ctrl.Result{}, err
 	}
 
+	ctx, span := startSpan(ctx, "IPAReconciler.Reconcile", ipa, ipa.Status.Digest != "")
+	defer func() {
+		momoutil.EndSpan(span, err)
+	}()
+
 	if ipa.Status.Phase != momov1alpha1.PhasePending {
 		ipa.Status.Phase = momov1alpha1.PhasePending
 
@@ -62,58 +93,131 @@ func (r *IPAReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.R
 		}
 	}
 
-	bucket := &momov1alpha1.Bucket{}
-
-	if err := r.Get(ctx,
-		client.ObjectKey{
-			Namespace: ipa.Namespace,
-			Name:      ipa.Spec.Bucket.Name,
-		},
-		bucket,
-	); err != nil {
+	bucket, err := momoutil.GetBucketReference(ctx, r.Client, ipa.Namespace, ipa.Spec.Bucket)
+	if err != nil {
 		if apierrors.IsNotFound(err) {
-			r.Eventf(ipa, corev1.EventTypeWarning, "BucketNotFound", "Bucket %s is not found", bucket.Name)
+			r.Eventf(ipa, corev1.EventTypeWarning, "BucketNotFound", "Bucket %s is not found", ipa.Spec.Bucket)
+			return ctrl.Result{}, nil
+		} else if apierrors.IsForbidden(err) {
+			r.Eventf(ipa, corev1.EventTypeWarning, "BucketForbidden", "Bucket %s is not allowed: %v", ipa.Spec.Bucket, err)
 			return ctrl.Result{}, nil
 		}
 
 		return ctrl.Result{}, err
 	}
 
-	if bucket.Status.Phase != momov1alpha1.PhaseReady {
-		r.Eventf(ipa, corev1.EventTypeNormal, "BucketNotReady", "Bucket %s is not ready", bucket.Name)
+	if bucket.GetBucketStatus().Phase != momov1alpha1.PhaseReady {
+		r.Eventf(ipa, corev1.EventTypeNormal, "BucketNotReady", "Bucket %s is not ready", ipa.Spec.Bucket)
 		return ctrl.Result{}, nil
 	}
 
-	cli, err := momoutil.OpenBucket(ctx, r.Client, bucket)
+	cli, err := r.Buckets.OpenBucketFor(ctx, r.Client, bucket, ipa.Namespace)
 	if err != nil {
 		// If the Bucket is Ready, then this error
 		// is likely temporary and will resolve itself.
 		return ctrl.Result{Requeue: true}, nil
 	}
+	defer func() {
+		_ = cli.Close()
+	}()
 
 	if !ipa.DeletionTimestamp.IsZero() {
 		return finalize(ctx, r, r, cli, ipa)
 	}
 
-	path, dig, err := downloadAndSumObject(ctx, r, cli, ipa, momo.ExtIPA, r.TmpDir)
+	if to := ipa.Annotations[momoutil.AnnotationMigrateTo]; to != "" && ipa.Status.Digest != "" {
+		return migrate(ctx, r, r, r.Buckets, ipa, momov1alpha1.ParseBucketReference(to))
+	}
+
+	// An error here is surfaced by downloadAndSumObject.
+	attrs, _ := objectAttributes(ctx, cli, ipa)
+
+	// Whether the object has changed or not, it is unpacked again if
+	// it was last decoded into fewer fields than are decoded now.
+	current := ipa.Status.DecoderVersion >= IPADecoderVersion
+
+	if current && !r.VerifyDigests && ipa.Status.Digest != "" && objectUnchanged(ipa, attrs) {
+		return ready(ctx, r, r, r.Scanner, cli, ipa)
+	}
+
+	var (
+		path string
+		dig  digest.Digest
+		job  *batchv1.Job
+	)
+	if r.UnpackJob != nil {
+		if job, err = getUnpackJob(ctx, r, ipa); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+
+	if job != nil {
+		// The object was already summed when the Job was created.
+		dig = digest.Digest(job.Annotations[AnnotationDigest])
+	} else if r.RangeReads && r.UnpackJob == nil && !r.VerifyDigests && attributesIdentify(attrs) {
+		// The object is only read in part to be decoded, so it is not read in full to be hashed either.
+		dig = attributesDigest(attrs)
+	} else if r.RangeReads || r.UnpackJob != nil {
+		dig, err = sumObject(ctx, r, cli, ipa, momo.ExtIPA)
+	} else {
+		path, dig, err = downloadAndSumObject(ctx, r, cli, ipa, momo.ExtIPA, r.TmpDir)
+	}
 	if err != nil {
 		return ctrl.Result{}, ignoreNotFound(err)
+	} else if dig == "" {
+		// The failure to get the object has been recorded in the status.
+		return ctrl.Result{}, nil
 	}
 	defer func() {
-		_ = os.Remove(path)
+		if path != "" {
+			_ = os.Remove(path)
+		}
 	}()
 
-	if dig.String() == ipa.Status.Digest {
-		ipa.Status.Phase = momov1alpha1.PhaseReady
-		return ctrl.Result{}, ignoreNotFound(r.Client.Status().Update(ctx, ipa))
+	if current && dig.String() == ipa.Status.Digest {
+		ipa.Status.ObjectAttributes = attrs
+		return ready(ctx, r, r, r.Scanner, cli, ipa)
 	}
 
-	ipaDecoder := ios.NewIPADecoder(path)
+	var ipaDecoder ipaUnpacker
+	if r.UnpackJob != nil {
+		result, err := unpackInJob(ctx, r, cli, r.UnpackJob, ipa, job, momoutil.PlatformIOS, dig)
+		if err != nil {
+			ipa.Status.Phase = momov1alpha1.PhaseFailed
+			setCondition(ipa, metav1.Condition{
+				Type:    "UnpackIPA",
+				Reason:  "UnpackJob",
+				Status:  metav1.ConditionFalse,
+				Message: err.Error(),
+			})
+
+			return ctrl.Result{}, ignoreNotFound(r.Status().Update(ctx, ipa))
+		} else if result == nil {
+			// The Job is running and will trigger another reconcile when it finishes.
+			return ctrl.Result{RequeueAfter: r.UnpackJob.Timeout}, nil
+		}
+
+		ipaDecoder = result
+	} else {
+		opts := []ios.IPADecoderOpt{}
+		if r.RangeReads {
+			ra, err := momoutil.NewBucketReaderAt(ctx, cli, ipa.Spec.Key)
+			if err != nil {
+				return ctrl.Result{}, err
+			}
+
+			opts = append(opts, ios.WithReaderAt(ra, ra.Size()))
+		}
+
+		ipaDecoder = ios.NewIPADecoder(path, opts...)
+	}
 	defer func() {
 		_ = ipaDecoder.Close()
 	}()
 
+	start := time.Now()
 	info, err := ipaDecoder.Info(ctx)
+	momoutil.ObserveDecode(momoutil.PlatformIOS, momoutil.DecodeStepInfoPlist, start, err)
 	if err != nil {
 		ipa.Status.Phase = momov1alpha1.PhaseFailed
 		setCondition(ipa, metav1.Condition{
@@ -128,9 +232,16 @@ func (r *IPAReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.R
 
 	ipa.Status.BundleName = xslice.Coalesce(info.CFBundleName, info.CFBundleDisplayName)
 	ipa.Status.BundleIdentifier = info.CFBundleIdentifier
+	ipa.Status.MinimumOSVersion = info.MinimumOSVersion
+	ipa.Status.DeviceFamilies = ios.DeviceFamilies(info.UIDeviceFamily)
+	ipa.Status.RequiredDeviceCapabilities = info.UIRequiredDeviceCapabilities
+	ipa.Status.SupportedPlatforms = info.CFBundleSupportedPlatforms
+	ipa.Status.PlatformName = info.DTPlatformName
+	ipa.Status.VersionName = info.CFBundleShortVersionString
+	ipa.Status.BuildNumber = info.CFBundleVersion
 	ipa.Status.Version = semver.Canonical(
 		xstrings.EnsurePrefix(
-			xslice.Coalesce(info.CFBundleVersion, info.CFBundleShortVersionString),
+			xslice.Coalesce(info.CFBundleShortVersionString, info.CFBundleVersion),
 			"v",
 		),
 	)
@@ -145,7 +256,9 @@ func (r *IPAReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.R
 		}
 	}
 
+	start = time.Now()
 	ipa.Status.Icons, err = unpackIcons(ctx, cli, ipaDecoder, ipa, r.EventRecorder)
+	momoutil.ObserveDecode(momoutil.PlatformIOS, momoutil.DecodeStepIcons, start, err)
 	if err != nil {
 		ipa.Status.Phase = momov1alpha1.PhaseFailed
 		setCondition(ipa, metav1.Condition{
@@ -186,15 +299,17 @@ func (r *IPAReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.R
 	}
 
 	ipa.Status.Digest = dig.String()
-	ipa.Status.Phase = momov1alpha1.PhaseReady
+	ipa.Status.ObjectAttributes = attrs
+	ipa.Status.DecoderVersion = IPADecoderVersion
+	resetScanned(ipa)
 	setCondition(ipa, metav1.Condition{
 		Type:   "UnpackIPA",
 		Reason: "Unpacked",
 		Status: metav1.ConditionTrue,
 	})
 
-	if err := r.Client.Status().Update(ctx, ipa); err != nil {
-		return ctrl.Result{}, ignoreNotFound(err)
+	if res, err := ready(ctx, r, r, r.Scanner, cli, ipa); err != nil || ipa.Status.Phase != momov1alpha1.PhaseReady {
+		return res, err
 	}
 
 	return ctrl.Result{RequeueAfter: time.Minute * 9}, nil
@@ -204,24 +319,43 @@ func (r *IPAReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.R
 func (r *IPAReconciler) SetupWithManager(mgr ctrl.Manager) error {
 	r.Client = mgr.GetClient()
 	r.EventRecorder = mgr.GetEventRecorderFor("momo")
+	if r.Buckets == nil {
+		r.Buckets = &momoutil.BucketManager{}
+	}
+	if err := indexBucketName(mgr, &momov1alpha1.IPA{}); err != nil {
+		return err
+	}
 	return ctrl.NewControllerManagedBy(mgr).
-		For(&momov1alpha1.IPA{}, builder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		For(&momov1alpha1.IPA{}, builder.WithPredicates(
+			// Annotations are watched for momoutil.AnnotationMigrateTo.
+			predicate.Or(predicate.GenerationChangedPredicate{}, predicate.AnnotationChangedPredicate{}),
+		)).
+		Owns(&batchv1.Job{}).
 		Watches(&momov1alpha1.Bucket{}, r.EventHandler()).
+		Watches(&momov1alpha1.ClusterBucket{}, r.EventHandler()).
 		Named("ipa").
 		Complete(r)
 }
 
 func (r *IPAReconciler) EventHandler() handler.EventHandler {
 	return handler.EnqueueRequestsFromMapFunc(func(ctx context.Context, obj client.Object) []ctrl.Request {
-		ipas := &momov1alpha1.IPAList{}
+		var (
+			ipas       = &momov1alpha1.IPAList{}
+			bucket, ok = obj.(momov1alpha1.BucketObject)
+		)
+
+		if !ok {
+			return []ctrl.Request{}
+		}
 
-		if err := r.List(ctx, ipas, &client.ListOptions{Namespace: obj.GetNamespace()}); err != nil {
+		// ClusterBuckets are not namespaced, so this lists IPAs in every namespace for them.
+		if err := r.List(ctx, ipas, client.InNamespace(obj.GetNamespace()), client.MatchingFields{IndexBucketName: bucket.GetName()}); err != nil {
 			return []ctrl.Request{}
 		}
 
 		return xslice.Map(
 			xslice.Filter(ipas.Items, func(ipa momov1alpha1.IPA, _ int) bool {
-				return ipa.Spec.Bucket.Name == obj.GetName()
+				return ipa.Spec.Bucket.Refers(ipa.Namespace, bucket)
 			}),
 			func(ipa momov1alpha1.IPA, _ int) ctrl.Request {
 				return ctrl.Request{NamespacedName: client.ObjectKeyFromObject(&ipa)}
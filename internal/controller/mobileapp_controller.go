@@ -4,14 +4,16 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"slices"
+	"time"
 
 	v1 "github.com/cert-manager/cert-manager/pkg/apis/certmanager/v1"
 	"github.com/frantjc/momo/android"
 	momov1alpha1 "github.com/frantjc/momo/api/v1alpha1"
+	"github.com/frantjc/momo/internal/momoutil"
 	"github.com/frantjc/momo/ios"
 	xslice "github.com/frantjc/x/slice"
 	"github.com/opencontainers/go-digest"
-	"golang.org/x/mod/semver"
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
 	networkingv1 "k8s.io/api/networking/v1"
@@ -36,7 +38,7 @@ type MobileAppReconciler struct {
 
 // +kubebuilder:rbac:groups=momo.frantj.cc,resources=mobileapps,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=momo.frantj.cc,resources=mobileapps/status,verbs=get;update;patch
-// +kubebuilder:rbac:groups=momo.frantj.cc,resources=apks;ipas,verbs=get;list;watch
+// +kubebuilder:rbac:groups=momo.frantj.cc,resources=apks;ipas,verbs=get;list;watch;update;delete
 // +kubebuilder:rbac:groups="",resources=services;configmaps,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=apps,resources=deployments,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=networking,resources=ingresses,verbs=get;list;watch;create;update;patch;delete
@@ -44,7 +46,7 @@ type MobileAppReconciler struct {
 
 // Reconcile is part of the main kubernetes reconciliation loop which aims to
 // move the current state of the cluster closer to the desired state.
-func (r *MobileAppReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+func (r *MobileAppReconciler) Reconcile(ctx context.Context, req ctrl.Request) (_ ctrl.Result, err error) {
 	var (
 		_         = log.FromContext(ctx)
 		mobileApp = &momov1alpha1.MobileApp{}
@@ -54,6 +56,16 @@ func (r *MobileAppReconciler) Reconcile(ctx context.Context, req ctrl.Request) (
 		return ctrl.Result{}, ignoreNotFound(err)
 	}
 
+	ctx, span := startSpan(ctx, "MobileAppReconciler.Reconcile", mobileApp, true)
+	defer func() {
+		momoutil.EndSpan(span, err)
+	}()
+
+	// Saved by the updates to the status below.
+	if _, err := momoutil.RecordPromotion(mobileApp); err != nil {
+		r.Eventf(mobileApp, corev1.EventTypeWarning, "RecordPromotion", "Recording promotion: %v", err)
+	}
+
 	if mobileApp.Status.Phase != momov1alpha1.PhasePending {
 		mobileApp.Status.Phase = momov1alpha1.PhasePending
 
@@ -76,15 +88,25 @@ func (r *MobileAppReconciler) Reconcile(ctx context.Context, req ctrl.Request) (
 
 	mobileApp.Status.APKs = []momov1alpha1.MobileAppStatusApp{}
 
-	packageToSHA256CertFingerprints := map[string][]string{}
+	var (
+		packageToSHA256CertFingerprints = map[string][]string{}
+		apkObjs                         = map[string]client.Object{}
+		apkUnready                      = []client.Object{}
+	)
 
 	for _, apk := range apks.Items {
 		if apk.Status.Phase == momov1alpha1.PhaseReady {
+			apkObjs[apk.Name] = &apk
 			mobileApp.Status.APKs = append(mobileApp.Status.APKs, momov1alpha1.MobileAppStatusApp{
-				Name:    apk.Name,
-				Bucket:  apk.Spec.Bucket,
-				Key:     apk.Spec.Key,
-				Version: apk.Status.Version,
+				Name:   apk.Name,
+				Bucket: apk.Spec.Bucket,
+				// Devices cannot install an Android App Bundle, so its universal APK is served instead.
+				Key:           xslice.Coalesce(apk.Status.UniversalAPKKey, apk.Spec.Key),
+				Version:       apk.Status.Version,
+				VersionName:   apk.Status.VersionName,
+				BuildNumber:   apk.Status.BuildNumber,
+				UploadTime:    &apk.CreationTimestamp,
+				SupportedABIs: apk.Status.SupportedABIs,
 			})
 
 			if apk.Status.SHA256CertFingerprints != "" && apk.Status.Package != "" {
@@ -94,10 +116,20 @@ func (r *MobileAppReconciler) Reconcile(ctx context.Context, req ctrl.Request) (
 					packageToSHA256CertFingerprints[apk.Status.Package] = []string{apk.Status.SHA256CertFingerprints}
 				}
 			}
+		} else {
+			apkUnready = append(apkUnready, &apk)
 		}
 	}
 
-	mobileApp.Status.APKs = markLatest(mobileApp.Status.APKs)
+	var apkRequeueAfter, ipaRequeueAfter time.Duration
+
+	mobileApp.Status.APKs, apkRequeueAfter, err = r.prune(ctx, mobileApp, "APK", markLatest(mobileApp.Spec.Ordering, mobileApp.Status.APKs, mobileApp.Spec.Pinned.Android), apkObjs, apkUnready)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	r.warnIfPinnedNotFound(mobileApp, momoutil.PlatformAndroid, mobileApp.Spec.Pinned.Android, mobileApp.Status.APKs)
+
 	setCondition(mobileApp, metav1.Condition{
 		Type:   "AggregatedAPKs",
 		Reason: "GotAPKs",
@@ -121,28 +153,48 @@ func (r *MobileAppReconciler) Reconcile(ctx context.Context, req ctrl.Request) (
 	}
 
 	mobileApp.Status.IPAs = []momov1alpha1.MobileAppStatusApp{}
-	bundleIdentifiers := []string{}
+
+	var (
+		bundleIdentifiers = []string{}
+		ipaObjs           = map[string]client.Object{}
+		ipaUnready        = []client.Object{}
+	)
 
 	for _, ipa := range ipas.Items {
 		if ipa.Status.Phase == momov1alpha1.PhaseReady {
+			ipaObjs[ipa.Name] = &ipa
 			mobileApp.Status.IPAs = append(mobileApp.Status.IPAs, momov1alpha1.MobileAppStatusApp{
-				Name:    ipa.Name,
-				Bucket:  ipa.Spec.Bucket,
-				Key:     ipa.Spec.Key,
-				Version: ipa.Status.Version,
+				Name:        ipa.Name,
+				Bucket:      ipa.Spec.Bucket,
+				Key:         ipa.Spec.Key,
+				Version:     ipa.Status.Version,
+				VersionName: ipa.Status.VersionName,
+				BuildNumber: ipa.Status.BuildNumber,
+				UploadTime:  &ipa.CreationTimestamp,
 			})
 
 			bundleIdentifiers = append(bundleIdentifiers, ipa.Status.BundleIdentifier)
+		} else {
+			ipaUnready = append(ipaUnready, &ipa)
 		}
 	}
 
-	mobileApp.Status.IPAs = markLatest(mobileApp.Status.IPAs)
+	mobileApp.Status.IPAs, ipaRequeueAfter, err = r.prune(ctx, mobileApp, "IPA", markLatest(mobileApp.Spec.Ordering, mobileApp.Status.IPAs, mobileApp.Spec.Pinned.IOS), ipaObjs, ipaUnready)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	r.warnIfPinnedNotFound(mobileApp, momoutil.PlatformIOS, mobileApp.Spec.Pinned.IOS, mobileApp.Status.IPAs)
+
 	setCondition(mobileApp, metav1.Condition{
 		Type:   "AggregatedIPAs",
 		Reason: "GotIPAs",
 		Status: metav1.ConditionTrue,
 	})
 
+	// KeepNewerThan has to be evaluated again as time passes, not only when the MobileApp changes.
+	requeueAfter := minRequeueAfter(r.rollout(mobileApp, time.Now()), apkRequeueAfter, ipaRequeueAfter)
+
 	if err := r.Client.Status().Update(ctx, mobileApp); err != nil {
 		return ctrl.Result{}, ignoreNotFound(err)
 	}
@@ -418,32 +470,53 @@ func (r *MobileAppReconciler) Reconcile(ctx context.Context, req ctrl.Request) (
 
 	mobileApp.Status.Phase = momov1alpha1.PhaseReady
 
-	return ctrl.Result{}, ignoreNotFound(r.Client.Status().Update(ctx, mobileApp))
+	return ctrl.Result{RequeueAfter: requeueAfter}, ignoreNotFound(r.Client.Status().Update(ctx, mobileApp))
 }
 
-func markLatest(apps []momov1alpha1.MobileAppStatusApp) []momov1alpha1.MobileAppStatusApp {
-	var (
-		latest  = -1
-		version string
-	)
-	for i, app := range apps {
-		if version == "" || semver.Compare(version, app.Version) < 0 {
+// markLatest sorts apps newest first according to ordering and marks the
+// newest of them as such and as the latest, unless one of them has the
+// pinned version, which is marked as the latest instead.
+func markLatest(ordering string, apps []momov1alpha1.MobileAppStatusApp, pinned string) []momov1alpha1.MobileAppStatusApp {
+	if len(apps) == 0 {
+		return apps
+	}
+
+	momoutil.SortApps(ordering, apps)
+
+	apps[0].Newest = true
+
+	latest := 0
+	if pinned != "" {
+		if i := slices.IndexFunc(apps, func(app momov1alpha1.MobileAppStatusApp) bool {
+			return momoutil.MatchesVersion(app, pinned)
+		}); i >= 0 {
+			apps[i].Pinned = true
 			latest = i
-			version = app.Version
 		}
 	}
 
-	if latest >= 0 {
-		apps[latest].Latest = true
-	}
+	apps[latest].Latest = true
 
 	return apps
 }
 
+// warnIfPinnedNotFound records an Event on mobileApp if the version
+// that is pinned for platform is not among the platform's apps.
+func (r *MobileAppReconciler) warnIfPinnedNotFound(mobileApp *momov1alpha1.MobileApp, platform, pinned string, apps []momov1alpha1.MobileAppStatusApp) {
+	if pinned != "" && !slices.ContainsFunc(apps, func(app momov1alpha1.MobileAppStatusApp) bool {
+		return app.Pinned
+	}) {
+		r.Eventf(mobileApp, corev1.EventTypeWarning, "PinnedVersionNotFound", "Pinned %s version %s is not found, serving the newest version instead", platform, pinned)
+	}
+}
+
 // SetupWithManager sets up the controller with the Manager.
 func (r *MobileAppReconciler) SetupWithManager(mgr ctrl.Manager) error {
 	r.Client = mgr.GetClient()
 	r.EventRecorder = mgr.GetEventRecorderFor("momo")
+	if err := indexMobileApp(mgr); err != nil {
+		return err
+	}
 	return ctrl.NewControllerManagedBy(mgr).
 		For(&momov1alpha1.MobileApp{}, builder.WithPredicates(predicate.GenerationChangedPredicate{})).
 		Watches(&momov1alpha1.APK{}, r.BinaryEventHandler()).
@@ -461,14 +534,13 @@ func (r *MobileAppReconciler) SetupWithManager(mgr ctrl.Manager) error {
 func (r *MobileAppReconciler) BinaryEventHandler() handler.EventHandler {
 	return handler.EnqueueRequestsFromMapFunc(func(ctx context.Context, obj client.Object) []ctrl.Request {
 		if lbls := obj.GetLabels(); lbls != nil {
-			mobileApps := &momov1alpha1.MobileAppList{}
-
-			if err := r.List(ctx, mobileApps, &client.ListOptions{Namespace: obj.GetNamespace()}); err != nil {
+			mobileApps, err := listMobileAppsSelecting(ctx, r.Client, obj.GetNamespace(), lbls)
+			if err != nil {
 				return []ctrl.Request{}
 			}
 
 			return xslice.Map(
-				xslice.Filter(mobileApps.Items, func(mobileApp momov1alpha1.MobileApp, _ int) bool {
+				xslice.Filter(mobileApps, func(mobileApp momov1alpha1.MobileApp, _ int) bool {
 					return mobileApp.Spec.Selector.AsSelector().Matches(labels.Set(lbls))
 				}),
 				func(mobileApp momov1alpha1.MobileApp, _ int) ctrl.Request {
@@ -486,7 +558,7 @@ func (r *MobileAppReconciler) IssuerEventHandler() handler.EventHandler {
 		if lbls := obj.GetLabels(); lbls != nil {
 			mobileApps := &momov1alpha1.MobileAppList{}
 
-			if err := r.List(ctx, mobileApps, &client.ListOptions{Namespace: obj.GetNamespace()}); err != nil {
+			if err := r.List(ctx, mobileApps, client.InNamespace(obj.GetNamespace()), client.MatchingFields{IndexIssuerName: obj.GetName()}); err != nil {
 				return []ctrl.Request{}
 			}
 
@@ -0,0 +1,261 @@
+package controller
+
+import (
+	"context"
+	"slices"
+	"strconv"
+	"time"
+
+	momov1alpha1 "github.com/frantjc/momo/api/v1alpha1"
+	"github.com/frantjc/momo/internal/momoutil"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// AnnotationPinned is set to "true" on an APK or IPA
+	// to keep it regardless of a MobileApp's retention policy.
+	AnnotationPinned = "momo.frantj.cc/pinned"
+	// AnnotationPruned is set on an APK or IPA that a MobileApp's
+	// retention policy deleted to the name of that MobileApp, so
+	// that its objects are deleted from its Bucket along with it.
+	AnnotationPruned = "momo.frantj.cc/pruned-by"
+)
+
+// servedVersions returns the versions that mobileApp serves other than its latest
+// ones, i.e. those of its Channels and its Rollout and those that are pinned.
+func servedVersions(mobileApp *momov1alpha1.MobileApp) []string {
+	versions := []string{}
+
+	for _, pinned := range []string{mobileApp.Spec.Pinned.Android, mobileApp.Spec.Pinned.IOS} {
+		if pinned != "" {
+			versions = append(versions, pinned)
+		}
+	}
+
+	for _, channel := range mobileApp.Spec.Channels {
+		if channel.Version != "" {
+			versions = append(versions, channel.Version)
+		}
+	}
+
+	if mobileApp.Spec.Rollout != nil {
+		versions = append(versions, mobileApp.Spec.Rollout.Version)
+	}
+
+	if mobileApp.Status.Rollout != nil {
+		for _, previous := range []string{mobileApp.Status.Rollout.PreviousVersions.Android, mobileApp.Status.Rollout.PreviousVersions.IOS} {
+			if previous != "" {
+				versions = append(versions, previous)
+			}
+		}
+	}
+
+	return versions
+}
+
+// retains reports whether the given retention policy prunes anything at all.
+func retains(retention *momov1alpha1.MobileAppSpecRetention) bool {
+	return retention != nil && (retention.KeepLast > 0 || retention.KeepNewerThan != nil)
+}
+
+// retained reports which of apps the given retention policy keeps, besides
+// the served versions, ranking them according to the given ordering. objs are the APKs or IPAs that apps were aggregated
+// from, keyed by their names. It also returns how long until the first of the apps
+// that are only kept for being newer than KeepNewerThan no longer is, if any.
+func retained(ordering string, retention *momov1alpha1.MobileAppSpecRetention, served []string, apps []momov1alpha1.MobileAppStatusApp, objs map[string]client.Object, now time.Time) (keep, prune []momov1alpha1.MobileAppStatusApp, requeueAfter time.Duration) {
+	if !retains(retention) {
+		return apps, nil, 0
+	}
+
+	sorted := slices.Clone(apps)
+	momoutil.SortApps(ordering, sorted)
+
+	// Newest first.
+	builds := []momov1alpha1.MobileAppStatusApp{}
+	for _, app := range sorted {
+		if !slices.ContainsFunc(builds, func(build momov1alpha1.MobileAppStatusApp) bool {
+			return momoutil.SameBuild(build, app)
+		}) {
+			builds = append(builds, app)
+		}
+	}
+
+	// The newest version is kept even if another one is pinned as the latest.
+	for _, app := range apps {
+		if app.Latest || app.Newest {
+			served = append(served, app.Version)
+		}
+	}
+
+	for _, app := range apps {
+		var (
+			obj       = objs[app.Name]
+			pinned, _ = strconv.ParseBool(obj.GetAnnotations()[AnnotationPinned])
+		)
+
+		switch {
+		case pinned, slices.ContainsFunc(served, func(version string) bool {
+			return momoutil.MatchesVersion(app, version)
+		}):
+		case retention.KeepLast > 0 && slices.IndexFunc(builds, func(build momov1alpha1.MobileAppStatusApp) bool {
+			return momoutil.SameBuild(build, app)
+		}) < retention.KeepLast:
+		case retention.KeepNewerThan != nil && now.Sub(obj.GetCreationTimestamp().Time) < retention.KeepNewerThan.Duration:
+			requeueAfter = minRequeueAfter(requeueAfter, retention.KeepNewerThan.Duration-now.Sub(obj.GetCreationTimestamp().Time))
+		default:
+			prune = append(prune, app)
+			continue
+		}
+
+		keep = append(keep, app)
+	}
+
+	return keep, prune, requeueAfter
+}
+
+// retainedUnready reports which of unready, the APKs or IPAs that are not Ready and so
+// are not among apps, the given retention policy prunes. They are never served, so unless
+// they are pinned, they are only kept while they are newer than KeepNewerThan or while
+// fewer than KeepLast of the builds in apps were uploaded after them, e.g. because they
+// are still being unpacked. objs are the APKs or IPAs that apps were aggregated from,
+// keyed by their names. It also returns how long until the first of unready that is
+// only kept for being newer than KeepNewerThan no longer is, if any.
+func retainedUnready(retention *momov1alpha1.MobileAppSpecRetention, apps []momov1alpha1.MobileAppStatusApp, objs map[string]client.Object, unready []client.Object, now time.Time) (prune []client.Object, requeueAfter time.Duration) {
+	if !retains(retention) {
+		return nil, 0
+	}
+
+	for _, obj := range unready {
+		var (
+			pinned, _ = strconv.ParseBool(obj.GetAnnotations()[AnnotationPinned])
+			created   = obj.GetCreationTimestamp().Time
+			newer     = []momov1alpha1.MobileAppStatusApp{}
+		)
+
+		for _, app := range apps {
+			if objs[app.Name].GetCreationTimestamp().After(created) && !slices.ContainsFunc(newer, func(build momov1alpha1.MobileAppStatusApp) bool {
+				return momoutil.SameBuild(build, app)
+			}) {
+				newer = append(newer, app)
+			}
+		}
+
+		switch {
+		case pinned:
+		case retention.KeepLast > 0 && len(newer) < retention.KeepLast:
+		case retention.KeepNewerThan != nil && now.Sub(created) < retention.KeepNewerThan.Duration:
+			requeueAfter = minRequeueAfter(requeueAfter, retention.KeepNewerThan.Duration-now.Sub(created))
+		default:
+			prune = append(prune, obj)
+		}
+	}
+
+	return prune, requeueAfter
+}
+
+// keptByOther reports whether a MobileApp in others other than mobileApp selects obj,
+// the APK or IPA of app, and keeps it, so that mobileApp's retention policy must not
+// delete it. A MobileApp keeps the APKs and IPAs that it selects if it has no retention
+// policy, if it serves their version or if they were among its apps when it was last
+// reconciled, as it has yet to decide to prune them if it ever will.
+func keptByOther(mobileApp *momov1alpha1.MobileApp, others []momov1alpha1.MobileApp, app momov1alpha1.MobileAppStatusApp, obj client.Object) (string, bool) {
+	for _, other := range others {
+		if other.Name == mobileApp.Name || !labels.SelectorFromSet(other.Spec.Selector).Matches(labels.Set(obj.GetLabels())) {
+			continue
+		}
+
+		if !retains(other.Spec.Retention) || slices.ContainsFunc(servedVersions(&other), func(version string) bool {
+			return momoutil.MatchesVersion(app, version)
+		}) || slices.ContainsFunc(append(other.Status.APKs, other.Status.IPAs...), func(otherApp momov1alpha1.MobileAppStatusApp) bool {
+			return otherApp.Name == obj.GetName()
+		}) {
+			return other.Name, true
+		}
+	}
+
+	return "", false
+}
+
+// prune deletes the APKs or IPAs that mobileApp's retention policy does not keep
+// and that no other MobileApp keeps, and returns the apps that remain along with how
+// long until its retention policy has to be evaluated again. objs are the APKs or IPAs
+// that apps were aggregated from, keyed by their names, and unready are those that
+// were not aggregated into apps because they are not Ready.
+func (r *MobileAppReconciler) prune(ctx context.Context, mobileApp *momov1alpha1.MobileApp, kind string, apps []momov1alpha1.MobileAppStatusApp, objs map[string]client.Object, unready []client.Object) ([]momov1alpha1.MobileAppStatusApp, time.Duration, error) {
+	var (
+		now                               = time.Now()
+		keep, prune, requeueAfter         = retained(mobileApp.Spec.Ordering, mobileApp.Spec.Retention, servedVersions(mobileApp), apps, objs, now)
+		pruneUnready, unreadyRequeueAfter = retainedUnready(mobileApp.Spec.Retention, apps, objs, unready, now)
+	)
+
+	for _, app := range prune {
+		other, deleted, err := r.deletePruned(ctx, mobileApp, app, objs[app.Name])
+		if err != nil {
+			return nil, 0, err
+		}
+
+		switch {
+		case other != "":
+			r.Eventf(mobileApp, corev1.EventTypeNormal, "NotPruned", "Not pruning %s %s version %s, as MobileApp %s keeps it", kind, app.Name, app.Version, other)
+		case deleted:
+			r.Eventf(mobileApp, corev1.EventTypeNormal, "Pruned", "Pruned %s %s version %s", kind, app.Name, app.Version)
+		}
+	}
+
+	for _, obj := range pruneUnready {
+		other, deleted, err := r.deletePruned(ctx, mobileApp, momov1alpha1.MobileAppStatusApp{Name: obj.GetName()}, obj)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		switch {
+		case other != "":
+			r.Eventf(mobileApp, corev1.EventTypeNormal, "NotPruned", "Not pruning %s %s that is not Ready, as MobileApp %s keeps it", kind, obj.GetName(), other)
+		case deleted:
+			r.Eventf(mobileApp, corev1.EventTypeNormal, "Pruned", "Pruned %s %s that is not Ready", kind, obj.GetName())
+		}
+	}
+
+	return keep, minRequeueAfter(requeueAfter, unreadyRequeueAfter), nil
+}
+
+// deletePruned deletes obj, the APK or IPA of app that mobileApp's retention policy does
+// not keep, unless another MobileApp keeps it, in which case it returns that MobileApp's
+// name. It reports whether obj was deleted by this call.
+func (r *MobileAppReconciler) deletePruned(ctx context.Context, mobileApp *momov1alpha1.MobileApp, app momov1alpha1.MobileAppStatusApp, obj client.Object) (string, bool, error) {
+	if !obj.GetDeletionTimestamp().IsZero() {
+		// Already pruned and waiting for its objects to be deleted.
+		return "", false, nil
+	}
+
+	others, err := listMobileAppsSelecting(ctx, r, mobileApp.Namespace, obj.GetLabels())
+	if err != nil {
+		return "", false, err
+	}
+
+	if other, ok := keptByOther(mobileApp, others, app, obj); ok {
+		return other, false, nil
+	}
+
+	annotations := obj.GetAnnotations()
+	if annotations == nil {
+		annotations = map[string]string{}
+	}
+
+	if annotations[AnnotationPruned] != mobileApp.Name {
+		annotations[AnnotationPruned] = mobileApp.Name
+		obj.SetAnnotations(annotations)
+
+		if err := r.Update(ctx, obj); err != nil {
+			return "", false, ignoreNotFound(err)
+		}
+	}
+
+	if err := r.Delete(ctx, obj); err != nil {
+		return "", false, ignoreNotFound(err)
+	}
+
+	return "", true, nil
+}
@@ -0,0 +1,250 @@
+package controller
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	momov1alpha1 "github.com/frantjc/momo/api/v1alpha1"
+	"github.com/frantjc/momo/internal/momoutil"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestRetained(t *testing.T) {
+	var (
+		now  = time.Now()
+		apks = []*momov1alpha1.APK{
+			{ObjectMeta: metav1.ObjectMeta{Name: "pinned", CreationTimestamp: metav1.NewTime(now.Add(-time.Hour * 4)), Annotations: map[string]string{AnnotationPinned: "true"}}},
+			{ObjectMeta: metav1.ObjectMeta{Name: "a", CreationTimestamp: metav1.NewTime(now.Add(-time.Hour * 3))}},
+			{ObjectMeta: metav1.ObjectMeta{Name: "b", CreationTimestamp: metav1.NewTime(now.Add(-time.Hour * 2))}},
+			{ObjectMeta: metav1.ObjectMeta{Name: "latest", CreationTimestamp: metav1.NewTime(now.Add(-time.Hour))}},
+		}
+		apps = []momov1alpha1.MobileAppStatusApp{
+			{Name: "pinned", Version: "v0.9.0"},
+			{Name: "a", Version: "v1.0.0"},
+			{Name: "b", Version: "v1.1.0"},
+			{Name: "latest", Version: "v1.2.0", Latest: true, Newest: true},
+		}
+		objs = map[string]client.Object{}
+	)
+
+	for _, apk := range apks {
+		objs[apk.Name] = apk
+	}
+
+	for _, tc := range []struct {
+		name         string
+		retention    *momov1alpha1.MobileAppSpecRetention
+		served       []string
+		pruned       []string
+		requeueAfter time.Duration
+	}{
+		{
+			name: "no retention",
+		},
+		{
+			name:      "empty retention",
+			retention: &momov1alpha1.MobileAppSpecRetention{},
+		},
+		{
+			name:      "keep last",
+			retention: &momov1alpha1.MobileAppSpecRetention{KeepLast: 2},
+			pruned:    []string{"a"},
+		},
+		{
+			name:      "keep last and served",
+			retention: &momov1alpha1.MobileAppSpecRetention{KeepLast: 1},
+			served:    []string{"v1.0.0"},
+			pruned:    []string{"b"},
+		},
+		{
+			name:         "keep newer than",
+			retention:    &momov1alpha1.MobileAppSpecRetention{KeepNewerThan: &metav1.Duration{Duration: time.Minute * 150}},
+			pruned:       []string{"a"},
+			requeueAfter: time.Minute * 30,
+		},
+		{
+			name:      "keep last and newer than",
+			retention: &momov1alpha1.MobileAppSpecRetention{KeepLast: 1, KeepNewerThan: &metav1.Duration{Duration: time.Minute * 90}},
+			pruned:    []string{"a", "b"},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			keep, prune, requeueAfter := retained(momov1alpha1.OrderingSemVer, tc.retention, tc.served, apps, objs, now)
+
+			if len(keep)+len(prune) != len(apps) {
+				t.Fatalf("expected every app to be kept or pruned, got %d kept and %d pruned", len(keep), len(prune))
+			}
+
+			for _, app := range apps {
+				var (
+					expected = slices.Contains(tc.pruned, app.Name)
+					pruned   = slices.ContainsFunc(prune, func(pruned momov1alpha1.MobileAppStatusApp) bool {
+						return pruned.Name == app.Name
+					})
+				)
+
+				if pruned != expected {
+					t.Errorf("expected %s to be pruned: %t, got %t", app.Name, expected, pruned)
+				}
+			}
+
+			if requeueAfter != tc.requeueAfter {
+				t.Errorf("expected to requeue after %s, got %s", tc.requeueAfter, requeueAfter)
+			}
+		})
+	}
+}
+
+func TestRetainedUnready(t *testing.T) {
+	var (
+		now  = time.Now()
+		apks = []*momov1alpha1.APK{
+			{ObjectMeta: metav1.ObjectMeta{Name: "a", CreationTimestamp: metav1.NewTime(now.Add(-time.Hour * 3))}},
+			{ObjectMeta: metav1.ObjectMeta{Name: "b", CreationTimestamp: metav1.NewTime(now.Add(-time.Hour))}},
+		}
+		apps = []momov1alpha1.MobileAppStatusApp{
+			{Name: "a", Version: "v1.0.0"},
+			{Name: "b", Version: "v1.1.0", Latest: true, Newest: true},
+		}
+		objs    = map[string]client.Object{}
+		unready = []client.Object{
+			&momov1alpha1.APK{
+				ObjectMeta: metav1.ObjectMeta{Name: "pinned", CreationTimestamp: metav1.NewTime(now.Add(-time.Hour * 5)), Annotations: map[string]string{AnnotationPinned: "true"}},
+				Status:     momov1alpha1.APKStatus{Phase: momov1alpha1.PhaseFailed},
+			},
+			&momov1alpha1.APK{
+				ObjectMeta: metav1.ObjectMeta{Name: "failed", CreationTimestamp: metav1.NewTime(now.Add(-time.Hour * 4))},
+				Status:     momov1alpha1.APKStatus{Phase: momov1alpha1.PhaseFailed},
+			},
+			&momov1alpha1.APK{
+				ObjectMeta: metav1.ObjectMeta{Name: "pending", CreationTimestamp: metav1.NewTime(now.Add(-time.Hour * 2))},
+				Status:     momov1alpha1.APKStatus{Phase: momov1alpha1.PhasePending},
+			},
+		}
+	)
+
+	for _, apk := range apks {
+		objs[apk.Name] = apk
+	}
+
+	for _, tc := range []struct {
+		name         string
+		retention    *momov1alpha1.MobileAppSpecRetention
+		pruned       []string
+		requeueAfter time.Duration
+	}{
+		{
+			name: "no retention",
+		},
+		{
+			name:      "keep last",
+			retention: &momov1alpha1.MobileAppSpecRetention{KeepLast: 1},
+			pruned:    []string{"failed", "pending"},
+		},
+		{
+			name:      "keep last of builds uploaded since",
+			retention: &momov1alpha1.MobileAppSpecRetention{KeepLast: 2},
+			pruned:    []string{"failed"},
+		},
+		{
+			name:         "keep newer than",
+			retention:    &momov1alpha1.MobileAppSpecRetention{KeepNewerThan: &metav1.Duration{Duration: time.Minute * 150}},
+			pruned:       []string{"failed"},
+			requeueAfter: time.Minute * 30,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			prune, requeueAfter := retainedUnready(tc.retention, apps, objs, unready, now)
+
+			for _, obj := range unready {
+				var (
+					expected = slices.Contains(tc.pruned, obj.GetName())
+					pruned   = slices.Contains(prune, obj)
+				)
+
+				if pruned != expected {
+					t.Errorf("expected %s to be pruned: %t, got %t", obj.GetName(), expected, pruned)
+				}
+			}
+
+			if requeueAfter != tc.requeueAfter {
+				t.Errorf("expected to requeue after %s, got %s", tc.requeueAfter, requeueAfter)
+			}
+		})
+	}
+}
+
+func TestPruneKeptByOther(t *testing.T) {
+	scheme, err := momoutil.NewScheme(corev1.AddToScheme, momov1alpha1.AddToScheme)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var (
+		ctx      = context.Background()
+		selector = map[string]string{momoutil.LabelApp: "app"}
+		old      = &momov1alpha1.APK{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "old", Labels: selector}}
+		apps     = []momov1alpha1.MobileAppStatusApp{
+			{Name: old.Name, Version: "v1.0.0"},
+			{Name: "new", Version: "v1.1.0", Latest: true, Newest: true},
+		}
+		objs = map[string]client.Object{
+			old.Name: old,
+			"new":    &momov1alpha1.APK{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "new", Labels: selector}},
+		}
+		mobileApp = &momov1alpha1.MobileApp{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "app"},
+			Spec: momov1alpha1.MobileAppSpec{
+				Selector:  selector,
+				Retention: &momov1alpha1.MobileAppSpecRetention{KeepLast: 1},
+			},
+		}
+		// other selects the same APKs, but has no retention policy, so it keeps them all.
+		other = &momov1alpha1.MobileApp{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "other"},
+			Spec:       momov1alpha1.MobileAppSpec{Selector: selector},
+		}
+		cli = fake.NewClientBuilder().
+			WithScheme(scheme).
+			WithObjects(old, mobileApp, other).
+			WithIndex(&momov1alpha1.MobileApp{}, IndexSelector, func(obj client.Object) []string {
+				return selectorIndexValues(obj.(*momov1alpha1.MobileApp).Spec.Selector)
+			}).
+			Build()
+		r = &MobileAppReconciler{Client: cli, EventRecorder: record.NewFakeRecorder(10)}
+	)
+
+	keep, _, err := r.prune(ctx, mobileApp, "APK", apps, objs, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(keep) != 1 {
+		t.Fatalf("expected 1 app to be kept, got %d", len(keep))
+	}
+
+	if err := cli.Get(ctx, client.ObjectKeyFromObject(old), &momov1alpha1.APK{}); err != nil {
+		t.Fatalf("expected %s to be kept for MobileApp %s: %v", old.Name, other.Name, err)
+	}
+
+	// Once other has a retention policy that it has already pruned old by, it is deleted.
+	other.Spec.Retention = &momov1alpha1.MobileAppSpecRetention{KeepLast: 1}
+	if err := cli.Update(ctx, other); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, _, err := r.prune(ctx, mobileApp, "APK", apps, objs, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := cli.Get(ctx, client.ObjectKeyFromObject(old), &momov1alpha1.APK{}); !apierrors.IsNotFound(err) {
+		t.Fatalf("expected %s to be pruned, got %v", old.Name, err)
+	}
+}
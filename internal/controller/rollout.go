@@ -0,0 +1,70 @@
+package controller
+
+import (
+	"time"
+
+	momov1alpha1 "github.com/frantjc/momo/api/v1alpha1"
+	"github.com/frantjc/momo/internal/momoutil"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// rollout records the progress of mobileApp's Rollout in its status, advancing
+// it through its steps on schedule, and returns how long until its next step.
+func (r *MobileAppReconciler) rollout(mobileApp *momov1alpha1.MobileApp, now time.Time) time.Duration {
+	spec := mobileApp.Spec.Rollout
+	if spec == nil {
+		mobileApp.Status.Rollout = nil
+		return 0
+	}
+
+	status := mobileApp.Status.Rollout
+	if status == nil || status.Version != spec.Version {
+		status = &momov1alpha1.MobileAppStatusRollout{
+			Version: spec.Version,
+			PreviousVersions: momov1alpha1.MobileAppStatusRolloutPreviousVersions{
+				Android: momoutil.PreviousVersion(mobileApp.Spec.Ordering, mobileApp.Status.APKs, spec.Version),
+				IOS:     momoutil.PreviousVersion(mobileApp.Spec.Ordering, mobileApp.Status.IPAs, spec.Version),
+			},
+			StepStartTime: metav1.NewTime(now),
+		}
+		r.Eventf(mobileApp, corev1.EventTypeNormal, "RolloutStarted", "Started rolling out version %s", spec.Version)
+	}
+
+	var requeueAfter time.Duration
+
+	switch {
+	case len(spec.Steps) == 0:
+		status.Step = 0
+		status.Percentage = spec.Percentage
+	case spec.Halted || spec.RolledBack:
+		// Restart the current step when the rollout is resumed.
+		status.Step = min(status.Step, len(spec.Steps)-1)
+		status.StepStartTime = metav1.NewTime(now)
+		status.Percentage = spec.Steps[status.Step].Percentage
+	default:
+		status.Step = min(status.Step, len(spec.Steps)-1)
+
+		for status.Step < len(spec.Steps)-1 {
+			next := status.StepStartTime.Add(spec.Steps[status.Step].Duration.Duration)
+			if now.Before(next) {
+				requeueAfter = next.Sub(now)
+				break
+			}
+
+			status.Step++
+			status.StepStartTime = metav1.NewTime(next)
+			r.Eventf(mobileApp, corev1.EventTypeNormal, "RolloutAdvanced", "Advanced rollout of version %s to %d%%", spec.Version, spec.Steps[status.Step].Percentage)
+		}
+
+		status.Percentage = spec.Steps[status.Step].Percentage
+	}
+
+	if spec.RolledBack {
+		status.Percentage = 0
+	}
+
+	mobileApp.Status.Rollout = status
+
+	return requeueAfter
+}
@@ -0,0 +1,42 @@
+package controller
+
+import (
+	"testing"
+	"time"
+
+	momov1alpha1 "github.com/frantjc/momo/api/v1alpha1"
+	"k8s.io/client-go/tools/record"
+)
+
+func TestRolloutPreviousVersions(t *testing.T) {
+	var (
+		r         = &MobileAppReconciler{EventRecorder: record.NewFakeRecorder(10)}
+		mobileApp = &momov1alpha1.MobileApp{
+			Spec: momov1alpha1.MobileAppSpec{
+				Rollout: &momov1alpha1.MobileAppSpecRollout{Version: "v1.2.0", Percentage: 10},
+			},
+			Status: momov1alpha1.MobileAppStatus{
+				APKs: []momov1alpha1.MobileAppStatusApp{
+					{Name: "app-v1.2.0", Version: "v1.2.0"},
+					{Name: "app-v1.1.0", Version: "v1.1.0"},
+				},
+				IPAs: []momov1alpha1.MobileAppStatusApp{
+					{Name: "app-v1.2.0", Version: "v1.2.0"},
+					{Name: "app-v1.0.0", Version: "v1.0.0"},
+				},
+			},
+		}
+	)
+
+	r.rollout(mobileApp, time.Now())
+
+	if mobileApp.Status.Rollout == nil {
+		t.Fatal("expected the rollout to be in progress")
+	}
+
+	// v1.1.0 was only built for Android, so iOS devices outside
+	// of the rollout must keep getting v1.0.0 instead.
+	if previous := mobileApp.Status.Rollout.PreviousVersions; previous.Android != "v1.1.0" || previous.IOS != "v1.0.0" {
+		t.Errorf("expected the previous versions to be v1.1.0 on Android and v1.0.0 on iOS, got %+v", previous)
+	}
+}
@@ -0,0 +1,151 @@
+package controller
+
+import (
+	"context"
+	"strings"
+
+	momov1alpha1 "github.com/frantjc/momo/api/v1alpha1"
+	"github.com/frantjc/momo/internal/momoutil"
+	"github.com/frantjc/momo/internal/scan"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	ConditionScanned = "Scanned"
+)
+
+// resetScanned forgets that obj's object was scanned, e.g. because it changed.
+func resetScanned(obj BinaryObject) {
+	conditions := obj.GetConditions()
+	meta.RemoveStatusCondition(&conditions, ConditionScanned)
+	obj.SetConditions(conditions)
+	obj.SetFindings(nil)
+}
+
+// ready marks obj Ready. If scanner is set, then obj's objects must have been scanned clean
+// first, so they are scanned if they have not been. If any is not clean, obj is marked Failed instead.
+func ready(ctx context.Context, cli client.Client, recorder record.EventRecorder, scanner scan.Scanner, bucket *momoutil.BucketHandle, obj BinaryObject) (ctrl.Result, error) {
+	if scanner != nil && !meta.IsStatusConditionTrue(obj.GetConditions(), ConditionScanned) {
+		// Objects that were found to be infected are not scanned again until they change.
+		if condition := meta.FindStatusCondition(obj.GetConditions(), ConditionScanned); condition != nil && condition.Reason == "Infected" {
+			obj.SetPhase(momov1alpha1.PhaseFailed)
+			return ctrl.Result{}, ignoreNotFound(cli.Status().Update(ctx, obj))
+		}
+
+		if err := scanObject(ctx, recorder, scanner, bucket, obj); err != nil {
+			// The failure to scan is recorded in the status and is retried.
+			obj.SetPhase(momov1alpha1.PhasePending)
+			if uerr := cli.Status().Update(ctx, obj); uerr != nil {
+				return ctrl.Result{}, ignoreNotFound(uerr)
+			}
+
+			return ctrl.Result{}, err
+		}
+
+		if !meta.IsStatusConditionTrue(obj.GetConditions(), ConditionScanned) {
+			obj.SetPhase(momov1alpha1.PhaseFailed)
+			return ctrl.Result{}, ignoreNotFound(cli.Status().Update(ctx, obj))
+		}
+	}
+
+	obj.SetPhase(momov1alpha1.PhaseReady)
+	return ctrl.Result{}, ignoreNotFound(cli.Status().Update(ctx, obj))
+}
+
+// scannedKeys returns the keys of the objects of obj that are served, and so must be
+// scanned: its own object and, for an APK built from an .aab, its universal APK.
+func scannedKeys(obj BinaryObject) []string {
+	keys := []string{obj.GetKey()}
+
+	if apk, ok := obj.(*momov1alpha1.APK); ok && apk.Status.UniversalAPKKey != "" {
+		keys = append(keys, apk.Status.UniversalAPKKey)
+	}
+
+	return keys
+}
+
+// scanObject scans obj's objects with scanner and records the verdict on obj.
+func scanObject(ctx context.Context, recorder record.EventRecorder, scanner scan.Scanner, bucket *momoutil.BucketHandle, obj BinaryObject) (err error) {
+	ctx, span := momoutil.Tracer.Start(ctx, "scanObject")
+	defer func() {
+		momoutil.EndSpan(span, err)
+	}()
+
+	var (
+		findings = []string{}
+		clean    = true
+	)
+
+	for _, key := range scannedKeys(obj) {
+		verdict, err := scanKey(ctx, scanner, bucket, obj, key)
+		if err != nil {
+			return err
+		}
+
+		findings = append(findings, verdict.Findings...)
+
+		if !verdict.Clean {
+			clean = false
+			recorder.Eventf(obj, corev1.EventTypeWarning, "Infected", "Scanning %s found %s", key, strings.Join(verdict.Findings, ", "))
+		}
+	}
+
+	obj.SetFindings(findings)
+
+	if !clean {
+		setCondition(obj, metav1.Condition{
+			Type:    ConditionScanned,
+			Reason:  "Infected",
+			Status:  metav1.ConditionFalse,
+			Message: strings.Join(findings, ", "),
+		})
+
+		return nil
+	}
+
+	setCondition(obj, metav1.Condition{
+		Type:   ConditionScanned,
+		Reason: "Clean",
+		Status: metav1.ConditionTrue,
+	})
+
+	return nil
+}
+
+// scanKey scans the object at key with scanner, recording
+// on obj why it could not be scanned if that is the case.
+func scanKey(ctx context.Context, scanner scan.Scanner, bucket *momoutil.BucketHandle, obj BinaryObject, key string) (*scan.Verdict, error) {
+	rc, err := bucket.NewReader(ctx, key, nil)
+	if err != nil {
+		setCondition(obj, metav1.Condition{
+			Type:    ConditionScanned,
+			Reason:  "ReadObject",
+			Status:  metav1.ConditionFalse,
+			Message: err.Error(),
+		})
+
+		return nil, momoutil.ObserveBucketError(bucket.Driver, "read", err)
+	}
+	defer func() {
+		_ = rc.Close()
+	}()
+
+	verdict, err := scanner.Scan(ctx, key, rc)
+	if err != nil {
+		setCondition(obj, metav1.Condition{
+			Type:    ConditionScanned,
+			Reason:  "ScanFailed",
+			Status:  metav1.ConditionFalse,
+			Message: err.Error(),
+		})
+
+		return nil, err
+	}
+
+	return verdict, nil
+}
@@ -0,0 +1,92 @@
+package controller
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+
+	momov1alpha1 "github.com/frantjc/momo/api/v1alpha1"
+	"github.com/frantjc/momo/internal/momoutil"
+	"github.com/frantjc/momo/internal/scan"
+	_ "gocloud.dev/blob/memblob"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+type scannerFunc func(name string, data string) *scan.Verdict
+
+func (f scannerFunc) Scan(_ context.Context, name string, r io.Reader) (*scan.Verdict, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	return f(name, string(data)), nil
+}
+
+func TestScanUniversalAPK(t *testing.T) {
+	scheme, err := momoutil.NewScheme(corev1.AddToScheme, momov1alpha1.AddToScheme)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var (
+		ctx    = context.Background()
+		bucket = &momov1alpha1.Bucket{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "scan"},
+			Spec:       momov1alpha1.BucketSpec{URL: "mem://scan"},
+		}
+		apk = &momov1alpha1.APK{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "app"},
+			Spec:       momov1alpha1.APKSpec{Bucket: momov1alpha1.BucketReference{Name: bucket.Name}, Key: "app.aab"},
+			Status:     momov1alpha1.APKStatus{UniversalAPKKey: "default/app/universal.apk"},
+		}
+		cli     = fake.NewClientBuilder().WithScheme(scheme).WithObjects(bucket, apk).WithStatusSubresource(apk).Build()
+		buckets = &momoutil.BucketManager{}
+		scanner = scannerFunc(func(_ string, data string) *scan.Verdict {
+			if strings.Contains(data, "EICAR") {
+				return &scan.Verdict{Findings: []string{"Eicar-Test-Signature"}}
+			}
+
+			return &scan.Verdict{Clean: true}
+		})
+	)
+	defer func() {
+		_ = buckets.Close()
+	}()
+
+	b, err := buckets.OpenBucket(ctx, cli, bucket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = b.Close()
+	}()
+
+	// The .aab itself is clean, but the universal APK
+	// that is built from it and served is not.
+	for key, data := range map[string]string{
+		apk.Spec.Key:               "aab",
+		apk.Status.UniversalAPKKey: "EICAR",
+	} {
+		if err := b.WriteAll(ctx, key, []byte(data), nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if _, err := ready(ctx, cli, record.NewFakeRecorder(10), scanner, b, apk); err != nil {
+		t.Fatal(err)
+	}
+
+	if apk.Status.Phase != momov1alpha1.PhaseFailed {
+		t.Errorf("expected an APK with an infected universal APK to be %s, got %s", momov1alpha1.PhaseFailed, apk.Status.Phase)
+	}
+
+	if scanned := meta.FindStatusCondition(apk.Status.Conditions, ConditionScanned); scanned == nil || scanned.Reason != "Infected" {
+		t.Errorf("expected the APK to be found to be infected, got %+v", scanned)
+	}
+}
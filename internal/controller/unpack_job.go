@@ -0,0 +1,277 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"time"
+
+	"github.com/frantjc/momo/internal/momoutil"
+	"github.com/opencontainers/go-digest"
+	"gocloud.dev/blob"
+	"gocloud.dev/gcerrors"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+const (
+	// AnnotationDigest is set on an unpack Job to the digest
+	// of the object that it is unpacking.
+	AnnotationDigest = "momo.frantj.cc/digest"
+)
+
+// UnpackJobOpts configure unpacking APKs and IPAs in Kubernetes Jobs instead
+// of in the controller's process, so that a huge or malicious one cannot
+// exhaust the controller's resources.
+type UnpackJobOpts struct {
+	// Image is an image of momo to run `momo unpack` from.
+	Image string
+	// Resources are the resources of the Job's container.
+	Resources corev1.ResourceRequirements
+	// Timeout is how long the Job may run for.
+	Timeout time.Duration
+}
+
+// unpackJobName returns the name of the Job that unpacks obj. It ends in a short hash
+// of obj's namespace, name and UID so that objects whose names only differ past where
+// they are truncated, such as by their random suffixes, do not share a Job.
+func unpackJobName(obj BinaryObject) string {
+	var (
+		hash = digest.FromString(fmt.Sprintf("%s/%s/%s", obj.GetNamespace(), obj.GetName(), obj.GetUID())).Encoded()[:8]
+		name = obj.GetName()
+	)
+	// Leave room for the hash and suffix within the 63 characters
+	// that the Job's name is limited to in its Pods' labels.
+	if len(name) > 47 {
+		name = name[:47]
+	}
+	return fmt.Sprintf("%s-%s-unpack", name, hash)
+}
+
+// unpackResultKey returns the key that the Job that unpacks obj writes its result to.
+func unpackResultKey(obj BinaryObject) string {
+	return filepath.Join(
+		filepath.Dir(obj.GetKey()),
+		obj.GetNamespace(),
+		obj.GetName(),
+		"unpack.json",
+	)
+}
+
+// getUnpackJob gets the Job that is unpacking obj, if any.
+func getUnpackJob(ctx context.Context, cli client.Client, obj BinaryObject) (*batchv1.Job, error) {
+	job := &batchv1.Job{}
+
+	if err := cli.Get(ctx, client.ObjectKey{Namespace: obj.GetNamespace(), Name: unpackJobName(obj)}, job); err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil, nil
+		}
+
+		return nil, err
+	}
+
+	return job, nil
+}
+
+// unpackInJob creates a Job to unpack obj's object with the given digest if job
+// is nil. Once job has finished, it reads back the result that the Job wrote to
+// bucket and cleans up after it. It returns a nil result while job is running.
+func unpackInJob(ctx context.Context, cli client.Client, bucket *momoutil.BucketHandle, opts *UnpackJobOpts, obj BinaryObject, job *batchv1.Job, platform string, dig digest.Digest) (*momoutil.UnpackResult, error) {
+	key := unpackResultKey(obj)
+
+	if job == nil {
+		return nil, createUnpackJob(ctx, cli, bucket, opts, obj, key, platform, dig)
+	}
+
+	var (
+		complete = jobCondition(job, batchv1.JobComplete)
+		failed   = jobCondition(job, batchv1.JobFailed)
+	)
+
+	if complete == nil && failed == nil {
+		return nil, nil
+	}
+
+	result := &momoutil.UnpackResult{}
+
+	b, err := bucket.ReadAll(ctx, key)
+	if err == nil {
+		err = json.Unmarshal(b, result)
+	} else {
+		err = momoutil.ObserveBucketError(bucket.Driver, "read", err)
+	}
+
+	if err := cleanUpUnpackJob(ctx, cli, bucket, job, key); err != nil {
+		return nil, err
+	}
+
+	switch {
+	case result.Error != "":
+		return nil, fmt.Errorf("unpack job %s: %s", job.Name, result.Error)
+	case failed != nil:
+		return nil, fmt.Errorf("unpack job %s: %s: %s", job.Name, failed.Reason, failed.Message)
+	case err != nil:
+		return nil, fmt.Errorf("read result of unpack job %s: %w", job.Name, err)
+	}
+
+	return result, nil
+}
+
+func jobCondition(job *batchv1.Job, conditionType batchv1.JobConditionType) *batchv1.JobCondition {
+	for _, condition := range job.Status.Conditions {
+		if condition.Type == conditionType && condition.Status == corev1.ConditionTrue {
+			return &condition
+		}
+	}
+
+	return nil
+}
+
+func cleanUpUnpackJob(ctx context.Context, cli client.Client, bucket *momoutil.BucketHandle, job *batchv1.Job, key string) error {
+	if err := bucket.Delete(ctx, key); gcerrors.Code(err) != gcerrors.NotFound {
+		_ = momoutil.ObserveBucketError(bucket.Driver, "delete", err)
+	}
+
+	return ignoreNotFound(cli.Delete(ctx, job, client.PropagationPolicy(metav1.DeletePropagationBackground)))
+}
+
+func createUnpackJob(ctx context.Context, cli client.Client, bucket *momoutil.BucketHandle, opts *UnpackJobOpts, obj BinaryObject, key, platform string, dig digest.Digest) error {
+	// The Job does not get a ServiceAccount token, so it gets
+	// URLs to read the object and write the result with instead.
+	expiry := opts.Timeout + time.Minute*5
+
+	url, err := bucket.SignedURL(ctx, obj.GetKey(), &blob.SignedURLOptions{Expiry: expiry})
+	if err != nil {
+		return momoutil.ObserveBucketError(bucket.Driver, "sign", err)
+	}
+
+	resultURL, err := bucket.SignedURL(ctx, key, &blob.SignedURLOptions{
+		Expiry:      expiry,
+		Method:      http.MethodPut,
+		ContentType: "application/json",
+	})
+	if err != nil {
+		return momoutil.ObserveBucketError(bucket.Driver, "sign", err)
+	}
+
+	subcommand := "ipa"
+	if platform == momoutil.PlatformAndroid {
+		subcommand = "apk"
+	}
+
+	name := unpackJobName(obj)
+
+	job := &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: obj.GetNamespace(),
+			Name:      name,
+			Annotations: map[string]string{
+				AnnotationDigest: dig.String(),
+			},
+		},
+		Spec: batchv1.JobSpec{
+			BackoffLimit:          &[]int32{0}[0],
+			ActiveDeadlineSeconds: &[]int64{int64(opts.Timeout.Seconds())}[0],
+			Template: corev1.PodTemplateSpec{
+				Spec: corev1.PodSpec{
+					RestartPolicy:                corev1.RestartPolicyNever,
+					AutomountServiceAccountToken: &[]bool{false}[0],
+					EnableServiceLinks:           &[]bool{false}[0],
+					Containers: []corev1.Container{
+						{
+							Name:  "unpack",
+							Image: opts.Image,
+							Args: []string{
+								"unpack", subcommand,
+								"--url=$(MOMO_UNPACK_URL)",
+								"--result-url=$(MOMO_UNPACK_RESULT_URL)",
+							},
+							Env: []corev1.EnvVar{
+								unpackJobSecretEnv(name, "MOMO_UNPACK_URL"),
+								unpackJobSecretEnv(name, "MOMO_UNPACK_RESULT_URL"),
+								{Name: "HOME", Value: "/tmp"},
+								{Name: "TMPDIR", Value: "/tmp"},
+								// Keep apktool's JVM within the container's memory limit.
+								{Name: "JAVA_TOOL_OPTIONS", Value: "-XX:MaxRAMPercentage=75"},
+							},
+							Resources: opts.Resources,
+							SecurityContext: &corev1.SecurityContext{
+								AllowPrivilegeEscalation: &[]bool{false}[0],
+								ReadOnlyRootFilesystem:   &[]bool{true}[0],
+								Capabilities: &corev1.Capabilities{
+									Drop: []corev1.Capability{"ALL"},
+								},
+							},
+							VolumeMounts: []corev1.VolumeMount{
+								{Name: "tmp", MountPath: "/tmp"},
+							},
+						},
+					},
+					Volumes: []corev1.Volume{
+						{
+							Name: "tmp",
+							VolumeSource: corev1.VolumeSource{
+								EmptyDir: &corev1.EmptyDirVolumeSource{},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	if err := controllerutil.SetControllerReference(obj, job, cli.Scheme()); err != nil {
+		return err
+	}
+
+	if err := cli.Create(ctx, job); err != nil {
+		return err
+	}
+
+	// The signed URLs are kept out of the Job's spec, which anyone who can get Jobs
+	// can read, in a Secret that the Job owns so that it is deleted along with it.
+	// The Job's Pod does not start until the Secret exists.
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: job.Namespace,
+			Name:      name,
+		},
+		StringData: map[string]string{
+			"MOMO_UNPACK_URL":        url,
+			"MOMO_UNPACK_RESULT_URL": resultURL,
+		},
+	}
+
+	if err := controllerutil.SetControllerReference(job, secret, cli.Scheme()); err != nil {
+		return err
+	}
+
+	if err := cli.Create(ctx, secret); err != nil {
+		// Without its Secret the Job would never start, so
+		// delete it for the next reconcile to create both again.
+		return errors.Join(err, ignoreNotFound(cli.Delete(ctx, job, client.PropagationPolicy(metav1.DeletePropagationBackground))))
+	}
+
+	return nil
+}
+
+// unpackJobSecretEnv returns an environment variable of the given
+// name that is read from the key of the same name in the Secret.
+func unpackJobSecretEnv(secretName, name string) corev1.EnvVar {
+	return corev1.EnvVar{
+		Name: name,
+		ValueFrom: &corev1.EnvVarSource{
+			SecretKeyRef: &corev1.SecretKeySelector{
+				LocalObjectReference: corev1.LocalObjectReference{Name: secretName},
+				Key:                  name,
+			},
+		},
+	}
+}
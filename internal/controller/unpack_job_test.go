@@ -0,0 +1,40 @@
+package controller
+
+import (
+	"strings"
+	"testing"
+
+	momov1alpha1 "github.com/frantjc/momo/api/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+func TestUnpackJobName(t *testing.T) {
+	var (
+		// Uploaded APKs are named after their app with a random suffix,
+		// so those of apps with long names only differ at their ends.
+		app = strings.Repeat("a", 60)
+		a   = &momov1alpha1.APK{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: app + "-abcde", UID: "a"}}
+		b   = &momov1alpha1.APK{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: app + "-fghij", UID: "b"}}
+	)
+
+	nameA, nameB := unpackJobName(a), unpackJobName(b)
+
+	if nameA == nameB {
+		t.Fatalf("expected APKs %s and %s to have different unpack Jobs, both got %s", a.Name, b.Name, nameA)
+	}
+
+	for _, name := range []string{nameA, nameB} {
+		if errs := validation.IsDNS1123Label(name); len(errs) > 0 {
+			t.Errorf("unpack Job name %s is not a valid label value: %s", name, strings.Join(errs, ", "))
+		}
+	}
+
+	if unpackJobName(a) != nameA {
+		t.Error("expected the unpack Job name of an APK to be stable")
+	}
+
+	if short := unpackJobName(&momov1alpha1.APK{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "app-abcde"}}); !strings.HasPrefix(short, "app-abcde-") {
+		t.Errorf("expected the unpack Job name of a short APK name to start with it, got %s", short)
+	}
+}
@@ -0,0 +1,226 @@
+package momoutil
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"time"
+
+	momov1alpha1 "github.com/frantjc/momo/api/v1alpha1"
+	"github.com/go-logr/logr"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/util/retry"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// AppEventInstall is asking to install a version of an app.
+	AppEventInstall = "install"
+	// AppEventManifest is fetching the manifest.plist of a version
+	// of an app, which iOS does when it goes through with an install.
+	AppEventManifest = "manifest"
+	// AppEventDownload is downloading the APK or IPA of a version of an app.
+	AppEventDownload = "download"
+)
+
+// AppEvent is a client installing or downloading a version of an app.
+type AppEvent struct {
+	Type      string
+	Namespace string
+	App       string
+	Version   string
+	Platform  string
+	// Client is the coarse kind of the client, e.g. "iPhone iOS 17" or "Android 14".
+	Client string
+	// Network is the network that the client's IP address is in, e.g. 203.0.113.0/24,
+	// so that clients can be told apart roughly without being identified.
+	Network string
+	Time    time.Time
+}
+
+// AppEventSink records AppEvents.
+type AppEventSink interface {
+	RecordAppEvent(context.Context, AppEvent)
+}
+
+// LogAppEventSink logs AppEvents.
+type LogAppEventSink struct {
+	Logger logr.Logger
+}
+
+func (s *LogAppEventSink) RecordAppEvent(_ context.Context, event AppEvent) {
+	s.Logger.Info("app event",
+		"type", event.Type,
+		"namespace", event.Namespace,
+		"app", event.App,
+		"version", event.Version,
+		"platform", event.Platform,
+		"client", event.Client,
+		"network", event.Network,
+	)
+}
+
+// TraceAppEventSink adds AppEvents to the span of the request that they happened
+// during, so that they are exported along with it, e.g. to an OTLP endpoint.
+type TraceAppEventSink struct{}
+
+func (TraceAppEventSink) RecordAppEvent(ctx context.Context, event AppEvent) {
+	trace.SpanFromContext(ctx).AddEvent("momo.app_event",
+		trace.WithTimestamp(event.Time),
+		trace.WithAttributes(
+			attribute.String("momo.app_event.type", event.Type),
+			attribute.String("momo.namespace", event.Namespace),
+			attribute.String("momo.app", event.App),
+			attribute.String("momo.version", event.Version),
+			attribute.String("momo.platform", event.Platform),
+			attribute.String("momo.client", event.Client),
+			attribute.String("momo.network", event.Network),
+		),
+	)
+}
+
+// StatusAppEventSink counts AppEvents in memory and periodically adds the
+// counts to the Stats in the status of the MobileApps that they are of.
+type StatusAppEventSink struct {
+	Client client.Client
+	// Interval is how often the counts are flushed.
+	Interval time.Duration
+
+	mu     sync.Mutex
+	counts map[client.ObjectKey][]momov1alpha1.MobileAppStatusStats
+}
+
+func (s *StatusAppEventSink) RecordAppEvent(_ context.Context, event AppEvent) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.counts == nil {
+		s.counts = map[client.ObjectKey][]momov1alpha1.MobileAppStatusStats{}
+	}
+
+	key := client.ObjectKey{Namespace: event.Namespace, Name: event.App}
+	s.counts[key] = addStats(s.counts[key], statsFromAppEvent(event))
+}
+
+// Start flushes the counts every Interval until ctx is done, then flushes them one last time.
+func (s *StatusAppEventSink) Start(ctx context.Context) error {
+	interval := s.Interval
+	if interval <= 0 {
+		interval = time.Minute
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return s.Flush(context.WithoutCancel(ctx))
+		case <-ticker.C:
+			// Counts that failed to flush are kept to be tried again.
+			_ = s.Flush(ctx)
+		}
+	}
+}
+
+// Flush adds the counts to the status of the MobileApps that they are of
+// and drops the Stats of versions that they no longer have an APK or IPA of.
+// Counts that cannot be added are kept to be added by the next Flush, unless
+// their MobileApp is not found.
+func (s *StatusAppEventSink) Flush(ctx context.Context) error {
+	s.mu.Lock()
+	counts := s.counts
+	s.counts = nil
+	s.mu.Unlock()
+
+	errs := []error{}
+
+	for key, stats := range counts {
+		if err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+			mobileApp := &momov1alpha1.MobileApp{}
+
+			if err := s.Client.Get(ctx, key, mobileApp); err != nil {
+				return err
+			}
+
+			for _, stat := range stats {
+				mobileApp.Status.Stats = addStats(mobileApp.Status.Stats, stat)
+			}
+			mobileApp.Status.Stats = dropStats(mobileApp)
+
+			return s.Client.Status().Update(ctx, mobileApp)
+		}); err != nil {
+			if err = client.IgnoreNotFound(err); err != nil {
+				errs = append(errs, err)
+
+				s.mu.Lock()
+				if s.counts == nil {
+					s.counts = map[client.ObjectKey][]momov1alpha1.MobileAppStatusStats{}
+				}
+				for _, stat := range stats {
+					s.counts[key] = addStats(s.counts[key], stat)
+				}
+				s.mu.Unlock()
+			}
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+func statsFromAppEvent(event AppEvent) momov1alpha1.MobileAppStatusStats {
+	stats := momov1alpha1.MobileAppStatusStats{
+		Version:  event.Version,
+		Platform: event.Platform,
+		LastTime: &metav1.Time{Time: event.Time},
+	}
+
+	switch event.Type {
+	case AppEventInstall:
+		stats.Installs = 1
+	case AppEventManifest:
+		stats.ManifestFetches = 1
+	case AppEventDownload:
+		stats.Downloads = 1
+	}
+
+	return stats
+}
+
+// addStats adds stat to the stats of the same version and platform in stats, if any.
+func addStats(stats []momov1alpha1.MobileAppStatusStats, stat momov1alpha1.MobileAppStatusStats) []momov1alpha1.MobileAppStatusStats {
+	i := slices.IndexFunc(stats, func(s momov1alpha1.MobileAppStatusStats) bool {
+		return s.Version == stat.Version && s.Platform == stat.Platform
+	})
+	if i < 0 {
+		return append(stats, stat)
+	}
+
+	stats[i].Installs += stat.Installs
+	stats[i].ManifestFetches += stat.ManifestFetches
+	stats[i].Downloads += stat.Downloads
+	if stats[i].LastTime == nil || (stat.LastTime != nil && stats[i].LastTime.Before(stat.LastTime)) {
+		stats[i].LastTime = stat.LastTime
+	}
+
+	return stats
+}
+
+// dropStats returns the Stats of mobileApp without those of
+// versions that it no longer has an APK or IPA of, so that they
+// do not grow without bound as versions come and go.
+func dropStats(mobileApp *momov1alpha1.MobileApp) []momov1alpha1.MobileAppStatusStats {
+	return slices.DeleteFunc(mobileApp.Status.Stats, func(stat momov1alpha1.MobileAppStatusStats) bool {
+		apps := mobileApp.Status.IPAs
+		if stat.Platform == PlatformAndroid {
+			apps = mobileApp.Status.APKs
+		}
+
+		return !slices.ContainsFunc(apps, func(app momov1alpha1.MobileAppStatusApp) bool {
+			return app.Version == stat.Version
+		})
+	})
+}
@@ -0,0 +1,87 @@
+package momoutil_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	momov1alpha1 "github.com/frantjc/momo/api/v1alpha1"
+	"github.com/frantjc/momo/internal/momoutil"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestStatusAppEventSink(t *testing.T) {
+	scheme, err := momoutil.NewScheme(corev1.AddToScheme, momov1alpha1.AddToScheme)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var (
+		ctx       = context.Background()
+		mobileApp = &momov1alpha1.MobileApp{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "app"},
+			Status: momov1alpha1.MobileAppStatus{
+				APKs: []momov1alpha1.MobileAppStatusApp{{Name: "app-apk", Version: "v1.0.0", Latest: true, Newest: true}},
+				IPAs: []momov1alpha1.MobileAppStatusApp{{Name: "app-ipa", Version: "v1.0.0", Latest: true, Newest: true}},
+				Stats: []momov1alpha1.MobileAppStatusStats{
+					{Version: "v1.0.0", Platform: momoutil.PlatformIOS, Installs: 1},
+					// Stats of versions that the MobileApp no longer has are dropped.
+					{Version: "v0.9.0", Platform: momoutil.PlatformIOS, Installs: 1},
+				},
+			},
+		}
+		key  = client.ObjectKeyFromObject(mobileApp)
+		cli  = fake.NewClientBuilder().WithScheme(scheme).WithObjects(mobileApp).WithStatusSubresource(mobileApp).Build()
+		sink = &momoutil.StatusAppEventSink{Client: cli}
+		now  = time.Now().Truncate(time.Second)
+	)
+
+	for _, event := range []momoutil.AppEvent{
+		{Type: momoutil.AppEventInstall, App: "app", Platform: momoutil.PlatformIOS},
+		{Type: momoutil.AppEventManifest, App: "app", Platform: momoutil.PlatformIOS},
+		{Type: momoutil.AppEventDownload, App: "app", Platform: momoutil.PlatformIOS},
+		{Type: momoutil.AppEventDownload, App: "app", Platform: momoutil.PlatformAndroid, Time: now.Add(-time.Minute)},
+		{Type: momoutil.AppEventDownload, App: "app", Platform: momoutil.PlatformAndroid, Time: now},
+		// Counts of MobileApps that are not found are dropped.
+		{Type: momoutil.AppEventInstall, App: "missing", Platform: momoutil.PlatformIOS},
+	} {
+		event.Namespace = "default"
+		event.Version = "v1.0.0"
+		sink.RecordAppEvent(ctx, event)
+	}
+
+	if err := sink.Flush(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	flushed := &momov1alpha1.MobileApp{}
+
+	if err := cli.Get(ctx, key, flushed); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(flushed.Status.Stats) != 2 {
+		t.Fatalf("expected stats of 2 versions, got %d", len(flushed.Status.Stats))
+	}
+
+	for _, stats := range flushed.Status.Stats {
+		switch stats.Platform {
+		case momoutil.PlatformIOS:
+			if stats.Installs != 2 || stats.ManifestFetches != 1 || stats.Downloads != 1 {
+				t.Errorf("unexpected iOS stats %+v", stats)
+			}
+		case momoutil.PlatformAndroid:
+			if stats.Downloads != 2 || stats.LastTime == nil || !stats.LastTime.Time.Equal(now) {
+				t.Errorf("unexpected Android stats %+v", stats)
+			}
+		}
+	}
+
+	// Everything was flushed, so flushing again changes nothing.
+	if err := sink.Flush(ctx); err != nil {
+		t.Fatal(err)
+	}
+}
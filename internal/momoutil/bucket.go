@@ -10,18 +10,22 @@ import (
 	"net/http"
 	"net/url"
 	"os"
+	"path"
+	"slices"
 	"strings"
-	"sync"
 
 	"github.com/frantjc/momo"
 	"github.com/frantjc/momo/android"
 	momov1alpha1 "github.com/frantjc/momo/api/v1alpha1"
 	"github.com/frantjc/momo/ios"
 	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 	"gocloud.dev/blob"
 	corev1 "k8s.io/api/core/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 )
@@ -36,9 +40,72 @@ func GetBucket(ctx context.Context, cli client.Client, key client.ObjectKey) (*m
 	return bucket, nil
 }
 
-var (
-	mu sync.Mutex
-)
+// GetBucketReference gets the Bucket or ClusterBucket that ref refers to from the given
+// namespace, returning a Forbidden error if a ClusterBucket does not allow the namespace.
+func GetBucketReference(ctx context.Context, cli client.Client, namespace string, ref momov1alpha1.BucketReference) (momov1alpha1.BucketObject, error) {
+	if !ref.IsClusterBucket() {
+		return GetBucket(ctx, cli, client.ObjectKey{Namespace: namespace, Name: ref.Name})
+	}
+
+	bucket := &momov1alpha1.ClusterBucket{}
+
+	if err := cli.Get(ctx, client.ObjectKey{Name: ref.Name}, bucket); err != nil {
+		return nil, err
+	}
+
+	if ok, err := ClusterBucketAllows(ctx, cli, bucket, namespace); err != nil {
+		return nil, err
+	} else if !ok {
+		return nil, apierrors.NewForbidden(
+			momov1alpha1.GroupVersion.WithResource("clusterbuckets").GroupResource(),
+			bucket.Name,
+			fmt.Errorf("namespace %s is not allowed to reference it", namespace),
+		)
+	}
+
+	return bucket, nil
+}
+
+// ClusterBucketAllows reports whether APKs and IPAs in the given namespace may reference
+// the ClusterBucket, either by the namespace being listed in its .spec.namespaces or by
+// being selected by its .spec.namespaceSelector.
+func ClusterBucketAllows(ctx context.Context, cli client.Client, bucket *momov1alpha1.ClusterBucket, namespace string) (bool, error) {
+	if slices.Contains(bucket.Spec.Namespaces, namespace) {
+		return true, nil
+	}
+
+	if bucket.Spec.NamespaceSelector == nil {
+		return false, nil
+	}
+
+	selector, err := metav1.LabelSelectorAsSelector(bucket.Spec.NamespaceSelector)
+	if err != nil {
+		return false, err
+	}
+
+	if selector.Empty() {
+		return true, nil
+	}
+
+	ns := &corev1.Namespace{}
+
+	if err := cli.Get(ctx, client.ObjectKey{Name: namespace}, ns); err != nil {
+		return false, err
+	}
+
+	return selector.Matches(labels.Set(ns.Labels)), nil
+}
+
+// BucketKeyPrefix returns the prefix that the keys of objects belonging to the given
+// namespace have within the given Bucket or ClusterBucket. Only ClusterBuckets
+// have such a prefix, as a Bucket only belongs to the namespace that it is in.
+func BucketKeyPrefix(bucket momov1alpha1.BucketObject, namespace string) string {
+	if cb, ok := bucket.(*momov1alpha1.ClusterBucket); ok && namespace != "" {
+		return path.Join(cb.Spec.KeyPrefix, namespace) + "/"
+	}
+
+	return ""
+}
 
 func openBucket(ctx context.Context, urlstr string) (*blob.Bucket, error) {
 	u, err := url.Parse(urlstr)
@@ -46,95 +113,194 @@ func openBucket(ctx context.Context, urlstr string) (*blob.Bucket, error) {
 		return nil, err
 	}
 
-	if envs := u.Query()["env"]; len(envs) > 0 {
-		mu.Lock()
-		defer mu.Unlock()
-
-		for _, env := range envs {
-			parts := strings.Split(env, "=")
-
-			if len(parts) >= 2 {
-				prev, ok := os.LookupEnv(parts[0])
-				if ok {
-					defer func() {
-						_ = os.Setenv(parts[0], prev)
-					}()
-				} else {
-					defer func() {
-						_ = os.Unsetenv(parts[0])
-					}()
-				}
+	env := map[string]string{}
 
-				if err := os.Setenv(parts[0], strings.Join(parts[1:], "=")); err != nil {
-					return nil, fmt.Errorf("set env %s: %w", parts[0], err)
-				}
+	if envs := u.Query()["env"]; len(envs) > 0 {
+		for _, e := range envs {
+			if name, value, ok := strings.Cut(e, "="); ok {
+				env[name] = value
 			}
 		}
 
 		q := u.Query()
 		q.Del("env")
 		u.RawQuery = q.Encode()
+	}
 
-		return blob.OpenBucket(ctx, u.String())
+	// Only the default URLMux understands the prefix query parameter,
+	// so handle it here for the schemes that are opened some other way.
+	prefix := u.Query().Get("prefix")
+	if prefix != "" {
+		q := u.Query()
+		q.Del("prefix")
+		u.RawQuery = q.Encode()
 	}
 
-	return blob.OpenBucket(ctx, urlstr)
+	b, err := openBucketURL(ctx, u, env)
+	if err != nil {
+		return nil, err
+	}
+
+	if prefix != "" {
+		return blob.PrefixedBucket(b, prefix), nil
+	}
+
+	return b, nil
 }
 
-func OpenBucket(ctx context.Context, cli client.Client, bucket *momov1alpha1.Bucket) (*blob.Bucket, error) {
-	if bucket.Spec.URL != "" {
-		return openBucket(ctx, bucket.Spec.URL)
-	} else if bucket.Spec.URLFrom != nil {
-		if bucket.Spec.URLFrom.ConfigMapKeyRef != nil {
+// BucketURL resolves the URL of the given Bucket or ClusterBucket from its
+// .spec.url or the ConfigMap or Secret referenced by .spec.urlFrom.
+func BucketURL(ctx context.Context, cli client.Client, bucket momov1alpha1.BucketObject) (string, error) {
+	var (
+		spec      = bucket.GetBucketSpec()
+		namespace = bucketResourceNamespace(bucket)
+	)
+
+	if spec.URL != "" {
+		return withEnv(ctx, cli, namespace, spec, spec.URL)
+	} else if spec.URLFrom != nil {
+		if spec.URLFrom.ConfigMapKeyRef != nil {
+			if namespace == "" {
+				return "", errMissingResourceNamespace
+			}
+
 			configMap := &corev1.ConfigMap{}
 			if err := cli.Get(ctx,
 				client.ObjectKey{
-					Name:      bucket.Spec.URLFrom.ConfigMapKeyRef.Name,
-					Namespace: bucket.Namespace,
+					Name:      spec.URLFrom.ConfigMapKeyRef.Name,
+					Namespace: namespace,
 				},
 				configMap,
 			); err != nil {
-				return nil, fmt.Errorf("get ConfigMap: %w", err)
+				return "", fmt.Errorf("get ConfigMap: %w", err)
 			}
 
-			value, ok := configMap.Data[bucket.Spec.URLFrom.ConfigMapKeyRef.Key]
+			value, ok := configMap.Data[spec.URLFrom.ConfigMapKeyRef.Key]
 			if !ok {
-				return nil, fmt.Errorf("get key %s in ConfigMap %s", bucket.Spec.URLFrom.ConfigMapKeyRef.Key, bucket.Spec.URLFrom.ConfigMapKeyRef.Name)
+				return "", fmt.Errorf("get key %s in ConfigMap %s", spec.URLFrom.ConfigMapKeyRef.Key, spec.URLFrom.ConfigMapKeyRef.Name)
 			}
 
-			return openBucket(ctx, value)
+			return withEnv(ctx, cli, namespace, spec, value)
 		}
 
-		if bucket.Spec.URLFrom.SecretKeyRef != nil {
+		if spec.URLFrom.SecretKeyRef != nil {
+			if namespace == "" {
+				return "", errMissingResourceNamespace
+			}
+
 			secret := &corev1.Secret{}
 			if err := cli.Get(ctx,
 				client.ObjectKey{
-					Name:      bucket.Spec.URLFrom.SecretKeyRef.Name,
-					Namespace: bucket.Namespace,
+					Name:      spec.URLFrom.SecretKeyRef.Name,
+					Namespace: namespace,
 				},
 				secret,
 			); err != nil {
-				return nil, fmt.Errorf("get Secret: %w", err)
+				return "", fmt.Errorf("get Secret: %w", err)
 			}
 
-			value, ok := secret.Data[bucket.Spec.URLFrom.SecretKeyRef.Key]
+			value, ok := secret.Data[spec.URLFrom.SecretKeyRef.Key]
 			if !ok {
-				return nil, fmt.Errorf("get key %s in Secret %s", bucket.Spec.URLFrom.SecretKeyRef.Key, bucket.Spec.URLFrom.SecretKeyRef.Name)
+				return "", fmt.Errorf("get key %s in Secret %s", spec.URLFrom.SecretKeyRef.Key, spec.URLFrom.SecretKeyRef.Name)
 			}
 
-			return openBucket(ctx, string(value))
+			return withEnv(ctx, cli, namespace, spec, string(value))
+		}
+
+		if spec.URLFrom.FieldRef != nil {
+			return "", fmt.Errorf(".spec.urlFrom.fieldRef unsupported")
 		}
 
-		if bucket.Spec.URLFrom.FieldRef != nil {
-			return nil, fmt.Errorf(".spec.urlFrom.fieldRef unsupported")
+		if spec.URLFrom.ResourceFieldRef != nil {
+			return "", fmt.Errorf(".spec.urlFrom.resourceFieldRef unsupported")
 		}
+	}
+
+	return "", fmt.Errorf("missing url in .spec")
+}
+
+var (
+	errMissingResourceNamespace = fmt.Errorf(".spec.resourceNamespace is required to reference ConfigMaps and Secrets")
+)
+
+// bucketResourceNamespace returns the namespace that the ConfigMaps
+// and Secrets referenced by the Bucket or ClusterBucket are read from.
+func bucketResourceNamespace(bucket momov1alpha1.BucketObject) string {
+	if cb, ok := bucket.(*momov1alpha1.ClusterBucket); ok {
+		return cb.Spec.ResourceNamespace
+	}
+
+	return bucket.GetNamespace()
+}
+
+// withEnv adds the Bucket's .spec.env to urlstr as env query parameters so
+// that they are passed to the Bucket's driver when it is opened, the same as
+// if they were written into the URL itself.
+func withEnv(ctx context.Context, cli client.Client, namespace string, spec *momov1alpha1.BucketSpec, urlstr string) (string, error) {
+	if len(spec.Env) == 0 {
+		return urlstr, nil
+	}
+
+	u, err := url.Parse(urlstr)
+	if err != nil {
+		return "", err
+	}
+
+	q := u.Query()
+
+	for _, env := range spec.Env {
+		value := env.Value
+
+		if env.ValueFrom != nil {
+			if namespace == "" {
+				return "", errMissingResourceNamespace
+			}
 
-		if bucket.Spec.URLFrom.ResourceFieldRef != nil {
-			return nil, fmt.Errorf(".spec.urlFrom.resourceFieldRef unsupported")
+			switch {
+			case env.ValueFrom.ConfigMapKeyRef != nil:
+				configMap := &corev1.ConfigMap{}
+				if err := cli.Get(ctx,
+					client.ObjectKey{
+						Name:      env.ValueFrom.ConfigMapKeyRef.Name,
+						Namespace: namespace,
+					},
+					configMap,
+				); err != nil {
+					return "", fmt.Errorf("get ConfigMap: %w", err)
+				}
+
+				var ok bool
+				if value, ok = configMap.Data[env.ValueFrom.ConfigMapKeyRef.Key]; !ok {
+					return "", fmt.Errorf("get key %s in ConfigMap %s", env.ValueFrom.ConfigMapKeyRef.Key, env.ValueFrom.ConfigMapKeyRef.Name)
+				}
+			case env.ValueFrom.SecretKeyRef != nil:
+				secret := &corev1.Secret{}
+				if err := cli.Get(ctx,
+					client.ObjectKey{
+						Name:      env.ValueFrom.SecretKeyRef.Name,
+						Namespace: namespace,
+					},
+					secret,
+				); err != nil {
+					return "", fmt.Errorf("get Secret: %w", err)
+				}
+
+				data, ok := secret.Data[env.ValueFrom.SecretKeyRef.Key]
+				if !ok {
+					return "", fmt.Errorf("get key %s in Secret %s", env.ValueFrom.SecretKeyRef.Key, env.ValueFrom.SecretKeyRef.Name)
+				}
+				value = string(data)
+			default:
+				return "", fmt.Errorf(".spec.env[%s].valueFrom must be a configMapKeyRef or secretKeyRef", env.Name)
+			}
 		}
+
+		q.Add("env", fmt.Sprintf("%s=%s", env.Name, value))
 	}
 
-	return nil, fmt.Errorf("missing url in .spec")
+	u.RawQuery = q.Encode()
+
+	return u.String(), nil
 }
 
 func UploadImage(ctx context.Context, bucket *blob.Bucket, key string, img image.Image) error {
@@ -155,6 +321,19 @@ func UploadImage(ctx context.Context, bucket *blob.Bucket, key string, img image
 	return nil
 }
 
+// UploadFile writes the file at name to key in bucket.
+func UploadFile(ctx context.Context, bucket *BucketHandle, key, mediaType, name string) error {
+	f, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+
+	return writeObject(ctx, bucket, key, mediaType, f)
+}
+
 func NewHTTPStatusCodeError(err error, httpStatusCode int) error {
 	if err == nil {
 		return nil
@@ -202,29 +381,71 @@ func HTTPStatusCode(err error) int {
 	return http.StatusInternalServerError
 }
 
-func UploadApp(ctx context.Context, cli client.Client, namespace, name, bucketName, mediaType string, r io.Reader) error {
-	var (
-		bucket = &momov1alpha1.Bucket{}
-		ext    = momo.ExtIPA
-	)
+type uploadAppOpts struct {
+	dsyms            io.Reader
+	release          momov1alpha1.Release
+	clusterBucketCli client.Client
+}
+
+type UploadAppOpt func(*uploadAppOpts)
+
+// WithDSYMs uploads a zip of the debug symbols of an IPA alongside it.
+func WithDSYMs(r io.Reader) UploadAppOpt {
+	return func(o *uploadAppOpts) {
+		o.dsyms = r
+	}
+}
+
+// WithRelease records the release notes and build metadata of the app on it.
+func WithRelease(release momov1alpha1.Release) UploadAppOpt {
+	return func(o *uploadAppOpts) {
+		o.release = release
+	}
+}
+
+// WithClusterBucketClient gets and opens ClusterBuckets with cli instead of the client
+// that the app is uploaded with. Uploaders usually may not get ClusterBuckets, the
+// Namespaces that they select or the Secrets that they reference, so the server's own
+// client is used for them once the uploader is authorized to create the app.
+func WithClusterBucketClient(cli client.Client) UploadAppOpt {
+	return func(o *uploadAppOpts) {
+		o.clusterBucketCli = cli
+	}
+}
+
+func UploadApp(ctx context.Context, cli client.Client, buckets *BucketManager, namespace, name string, bucketRef momov1alpha1.BucketReference, mediaType string, r io.Reader, opts ...UploadAppOpt) (err error) {
+	ctx, span := Tracer.Start(ctx, "UploadApp", trace.WithAttributes(
+		attribute.String("momo.namespace", namespace),
+		attribute.String("momo.app", name),
+		attribute.String("momo.bucket", bucketRef.String()),
+		attribute.String("momo.media_type", mediaType),
+	))
+	defer func() {
+		EndSpan(span, err)
+	}()
+
+	o := &uploadAppOpts{}
+
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	ext := momo.ExtIPA
 
 	switch mediaType {
 	case android.ContentTypeAPK:
 		ext = momo.ExtAPK
+	case android.ContentTypeAAB:
+		ext = momo.ExtAAB
+	case android.ContentTypeAPKS:
+		ext = momo.ExtAPKS
+	case android.ContentTypeXAPK:
+		ext = momo.ExtXAPK
 	case ios.ContentTypeIPA:
 	default:
 		return NewHTTPStatusCodeError(fmt.Errorf("unsupported Content-Type %s", mediaType), http.StatusUnsupportedMediaType)
 	}
 
-	if err := cli.Get(ctx, client.ObjectKey{Name: bucketName, Namespace: namespace}, bucket); err != nil {
-		return err
-	}
-
-	b, err := OpenBucket(ctx, cli, bucket)
-	if err != nil {
-		return err
-	}
-
 	var (
 		artifactName = fmt.Sprintf("%s-%s", name, uuid.NewString()[:5])
 		key          = fmt.Sprintf("%s%s", artifactName, ext)
@@ -240,63 +461,127 @@ func UploadApp(ctx context.Context, cli client.Client, namespace, name, bucketNa
 				Selector: selector,
 			},
 		}
+		app client.Object
 	)
+
 	switch mediaType {
-	case android.ContentTypeAPK:
-		if err = cli.Create(ctx,
-			&momov1alpha1.APK{
-				ObjectMeta: metav1.ObjectMeta{
-					Namespace: namespace,
-					Name:      artifactName,
-					Labels:    selector,
-				},
-				Spec: momov1alpha1.APKSpec{
-					Bucket: corev1.LocalObjectReference{
-						Name: bucketName,
-					},
-					Key: key,
-				},
+	case android.ContentTypeAPK, android.ContentTypeAAB, android.ContentTypeAPKS, android.ContentTypeXAPK:
+		app = &momov1alpha1.APK{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: namespace,
+				Name:      artifactName,
+				Labels:    selector,
+			},
+			Spec: momov1alpha1.APKSpec{
+				Bucket:  bucketRef,
+				Key:     key,
+				Release: o.release,
 			},
-		); err != nil {
-			return err
 		}
 	case ios.ContentTypeIPA:
-		if err = cli.Create(ctx,
-			&momov1alpha1.IPA{
-				ObjectMeta: metav1.ObjectMeta{
-					Namespace: namespace,
-					Name:      artifactName,
-					Labels:    selector,
-				},
-				Spec: momov1alpha1.IPASpec{
-					Bucket: corev1.LocalObjectReference{
-						Name: bucketName,
-					},
-					Key: key,
-				},
+		app = &momov1alpha1.IPA{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: namespace,
+				Name:      artifactName,
+				Labels:    selector,
 			},
-		); err != nil {
-			return err
+			Spec: momov1alpha1.IPASpec{
+				Bucket:  bucketRef,
+				Key:     key,
+				Release: o.release,
+			},
+		}
+
+		if o.dsyms != nil {
+			app.(*momov1alpha1.IPA).Spec.DSYMsKey = fmt.Sprintf("%s.%s", artifactName, momo.FileDSYMs)
 		}
 	}
-	wc, err := b.NewWriter(ctx, key, &blob.WriterOptions{ContentType: mediaType})
+
+	// Creating the app in a dry run authorizes the uploader in its own namespace
+	// before anything is written to the Bucket or ClusterBucket.
+	if err = cli.Create(ctx, app.DeepCopyObject().(client.Object), client.DryRunAll); err != nil {
+		return err
+	}
+
+	bucketCli := cli
+	if bucketRef.IsClusterBucket() && o.clusterBucketCli != nil {
+		bucketCli = o.clusterBucketCli
+	}
+
+	bucket, err := GetBucketReference(ctx, bucketCli, namespace, bucketRef)
+	if err != nil {
+		return err
+	}
+
+	_, openSpan := Tracer.Start(ctx, "OpenBucket")
+	b, err := buckets.OpenBucketFor(ctx, bucketCli, bucket, namespace)
+	EndSpan(openSpan, err)
 	if err != nil {
 		return err
 	}
 	defer func() {
-		_ = wc.Close()
+		_ = b.Close()
 	}()
 
-	if _, err = io.Copy(wc, r); err != nil {
+	span.SetAttributes(attribute.String("momo.artifact", artifactName))
+
+	createCtx, createSpan := Tracer.Start(ctx, "Create")
+	// The reconcile that unpacks the app continues this trace.
+	InjectTraceContext(createCtx, app)
+	err = cli.Create(createCtx, app)
+	EndSpan(createSpan, err)
+	if err != nil {
 		return err
 	}
 
-	if _, err = controllerutil.CreateOrUpdate(ctx, cli, mobileApp, func() error {
+	if err = writeObject(ctx, b, key, mediaType, r); err != nil {
+		return err
+	}
+
+	if ipa, ok := app.(*momov1alpha1.IPA); ok && ipa.Spec.DSYMsKey != "" {
+		if err = writeObject(ctx, b, ipa.Spec.DSYMsKey, "application/zip", o.dsyms); err != nil {
+			return err
+		}
+	}
+
+	_, mobileAppSpan := Tracer.Start(ctx, "CreateOrUpdateMobileApp")
+	_, err = controllerutil.CreateOrUpdate(ctx, cli, mobileApp, func() error {
 		mobileApp.Spec.Selector = selector
 		return nil
-	}); err != nil {
+	})
+	EndSpan(mobileAppSpan, err)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func writeObject(ctx context.Context, b *BucketHandle, key, mediaType string, r io.Reader) (err error) {
+	ctx, span := Tracer.Start(ctx, "WriteObject", trace.WithAttributes(
+		attribute.String("momo.key", key),
+	))
+	defer func() {
+		EndSpan(span, err)
+	}()
+
+	wc, err := b.NewWriter(ctx, key, &blob.WriterOptions{ContentType: mediaType})
+	if err != nil {
+		return ObserveBucketError(b.Driver, "write", err)
+	}
+	defer func() {
+		_ = wc.Close()
+	}()
+
+	n, err := io.Copy(wc, r)
+	span.SetAttributes(attribute.Int64("momo.bytes", n))
+	if err != nil {
 		return err
 	}
 
+	if err = wc.Close(); err != nil {
+		return ObserveBucketError(b.Driver, "write", err)
+	}
+
 	return nil
 }
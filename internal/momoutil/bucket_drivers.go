@@ -0,0 +1,285 @@
+package momoutil
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/container"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	gcaws "gocloud.dev/aws"
+	"gocloud.dev/blob"
+	"gocloud.dev/blob/azureblob"
+	"gocloud.dev/blob/gcsblob"
+	"gocloud.dev/blob/s3blob"
+	"gocloud.dev/gcp"
+	"golang.org/x/oauth2/google"
+	"google.golang.org/api/option"
+)
+
+const (
+	// EnvGoogleCredentials is the environment variable that the
+	// contents of a Google Cloud service account key can be passed
+	// through to open gs:// URLs, as opposed to a path to such a key
+	// in GOOGLE_APPLICATION_CREDENTIALS.
+	EnvGoogleCredentials = "GOOGLE_CREDENTIALS"
+)
+
+// bucketEnv is the environment variables that can be passed to
+// the driver of each scheme through a Bucket's env.
+var bucketEnv = map[string][]string{
+	gcsblob.Scheme: {
+		"STORAGE_EMULATOR_HOST",
+		EnvGoogleCredentials,
+	},
+	azureblob.Scheme: {
+		"AZURE_STORAGE_ACCOUNT",
+		"AZURE_STORAGE_KEY",
+		"AZURE_STORAGE_SAS_TOKEN",
+		"AZURE_STORAGE_CONNECTION_STRING",
+		"AZURE_STORAGE_DOMAIN",
+		"AZURE_STORAGE_PROTOCOL",
+		"AZURE_STORAGE_IS_CDN",
+		"AZURE_STORAGE_IS_LOCAL_EMULATOR",
+	},
+	s3blob.Scheme: {
+		"AWS_ACCESS_KEY_ID",
+		"AWS_SECRET_ACCESS_KEY",
+		"AWS_SESSION_TOKEN",
+		"AWS_REGION",
+		"AWS_DEFAULT_REGION",
+	},
+}
+
+// openBucketURL opens u with the credentials in env, which holds the Bucket's
+// .spec.env and the env query parameters of its URL. They are passed to the
+// driver explicitly rather than set in the process' environment, where
+// subprocesses and concurrent opens of other Buckets would see them.
+// Variables that env does not set are read from the process' environment.
+//
+// The default openers for gs:// and azblob:// URLs are not used either way,
+// as they only read credentials once per process, which would make every
+// Bucket share the credentials of whichever one happened to be opened first.
+func openBucketURL(ctx context.Context, u *url.URL, env map[string]string) (*blob.Bucket, error) {
+	for name := range env {
+		if !slices.Contains(bucketEnv[u.Scheme], name) {
+			return nil, fmt.Errorf("env %s is not supported for %s:// Buckets", name, u.Scheme)
+		}
+	}
+
+	getenv := func(name string) string {
+		if value, ok := env[name]; ok {
+			return value
+		}
+
+		return os.Getenv(name)
+	}
+
+	switch u.Scheme {
+	case gcsblob.Scheme:
+		return openGCSBucketURL(ctx, u, getenv)
+	case azureblob.Scheme:
+		return openAzureBlobBucketURL(ctx, u, getenv)
+	case s3blob.Scheme:
+		return openS3BucketURL(ctx, u, env)
+	}
+
+	return blob.OpenBucket(ctx, u.String())
+}
+
+// openGCSBucketURL opens u with a client for STORAGE_EMULATOR_HOST if it is set,
+// e.g. to fake-gcs-server, or one authenticated with the service account key in
+// GOOGLE_CREDENTIALS if it is set, or else one authenticated with Application
+// Default Credentials, which covers GKE Workload Identity.
+func openGCSBucketURL(ctx context.Context, u *url.URL, getenv func(string) string) (*blob.Bucket, error) {
+	opener := &gcsblob.URLOpener{}
+
+	if host := getenv("STORAGE_EMULATOR_HOST"); host != "" {
+		opener.Client = gcp.NewAnonymousHTTPClient(gcp.DefaultTransport())
+		opener.Options.ClientOptions = []option.ClientOption{
+			option.WithoutAuthentication(),
+			option.WithEndpoint("http://" + host + "/storage/v1/"),
+			option.WithHTTPClient(http.DefaultClient),
+		}
+
+		return opener.OpenBucketURL(ctx, u)
+	}
+
+	var (
+		creds *google.Credentials
+		err   error
+	)
+
+	if credsJSON := getenv(EnvGoogleCredentials); credsJSON != "" {
+		creds, err = google.CredentialsFromJSON(ctx, []byte(credsJSON), "https://www.googleapis.com/auth/cloud-platform")
+	} else {
+		creds, err = gcp.DefaultCredentials(ctx)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if opener.Client, err = gcp.NewHTTPClient(gcp.DefaultTransport(), gcp.CredentialsTokenSource(creds)); err != nil {
+		return nil, err
+	}
+
+	return opener.OpenBucketURL(ctx, u)
+}
+
+// openAzureBlobBucketURL opens u with a shared key credential if AZURE_STORAGE_ACCOUNT
+// and AZURE_STORAGE_KEY are set, AZURE_STORAGE_SAS_TOKEN if it is set or
+// AZURE_STORAGE_CONNECTION_STRING if it is set, or else the default Azure
+// credential, which covers AKS Workload Identity.
+func openAzureBlobBucketURL(ctx context.Context, u *url.URL, getenv func(string) string) (*blob.Bucket, error) {
+	var (
+		isCDN, _           = strconv.ParseBool(getenv("AZURE_STORAGE_IS_CDN"))
+		isLocalEmulator, _ = strconv.ParseBool(getenv("AZURE_STORAGE_IS_LOCAL_EMULATOR"))
+		accountName        = getenv("AZURE_STORAGE_ACCOUNT")
+		accountKey         = getenv("AZURE_STORAGE_KEY")
+		sasToken           = getenv("AZURE_STORAGE_SAS_TOKEN")
+		protocol           = getenv("AZURE_STORAGE_PROTOCOL")
+		connectionString   = getenv("AZURE_STORAGE_CONNECTION_STRING")
+	)
+
+	// Default the account name and protocol from the connection string, e.g.
+	// DefaultEndpointsProtocol=https;AccountName=momo;AccountKey=...;EndpointSuffix=core.windows.net
+	for part := range strings.SplitSeq(connectionString, ";") {
+		key, value, _ := strings.Cut(part, "=")
+		switch {
+		case key == "AccountName" && accountName == "":
+			accountName = value
+		case key == "DefaultEndpointsProtocol" && protocol == "":
+			protocol = value
+		}
+	}
+
+	opener := &azureblob.URLOpener{
+		MakeClient: func(svcURL azureblob.ServiceURL, containerName azureblob.ContainerName) (*container.Client, error) {
+			containerURL, err := url.JoinPath(string(svcURL), string(containerName))
+			if err != nil {
+				return nil, err
+			}
+
+			switch {
+			case accountName != "" && accountKey != "":
+				cred, err := azblob.NewSharedKeyCredential(accountName, accountKey)
+				if err != nil {
+					return nil, err
+				}
+
+				return container.NewClientWithSharedKeyCredential(containerURL, cred, nil)
+			case sasToken != "":
+				return container.NewClientWithNoCredential(containerURL, nil)
+			case connectionString != "":
+				return container.NewClientFromConnectionString(connectionString, string(containerName), nil)
+			}
+
+			cred, err := azidentity.NewDefaultAzureCredential(nil)
+			if err != nil {
+				return nil, err
+			}
+
+			return container.NewClient(containerURL, cred, nil)
+		},
+		ServiceURLOptions: azureblob.ServiceURLOptions{
+			AccountName:     accountName,
+			SASToken:        sasToken,
+			StorageDomain:   getenv("AZURE_STORAGE_DOMAIN"),
+			Protocol:        protocol,
+			IsCDN:           isCDN,
+			IsLocalEmulator: isLocalEmulator,
+		},
+	}
+
+	return opener.OpenBucketURL(ctx, u)
+}
+
+// openS3BucketURL opens u the same as s3blob.URLOpener, but with the static
+// credentials and region in env, if any, in place of those that the AWS SDK
+// reads from the process' environment.
+func openS3BucketURL(ctx context.Context, u *url.URL, env map[string]string) (*blob.Bucket, error) {
+	var (
+		q      = u.Query()
+		opts   = &s3blob.Options{}
+		s3opts = []func(*s3.Options){}
+	)
+
+	// These are the query parameters that s3blob.URLOpener handles itself
+	// rather than passing on to gcaws.V2ConfigFromURLParams.
+	if sseType := q.Get("ssetype"); sseType != "" {
+		q.Del("ssetype")
+
+		i := slices.IndexFunc(types.ServerSideEncryptionAes256.Values(), func(value types.ServerSideEncryption) bool {
+			return strings.EqualFold(string(value), sseType)
+		})
+		if i < 0 {
+			return nil, fmt.Errorf("%q is not a valid value for %q", sseType, "ssetype")
+		}
+
+		opts.EncryptionType = types.ServerSideEncryptionAes256.Values()[i]
+	}
+
+	if kmsKeyID := q.Get("kmskeyid"); kmsKeyID != "" {
+		q.Del("kmskeyid")
+		opts.KMSEncryptionID = kmsKeyID
+	}
+
+	for _, key := range []string{"accelerate", "disable_https", "use_path_style", "s3ForcePathStyle"} {
+		param := q.Get(key)
+		if param == "" {
+			continue
+		}
+		q.Del(key)
+
+		value, err := strconv.ParseBool(param)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value for %q: %w", key, err)
+		}
+
+		s3opts = append(s3opts, func(o *s3.Options) {
+			switch key {
+			case "accelerate":
+				o.UseAccelerate = value
+			case "disable_https":
+				o.EndpointOptions.DisableHTTPS = value
+			default:
+				o.UsePathStyle = value
+			}
+		})
+	}
+
+	cfg, err := gcaws.V2ConfigFromURLParams(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+
+	if !q.Has("region") {
+		if region := env["AWS_REGION"]; region != "" {
+			cfg.Region = region
+		} else if region := env["AWS_DEFAULT_REGION"]; region != "" {
+			cfg.Region = region
+		}
+	}
+
+	if accessKeyID := env["AWS_ACCESS_KEY_ID"]; accessKeyID != "" {
+		cfg.Credentials = aws.NewCredentialsCache(
+			credentials.NewStaticCredentialsProvider(accessKeyID, env["AWS_SECRET_ACCESS_KEY"], env["AWS_SESSION_TOKEN"]),
+		)
+	}
+
+	// The S3 upload manager does not use the config or options
+	// to set the request checksum calculation, so set it explicitly.
+	opts.RequestChecksumCalculation = cfg.RequestChecksumCalculation
+
+	return s3blob.OpenBucket(ctx, s3.NewFromConfig(cfg, s3opts...), u.Host, opts)
+}
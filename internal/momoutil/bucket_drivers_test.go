@@ -0,0 +1,191 @@
+package momoutil_test
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"testing"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/bloberror"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/container"
+	momov1alpha1 "github.com/frantjc/momo/api/v1alpha1"
+	"github.com/frantjc/momo/internal/momoutil"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+const (
+	// azuriteAccountKey is the well-known key of Azurite's default storage account.
+	azuriteAccountKey = "Eby8vdM02xNOcqFlqUwJPLlmEtlCDXJ1OUzFT50uSRZ6IFsuFq2UVErCz4I6tq/K1SZFPTOtr/KBHBeksoGMGw=="
+)
+
+func testBucketRoundTrip(t *testing.T, bucket *momov1alpha1.Bucket, secret *corev1.Secret, setup func(*momoutil.BucketHandle) error) {
+	scheme, err := momoutil.NewScheme(corev1.AddToScheme, momov1alpha1.AddToScheme)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var (
+		ctx     = context.Background()
+		cli     = fake.NewClientBuilder().WithScheme(scheme).WithObjects(secret, bucket).Build()
+		buckets = &momoutil.BucketManager{}
+		key     = "momo/test"
+		data    = []byte(t.Name())
+	)
+	defer func() {
+		_ = buckets.Close()
+	}()
+
+	b, err := buckets.OpenBucket(ctx, cli, bucket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = b.Close()
+	}()
+
+	if setup != nil {
+		if err = setup(b); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err = b.WriteAll(ctx, key, data, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	read, err := b.ReadAll(ctx, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(read, data) {
+		t.Fatalf("read %q, expected %q", read, data)
+	}
+
+	if err = b.Delete(ctx, key); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestGCSBucket(t *testing.T) {
+	host := os.Getenv("FAKE_GCS_SERVER_HOST")
+	if host == "" {
+		t.Skip("FAKE_GCS_SERVER_HOST is not set")
+	}
+
+	var (
+		secret = &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "gcs"},
+			Data:       map[string][]byte{"STORAGE_EMULATOR_HOST": []byte(host)},
+		}
+		bucket = &momov1alpha1.Bucket{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "gcs"},
+			Spec: momov1alpha1.BucketSpec{
+				URL: "gs://momo",
+				Env: []corev1.EnvVar{
+					{
+						Name: "STORAGE_EMULATOR_HOST",
+						ValueFrom: &corev1.EnvVarSource{
+							SecretKeyRef: &corev1.SecretKeySelector{
+								LocalObjectReference: corev1.LocalObjectReference{Name: secret.Name},
+								Key:                  "STORAGE_EMULATOR_HOST",
+							},
+						},
+					},
+				},
+			},
+		}
+	)
+
+	res, err := http.Post(fmt.Sprintf("http://%s/storage/v1/b?project=momo", host), "application/json", bytes.NewReader([]byte(`{"name":"momo"}`)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = res.Body.Close()
+
+	testBucketRoundTrip(t, bucket, secret, nil)
+}
+
+func TestAzureBlobBucket(t *testing.T) {
+	host := os.Getenv("AZURITE_BLOB_HOST")
+	if host == "" {
+		t.Skip("AZURITE_BLOB_HOST is not set")
+	}
+
+	var (
+		secret = &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "azurite"},
+			Data:       map[string][]byte{"AZURE_STORAGE_KEY": []byte(azuriteAccountKey)},
+		}
+		bucket = &momov1alpha1.Bucket{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "azblob"},
+			Spec: momov1alpha1.BucketSpec{
+				URL: fmt.Sprintf("azblob://momo?protocol=http&domain=%s&localemu=true", host),
+				Env: []corev1.EnvVar{
+					{
+						Name:  "AZURE_STORAGE_ACCOUNT",
+						Value: "devstoreaccount1",
+					},
+					{
+						Name: "AZURE_STORAGE_KEY",
+						ValueFrom: &corev1.EnvVarSource{
+							SecretKeyRef: &corev1.SecretKeySelector{
+								LocalObjectReference: corev1.LocalObjectReference{Name: secret.Name},
+								Key:                  "AZURE_STORAGE_KEY",
+							},
+						},
+					},
+				},
+			},
+		}
+	)
+
+	testBucketRoundTrip(t, bucket, secret, func(b *momoutil.BucketHandle) error {
+		var cli *container.Client
+		if !b.As(&cli) {
+			return fmt.Errorf("bucket is not an Azure Blob container")
+		}
+
+		if _, err := cli.Create(context.Background(), nil); err != nil && !bloberror.HasCode(err, bloberror.ContainerAlreadyExists) {
+			return err
+		}
+
+		return nil
+	})
+}
+
+func TestBucketUnsupportedEnv(t *testing.T) {
+	scheme, err := momoutil.NewScheme(corev1.AddToScheme, momov1alpha1.AddToScheme)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var (
+		ctx    = context.Background()
+		bucket = &momov1alpha1.Bucket{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "mem"},
+			Spec: momov1alpha1.BucketSpec{
+				URL: "mem://env",
+				Env: []corev1.EnvVar{
+					{
+						Name:  "AWS_SECRET_ACCESS_KEY",
+						Value: t.Name(),
+					},
+				},
+			},
+		}
+		cli     = fake.NewClientBuilder().WithScheme(scheme).WithObjects(bucket).Build()
+		buckets = &momoutil.BucketManager{}
+	)
+	defer func() {
+		_ = buckets.Close()
+	}()
+
+	if _, err = buckets.OpenBucket(ctx, cli, bucket); err == nil {
+		t.Fatal("expected env that mem:// Buckets do not read to be rejected")
+	}
+}
@@ -0,0 +1,320 @@
+package momoutil
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"path"
+	"sync"
+	"time"
+
+	momov1alpha1 "github.com/frantjc/momo/api/v1alpha1"
+	"github.com/opencontainers/go-digest"
+	"gocloud.dev/blob"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// DefaultBucketResolveTTL is how long a BucketManager trusts the URL
+	// that it resolved for a Bucket before resolving it again.
+	DefaultBucketResolveTTL = time.Minute * 5
+)
+
+// BucketManager caches opened *blob.Buckets keyed by their resolved URL,
+// which includes any credentials passed through it, so that each distinct
+// backend is opened once per process rather than once per use.
+//
+// Handles are reference-counted. A *blob.Bucket that is evicted while in
+// use is closed once its last BucketHandle is closed.
+//
+// The zero value is ready to use.
+type BucketManager struct {
+	// ResolveTTL is how long the URL resolved for a Bucket is trusted before
+	// the ConfigMap or Secret that it references is read again. It bounds how
+	// stale a BucketManager can be in processes that do not call Invalidate.
+	ResolveTTL time.Duration
+
+	mu      sync.Mutex
+	buckets map[digest.Digest]*sharedBucket
+	objects map[resolvedKey]*resolvedBucket
+}
+
+// resolvedKey identifies a Bucket or ClusterBucket, the latter of which
+// is opened once for each prefix that namespaces' objects are kept under.
+type resolvedKey struct {
+	client.ObjectKey
+	prefix string
+}
+
+type sharedBucket struct {
+	bucket  *blob.Bucket
+	url     digest.Digest
+	driver  string
+	refs    int
+	evicted bool
+}
+
+type resolvedBucket struct {
+	url        digest.Digest
+	generation int64
+	resolvedAt time.Time
+	stale      bool
+}
+
+// BucketHandle is a reference to a *blob.Bucket opened by a BucketManager.
+// Its Close releases the reference instead of closing the *blob.Bucket.
+type BucketHandle struct {
+	*blob.Bucket
+	// Driver is the scheme of the URL that the *blob.Bucket was opened from.
+	Driver string
+
+	url     digest.Digest
+	once    sync.Once
+	release func() error
+	err     error
+}
+
+// Close releases the reference to the underlying *blob.Bucket,
+// closing it if it has been evicted and this was the last reference.
+func (h *BucketHandle) Close() error {
+	h.once.Do(func() {
+		h.err = h.release()
+	})
+	return h.err
+}
+
+func (m *BucketManager) init() {
+	if m.buckets == nil {
+		m.buckets = map[digest.Digest]*sharedBucket{}
+	}
+	if m.objects == nil {
+		m.objects = map[resolvedKey]*resolvedBucket{}
+	}
+	if m.ResolveTTL <= 0 {
+		m.ResolveTTL = DefaultBucketResolveTTL
+	}
+}
+
+// OpenBucket returns a handle to the *blob.Bucket for the given Bucket or ClusterBucket,
+// opening it if no Bucket resolving to the same URL has been opened yet.
+// The caller must close the returned BucketHandle.
+func (m *BucketManager) OpenBucket(ctx context.Context, cli client.Client, bucket momov1alpha1.BucketObject) (*BucketHandle, error) {
+	return m.OpenBucketFor(ctx, cli, bucket, "")
+}
+
+// OpenBucketFor is like OpenBucket, but the returned handle is scoped to the
+// objects belonging to the given namespace. This only makes a difference for
+// ClusterBuckets, which keep each namespace's objects under its own prefix.
+//
+// Whether or not the *blob.Bucket is cached, cli must be able to get the Bucket
+// or ClusterBucket, so that a *blob.Bucket opened for one caller is not handed to
+// another that cannot see it. The ConfigMaps and Secrets that its URL is resolved
+// from are only read with cli when it is resolved again, so that using a cached
+// *blob.Bucket does not read them on every call.
+func (m *BucketManager) OpenBucketFor(ctx context.Context, cli client.Client, bucket momov1alpha1.BucketObject, namespace string) (*BucketHandle, error) {
+	var (
+		key = resolvedKey{
+			ObjectKey: client.ObjectKeyFromObject(bucket),
+			prefix:    BucketKeyPrefix(bucket, namespace),
+		}
+		now = time.Now()
+	)
+
+	if err := cli.Get(ctx, key.ObjectKey, bucket.DeepCopyObject().(client.Object)); err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	m.init()
+
+	if res, ok := m.objects[key]; ok && !res.stale && res.generation == bucket.GetGeneration() && now.Sub(res.resolvedAt) < m.ResolveTTL {
+		// The *blob.Bucket is acquired under the same lock that it was looked up
+		// under, as evict closes those that are no longer in m.buckets.
+		if sb, ok := m.buckets[res.url]; ok {
+			defer m.mu.Unlock()
+			return m.acquire(sb), nil
+		}
+	}
+	m.mu.Unlock()
+
+	urlstr, err := BucketURL(ctx, cli, bucket)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, ok := bucket.(*momov1alpha1.ClusterBucket); ok {
+		if urlstr, err = withPrefix(urlstr, key.prefix); err != nil {
+			return nil, err
+		}
+	}
+
+	dgst := digest.FromString(urlstr)
+
+	// Hold the lock from looking up the *blob.Bucket until it is acquired,
+	// except while opening it, so that it cannot be evicted and closed in between.
+	m.mu.Lock()
+	sb, ok := m.buckets[dgst]
+	if !ok {
+		m.mu.Unlock()
+
+		b, err := openBucket(ctx, urlstr)
+		if err != nil {
+			return nil, err
+		}
+
+		m.mu.Lock()
+		if sb, ok = m.buckets[dgst]; ok {
+			// Lost a race with another open of the same URL,
+			// so throw away this one in favor of that one.
+			defer func() {
+				_ = b.Close()
+			}()
+		} else {
+			sb = &sharedBucket{bucket: b, url: dgst, driver: driverOf(urlstr)}
+			m.buckets[dgst] = sb
+		}
+	}
+	defer m.mu.Unlock()
+
+	prev, hadPrev := m.objects[key]
+	m.objects[key] = &resolvedBucket{
+		url:        dgst,
+		generation: bucket.GetGeneration(),
+		resolvedAt: now,
+	}
+
+	if hadPrev && prev.url != dgst {
+		m.evict(prev.url)
+	}
+
+	return m.acquire(sb), nil
+}
+
+// Invalidate makes the next OpenBucket for the Bucket or ClusterBucket with
+// the given key resolve its URL again, such as after the ConfigMap or Secret
+// that it references changes. If the URL changed, the *blob.Bucket that was
+// opened for the previous URL is evicted.
+func (m *BucketManager) Invalidate(key client.ObjectKey) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.init()
+
+	for k, res := range m.objects {
+		if k.ObjectKey == key {
+			res.stale = true
+		}
+	}
+}
+
+// Evict forgets the Bucket or ClusterBucket with the given key, such as after
+// it is deleted, evicting the *blob.Bucket that was opened for it if no other
+// Bucket resolves to the same URL.
+func (m *BucketManager) Evict(key client.ObjectKey) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.init()
+
+	for k, res := range m.objects {
+		if k.ObjectKey == key {
+			delete(m.objects, k)
+			m.evict(res.url)
+		}
+	}
+}
+
+// Start blocks until the context is done and then closes the BucketManager.
+// It allows a BucketManager to be added to a controller-runtime Manager.
+func (m *BucketManager) Start(ctx context.Context) error {
+	<-ctx.Done()
+	return m.Close()
+}
+
+// Close evicts every *blob.Bucket opened by the BucketManager. Those
+// that are still in use are closed once their last reference is released.
+func (m *BucketManager) Close() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var errs []error
+
+	for dgst, sb := range m.buckets {
+		delete(m.buckets, dgst)
+		sb.evicted = true
+
+		if sb.refs == 0 {
+			errs = append(errs, sb.bucket.Close())
+		}
+	}
+
+	clear(m.objects)
+
+	return errors.Join(errs...)
+}
+
+// driverOf returns the scheme of urlstr.
+func driverOf(urlstr string) string {
+	u, err := url.Parse(urlstr)
+	if err != nil {
+		return ""
+	}
+
+	return u.Scheme
+}
+
+// withPrefix nests prefix under the prefix query parameter of urlstr, if any,
+// treating the latter as a directory whether or not it ends in a slash so that
+// a ClusterBucket's namespaces' objects are under it the same as its own.
+func withPrefix(urlstr, prefix string) (string, error) {
+	u, err := url.Parse(urlstr)
+	if err != nil {
+		return "", err
+	}
+
+	q := u.Query()
+	if joined := path.Join(q.Get("prefix"), prefix); joined != "" {
+		q.Set("prefix", joined+"/")
+	}
+	u.RawQuery = q.Encode()
+
+	return u.String(), nil
+}
+
+// acquire must be called while holding m.mu.
+func (m *BucketManager) acquire(sb *sharedBucket) *BucketHandle {
+	sb.refs++
+
+	return &BucketHandle{
+		Bucket: sb.bucket,
+		Driver: sb.driver,
+		url:    sb.url,
+		release: func() error {
+			m.mu.Lock()
+			defer m.mu.Unlock()
+
+			sb.refs--
+			if sb.evicted && sb.refs == 0 {
+				return sb.bucket.Close()
+			}
+
+			return nil
+		},
+	}
+}
+
+// evict must be called while holding m.mu.
+func (m *BucketManager) evict(dgst digest.Digest) {
+	for _, res := range m.objects {
+		if res.url == dgst {
+			return
+		}
+	}
+
+	if sb, ok := m.buckets[dgst]; ok {
+		delete(m.buckets, dgst)
+		sb.evicted = true
+
+		if sb.refs == 0 {
+			_ = sb.bucket.Close()
+		}
+	}
+}
@@ -0,0 +1,138 @@
+package momoutil_test
+
+import (
+	"context"
+	"testing"
+
+	momov1alpha1 "github.com/frantjc/momo/api/v1alpha1"
+	"github.com/frantjc/momo/internal/momoutil"
+	_ "gocloud.dev/blob/memblob"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+)
+
+func TestBucketManager(t *testing.T) {
+	scheme, err := momoutil.NewScheme(corev1.AddToScheme, momov1alpha1.AddToScheme)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var (
+		ctx    = context.Background()
+		secret = &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "bucket"},
+			Data:       map[string][]byte{"url": []byte("mem://a")},
+		}
+		fromSecret = &momov1alpha1.Bucket{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "from-secret"},
+			Spec: momov1alpha1.BucketSpec{
+				URLFrom: &corev1.EnvVarSource{
+					SecretKeyRef: &corev1.SecretKeySelector{
+						LocalObjectReference: corev1.LocalObjectReference{Name: secret.Name},
+						Key:                  "url",
+					},
+				},
+			},
+		}
+		fromURL = &momov1alpha1.Bucket{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "from-url"},
+			Spec:       momov1alpha1.BucketSpec{URL: "mem://a"},
+		}
+		cli     = fake.NewClientBuilder().WithScheme(scheme).WithObjects(secret, fromSecret, fromURL).Build()
+		buckets = &momoutil.BucketManager{}
+	)
+	defer func() {
+		_ = buckets.Close()
+	}()
+
+	a, err := buckets.OpenBucket(ctx, cli, fromSecret)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := buckets.OpenBucket(ctx, cli, fromURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if a.Bucket != b.Bucket {
+		t.Fatal("expected Buckets with the same URL to share a *blob.Bucket")
+	}
+
+	// A caller that cannot read the Bucket is not handed
+	// the *blob.Bucket that was opened for another caller.
+	forbidden := interceptor.NewClient(cli, interceptor.Funcs{
+		Get: func(ctx context.Context, cli client.WithWatch, key client.ObjectKey, obj client.Object, opts ...client.GetOption) error {
+			if _, ok := obj.(*momov1alpha1.Bucket); ok {
+				return apierrors.NewForbidden(momov1alpha1.GroupVersion.WithResource("buckets").GroupResource(), key.Name, nil)
+			}
+
+			return cli.Get(ctx, key, obj, opts...)
+		},
+	})
+
+	if _, err = buckets.OpenBucket(ctx, forbidden, fromSecret); !apierrors.IsForbidden(err) {
+		t.Fatalf("expected a caller that cannot get the Bucket to be forbidden, got %v", err)
+	}
+
+	if err = b.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = a.WriteAll(ctx, "key", []byte("value"), nil); err != nil {
+		t.Fatal("expected *blob.Bucket to stay open while referenced", err)
+	}
+
+	secret.Data["url"] = []byte("mem://b")
+	if err = cli.Update(ctx, secret); err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := buckets.OpenBucket(ctx, cli, fromSecret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = c.Close()
+	}()
+
+	if c.Bucket != a.Bucket {
+		t.Fatal("expected resolved URL to be cached until invalidated")
+	}
+
+	if err = c.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	buckets.Invalidate(client.ObjectKeyFromObject(fromSecret))
+
+	d, err := buckets.OpenBucket(ctx, cli, fromSecret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = d.Close()
+	}()
+
+	if d.Bucket == a.Bucket {
+		t.Fatal("expected invalidated Bucket to be opened with its new URL")
+	}
+
+	buckets.Evict(client.ObjectKeyFromObject(fromURL))
+
+	if err = a.WriteAll(ctx, "key", []byte("value"), nil); err != nil {
+		t.Fatal("expected evicted *blob.Bucket to stay open while referenced", err)
+	}
+
+	if err = a.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = a.WriteAll(ctx, "key", []byte("value"), nil); err == nil {
+		t.Fatal("expected evicted *blob.Bucket to be closed after its last reference was released")
+	}
+}
@@ -0,0 +1,179 @@
+package momoutil
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"time"
+
+	momov1alpha1 "github.com/frantjc/momo/api/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/util/retry"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// MaxPromotions is the number of the most recent
+	// promotions that are kept in a MobileApp's status.
+	MaxPromotions = 20
+	// AnnotationPromotion is set on a MobileApp to its most recent promotion
+	// as JSON by the same update that promotes one of its Channels, so that
+	// the promotion is recorded even if updating its status then fails.
+	AnnotationPromotion = "momo.frantj.cc/promotion"
+)
+
+// ChannelVersion returns the version that the channel of mobileApp with the given
+// name serves, which is empty if it serves the latest version, and whether or not
+// mobileApp has such a channel.
+func ChannelVersion(mobileApp *momov1alpha1.MobileApp, name string) (string, bool) {
+	for _, channel := range mobileApp.Spec.Channels {
+		if channel.Name == name {
+			return channel.Version, true
+		}
+	}
+
+	return "", false
+}
+
+// LatestVersion returns the newest version of mobileApp that each platform that it has
+// apps for has an app of, that is no newer than that platform's latest one, so that
+// every device that is served the version is served the same one.
+func LatestVersion(mobileApp *momov1alpha1.MobileApp) string {
+	var (
+		platforms = [][]momov1alpha1.MobileAppStatusApp{}
+		latest    *momov1alpha1.MobileAppStatusApp
+	)
+
+	for _, apps := range [][]momov1alpha1.MobileAppStatusApp{mobileApp.Status.APKs, mobileApp.Status.IPAs} {
+		if len(apps) > 0 {
+			platforms = append(platforms, apps)
+		}
+	}
+
+	if len(platforms) == 0 {
+		return ""
+	}
+
+	for _, app := range platforms[0] {
+		if latest != nil && CompareApps(mobileApp.Spec.Ordering, app, *latest) <= 0 {
+			continue
+		}
+
+		if !slices.ContainsFunc(platforms, func(apps []momov1alpha1.MobileAppStatusApp) bool {
+			return !servesUpTo(mobileApp.Spec.Ordering, apps, app.Version)
+		}) {
+			latest = &app
+		}
+	}
+
+	if latest == nil {
+		return ""
+	}
+
+	return latest.Version
+}
+
+// servesUpTo reports whether apps has one of the given version
+// that is no newer than the latest one of apps.
+func servesUpTo(ordering string, apps []momov1alpha1.MobileAppStatusApp, version string) bool {
+	i := slices.IndexFunc(apps, func(app momov1alpha1.MobileAppStatusApp) bool {
+		return app.Latest
+	})
+	if i < 0 {
+		return false
+	}
+
+	return slices.ContainsFunc(apps, func(app momov1alpha1.MobileAppStatusApp) bool {
+		return app.Version == version && CompareApps(ordering, app, apps[i]) <= 0
+	})
+}
+
+// PromoteChannel points the channel after the named one of the MobileApp with the given
+// key at the version that the named one serves. The promotion is recorded in the same
+// update, in AnnotationPromotion, for the MobileApp's controller to add to its status.
+func PromoteChannel(ctx context.Context, cli client.Client, key client.ObjectKey, name string) (*momov1alpha1.MobileAppStatusPromotion, error) {
+	var (
+		mobileApp = &momov1alpha1.MobileApp{}
+		promotion *momov1alpha1.MobileAppStatusPromotion
+	)
+
+	if err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		if err := cli.Get(ctx, key, mobileApp); err != nil {
+			return err
+		}
+
+		i := slices.IndexFunc(mobileApp.Spec.Channels, func(channel momov1alpha1.MobileAppSpecChannel) bool {
+			return channel.Name == name
+		})
+		if i < 0 {
+			return NewHTTPStatusCodeError(fmt.Errorf("mobileapp %s does not have channel %s", key.Name, name), http.StatusNotFound)
+		} else if i == len(mobileApp.Spec.Channels)-1 {
+			return NewHTTPStatusCodeError(fmt.Errorf("channel %s is the last channel of mobileapp %s", name, key.Name), http.StatusUnprocessableEntity)
+		}
+
+		version := mobileApp.Spec.Channels[i].Version
+		if version == "" {
+			if version = LatestVersion(mobileApp); version == "" {
+				return NewHTTPStatusCodeError(fmt.Errorf("channel %s does not have a version to promote", name), http.StatusPreconditionFailed)
+			}
+		}
+
+		mobileApp.Spec.Channels[i+1].Version = version
+		promotion = &momov1alpha1.MobileAppStatusPromotion{
+			Version: version,
+			From:    name,
+			To:      mobileApp.Spec.Channels[i+1].Name,
+			// Truncated to how precisely it is serialized so that it compares
+			// equal to itself once it is read back from AnnotationPromotion.
+			Time: metav1.NewTime(time.Now().Truncate(time.Second)),
+		}
+
+		annotation, err := json.Marshal(promotion)
+		if err != nil {
+			return err
+		}
+
+		annotations := mobileApp.GetAnnotations()
+		if annotations == nil {
+			annotations = map[string]string{}
+		}
+		annotations[AnnotationPromotion] = string(annotation)
+		mobileApp.SetAnnotations(annotations)
+
+		return cli.Update(ctx, mobileApp)
+	}); err != nil {
+		return nil, err
+	}
+
+	return promotion, nil
+}
+
+// RecordPromotion adds the promotion in mobileApp's AnnotationPromotion to its status'
+// Promotions unless it is already the most recent of them, reporting whether it did.
+func RecordPromotion(mobileApp *momov1alpha1.MobileApp) (bool, error) {
+	annotation, ok := mobileApp.GetAnnotations()[AnnotationPromotion]
+	if !ok {
+		return false, nil
+	}
+
+	promotion := momov1alpha1.MobileAppStatusPromotion{}
+	if err := json.Unmarshal([]byte(annotation), &promotion); err != nil {
+		return false, fmt.Errorf("parse annotation %s: %w", AnnotationPromotion, err)
+	}
+
+	if n := len(mobileApp.Status.Promotions); n > 0 {
+		last := mobileApp.Status.Promotions[n-1]
+		if last.Version == promotion.Version && last.From == promotion.From && last.To == promotion.To && last.Time.Equal(&promotion.Time) {
+			return false, nil
+		}
+	}
+
+	mobileApp.Status.Promotions = append(mobileApp.Status.Promotions, promotion)
+	if overflow := len(mobileApp.Status.Promotions) - MaxPromotions; overflow > 0 {
+		mobileApp.Status.Promotions = mobileApp.Status.Promotions[overflow:]
+	}
+
+	return true, nil
+}
@@ -0,0 +1,116 @@
+package momoutil_test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	momov1alpha1 "github.com/frantjc/momo/api/v1alpha1"
+	"github.com/frantjc/momo/internal/momoutil"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestPromoteChannel(t *testing.T) {
+	scheme, err := momoutil.NewScheme(corev1.AddToScheme, momov1alpha1.AddToScheme)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var (
+		ctx       = context.Background()
+		mobileApp = &momov1alpha1.MobileApp{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "app"},
+			Spec: momov1alpha1.MobileAppSpec{
+				Channels: []momov1alpha1.MobileAppSpecChannel{
+					{Name: "alpha"},
+					{Name: "beta", Version: "v1.0.0"},
+					{Name: "prod"},
+				},
+			},
+			Status: momov1alpha1.MobileAppStatus{
+				APKs: []momov1alpha1.MobileAppStatusApp{
+					{Name: "app-1", Version: "v1.0.0"},
+					{Name: "app-2", Version: "v1.1.0", Latest: true},
+				},
+				IPAs: []momov1alpha1.MobileAppStatusApp{
+					{Name: "app-3", Version: "v1.1.0"},
+					{Name: "app-4", Version: "v1.2.0", Latest: true},
+				},
+			},
+		}
+		key = client.ObjectKeyFromObject(mobileApp)
+		cli = fake.NewClientBuilder().WithScheme(scheme).WithObjects(mobileApp).WithStatusSubresource(mobileApp).Build()
+	)
+
+	promotion, err := momoutil.PromoteChannel(ctx, cli, key, "alpha")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// v1.2.0 is only built for iOS, so v1.1.0 is the latest version of both platforms.
+	if promotion.Version != "v1.1.0" || promotion.From != "alpha" || promotion.To != "beta" {
+		t.Fatalf("unexpected promotion %+v", promotion)
+	}
+
+	if _, err = momoutil.PromoteChannel(ctx, cli, key, "beta"); err != nil {
+		t.Fatal(err)
+	}
+
+	promoted := &momov1alpha1.MobileApp{}
+
+	if err = cli.Get(ctx, key, promoted); err != nil {
+		t.Fatal(err)
+	}
+
+	if version, _ := momoutil.ChannelVersion(promoted, "prod"); version != "v1.1.0" {
+		t.Fatalf("expected prod to be at v1.1.0, got %q", version)
+	}
+
+	// The most recent promotion is recorded in the same update as the
+	// Channel that it promoted, for the controller to add to the status.
+	if recorded, err := momoutil.RecordPromotion(promoted); err != nil || !recorded {
+		t.Fatalf("expected the promotion to be recorded, got %t, %v", recorded, err)
+	}
+
+	if recorded, err := momoutil.RecordPromotion(promoted); err != nil || recorded {
+		t.Fatalf("expected the promotion to only be recorded once, got %t, %v", recorded, err)
+	}
+
+	if len(promoted.Status.Promotions) != 1 || promoted.Status.Promotions[0].To != "prod" {
+		t.Fatalf("expected the promotion to prod, got %+v", promoted.Status.Promotions)
+	}
+
+	if _, err = momoutil.PromoteChannel(ctx, cli, key, "prod"); momoutil.HTTPStatusCode(err) != http.StatusUnprocessableEntity {
+		t.Fatalf("expected %d promoting the last channel, got %v", http.StatusUnprocessableEntity, err)
+	}
+
+	if _, err = momoutil.PromoteChannel(ctx, cli, key, "gamma"); momoutil.HTTPStatusCode(err) != http.StatusNotFound {
+		t.Fatalf("expected %d promoting a missing channel, got %v", http.StatusNotFound, err)
+	}
+}
+
+func TestLatestVersion(t *testing.T) {
+	mobileApp := &momov1alpha1.MobileApp{
+		Status: momov1alpha1.MobileAppStatus{
+			APKs: []momov1alpha1.MobileAppStatusApp{
+				{Name: "app-1", Version: "v1.0.0"},
+				{Name: "app-2", Version: "v1.1.0", Latest: true},
+			},
+		},
+	}
+
+	if version := momoutil.LatestVersion(mobileApp); version != "v1.1.0" {
+		t.Fatalf("expected the latest version of the only platform, v1.1.0, got %q", version)
+	}
+
+	mobileApp.Status.IPAs = []momov1alpha1.MobileAppStatusApp{
+		{Name: "app-3", Version: "v1.2.0", Latest: true},
+	}
+
+	if version := momoutil.LatestVersion(mobileApp); version != "" {
+		t.Fatalf("expected no version that both platforms have, got %q", version)
+	}
+}
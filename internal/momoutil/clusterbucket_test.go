@@ -0,0 +1,198 @@
+package momoutil_test
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/frantjc/momo/android"
+	momov1alpha1 "github.com/frantjc/momo/api/v1alpha1"
+	"github.com/frantjc/momo/internal/momoutil"
+	_ "gocloud.dev/blob/fileblob"
+	"gocloud.dev/gcerrors"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+)
+
+func TestClusterBucket(t *testing.T) {
+	scheme, err := momoutil.NewScheme(corev1.AddToScheme, momov1alpha1.AddToScheme)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var (
+		ctx    = context.Background()
+		dir    = t.TempDir()
+		bucket = &momov1alpha1.ClusterBucket{
+			ObjectMeta: metav1.ObjectMeta{Name: "shared"},
+			Spec: momov1alpha1.ClusterBucketSpec{
+				BucketSpec: momov1alpha1.BucketSpec{URL: fmt.Sprintf("file://%s?prefix=base", dir)},
+				Namespaces: []string{"a"},
+				NamespaceSelector: &metav1.LabelSelector{
+					MatchLabels: map[string]string{"shared": "true"},
+				},
+				KeyPrefix: "tenants",
+			},
+		}
+		a       = &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "a"}}
+		b       = &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "b", Labels: map[string]string{"shared": "true"}}}
+		c       = &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "c"}}
+		ref     = momov1alpha1.BucketReference{Kind: momov1alpha1.KindClusterBucket, Name: bucket.Name}
+		cli     = fake.NewClientBuilder().WithScheme(scheme).WithObjects(bucket, a, b, c).Build()
+		buckets = &momoutil.BucketManager{}
+		key     = "app.apk"
+	)
+	defer func() {
+		_ = buckets.Close()
+	}()
+
+	for _, ns := range []string{a.Name, b.Name} {
+		if _, err := momoutil.GetBucketReference(ctx, cli, ns, ref); err != nil {
+			t.Fatalf("namespace %s: %v", ns, err)
+		}
+	}
+
+	if _, err := momoutil.GetBucketReference(ctx, cli, c.Name, ref); !apierrors.IsForbidden(err) {
+		t.Fatalf("namespace %s: expected Forbidden, got %v", c.Name, err)
+	}
+
+	ha, err := buckets.OpenBucketFor(ctx, cli, bucket, a.Name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = ha.Close()
+	}()
+
+	hb, err := buckets.OpenBucketFor(ctx, cli, bucket, b.Name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = hb.Close()
+	}()
+
+	root, err := buckets.OpenBucket(ctx, cli, bucket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = root.Close()
+	}()
+
+	if err = ha.WriteAll(ctx, key, []byte(t.Name()), nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = hb.Attributes(ctx, key); gcerrors.Code(err) != gcerrors.NotFound {
+		t.Fatalf("namespace %s can read namespace %s's %s: %v", b.Name, a.Name, key, err)
+	}
+
+	if _, err = root.Attributes(ctx, momoutil.BucketKeyPrefix(bucket, a.Name)+key); err != nil {
+		t.Fatal(err)
+	}
+
+	if prefix := momoutil.BucketKeyPrefix(bucket, a.Name); prefix != "tenants/a/" {
+		t.Fatalf("prefix is %s, expected tenants/a/", prefix)
+	}
+
+	// The namespace's prefix is nested under the prefix in the ClusterBucket's URL.
+	if _, err = os.Stat(filepath.Join(dir, "base", "tenants", "a", key)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestUploadAppClusterBucket(t *testing.T) {
+	scheme, err := momoutil.NewScheme(corev1.AddToScheme, momov1alpha1.AddToScheme)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var (
+		ctx    = context.Background()
+		dir    = t.TempDir()
+		secret = &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "momo-system", Name: "shared"},
+			Data:       map[string][]byte{"url": []byte(fmt.Sprintf("file://%s", dir))},
+		}
+		bucket = &momov1alpha1.ClusterBucket{
+			ObjectMeta: metav1.ObjectMeta{Name: "shared"},
+			Spec: momov1alpha1.ClusterBucketSpec{
+				BucketSpec: momov1alpha1.BucketSpec{
+					URLFrom: &corev1.EnvVarSource{
+						SecretKeyRef: &corev1.SecretKeySelector{
+							LocalObjectReference: corev1.LocalObjectReference{Name: secret.Name},
+							Key:                  "url",
+						},
+					},
+				},
+				NamespaceSelector: &metav1.LabelSelector{
+					MatchLabels: map[string]string{"shared": "true"},
+				},
+				ResourceNamespace: secret.Namespace,
+			},
+		}
+		a      = &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "a", Labels: map[string]string{"shared": "true"}}}
+		b      = &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "b", Labels: map[string]string{"shared": "true"}}}
+		ref    = momov1alpha1.BucketReference{Kind: momov1alpha1.KindClusterBucket, Name: bucket.Name}
+		server = fake.NewClientBuilder().WithScheme(scheme).WithObjects(secret, bucket, a, b).Build()
+		// tenant may only create and get objects in namespace a, like an uploader to it
+		// that may not get ClusterBuckets, Namespaces or Secrets in other namespaces.
+		tenant = interceptor.NewClient(server, interceptor.Funcs{
+			Get: func(ctx context.Context, cli client.WithWatch, key client.ObjectKey, obj client.Object, opts ...client.GetOption) error {
+				if key.Namespace != a.Name {
+					return apierrors.NewForbidden(schema.GroupResource{}, key.Name, fmt.Errorf("tenant"))
+				}
+
+				return cli.Get(ctx, key, obj, opts...)
+			},
+			Create: func(ctx context.Context, cli client.WithWatch, obj client.Object, opts ...client.CreateOption) error {
+				if obj.GetNamespace() != a.Name {
+					return apierrors.NewForbidden(schema.GroupResource{}, obj.GetName(), fmt.Errorf("tenant"))
+				}
+
+				return cli.Create(ctx, obj, opts...)
+			},
+		})
+		buckets = &momoutil.BucketManager{}
+	)
+	defer func() {
+		_ = buckets.Close()
+	}()
+
+	if err := momoutil.UploadApp(ctx, tenant, buckets, a.Name, "app", ref, android.ContentTypeAPK, strings.NewReader(t.Name())); !apierrors.IsForbidden(err) {
+		t.Fatalf("expected Forbidden without the server's client, got %v", err)
+	}
+
+	if err := momoutil.UploadApp(ctx, tenant, buckets, b.Name, "app", ref, android.ContentTypeAPK, strings.NewReader(t.Name()), momoutil.WithClusterBucketClient(server)); !apierrors.IsForbidden(err) {
+		t.Fatalf("expected Forbidden for another namespace, got %v", err)
+	}
+
+	if entries, _ := os.ReadDir(dir); len(entries) > 0 {
+		t.Fatal("expected nothing to be written to the ClusterBucket for unauthorized uploads")
+	}
+
+	if err := momoutil.UploadApp(ctx, tenant, buckets, a.Name, "app", ref, android.ContentTypeAPK, strings.NewReader(t.Name()), momoutil.WithClusterBucketClient(server)); err != nil {
+		t.Fatal(err)
+	}
+
+	apks := &momov1alpha1.APKList{}
+
+	if err := server.List(ctx, apks, client.InNamespace(a.Name)); err != nil {
+		t.Fatal(err)
+	} else if len(apks.Items) != 1 {
+		t.Fatalf("expected 1 APK, got %d", len(apks.Items))
+	}
+
+	if _, err := os.Stat(filepath.Join(dir, a.Name, apks.Items[0].Spec.Key)); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,197 @@
+package momoutil
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"gocloud.dev/gcerrors"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+const (
+	PlatformAndroid = "android"
+	PlatformIOS     = "ios"
+)
+
+const (
+	DecodeStepAPKTool    = "apktool"
+	DecodeStepKeytool    = "keytool"
+	DecodeStepInfoPlist  = "Info.plist"
+	DecodeStepIcons      = "icons"
+	DecodeStepBundletool = "bundletool"
+)
+
+var (
+	decodeDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: "momo",
+			Name:      "decode_duration_seconds",
+			Help:      "How long each step of decoding an APK or IPA takes.",
+			Buckets:   prometheus.ExponentialBuckets(0.1, 2, 10),
+		},
+		[]string{"platform", "step"},
+	)
+	decodeFailures = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "momo",
+			Name:      "decode_failures_total",
+			Help:      "Number of times each step of decoding an APK or IPA failed.",
+		},
+		[]string{"platform", "step"},
+	)
+	digestBytes = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "momo",
+			Name:      "digest_downloaded_bytes_total",
+			Help:      "Bytes of APKs and IPAs downloaded from Buckets to compute their digest.",
+		},
+		[]string{"platform"},
+	)
+	uploads = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "momo",
+			Name:      "uploads_total",
+			Help:      "Number of APKs and IPAs uploaded, by HTTP status code.",
+		},
+		[]string{"platform", "code"},
+	)
+	uploadBytes = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "momo",
+			Name:      "upload_bytes_total",
+			Help:      "Bytes of APKs and IPAs uploaded.",
+		},
+		[]string{"platform"},
+	)
+	uploadDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: "momo",
+			Name:      "upload_duration_seconds",
+			Help:      "How long uploading an APK or IPA takes.",
+			Buckets:   prometheus.ExponentialBuckets(0.5, 2, 10),
+		},
+		[]string{"platform"},
+	)
+	downloads = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "momo",
+			Name:      "downloads_total",
+			Help:      "Number of APKs and IPAs downloaded.",
+		},
+		[]string{"namespace", "app", "platform"},
+	)
+	downloadBytes = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "momo",
+			Name:      "download_bytes_total",
+			Help:      "Bytes of APKs and IPAs downloaded.",
+		},
+		[]string{"namespace", "app", "platform"},
+	)
+	bucketErrors = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "momo",
+			Name:      "bucket_operation_errors_total",
+			Help:      "Number of Bucket operations that failed, by driver.",
+		},
+		[]string{"driver", "operation"},
+	)
+	bucketProbeDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: "momo",
+			Name:      "bucket_probe_duration_seconds",
+			Help:      "How long probing Buckets takes, by driver.",
+		},
+		[]string{"driver", "operation"},
+	)
+)
+
+func init() {
+	metrics.Registry.MustRegister(
+		decodeDuration,
+		decodeFailures,
+		digestBytes,
+		uploads,
+		uploadBytes,
+		uploadDuration,
+		downloads,
+		downloadBytes,
+		bucketErrors,
+		bucketProbeDuration,
+	)
+}
+
+// ObserveDecode records how long a step of decoding an
+// APK or IPA that started at start took and if it failed.
+func ObserveDecode(platform, step string, start time.Time, err error) {
+	decodeDuration.WithLabelValues(platform, step).Observe(time.Since(start).Seconds())
+	if err != nil {
+		decodeFailures.WithLabelValues(platform, step).Inc()
+	}
+}
+
+// ObserveDigestBytes records n bytes of an APK or
+// IPA having been downloaded to compute its digest.
+func ObserveDigestBytes(platform string, n int64) {
+	digestBytes.WithLabelValues(platform).Add(float64(n))
+}
+
+// ObserveUpload records an upload of n bytes that started at start
+// and was responded to with the given HTTP status code.
+func ObserveUpload(platform string, code int, n int64, start time.Time) {
+	uploads.WithLabelValues(platform, fmt.Sprint(code)).Inc()
+	uploadBytes.WithLabelValues(platform).Add(float64(n))
+	uploadDuration.WithLabelValues(platform).Observe(time.Since(start).Seconds())
+}
+
+// ObserveDownload records a download of n bytes of an app. Versions are not
+// labeled, as each upload would otherwise add series that are never removed;
+// downloads of each version are counted in MobileApps' statuses instead.
+func ObserveDownload(namespace, app, platform string, n int64) {
+	downloads.WithLabelValues(namespace, app, platform).Inc()
+	downloadBytes.WithLabelValues(namespace, app, platform).Add(float64(n))
+}
+
+// ObserveBucketError records err if it is a failure of the given operation on
+// a Bucket opened by the given driver. Objects not being found are not counted,
+// as that is often expected. It returns err so that calls can be inlined.
+func ObserveBucketError(driver, operation string, err error) error {
+	if err != nil && gcerrors.Code(err) != gcerrors.NotFound {
+		bucketErrors.WithLabelValues(driver, operation).Inc()
+	}
+
+	return err
+}
+
+// ObserveBucketProbe records how long the given operation of probing a Bucket
+// opened by the given driver that started at start took. It is recorded here
+// rather than in the Bucket's conditions so that they do not change every probe.
+func ObserveBucketProbe(driver, operation string, start time.Time) {
+	bucketProbeDuration.WithLabelValues(driver, operation).Observe(time.Since(start).Seconds())
+}
+
+// CountingReader counts the bytes read through it.
+type CountingReader struct {
+	io.Reader
+	N int64
+}
+
+func (r *CountingReader) Read(p []byte) (int, error) {
+	n, err := r.Reader.Read(p)
+	r.N += int64(n)
+	return n, err
+}
+
+// CountingWriter counts the bytes written through it.
+type CountingWriter struct {
+	io.Writer
+	N int64
+}
+
+func (w *CountingWriter) Write(p []byte) (int, error) {
+	n, err := w.Writer.Write(p)
+	w.N += int64(n)
+	return n, err
+}
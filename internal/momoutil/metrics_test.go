@@ -0,0 +1,127 @@
+package momoutil_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/frantjc/momo/internal/momoutil"
+	"gocloud.dev/blob/memblob"
+	"gocloud.dev/gcerrors"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+// gather returns the value of the counter, or the sample count of the histogram,
+// with the given name and labels in the controller-runtime metrics registry.
+func gather(t *testing.T, name string, labels map[string]string) float64 {
+	t.Helper()
+
+	families, err := metrics.Registry.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, family := range families {
+		if family.GetName() != name {
+			continue
+		}
+
+		for _, metric := range family.GetMetric() {
+			matches := 0
+			for _, label := range metric.GetLabel() {
+				if value, ok := labels[label.GetName()]; ok && value == label.GetValue() {
+					matches++
+				}
+			}
+
+			if matches != len(labels) {
+				continue
+			}
+
+			if histogram := metric.GetHistogram(); histogram != nil {
+				return float64(histogram.GetSampleCount())
+			}
+
+			return metric.GetCounter().GetValue()
+		}
+	}
+
+	return 0
+}
+
+func TestObserveDownload(t *testing.T) {
+	labels := map[string]string{"namespace": t.Name(), "app": "app", "platform": momoutil.PlatformAndroid}
+
+	momoutil.ObserveDownload(t.Name(), "app", momoutil.PlatformAndroid, 3)
+	momoutil.ObserveDownload(t.Name(), "app", momoutil.PlatformAndroid, 4)
+
+	if downloads := gather(t, "momo_downloads_total", labels); downloads != 2 {
+		t.Errorf("expected 2 downloads, got %v", downloads)
+	}
+
+	if n := gather(t, "momo_download_bytes_total", labels); n != 7 {
+		t.Errorf("expected 7 bytes downloaded, got %v", n)
+	}
+}
+
+func TestObserveUpload(t *testing.T) {
+	platform := strings.ToLower(t.Name())
+
+	momoutil.ObserveUpload(platform, 201, 5, time.Now())
+	momoutil.ObserveUpload(platform, 500, 0, time.Now())
+
+	if uploads := gather(t, "momo_uploads_total", map[string]string{"platform": platform, "code": "201"}); uploads != 1 {
+		t.Errorf("expected 1 created upload, got %v", uploads)
+	}
+
+	if n := gather(t, "momo_upload_bytes_total", map[string]string{"platform": platform}); n != 5 {
+		t.Errorf("expected 5 bytes uploaded, got %v", n)
+	}
+
+	if observed := gather(t, "momo_upload_duration_seconds", map[string]string{"platform": platform}); observed != 2 {
+		t.Errorf("expected 2 upload durations, got %v", observed)
+	}
+}
+
+func TestObserveDecode(t *testing.T) {
+	platform := strings.ToLower(t.Name())
+
+	momoutil.ObserveDecode(platform, momoutil.DecodeStepIcons, time.Now(), nil)
+	momoutil.ObserveDecode(platform, momoutil.DecodeStepIcons, time.Now(), errors.New("decode"))
+
+	labels := map[string]string{"platform": platform, "step": momoutil.DecodeStepIcons}
+
+	if observed := gather(t, "momo_decode_duration_seconds", labels); observed != 2 {
+		t.Errorf("expected 2 decode durations, got %v", observed)
+	}
+
+	if failures := gather(t, "momo_decode_failures_total", labels); failures != 1 {
+		t.Errorf("expected 1 decode failure, got %v", failures)
+	}
+}
+
+func TestObserveBucketError(t *testing.T) {
+	var (
+		driver = strings.ToLower(t.Name())
+		labels = map[string]string{"driver": driver, "operation": "read"}
+		err    = errors.New("read")
+	)
+
+	if actual := momoutil.ObserveBucketError(driver, "read", err); actual != err {
+		t.Fatalf("expected the error to be returned, got %v", actual)
+	}
+
+	_, notFound := memblob.OpenBucket(nil).NewReader(context.Background(), t.Name(), nil)
+	if gcerrors.Code(notFound) != gcerrors.NotFound {
+		t.Fatalf("expected NotFound, got %v", notFound)
+	}
+
+	_ = momoutil.ObserveBucketError(driver, "read", nil)
+	_ = momoutil.ObserveBucketError(driver, "read", notFound)
+
+	if failures := gather(t, "momo_bucket_operation_errors_total", labels); failures != 1 {
+		t.Errorf("expected 1 Bucket error, got %v", failures)
+	}
+}
@@ -0,0 +1,221 @@
+package momoutil
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	momov1alpha1 "github.com/frantjc/momo/api/v1alpha1"
+	"github.com/opencontainers/go-digest"
+	"gocloud.dev/blob"
+	"gocloud.dev/gcerrors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// AnnotationMigrateTo is set on an APK or IPA to have it migrated
+	// to the Bucket with the given name, or ClusterBucket/<name>, or
+	// on a Bucket to have every APK and IPA that references it migrated.
+	AnnotationMigrateTo = "momo.frantj.cc/migrate-to"
+)
+
+var (
+	// ErrObjectsLeftBehind is wrapped by the error that MigrateApp returns when
+	// the APK or IPA was migrated, but not all of its objects could be deleted
+	// from the Bucket that it was migrated from.
+	ErrObjectsLeftBehind = errors.New("objects left behind")
+)
+
+// MigrateApp copies the binary and icons of the given APK or IPA from the Bucket
+// that it references to the referenced Bucket or ClusterBucket, verifies the copied
+// binary against the digest that it was unpacked with, points the APK or IPA
+// at the new Bucket and then deletes the objects from the old one. If both resolve
+// to the same objects, it only points the APK or IPA at the new Bucket.
+func MigrateApp(ctx context.Context, cli client.Client, buckets *BucketManager, obj client.Object, to momov1alpha1.BucketReference) error {
+	var (
+		from  momov1alpha1.BucketReference
+		key   string
+		dgst  string
+		icons []momov1alpha1.AppStatusIcon
+		// built are the keys of objects that were built from or uploaded with key, e.g. a universal APK.
+		built []string
+	)
+
+	switch app := obj.(type) {
+	case *momov1alpha1.APK:
+		from, key, dgst, icons = app.Spec.Bucket, app.Spec.Key, app.Status.Digest, app.Status.Icons
+		if app.Status.UniversalAPKKey != "" {
+			built = append(built, app.Status.UniversalAPKKey)
+		}
+	case *momov1alpha1.IPA:
+		from, key, dgst, icons = app.Spec.Bucket, app.Spec.Key, app.Status.Digest, app.Status.Icons
+		if app.Spec.DSYMsKey != "" {
+			built = append(built, app.Spec.DSYMsKey)
+		}
+	default:
+		return fmt.Errorf("cannot migrate %T", obj)
+	}
+
+	if from.String() == to.String() {
+		if _, ok := obj.GetAnnotations()[AnnotationMigrateTo]; ok {
+			return cli.Update(ctx, withoutMigrateTo(obj))
+		}
+
+		return nil
+	}
+
+	src, err := GetBucketReference(ctx, cli, obj.GetNamespace(), from)
+	if err != nil {
+		return err
+	}
+
+	dst, err := GetBucketReference(ctx, cli, obj.GetNamespace(), to)
+	if err != nil {
+		return err
+	}
+
+	if dst.GetBucketStatus().Phase != momov1alpha1.PhaseReady {
+		return fmt.Errorf("bucket %s is not ready", to)
+	}
+
+	srcb, err := buckets.OpenBucketFor(ctx, cli, src, obj.GetNamespace())
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = srcb.Close()
+	}()
+
+	dstb, err := buckets.OpenBucketFor(ctx, cli, dst, obj.GetNamespace())
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = dstb.Close()
+	}()
+
+	if srcb.url == dstb.url {
+		// Both references resolve to the same objects, so they are already where they need to be,
+		// and deleting them from the Bucket that they are migrated from would delete them altogether.
+		setBucket(obj, to)
+
+		return cli.Update(ctx, withoutMigrateTo(obj))
+	}
+
+	if dgst == "" {
+		return fmt.Errorf("%s has not been unpacked yet", obj.GetName())
+	}
+
+	if err := copyObject(ctx, srcb, dstb, key, digest.Digest(dgst)); err != nil {
+		return fmt.Errorf("copy %s: %w", key, err)
+	}
+
+	for _, k := range append(built, iconKeys(icons)...) {
+		if err := copyObject(ctx, srcb, dstb, k, ""); err != nil {
+			return fmt.Errorf("copy %s: %w", k, err)
+		}
+	}
+
+	setBucket(obj, to)
+
+	if err := cli.Update(ctx, withoutMigrateTo(obj)); err != nil {
+		return err
+	}
+
+	errs := []error{}
+
+	for _, k := range append(append([]string{key}, built...), iconKeys(icons)...) {
+		if err := srcb.Delete(ctx, k); gcerrors.Code(err) != gcerrors.NotFound {
+			errs = append(errs, ObserveBucketError(srcb.Driver, "delete", err))
+		}
+	}
+
+	if err := errors.Join(errs...); err != nil {
+		return fmt.Errorf("%w in bucket %s: %w", ErrObjectsLeftBehind, from, err)
+	}
+
+	return nil
+}
+
+func setBucket(obj client.Object, to momov1alpha1.BucketReference) {
+	switch app := obj.(type) {
+	case *momov1alpha1.APK:
+		app.Spec.Bucket = to
+	case *momov1alpha1.IPA:
+		app.Spec.Bucket = to
+	}
+}
+
+func withoutMigrateTo(obj client.Object) client.Object {
+	if annotations := obj.GetAnnotations(); annotations != nil {
+		delete(annotations, AnnotationMigrateTo)
+		obj.SetAnnotations(annotations)
+	}
+	return obj
+}
+
+func iconKeys(icons []momov1alpha1.AppStatusIcon) []string {
+	keys := make([]string, len(icons))
+	for i, icon := range icons {
+		keys[i] = icon.Key
+	}
+	return keys
+}
+
+// copyObject copies key from src to dst. If expected is a digest of the object's
+// contents, the copy is read back from dst and removed if it does not match.
+// Digests of other things, such as of the object's attributes, are not checked.
+func copyObject(ctx context.Context, src, dst *BucketHandle, key string, expected digest.Digest) error {
+	r, err := src.NewReader(ctx, key, nil)
+	if err != nil {
+		return ObserveBucketError(src.Driver, "read", err)
+	}
+	defer func() {
+		_ = r.Close()
+	}()
+
+	wctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	w, err := dst.NewWriter(wctx, key, &blob.WriterOptions{ContentType: r.ContentType()})
+	if err != nil {
+		return ObserveBucketError(dst.Driver, "write", err)
+	}
+
+	if _, err = io.Copy(w, r); err != nil {
+		// Canceling the context that the writer
+		// was created with aborts the write.
+		cancel()
+		_ = w.Close()
+		return err
+	}
+
+	if err = w.Close(); err != nil {
+		return ObserveBucketError(dst.Driver, "write", err)
+	}
+
+	if expected == "" || !expected.Algorithm().Available() {
+		return nil
+	}
+
+	rc, err := dst.NewReader(ctx, key, nil)
+	if err != nil {
+		return ObserveBucketError(dst.Driver, "read", err)
+	}
+	defer func() {
+		_ = rc.Close()
+	}()
+
+	actual, err := expected.Algorithm().FromReader(rc)
+	if err != nil {
+		return err
+	}
+
+	if actual != expected {
+		_ = dst.Delete(ctx, key)
+		return fmt.Errorf("digest mismatch: expected %s, got %s", expected, actual)
+	}
+
+	return nil
+}
@@ -0,0 +1,191 @@
+package momoutil_test
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	momov1alpha1 "github.com/frantjc/momo/api/v1alpha1"
+	"github.com/frantjc/momo/internal/momoutil"
+	"github.com/opencontainers/go-digest"
+	_ "gocloud.dev/blob/memblob"
+	"gocloud.dev/gcerrors"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestMigrateApp(t *testing.T) {
+	scheme, err := momoutil.NewScheme(corev1.AddToScheme, momov1alpha1.AddToScheme)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data := []byte(t.Name())
+
+	for _, dig := range []digest.Digest{
+		digest.FromBytes(data),
+		// Digests of objects' attributes cannot be checked against their copies.
+		digest.NewDigestFromEncoded("attributes", digest.FromString(t.Name()).Encoded()),
+	} {
+		t.Run(dig.Algorithm().String(), func(t *testing.T) {
+			var (
+				ctx  = context.Background()
+				from = &momov1alpha1.Bucket{
+					ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "from"},
+					Spec:       momov1alpha1.BucketSpec{URL: "mem://from"},
+					Status:     momov1alpha1.BucketStatus{Phase: momov1alpha1.PhaseReady},
+				}
+				to = &momov1alpha1.Bucket{
+					ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "to"},
+					Spec:       momov1alpha1.BucketSpec{URL: "mem://to"},
+					Status:     momov1alpha1.BucketStatus{Phase: momov1alpha1.PhaseReady},
+				}
+				apk = &momov1alpha1.APK{
+					ObjectMeta: metav1.ObjectMeta{
+						Namespace:   "default",
+						Name:        "app",
+						Annotations: map[string]string{momoutil.AnnotationMigrateTo: to.Name},
+					},
+					Spec: momov1alpha1.APKSpec{
+						Bucket: momov1alpha1.BucketReference{Name: from.Name},
+						Key:    "app.apk",
+					},
+					Status: momov1alpha1.APKStatus{
+						Digest: dig.String(),
+						Icons:  []momov1alpha1.AppStatusIcon{{Key: "default/app/icon-57x57.png", Size: 57}},
+					},
+				}
+				cli     = fake.NewClientBuilder().WithScheme(scheme).WithObjects(from, to, apk).Build()
+				buckets = &momoutil.BucketManager{}
+			)
+			defer func() {
+				_ = buckets.Close()
+			}()
+
+			src, err := buckets.OpenBucket(ctx, cli, from)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer func() {
+				_ = src.Close()
+			}()
+
+			dst, err := buckets.OpenBucket(ctx, cli, to)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer func() {
+				_ = dst.Close()
+			}()
+
+			for _, key := range []string{apk.Spec.Key, apk.Status.Icons[0].Key} {
+				if err = src.WriteAll(ctx, key, data, nil); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			if err = momoutil.MigrateApp(ctx, cli, buckets, apk, momov1alpha1.BucketReference{Name: to.Name}); err != nil {
+				t.Fatal(err)
+			}
+
+			migrated := &momov1alpha1.APK{}
+			if err = cli.Get(ctx, client.ObjectKeyFromObject(apk), migrated); err != nil {
+				t.Fatal(err)
+			}
+
+			if migrated.Spec.Bucket.Name != to.Name {
+				t.Fatalf("bucket is %s, expected %s", migrated.Spec.Bucket.Name, to.Name)
+			}
+
+			if _, ok := migrated.Annotations[momoutil.AnnotationMigrateTo]; ok {
+				t.Fatalf("annotation %s was not removed", momoutil.AnnotationMigrateTo)
+			}
+
+			for _, key := range []string{apk.Spec.Key, apk.Status.Icons[0].Key} {
+				read, err := dst.ReadAll(ctx, key)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				if !bytes.Equal(read, data) {
+					t.Fatalf("read %q from %s, expected %q", read, key, data)
+				}
+
+				if _, err = src.Attributes(ctx, key); gcerrors.Code(err) != gcerrors.NotFound {
+					t.Fatalf("%s was not deleted from the source bucket: %v", key, err)
+				}
+			}
+		})
+	}
+}
+
+func TestMigrateAppSameObjects(t *testing.T) {
+	scheme, err := momoutil.NewScheme(corev1.AddToScheme, momov1alpha1.AddToScheme)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var (
+		ctx  = context.Background()
+		data = []byte(t.Name())
+		// from and to are different Buckets that resolve to the same objects.
+		from = &momov1alpha1.Bucket{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "from"},
+			Spec:       momov1alpha1.BucketSpec{URL: "mem://same"},
+			Status:     momov1alpha1.BucketStatus{Phase: momov1alpha1.PhaseReady},
+		}
+		to = &momov1alpha1.Bucket{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "to"},
+			Spec:       momov1alpha1.BucketSpec{URL: "mem://same"},
+			Status:     momov1alpha1.BucketStatus{Phase: momov1alpha1.PhaseReady},
+		}
+		apk = &momov1alpha1.APK{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace:   "default",
+				Name:        "app",
+				Annotations: map[string]string{momoutil.AnnotationMigrateTo: to.Name},
+			},
+			Spec: momov1alpha1.APKSpec{
+				Bucket: momov1alpha1.BucketReference{Name: from.Name},
+				Key:    "app.apk",
+			},
+			Status: momov1alpha1.APKStatus{Digest: digest.FromBytes(data).String()},
+		}
+		cli     = fake.NewClientBuilder().WithScheme(scheme).WithObjects(from, to, apk).Build()
+		buckets = &momoutil.BucketManager{}
+	)
+	defer func() {
+		_ = buckets.Close()
+	}()
+
+	b, err := buckets.OpenBucket(ctx, cli, to)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = b.Close()
+	}()
+
+	if err = b.WriteAll(ctx, apk.Spec.Key, data, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = momoutil.MigrateApp(ctx, cli, buckets, apk, momov1alpha1.BucketReference{Name: to.Name}); err != nil {
+		t.Fatal(err)
+	}
+
+	migrated := &momov1alpha1.APK{}
+	if err = cli.Get(ctx, client.ObjectKeyFromObject(apk), migrated); err != nil {
+		t.Fatal(err)
+	}
+
+	if migrated.Spec.Bucket.Name != to.Name {
+		t.Fatalf("bucket is %s, expected %s", migrated.Spec.Bucket.Name, to.Name)
+	}
+
+	if read, err := b.ReadAll(ctx, apk.Spec.Key); err != nil || !bytes.Equal(read, data) {
+		t.Fatalf("expected %s to be kept, read %q: %v", apk.Spec.Key, read, err)
+	}
+}
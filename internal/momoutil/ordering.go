@@ -0,0 +1,148 @@
+package momoutil
+
+import (
+	"cmp"
+	"slices"
+	"strconv"
+	"strings"
+
+	momov1alpha1 "github.com/frantjc/momo/api/v1alpha1"
+	"golang.org/x/mod/semver"
+)
+
+// CompareApps returns a negative number if a is older than b, a positive number
+// if a is newer than b and 0 if neither is, according to the given ordering.
+func CompareApps(ordering string, a, b momov1alpha1.MobileAppStatusApp) int {
+	var (
+		bySemVer = func() int {
+			return semver.Compare(a.Version, b.Version)
+		}
+		byBuildNumber = func() int {
+			return CompareBuildNumbers(a.BuildNumber, b.BuildNumber)
+		}
+		byUploadTime = func() int {
+			switch {
+			case a.UploadTime == nil && b.UploadTime == nil:
+				return 0
+			case a.UploadTime == nil:
+				return -1
+			case b.UploadTime == nil:
+				return 1
+			}
+
+			return a.UploadTime.Compare(b.UploadTime.Time)
+		}
+	)
+
+	switch ordering {
+	case momov1alpha1.OrderingBuildNumber:
+		return cmp.Or(byBuildNumber(), bySemVer(), byUploadTime())
+	case momov1alpha1.OrderingUploadTime:
+		return cmp.Or(byUploadTime(), bySemVer(), byBuildNumber())
+	}
+
+	return cmp.Or(bySemVer(), byBuildNumber(), byUploadTime())
+}
+
+// SortApps sorts apps newest first according to the given ordering.
+func SortApps(ordering string, apps []momov1alpha1.MobileAppStatusApp) {
+	slices.SortStableFunc(apps, func(a, b momov1alpha1.MobileAppStatusApp) int {
+		return CompareApps(ordering, b, a)
+	})
+}
+
+// CompareBuildNumbers compares build numbers such as 457 or 2024.10.3
+// segment by segment, numerically where both segments are numbers.
+// An empty build number is older than any other.
+func CompareBuildNumbers(a, b string) int {
+	switch {
+	case a == b:
+		return 0
+	case a == "":
+		return -1
+	case b == "":
+		return 1
+	}
+
+	var (
+		as = strings.Split(a, ".")
+		bs = strings.Split(b, ".")
+	)
+
+	for i := 0; i < len(as) && i < len(bs); i++ {
+		an, aErr := strconv.ParseUint(as[i], 10, 64)
+		bn, bErr := strconv.ParseUint(bs[i], 10, 64)
+
+		c := 0
+		switch {
+		case aErr == nil && bErr == nil:
+			c = cmp.Compare(an, bn)
+		case aErr == nil:
+			c = 1
+		case bErr == nil:
+			c = -1
+		default:
+			c = strings.Compare(as[i], bs[i])
+		}
+
+		if c != 0 {
+			return c
+		}
+	}
+
+	return cmp.Compare(len(as), len(bs))
+}
+
+// MatchesVersion reports whether the given version refers to app,
+// by its semantic version, its version name or its build number.
+func MatchesVersion(app momov1alpha1.MobileAppStatusApp, version string) bool {
+	return version != "" && (strings.EqualFold(app.Version, version) ||
+		strings.EqualFold(app.VersionName, version) ||
+		app.BuildNumber == version)
+}
+
+// SameBuild reports whether a and b are builds of the same version.
+func SameBuild(a, b momov1alpha1.MobileAppStatusApp) bool {
+	return a.Version == b.Version && a.BuildNumber == b.BuildNumber
+}
+
+// PreviousVersion returns the version of the newest of apps that is older than
+// the newest one that version refers to, or than version itself if none does,
+// according to the given ordering.
+func PreviousVersion(ordering string, apps []momov1alpha1.MobileAppStatusApp, version string) string {
+	ref := momov1alpha1.MobileAppStatusApp{Version: version}
+
+	for _, app := range apps {
+		if MatchesVersion(app, version) && (ref.Name == "" || CompareApps(ordering, app, ref) > 0) {
+			ref = app
+		}
+	}
+
+	return versionBefore(ordering, apps, ref)
+}
+
+// versionBefore returns the version of the newest of apps that is older than ref
+// according to the given ordering. If that app shares its semantic version with
+// ref, its build number is returned in place of its version to tell them apart.
+func versionBefore(ordering string, apps []momov1alpha1.MobileAppStatusApp, ref momov1alpha1.MobileAppStatusApp) string {
+	var previous *momov1alpha1.MobileAppStatusApp
+
+	for _, app := range apps {
+		if SameBuild(app, ref) || CompareApps(ordering, app, ref) >= 0 {
+			continue
+		}
+
+		if previous == nil || CompareApps(ordering, app, *previous) > 0 {
+			previous = &app
+		}
+	}
+
+	switch {
+	case previous == nil:
+		return ""
+	case previous.Version == ref.Version && previous.BuildNumber != "":
+		return previous.BuildNumber
+	}
+
+	return previous.Version
+}
@@ -0,0 +1,68 @@
+package momoutil_test
+
+import (
+	"testing"
+	"time"
+
+	momov1alpha1 "github.com/frantjc/momo/api/v1alpha1"
+	"github.com/frantjc/momo/internal/momoutil"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestCompareBuildNumbers(t *testing.T) {
+	for _, tc := range []struct {
+		a, b     string
+		expected int
+	}{
+		{"457", "457", 0},
+		{"99", "457", -1},
+		{"1234", "999", 1},
+		{"2024.10.3", "2024.9.12", 1},
+		{"1.2", "1.2.1", -1},
+		{"", "1", -1},
+		{"1a", "1", -1},
+	} {
+		if actual := momoutil.CompareBuildNumbers(tc.a, tc.b); actual != tc.expected {
+			t.Errorf("comparing %q to %q: expected %d, got %d", tc.a, tc.b, tc.expected, actual)
+		}
+	}
+}
+
+func TestSortApps(t *testing.T) {
+	var (
+		now  = time.Now()
+		apps = []momov1alpha1.MobileAppStatusApp{
+			{Name: "a", Version: "v1.2.0", VersionName: "1.2", BuildNumber: "456", UploadTime: &metav1.Time{Time: now.Add(-time.Hour)}},
+			{Name: "b", Version: "v1.1.0", VersionName: "1.1", BuildNumber: "500", UploadTime: &metav1.Time{Time: now}},
+			{Name: "c", Version: "v1.2.0", VersionName: "1.2", BuildNumber: "457", UploadTime: &metav1.Time{Time: now.Add(-time.Minute)}},
+		}
+	)
+
+	for ordering, expected := range map[string]string{
+		momov1alpha1.OrderingSemVer:      "cab",
+		momov1alpha1.OrderingBuildNumber: "bca",
+		momov1alpha1.OrderingUploadTime:  "bca",
+		"":                               "cab",
+	} {
+		momoutil.SortApps(ordering, apps)
+
+		actual := ""
+		for _, app := range apps {
+			actual += app.Name
+		}
+
+		if actual != expected {
+			t.Errorf("ordering by %q: expected %s, got %s", ordering, expected, actual)
+		}
+	}
+
+	momoutil.SortApps(momov1alpha1.OrderingSemVer, apps)
+
+	if previous := momoutil.PreviousVersion(momov1alpha1.OrderingSemVer, apps, "1.2"); previous != "456" {
+		t.Errorf("expected the build before 1.2 (457) to be 456, got %q", previous)
+	}
+
+	if previous := momoutil.PreviousVersion(momov1alpha1.OrderingSemVer, apps, "456"); previous != "v1.1.0" {
+		t.Errorf("expected the version before 1.2 (456) to be v1.1.0, got %q", previous)
+	}
+}
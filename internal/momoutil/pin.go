@@ -0,0 +1,96 @@
+package momoutil
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"slices"
+
+	momov1alpha1 "github.com/frantjc/momo/api/v1alpha1"
+	"k8s.io/client-go/util/retry"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// PinnedVersion returns the version of mobileApp that is pinned for platform, if any.
+func PinnedVersion(mobileApp *momov1alpha1.MobileApp, platform string) string {
+	switch platform {
+	case PlatformAndroid:
+		return mobileApp.Spec.Pinned.Android
+	case PlatformIOS:
+		return mobileApp.Spec.Pinned.IOS
+	}
+
+	return ""
+}
+
+// Rollback is the pinning of the version before the latest one of a platform.
+type Rollback struct {
+	Platform string
+	From     string
+	Version  string
+}
+
+// RollBack pins the version before the one that the MobileApp with the given key serves as
+// the latest for each of the given platforms, or for each platform that it has apps for.
+func RollBack(ctx context.Context, cli client.Client, key client.ObjectKey, platforms ...string) ([]Rollback, error) {
+	for _, platform := range platforms {
+		if platform != PlatformAndroid && platform != PlatformIOS {
+			return nil, NewHTTPStatusCodeError(fmt.Errorf("unsupported platform %s", platform), http.StatusBadRequest)
+		}
+	}
+
+	var rollbacks []Rollback
+
+	if err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		mobileApp := &momov1alpha1.MobileApp{}
+
+		if err := cli.Get(ctx, key, mobileApp); err != nil {
+			return err
+		}
+
+		rollbacks = []Rollback{}
+
+		for _, platform := range []string{PlatformAndroid, PlatformIOS} {
+			var (
+				apps   = mobileApp.Status.APKs
+				pinned = &mobileApp.Spec.Pinned.Android
+			)
+			if platform == PlatformIOS {
+				apps = mobileApp.Status.IPAs
+				pinned = &mobileApp.Spec.Pinned.IOS
+			}
+
+			if len(platforms) > 0 && !slices.Contains(platforms, platform) {
+				continue
+			} else if len(platforms) == 0 && len(apps) == 0 {
+				continue
+			}
+
+			latest := momov1alpha1.MobileAppStatusApp{}
+			for _, app := range apps {
+				if app.Latest {
+					latest = app
+				}
+			}
+
+			previous := versionBefore(mobileApp.Spec.Ordering, apps, latest)
+
+			if previous == "" {
+				return NewHTTPStatusCodeError(fmt.Errorf("mobileapp %s does not have a %s version before %s to roll back to", key.Name, platform, latest.Version), http.StatusPreconditionFailed)
+			}
+
+			*pinned = previous
+			rollbacks = append(rollbacks, Rollback{Platform: platform, From: latest.Version, Version: previous})
+		}
+
+		if len(rollbacks) == 0 {
+			return NewHTTPStatusCodeError(fmt.Errorf("mobileapp %s does not have any versions to roll back", key.Name), http.StatusPreconditionFailed)
+		}
+
+		return cli.Update(ctx, mobileApp)
+	}); err != nil {
+		return nil, err
+	}
+
+	return rollbacks, nil
+}
@@ -0,0 +1,67 @@
+package momoutil_test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	momov1alpha1 "github.com/frantjc/momo/api/v1alpha1"
+	"github.com/frantjc/momo/internal/momoutil"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestRollBack(t *testing.T) {
+	scheme, err := momoutil.NewScheme(corev1.AddToScheme, momov1alpha1.AddToScheme)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var (
+		ctx       = context.Background()
+		mobileApp = &momov1alpha1.MobileApp{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "app"},
+			Status: momov1alpha1.MobileAppStatus{
+				APKs: []momov1alpha1.MobileAppStatusApp{
+					{Name: "app-1", Version: "v1.0.0"},
+					{Name: "app-2", Version: "v1.2.0", Latest: true, Newest: true},
+					{Name: "app-3", Version: "v1.1.0"},
+				},
+				IPAs: []momov1alpha1.MobileAppStatusApp{
+					{Name: "app-4", Version: "v1.2.0", Latest: true, Newest: true},
+				},
+			},
+		}
+		key = client.ObjectKeyFromObject(mobileApp)
+		cli = fake.NewClientBuilder().WithScheme(scheme).WithObjects(mobileApp).WithStatusSubresource(mobileApp).Build()
+	)
+
+	if _, err = momoutil.RollBack(ctx, cli, key); momoutil.HTTPStatusCode(err) != http.StatusPreconditionFailed {
+		t.Fatalf("expected %d rolling back an IPA without a previous version, got %v", http.StatusPreconditionFailed, err)
+	}
+
+	rollbacks, err := momoutil.RollBack(ctx, cli, key, momoutil.PlatformAndroid)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(rollbacks) != 1 || rollbacks[0].From != "v1.2.0" || rollbacks[0].Version != "v1.1.0" {
+		t.Fatalf("unexpected rollbacks %+v", rollbacks)
+	}
+
+	rolledBack := &momov1alpha1.MobileApp{}
+
+	if err = cli.Get(ctx, key, rolledBack); err != nil {
+		t.Fatal(err)
+	}
+
+	if pinned := momoutil.PinnedVersion(rolledBack, momoutil.PlatformAndroid); pinned != "v1.1.0" {
+		t.Fatalf("expected v1.1.0 to be pinned, got %q", pinned)
+	}
+
+	if pinned := momoutil.PinnedVersion(rolledBack, momoutil.PlatformIOS); pinned != "" {
+		t.Fatalf("expected no iOS version to be pinned, got %q", pinned)
+	}
+}
@@ -0,0 +1,133 @@
+package momoutil
+
+import (
+	"container/list"
+	"context"
+	"fmt"
+	"io"
+	"sync"
+)
+
+const (
+	// DefaultBlockSize is the size of the ranges that a BucketReaderAt reads.
+	DefaultBlockSize = 256 * 1024
+	// DefaultMaxBlocks is the number of blocks that a BucketReaderAt caches.
+	DefaultMaxBlocks = 64
+)
+
+// BucketReaderAt is an io.ReaderAt over an object in a Bucket. It reads the object
+// in blocks with range requests and caches the most recently used ones, so that
+// readers such as archive/zip that seek around the end of an object and then read
+// a few entries from it do not download all of it, nor the same bytes twice.
+type BucketReaderAt struct {
+	// BlockSize is the size of each range that is read from the object.
+	BlockSize int64
+	// MaxBlocks is the maximum number of blocks that are cached at once.
+	MaxBlocks int
+
+	// ctx is used for every range request, because
+	// io.ReaderAt does not otherwise take a context.
+	ctx    context.Context
+	bucket *BucketHandle
+	key    string
+	size   int64
+
+	mu     sync.Mutex
+	blocks map[int64]*list.Element
+	lru    *list.List
+}
+
+type block struct {
+	index int64
+	data  []byte
+}
+
+// NewBucketReaderAt returns a BucketReaderAt for the object
+// with the given key. ctx must outlive the BucketReaderAt.
+func NewBucketReaderAt(ctx context.Context, bucket *BucketHandle, key string) (*BucketReaderAt, error) {
+	attrs, err := bucket.Attributes(ctx, key)
+	if err != nil {
+		return nil, ObserveBucketError(bucket.Driver, "attributes", err)
+	}
+
+	return &BucketReaderAt{
+		BlockSize: DefaultBlockSize,
+		MaxBlocks: DefaultMaxBlocks,
+		ctx:       ctx,
+		bucket:    bucket,
+		key:       key,
+		size:      attrs.Size,
+		blocks:    map[int64]*list.Element{},
+		lru:       list.New(),
+	}, nil
+}
+
+// Size returns the size of the object.
+func (r *BucketReaderAt) Size() int64 {
+	return r.size
+}
+
+// ReadAt implements io.ReaderAt.
+func (r *BucketReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, fmt.Errorf("negative offset %d", off)
+	} else if off >= r.size {
+		return 0, io.EOF
+	}
+
+	n := 0
+	for n < len(p) && off < r.size {
+		data, err := r.block(off / r.BlockSize)
+		if err != nil {
+			return n, err
+		}
+
+		c := copy(p[n:], data[off%r.BlockSize:])
+		n += c
+		off += int64(c)
+	}
+
+	if n < len(p) {
+		return n, io.EOF
+	}
+
+	return n, nil
+}
+
+func (r *BucketReaderAt) block(index int64) ([]byte, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if e, ok := r.blocks[index]; ok {
+		r.lru.MoveToFront(e)
+		return e.Value.(*block).data, nil
+	}
+
+	var (
+		off    = index * r.BlockSize
+		length = min(r.BlockSize, r.size-off)
+	)
+
+	rr, err := r.bucket.NewRangeReader(r.ctx, r.key, off, length, nil)
+	if err != nil {
+		return nil, ObserveBucketError(r.bucket.Driver, "read", err)
+	}
+	defer func() {
+		_ = rr.Close()
+	}()
+
+	data := make([]byte, length)
+	if _, err := io.ReadFull(rr, data); err != nil {
+		return nil, ObserveBucketError(r.bucket.Driver, "read", err)
+	}
+
+	r.blocks[index] = r.lru.PushFront(&block{index: index, data: data})
+
+	for r.MaxBlocks > 0 && r.lru.Len() > r.MaxBlocks {
+		e := r.lru.Back()
+		r.lru.Remove(e)
+		delete(r.blocks, e.Value.(*block).index)
+	}
+
+	return data, nil
+}
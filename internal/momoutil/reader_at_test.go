@@ -0,0 +1,119 @@
+package momoutil_test
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"crypto/rand"
+	"io"
+	"testing"
+
+	momov1alpha1 "github.com/frantjc/momo/api/v1alpha1"
+	"github.com/frantjc/momo/internal/momoutil"
+	_ "gocloud.dev/blob/memblob"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestBucketReaderAt(t *testing.T) {
+	scheme, err := momoutil.NewScheme(corev1.AddToScheme, momov1alpha1.AddToScheme)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var (
+		ctx    = context.Background()
+		bucket = &momov1alpha1.Bucket{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "reader-at"},
+			Spec:       momov1alpha1.BucketSpec{URL: "mem://reader-at"},
+		}
+		cli     = fake.NewClientBuilder().WithScheme(scheme).WithObjects(bucket).Build()
+		buckets = &momoutil.BucketManager{}
+		buf     = new(bytes.Buffer)
+		zw      = zip.NewWriter(buf)
+		entries = map[string][]byte{
+			"Payload/Momo.app/Info.plist": []byte(t.Name()),
+			"Payload/Momo.app/big":        make([]byte, 3*momoutil.DefaultBlockSize+7),
+		}
+	)
+	defer func() {
+		_ = buckets.Close()
+	}()
+
+	if _, err := rand.Read(entries["Payload/Momo.app/big"]); err != nil {
+		t.Fatal(err)
+	}
+
+	for name, data := range entries {
+		w, err := zw.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Store})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := w.Write(data); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := buckets.OpenBucket(ctx, cli, bucket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = b.Close()
+	}()
+
+	if err := b.WriteAll(ctx, "app.ipa", buf.Bytes(), nil); err != nil {
+		t.Fatal(err)
+	}
+
+	ra, err := momoutil.NewBucketReaderAt(ctx, b, "app.ipa")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Make sure that blocks get evicted and reread.
+	ra.MaxBlocks = 2
+
+	if ra.Size() != int64(buf.Len()) {
+		t.Fatalf("expected size %d, got %d", buf.Len(), ra.Size())
+	}
+
+	p := make([]byte, momoutil.DefaultBlockSize+13)
+	if _, err := ra.ReadAt(p, momoutil.DefaultBlockSize-5); err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(p, buf.Bytes()[momoutil.DefaultBlockSize-5:2*momoutil.DefaultBlockSize+8]) {
+		t.Fatal("read across blocks does not match")
+	}
+
+	if n, err := ra.ReadAt(p, ra.Size()-3); n != 3 || err != io.EOF {
+		t.Fatalf("expected 3 and io.EOF at end of object, got %d and %v", n, err)
+	}
+
+	zr, err := zip.NewReader(ra, ra.Size())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, data := range entries {
+		f, err := zr.Open(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		actual, err := io.ReadAll(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !bytes.Equal(actual, data) {
+			t.Fatalf("%s does not match", name)
+		}
+	}
+}
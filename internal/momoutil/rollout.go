@@ -0,0 +1,47 @@
+package momoutil
+
+import (
+	"hash/fnv"
+
+	momov1alpha1 "github.com/frantjc/momo/api/v1alpha1"
+)
+
+// InRollout reports whether the user with the given ID is among the percentage
+// of users that version is rolled out to. A user stays in it as the percentage
+// grows, but which users are in it differs between versions.
+func InRollout(id, version string, percentage int) bool {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(version))
+	_, _ = h.Write([]byte{0})
+	_, _ = h.Write([]byte(id))
+
+	return int(h.Sum32()%100) < percentage
+}
+
+// RolloutVersion returns the version that mobileApp serves as the latest to the user
+// with the given ID on the given platform, or an empty string if mobileApp has no
+// rollout in progress on that platform.
+func RolloutVersion(mobileApp *momov1alpha1.MobileApp, id, platform string) string {
+	rollout := mobileApp.Status.Rollout
+	if rollout == nil || rollout.Percentage >= 100 {
+		return ""
+	}
+
+	var previous string
+	switch platform {
+	case PlatformAndroid:
+		previous = rollout.PreviousVersions.Android
+	case PlatformIOS:
+		previous = rollout.PreviousVersions.IOS
+	}
+
+	if previous == "" {
+		return ""
+	}
+
+	if InRollout(id, rollout.Version, rollout.Percentage) {
+		return rollout.Version
+	}
+
+	return previous
+}
@@ -0,0 +1,72 @@
+package momoutil_test
+
+import (
+	"fmt"
+	"testing"
+
+	momov1alpha1 "github.com/frantjc/momo/api/v1alpha1"
+	"github.com/frantjc/momo/internal/momoutil"
+)
+
+func TestInRollout(t *testing.T) {
+	var (
+		in    = 0
+		total = 10000
+	)
+
+	for i := range total {
+		id := fmt.Sprint(i)
+
+		if momoutil.InRollout(id, "v1.0.0", 10) {
+			in++
+
+			if !momoutil.InRollout(id, "v1.0.0", 50) {
+				t.Fatalf("%s left the rollout as it grew", id)
+			}
+		}
+
+		if momoutil.InRollout(id, "v1.0.0", 0) {
+			t.Fatalf("%s is in a rollout at 0%%", id)
+		}
+
+		if !momoutil.InRollout(id, "v1.0.0", 100) {
+			t.Fatalf("%s is not in a rollout at 100%%", id)
+		}
+	}
+
+	if in < total/20 || in > total*3/20 {
+		t.Fatalf("expected about 10%% of users in the rollout, got %d of %d", in, total)
+	}
+}
+
+func TestRolloutVersion(t *testing.T) {
+	mobileApp := &momov1alpha1.MobileApp{
+		Status: momov1alpha1.MobileAppStatus{
+			Rollout: &momov1alpha1.MobileAppStatusRollout{
+				Version:          "v2.0.0",
+				PreviousVersions: momov1alpha1.MobileAppStatusRolloutPreviousVersions{Android: "v1.0.0"},
+				Percentage:       0,
+			},
+		},
+	}
+
+	if version := momoutil.RolloutVersion(mobileApp, "id", momoutil.PlatformAndroid); version != "v1.0.0" {
+		t.Fatalf("expected v1.0.0 at 0%%, got %q", version)
+	}
+
+	if version := momoutil.RolloutVersion(mobileApp, "id", momoutil.PlatformIOS); version != "" {
+		t.Fatalf("expected no rollout in progress on a platform without a previous version, got %q", version)
+	}
+
+	mobileApp.Status.Rollout.Percentage = 100
+
+	if version := momoutil.RolloutVersion(mobileApp, "id", momoutil.PlatformAndroid); version != "" {
+		t.Fatalf("expected no rollout in progress at 100%%, got %q", version)
+	}
+
+	mobileApp.Status.Rollout = nil
+
+	if version := momoutil.RolloutVersion(mobileApp, "id", momoutil.PlatformAndroid); version != "" {
+		t.Fatalf("expected no rollout in progress, got %q", version)
+	}
+}
@@ -0,0 +1,74 @@
+package momoutil
+
+import (
+	"context"
+	"strings"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// AnnotationTraceContextPrefix prefixes the W3C Trace Context fields,
+	// e.g. traceparent, that are stored on an APK or IPA when it is
+	// uploaded so that the reconcile that unpacks it joins the same trace.
+	AnnotationTraceContextPrefix = "trace.momo.frantj.cc/"
+)
+
+var (
+	Tracer = otel.Tracer("github.com/frantjc/momo")
+)
+
+// EndSpan records err on span, if any, and ends it.
+func EndSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+
+	span.End()
+}
+
+// annotationCarrier adapts an object's annotations to a propagation.TextMapCarrier.
+type annotationCarrier struct {
+	client.Object
+}
+
+var _ propagation.TextMapCarrier = annotationCarrier{}
+
+func (c annotationCarrier) Get(key string) string {
+	return c.GetAnnotations()[AnnotationTraceContextPrefix+key]
+}
+
+func (c annotationCarrier) Set(key, value string) {
+	annotations := c.GetAnnotations()
+	if annotations == nil {
+		annotations = map[string]string{}
+	}
+	annotations[AnnotationTraceContextPrefix+key] = value
+	c.SetAnnotations(annotations)
+}
+
+func (c annotationCarrier) Keys() []string {
+	keys := []string{}
+	for key := range c.GetAnnotations() {
+		if k, ok := strings.CutPrefix(key, AnnotationTraceContextPrefix); ok {
+			keys = append(keys, k)
+		}
+	}
+	return keys
+}
+
+// InjectTraceContext stores the trace context from ctx in obj's annotations.
+func InjectTraceContext(ctx context.Context, obj client.Object) {
+	otel.GetTextMapPropagator().Inject(ctx, annotationCarrier{obj})
+}
+
+// ExtractTraceContext returns a copy of ctx with the trace
+// context that is stored in obj's annotations, if any.
+func ExtractTraceContext(ctx context.Context, obj client.Object) context.Context {
+	return otel.GetTextMapPropagator().Extract(ctx, annotationCarrier{obj})
+}
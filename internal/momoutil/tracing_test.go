@@ -0,0 +1,39 @@
+package momoutil_test
+
+import (
+	"context"
+	"testing"
+
+	momov1alpha1 "github.com/frantjc/momo/api/v1alpha1"
+	"github.com/frantjc/momo/internal/momoutil"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestTraceContext(t *testing.T) {
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+
+	var (
+		ctx, span = sdktrace.NewTracerProvider().Tracer("test").Start(context.Background(), "upload")
+		apk       = &momov1alpha1.APK{}
+	)
+	defer span.End()
+
+	momoutil.InjectTraceContext(ctx, apk)
+
+	if _, ok := apk.Annotations[momoutil.AnnotationTraceContextPrefix+"traceparent"]; !ok {
+		t.Fatalf("traceparent annotation not set: %v", apk.Annotations)
+	}
+
+	extracted := trace.SpanContextFromContext(momoutil.ExtractTraceContext(context.Background(), apk))
+
+	if extracted.TraceID() != span.SpanContext().TraceID() {
+		t.Fatalf("expected trace ID %s, got %s", span.SpanContext().TraceID(), extracted.TraceID())
+	}
+
+	if extracted.SpanID() != span.SpanContext().SpanID() {
+		t.Fatalf("expected span ID %s, got %s", span.SpanContext().SpanID(), extracted.SpanID())
+	}
+}
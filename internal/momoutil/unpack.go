@@ -0,0 +1,214 @@
+package momoutil
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/frantjc/momo/android"
+	"github.com/frantjc/momo/apktool"
+	"github.com/frantjc/momo/ios"
+)
+
+// UnpackResult is what `momo unpack apk` and `momo unpack ipa` output so that a
+// reconciler which offloaded the decoding of an APK or IPA to a Job can read it
+// back. It implements the same methods as the decoders so that it can stand in
+// for one.
+type UnpackResult struct {
+	Error                  string             `json:"error,omitempty"`
+	AndroidManifest        *android.Manifest  `json:"androidManifest,omitempty"`
+	APKToolMetadata        *apktool.Metadata  `json:"apktoolMetadata,omitempty"`
+	CertFingerprintsSHA256 string             `json:"sha256CertFingerprints,omitempty"`
+	AppLabel               string             `json:"label,omitempty"`
+	NativeABIs             []string           `json:"abis,omitempty"`
+	ScreenDensities        []string           `json:"densities,omitempty"`
+	InfoPlist              *ios.Info          `json:"infoPlist,omitempty"`
+	IconFiles              []UnpackResultIcon `json:"icons,omitempty"`
+}
+
+type UnpackResultIcon struct {
+	Name string `json:"name"`
+	Data []byte `json:"data"`
+}
+
+var (
+	errNotUnpacked = errors.New("not in unpack result")
+)
+
+// UnpackAPK decodes everything that the APKReconciler needs from an .apk. If
+// decoding fails, the error is also recorded in the returned UnpackResult.
+func UnpackAPK(ctx context.Context, dec *android.APKDecoder) (*UnpackResult, error) {
+	var (
+		result = &UnpackResult{}
+		err    error
+	)
+
+	if result.CertFingerprintsSHA256, err = dec.SHA256CertFingerprints(ctx); err != nil {
+		return result.withError(fmt.Errorf("sha256 cert fingerprints: %w", err))
+	}
+
+	if result.APKToolMetadata, err = dec.Metadata(ctx); err != nil {
+		return result.withError(fmt.Errorf("metadata: %w", err))
+	}
+
+	if result.AndroidManifest, err = dec.Manifest(ctx); err != nil {
+		return result.withError(fmt.Errorf("manifest: %w", err))
+	}
+
+	// The label is optional, so failing to resolve it is not an error.
+	result.AppLabel, _ = dec.Label(ctx)
+
+	if result.NativeABIs, err = dec.ABIs(ctx); err != nil {
+		return result.withError(fmt.Errorf("abis: %w", err))
+	}
+
+	if result.ScreenDensities, err = dec.Densities(ctx); err != nil {
+		return result.withError(fmt.Errorf("densities: %w", err))
+	}
+
+	icons, err := dec.Icons(ctx)
+	if err != nil {
+		return result.withError(fmt.Errorf("icons: %w", err))
+	}
+
+	if result.IconFiles, err = iconFilesFromTar(icons); err != nil {
+		return result.withError(fmt.Errorf("icons: %w", err))
+	}
+
+	return result, nil
+}
+
+// UnpackIPA decodes everything that the IPAReconciler needs from an .ipa. If
+// decoding fails, the error is also recorded in the returned UnpackResult.
+func UnpackIPA(ctx context.Context, dec *ios.IPADecoder) (*UnpackResult, error) {
+	var (
+		result = &UnpackResult{}
+		err    error
+	)
+
+	if result.InfoPlist, err = dec.Info(ctx); err != nil {
+		return result.withError(fmt.Errorf("info: %w", err))
+	}
+
+	icons, err := dec.Icons(ctx)
+	if err != nil {
+		return result.withError(fmt.Errorf("icons: %w", err))
+	}
+
+	if result.IconFiles, err = iconFilesFromTar(icons); err != nil {
+		return result.withError(fmt.Errorf("icons: %w", err))
+	}
+
+	return result, nil
+}
+
+func (r *UnpackResult) withError(err error) (*UnpackResult, error) {
+	r.Error = err.Error()
+	return r, err
+}
+
+func iconFilesFromTar(r io.Reader) ([]UnpackResultIcon, error) {
+	var (
+		tr    = tar.NewReader(r)
+		icons = []UnpackResultIcon{}
+	)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+
+		data, err := io.ReadAll(tr)
+		if err != nil {
+			return nil, err
+		}
+
+		icons = append(icons, UnpackResultIcon{Name: hdr.Name, Data: data})
+	}
+
+	return icons, nil
+}
+
+func (r *UnpackResult) SHA256CertFingerprints(_ context.Context) (string, error) {
+	if r.CertFingerprintsSHA256 == "" {
+		return "", errNotUnpacked
+	}
+
+	return r.CertFingerprintsSHA256, nil
+}
+
+func (r *UnpackResult) Metadata(_ context.Context) (*apktool.Metadata, error) {
+	if r.APKToolMetadata == nil {
+		return nil, errNotUnpacked
+	}
+
+	return r.APKToolMetadata, nil
+}
+
+func (r *UnpackResult) Manifest(_ context.Context) (*android.Manifest, error) {
+	if r.AndroidManifest == nil {
+		return nil, errNotUnpacked
+	}
+
+	return r.AndroidManifest, nil
+}
+
+func (r *UnpackResult) Label(_ context.Context) (string, error) {
+	return r.AppLabel, nil
+}
+
+func (r *UnpackResult) ABIs(_ context.Context) ([]string, error) {
+	return r.NativeABIs, nil
+}
+
+func (r *UnpackResult) Densities(_ context.Context) ([]string, error) {
+	return r.ScreenDensities, nil
+}
+
+func (r *UnpackResult) Info(_ context.Context) (*ios.Info, error) {
+	if r.InfoPlist == nil {
+		return nil, errNotUnpacked
+	}
+
+	return r.InfoPlist, nil
+}
+
+// Icons returns a tarball of the icons, like the decoders do.
+func (r *UnpackResult) Icons(_ context.Context) (io.Reader, error) {
+	var (
+		buf = new(bytes.Buffer)
+		tw  = tar.NewWriter(buf)
+	)
+
+	for _, icon := range r.IconFiles {
+		if err := tw.WriteHeader(&tar.Header{
+			Name:     icon.Name,
+			Mode:     0o644,
+			Size:     int64(len(icon.Data)),
+			ModTime:  time.Now(),
+			Typeflag: tar.TypeReg,
+		}); err != nil {
+			return nil, err
+		}
+
+		if _, err := tw.Write(icon.Data); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return nil, err
+	}
+
+	return buf, nil
+}
+
+func (r *UnpackResult) Close() error {
+	return nil
+}
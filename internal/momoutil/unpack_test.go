@@ -0,0 +1,72 @@
+package momoutil_test
+
+import (
+	"archive/tar"
+	"context"
+	"encoding/json"
+	"io"
+	"testing"
+
+	"github.com/frantjc/momo/internal/momoutil"
+)
+
+func TestUnpackResult(t *testing.T) {
+	var (
+		ctx    = context.Background()
+		result = &momoutil.UnpackResult{
+			CertFingerprintsSHA256: "AB:CD",
+			IconFiles: []momoutil.UnpackResultIcon{
+				{Name: "ic_launcher.png", Data: []byte("png")},
+			},
+		}
+	)
+
+	b, err := json.Marshal(result)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	unpacked := &momoutil.UnpackResult{}
+	if err := json.Unmarshal(b, unpacked); err != nil {
+		t.Fatal(err)
+	}
+
+	if fingerprints, err := unpacked.SHA256CertFingerprints(ctx); err != nil {
+		t.Fatal(err)
+	} else if fingerprints != result.CertFingerprintsSHA256 {
+		t.Errorf("expected %s, got %s", result.CertFingerprintsSHA256, fingerprints)
+	}
+
+	if _, err := unpacked.Manifest(ctx); err == nil {
+		t.Error("expected error for an AndroidManifest.xml that was not unpacked")
+	}
+
+	icons, err := unpacked.Icons(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tr := tar.NewReader(icons)
+
+	hdr, err := tr.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if hdr.Name != "ic_launcher.png" {
+		t.Errorf("expected ic_launcher.png, got %s", hdr.Name)
+	}
+
+	data, err := io.ReadAll(tr)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(data) != "png" {
+		t.Errorf("expected png, got %s", data)
+	}
+
+	if _, err := tr.Next(); err != io.EOF {
+		t.Errorf("expected one icon, got %v", err)
+	}
+}
@@ -0,0 +1,93 @@
+package scan
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+)
+
+const (
+	// clamdChunkSize must be less than clamd's StreamMaxLength.
+	clamdChunkSize = 64 * 1024
+)
+
+// Clamd scans with a clamd over its socket using the INSTREAM command.
+type Clamd struct {
+	// Network is "tcp" or "unix".
+	Network string
+	// Address is host:port for "tcp" or a path for "unix".
+	Address string
+}
+
+// Scan implements Scanner.
+func (c *Clamd) Scan(ctx context.Context, _ string, r io.Reader) (*Verdict, error) {
+	conn, err := (&net.Dialer{}).DialContext(ctx, c.Network, c.Address)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = conn.Close()
+	}()
+
+	if deadline, ok := ctx.Deadline(); ok {
+		if err := conn.SetDeadline(deadline); err != nil {
+			return nil, err
+		}
+	}
+
+	if _, err := conn.Write([]byte("zINSTREAM\x00")); err != nil {
+		return nil, err
+	}
+
+	var (
+		buf  = make([]byte, clamdChunkSize)
+		size = make([]byte, 4)
+	)
+	for {
+		n, err := r.Read(buf)
+		if n > 0 {
+			binary.BigEndian.PutUint32(size, uint32(n))
+			if _, err := conn.Write(append(size, buf[:n]...)); err != nil {
+				return nil, err
+			}
+		}
+
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+	}
+
+	// A zero-length chunk ends the stream.
+	if _, err := conn.Write([]byte{0, 0, 0, 0}); err != nil {
+		return nil, err
+	}
+
+	reply, err := bufio.NewReader(conn).ReadBytes(0)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+
+	return parseClamdReply(string(bytes.TrimRight(reply, "\x00\n")))
+}
+
+// parseClamdReply parses replies such as "stream: OK",
+// "stream: Eicar-Signature FOUND" and "INSTREAM size limit exceeded. ERROR".
+func parseClamdReply(reply string) (*Verdict, error) {
+	_, result, _ := strings.Cut(reply, ": ")
+
+	switch {
+	case result == "OK":
+		return &Verdict{Clean: true}, nil
+	case strings.HasSuffix(result, " FOUND"):
+		return &Verdict{Findings: []string{strings.TrimSuffix(result, " FOUND")}}, nil
+	}
+
+	return nil, fmt.Errorf("clamd: %s", reply)
+}
@@ -0,0 +1,50 @@
+package scan
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"strings"
+)
+
+// Exec scans by running a command with the contents to scan on its stdin, following
+// clamscan's convention: it exits 0 if they are clean or 1 if something is found,
+// in which case each non-empty line of its stdout is a finding.
+type Exec struct {
+	Path string
+	Args []string
+}
+
+// Scan implements Scanner.
+func (e *Exec) Scan(ctx context.Context, _ string, r io.Reader) (*Verdict, error) {
+	var (
+		stdout = new(bytes.Buffer)
+		stderr = new(bytes.Buffer)
+		//nolint:gosec
+		cmd = exec.CommandContext(ctx, e.Path, e.Args...)
+	)
+	cmd.Stdin = r
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+
+	if err := cmd.Run(); err != nil {
+		xerr := &exec.ExitError{}
+		if errors.As(err, &xerr) && xerr.ExitCode() == 1 {
+			findings := []string{}
+			for _, line := range strings.Split(stdout.String(), "\n") {
+				if line = strings.TrimSpace(line); line != "" {
+					findings = append(findings, line)
+				}
+			}
+
+			return &Verdict{Findings: findings}, nil
+		}
+
+		return nil, fmt.Errorf("%s: %w: %s", e.Path, err, strings.TrimSpace(stderr.String()))
+	}
+
+	return &Verdict{Clean: true}, nil
+}
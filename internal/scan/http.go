@@ -0,0 +1,55 @@
+package scan
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+const (
+	// HeaderName is set to the name of the object that an HTTP is scanning.
+	HeaderName = "X-Momo-Name"
+)
+
+// HTTP scans by POSTing the contents to scan to URL, which
+// is expected to respond with a Verdict encoded as JSON.
+type HTTP struct {
+	URL    string
+	Client *http.Client
+}
+
+// Scan implements Scanner.
+func (h *HTTP) Scan(ctx context.Context, name string, r io.Reader) (*Verdict, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, h.URL, r)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/octet-stream")
+	req.Header.Set(HeaderName, name)
+
+	cli := h.Client
+	if cli == nil {
+		cli = http.DefaultClient
+	}
+
+	res, err := cli.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = res.Body.Close()
+	}()
+
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("scan: %s", res.Status)
+	}
+
+	verdict := &Verdict{}
+	if err := json.NewDecoder(res.Body).Decode(verdict); err != nil {
+		return nil, err
+	}
+
+	return verdict, nil
+}
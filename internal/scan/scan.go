@@ -0,0 +1,47 @@
+// Package scan scans APKs and IPAs for malware before they become installable.
+package scan
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+)
+
+// Verdict is the result of a scan.
+type Verdict struct {
+	// Clean is whether nothing was found.
+	Clean bool `json:"clean"`
+	// Findings describes what was found, e.g. the names of signatures that matched.
+	Findings []string `json:"findings,omitempty"`
+}
+
+// Scanner scans the contents of r, which is the object with the given name.
+type Scanner interface {
+	Scan(ctx context.Context, name string, r io.Reader) (*Verdict, error)
+}
+
+// New returns a Scanner for the given URL:
+//
+//   - tcp://host:port or unix:///path/to/clamd.sock for a Clamd.
+//   - exec:///path/to/bin?arg=-&arg=... for an Exec.
+//   - http://... or https://... for an HTTP.
+func New(rawURL string) (Scanner, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+
+	switch u.Scheme {
+	case "tcp", "clamd":
+		return &Clamd{Network: "tcp", Address: u.Host}, nil
+	case "unix":
+		return &Clamd{Network: "unix", Address: u.Path}, nil
+	case "exec":
+		return &Exec{Path: u.Path, Args: u.Query()["arg"]}, nil
+	case "http", "https":
+		return &HTTP{URL: rawURL}, nil
+	}
+
+	return nil, fmt.Errorf("unsupported scanner scheme %q", u.Scheme)
+}
@@ -0,0 +1,152 @@
+package scan_test
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/frantjc/momo/internal/scan"
+)
+
+const (
+	eicar = `X5O!P%@AP[4\PZX54(P^)7CC)7}$EICAR-STANDARD-ANTIVIRUS-TEST-FILE!$H+H*`
+)
+
+// clamd is a stand-in for clamd that finds the EICAR test string.
+func clamd(t *testing.T) string {
+	t.Helper()
+
+	address := filepath.Join(t.TempDir(), "clamd.sock")
+
+	l, err := net.Listen("unix", address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_ = l.Close()
+	})
+
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+
+			go func() {
+				defer conn.Close()
+
+				r := bufio.NewReader(conn)
+				if command, err := r.ReadString(0); err != nil || command != "zINSTREAM\x00" {
+					_, _ = conn.Write([]byte("UNKNOWN COMMAND\x00"))
+					return
+				}
+
+				var (
+					stream = new(bytes.Buffer)
+					size   = make([]byte, 4)
+				)
+				for {
+					if _, err := io.ReadFull(r, size); err != nil {
+						return
+					}
+
+					n := binary.BigEndian.Uint32(size)
+					if n == 0 {
+						break
+					}
+
+					if _, err := io.CopyN(stream, r, int64(n)); err != nil {
+						return
+					}
+				}
+
+				if strings.Contains(stream.String(), eicar) {
+					_, _ = conn.Write([]byte("stream: Eicar-Test-Signature FOUND\x00"))
+				} else {
+					_, _ = conn.Write([]byte("stream: OK\x00"))
+				}
+			}()
+		}
+	}()
+
+	return address
+}
+
+func testScanner(t *testing.T, scanner scan.Scanner) {
+	t.Helper()
+
+	ctx := context.Background()
+
+	verdict, err := scanner.Scan(ctx, "clean.apk", strings.NewReader("clean"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !verdict.Clean {
+		t.Errorf("expected clean, got %v", verdict.Findings)
+	}
+
+	verdict, err = scanner.Scan(ctx, "infected.apk", strings.NewReader("infected "+eicar))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if verdict.Clean {
+		t.Error("expected infected")
+	}
+
+	if len(verdict.Findings) == 0 {
+		t.Error("expected findings")
+	}
+}
+
+func TestClamd(t *testing.T) {
+	scanner, err := scan.New("unix://" + clamd(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testScanner(t, scanner)
+}
+
+func TestExec(t *testing.T) {
+	testScanner(t, &scan.Exec{
+		Path: "/bin/sh",
+		Args: []string{"-c", `if grep -q EICAR; then echo Eicar-Test-Signature; exit 1; fi`},
+	})
+}
+
+func TestHTTP(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, err := io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		verdict := &scan.Verdict{Clean: true}
+		if bytes.Contains(b, []byte(eicar)) {
+			verdict = &scan.Verdict{Findings: []string{r.Header.Get(scan.HeaderName) + ": Eicar-Test-Signature"}}
+		}
+
+		_ = json.NewEncoder(w).Encode(verdict)
+	}))
+	t.Cleanup(srv.Close)
+
+	scanner, err := scan.New(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testScanner(t, scanner)
+}
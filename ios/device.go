@@ -0,0 +1,122 @@
+package ios
+
+import (
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+const (
+	DeviceFamilyIPhone = "iphone"
+	DeviceFamilyIPad   = "ipad"
+)
+
+var (
+	// deviceFamilies maps the values of UIDeviceFamily to names.
+	deviceFamilies = map[int]string{
+		1: DeviceFamilyIPhone,
+		2: DeviceFamilyIPad,
+		3: "tv",
+		4: "watch",
+		6: "mac",
+		7: "vision",
+	}
+
+	userAgentRegexp = regexp.MustCompile(`\((iPhone|iPod|iPad)[^)]*? OS (\d+(?:_\d+)*)`)
+)
+
+// DeviceFamilies returns the names of the device families in uiDeviceFamily.
+// An app without a UIDeviceFamily only supports iPhone.
+func DeviceFamilies(uiDeviceFamily []int) []string {
+	if len(uiDeviceFamily) == 0 {
+		return []string{DeviceFamilyIPhone}
+	}
+
+	families := []string{}
+	for _, family := range uiDeviceFamily {
+		if name, ok := deviceFamilies[family]; ok {
+			families = append(families, name)
+		} else {
+			families = append(families, strconv.Itoa(family))
+		}
+	}
+
+	return families
+}
+
+// Device is an iOS device as described by its User-Agent.
+type Device struct {
+	// Family is DeviceFamilyIPhone or DeviceFamilyIPad.
+	Family string
+	// OSVersion is the version of iOS or iPadOS, e.g. 17.2.
+	OSVersion string
+}
+
+// ParseUserAgent returns the Device that sent userAgent, or nil if it cannot tell, such as
+// for iPads that request desktop websites, which they do by default since iPadOS 13.
+func ParseUserAgent(userAgent string) *Device {
+	matches := userAgentRegexp.FindStringSubmatch(userAgent)
+	if matches == nil {
+		return nil
+	}
+
+	device := &Device{
+		Family:    DeviceFamilyIPhone,
+		OSVersion: strings.ReplaceAll(matches[2], "_", "."),
+	}
+
+	if matches[1] == "iPad" {
+		device.Family = DeviceFamilyIPad
+	}
+
+	return device
+}
+
+// Incompatibilities returns the reasons that an app with the given Info.plist values, as
+// decoded by DeviceFamilies, cannot be installed on the device. It returns none if it can.
+func (d *Device) Incompatibilities(platformName, minimumOSVersion string, deviceFamilies []string) []string {
+	reasons := []string{}
+
+	if strings.EqualFold(platformName, "iphonesimulator") {
+		reasons = append(reasons, "This build is for the iOS Simulator, not for devices.")
+	}
+
+	if minimumOSVersion != "" && CompareVersions(d.OSVersion, minimumOSVersion) < 0 {
+		reasons = append(reasons, "This build requires iOS "+minimumOSVersion+" or later, but this device has iOS "+d.OSVersion+".")
+	}
+
+	// iPads can run apps that only support iPhone, but not the other way around.
+	if len(deviceFamilies) > 0 && !slices.Contains(deviceFamilies, d.Family) && (d.Family != DeviceFamilyIPad || !slices.Contains(deviceFamilies, DeviceFamilyIPhone)) {
+		reasons = append(reasons, "This build does not support "+map[string]string{DeviceFamilyIPhone: "iPhone", DeviceFamilyIPad: "iPad"}[d.Family]+".")
+	}
+
+	return reasons
+}
+
+// CompareVersions compares dot-separated numeric versions such as 17.2 and 15.0.1, returning
+// -1, 0 or 1 if a is less than, equal to or greater than b. Missing parts count as 0.
+func CompareVersions(a, b string) int {
+	var (
+		as = strings.Split(a, ".")
+		bs = strings.Split(b, ".")
+	)
+
+	for i := range max(len(as), len(bs)) {
+		var x, y int
+		if i < len(as) {
+			x, _ = strconv.Atoi(as[i])
+		}
+		if i < len(bs) {
+			y, _ = strconv.Atoi(bs[i])
+		}
+
+		if x < y {
+			return -1
+		} else if x > y {
+			return 1
+		}
+	}
+
+	return 0
+}
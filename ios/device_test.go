@@ -0,0 +1,65 @@
+package ios_test
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/frantjc/momo/ios"
+	"howett.net/plist"
+)
+
+func TestParseUserAgent(t *testing.T) {
+	for userAgent, expected := range map[string]*ios.Device{
+		"Mozilla/5.0 (iPhone; CPU iPhone OS 17_2 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.2 Mobile/15E148 Safari/604.1": {Family: ios.DeviceFamilyIPhone, OSVersion: "17.2"},
+		"Mozilla/5.0 (iPad; CPU OS 16_6_1 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/16.6 Mobile/15E148 Safari/604.1":        {Family: ios.DeviceFamilyIPad, OSVersion: "16.6.1"},
+		"Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.2 Safari/605.1.15":                   nil,
+	} {
+		device := ios.ParseUserAgent(userAgent)
+		if expected == nil {
+			if device != nil {
+				t.Errorf("expected no device for %s, got %v", userAgent, device)
+			}
+		} else if device == nil || *device != *expected {
+			t.Errorf("expected %v for %s, got %v", expected, userAgent, device)
+		}
+	}
+}
+
+func TestIncompatibilities(t *testing.T) {
+	var (
+		iPhone = &ios.Device{Family: ios.DeviceFamilyIPhone, OSVersion: "16.4"}
+		iPad   = &ios.Device{Family: ios.DeviceFamilyIPad, OSVersion: "17.0"}
+	)
+
+	if reasons := iPhone.Incompatibilities("iphoneos", "15.0", ios.DeviceFamilies([]int{1, 2})); len(reasons) > 0 {
+		t.Errorf("expected compatible, got %v", reasons)
+	}
+
+	if reasons := iPad.Incompatibilities("iphoneos", "", ios.DeviceFamilies(nil)); len(reasons) > 0 {
+		t.Errorf("expected iPads to run iPhone apps, got %v", reasons)
+	}
+
+	if reasons := iPhone.Incompatibilities("iphoneos", "16.10", ios.DeviceFamilies([]int{2})); len(reasons) != 2 {
+		t.Errorf("expected 2 reasons, got %v", reasons)
+	}
+
+	if reasons := iPhone.Incompatibilities("iphonesimulator", "", nil); len(reasons) != 1 {
+		t.Errorf("expected 1 reason, got %v", reasons)
+	}
+}
+
+func TestDeviceCapabilities(t *testing.T) {
+	for _, data := range []string{
+		`<plist version="1.0"><dict><key>UIRequiredDeviceCapabilities</key><array><string>arm64</string><string>nfc</string></array></dict></plist>`,
+		`<plist version="1.0"><dict><key>UIRequiredDeviceCapabilities</key><dict><key>arm64</key><true/><key>nfc</key><true/><key>telephony</key><false/></dict></dict></plist>`,
+	} {
+		info := &ios.Info{}
+		if _, err := plist.Unmarshal([]byte(data), info); err != nil {
+			t.Fatal(err)
+		}
+
+		if !slices.Equal(info.UIRequiredDeviceCapabilities, []string{"arm64", "nfc"}) {
+			t.Errorf("expected arm64 and nfc, got %v", info.UIRequiredDeviceCapabilities)
+		}
+	}
+}
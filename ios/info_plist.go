@@ -1,6 +1,8 @@
 package ios
 
 import (
+	"slices"
+
 	// Document what package can be used to unmarshal
 	// an info.plist into the struct below.
 	_ "howett.net/plist"
@@ -17,11 +19,45 @@ type Info struct {
 			CFBundleIconFiles []string `plist:"CFBundleIconFiles"`
 		} `plist:"CFBundlePrimaryIcon"`
 	} `plist:"CFBundleIcons"`
-	CFBundleIdentifier            string   `plist:"CFBundleIdentifier"`
-	CFBundleInfoDictionaryVersion string   `plist:"CFBundleInfoDictionaryVersion"`
-	CFBundleName                  string   `plist:"CFBundleName"`
-	CFBundlePackageType           string   `plist:"CFBundlePackageType"`
-	CFBundleShortVersionString    string   `plist:"CFBundleShortVersionString"`
-	CFBundleSupportedPlatforms    []string `plist:"CFBundleSupportedPlatforms"`
-	CFBundleVersion               string   `plist:"CFBundleVersion"`
+	CFBundleIdentifier            string             `plist:"CFBundleIdentifier"`
+	CFBundleInfoDictionaryVersion string             `plist:"CFBundleInfoDictionaryVersion"`
+	CFBundleName                  string             `plist:"CFBundleName"`
+	CFBundlePackageType           string             `plist:"CFBundlePackageType"`
+	CFBundleShortVersionString    string             `plist:"CFBundleShortVersionString"`
+	CFBundleSupportedPlatforms    []string           `plist:"CFBundleSupportedPlatforms"`
+	CFBundleVersion               string             `plist:"CFBundleVersion"`
+	DTPlatformName                string             `plist:"DTPlatformName"`
+	MinimumOSVersion              string             `plist:"MinimumOSVersion"`
+	UIDeviceFamily                []int              `plist:"UIDeviceFamily"`
+	UIRequiredDeviceCapabilities  DeviceCapabilities `plist:"UIRequiredDeviceCapabilities"`
+}
+
+// DeviceCapabilities are the UIRequiredDeviceCapabilities of an app. In an Info.plist, they are
+// either an array of the capabilities that are required or a dictionary of capabilities to
+// whether they are required or must be absent. Only the required ones are kept.
+type DeviceCapabilities []string
+
+// UnmarshalPlist implements plist.Unmarshaler.
+func (c *DeviceCapabilities) UnmarshalPlist(unmarshal func(any) error) error {
+	capabilities := []string{}
+	if err := unmarshal(&capabilities); err == nil {
+		*c = capabilities
+		return nil
+	}
+
+	required := map[string]bool{}
+	if err := unmarshal(&required); err != nil {
+		return err
+	}
+
+	capabilities = []string{}
+	for capability, ok := range required {
+		if ok {
+			capabilities = append(capabilities, capability)
+		}
+	}
+	slices.Sort(capabilities)
+	*c = capabilities
+
+	return nil
 }
@@ -9,6 +9,7 @@ import (
 	"io"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 
 	xslice "github.com/frantjc/x/slice"
@@ -22,17 +23,39 @@ const (
 type IPADecoder struct {
 	Name string
 
-	info    *Info
-	infoDir string
+	readerAt io.ReaderAt
+	size     int64
+	info     *Info
+	infoDir  string
 }
 
-func NewIPADecoder(name string) *IPADecoder {
+type IPADecoderOpt func(*IPADecoder)
+
+// WithReaderAt makes the IPADecoder read the .ipa from r, which is size bytes
+// long, instead of from the file Name, e.g. to read only the entries that it
+// needs from a remote .ipa. Close does not remove anything in this case.
+func WithReaderAt(r io.ReaderAt, size int64) IPADecoderOpt {
+	return func(i *IPADecoder) {
+		i.readerAt = r
+		i.size = size
+	}
+}
+
+func NewIPADecoder(name string, opts ...IPADecoderOpt) *IPADecoder {
 	id := &IPADecoder{Name: name}
 
+	for _, opt := range opts {
+		opt(id)
+	}
+
 	return id
 }
 
 func (i *IPADecoder) zipReader() (*zip.Reader, error) {
+	if i.readerAt != nil {
+		return zip.NewReader(i.readerAt, i.size)
+	}
+
 	ipa, err := os.Open(i.Name)
 	if err != nil {
 		return nil, err
@@ -72,11 +95,16 @@ func (i *IPADecoder) infoFromZipReader(zr *zip.Reader) (*Info, error) {
 	return nil, fmt.Errorf("info not found in .ipa")
 }
 
-func (i *IPADecoder) Info(_ context.Context) (*Info, error) {
+func (i *IPADecoder) Info(ctx context.Context) (_ *Info, err error) {
 	if i.info != nil {
 		return i.info, nil
 	}
 
+	_, span := tracer.Start(ctx, "IPADecoder.Info")
+	defer func() {
+		endSpan(span, err)
+	}()
+
 	zr, err := i.zipReader()
 	if err != nil {
 		return nil, err
@@ -85,16 +113,25 @@ func (i *IPADecoder) Info(_ context.Context) (*Info, error) {
 	return i.infoFromZipReader(zr)
 }
 
-func (i *IPADecoder) Icons(_ context.Context) (io.Reader, error) {
+func (i *IPADecoder) Icons(ctx context.Context) (io.Reader, error) {
+	_, span := tracer.Start(ctx, "IPADecoder.Icons")
+
 	zr, err := i.zipReader()
 	if err != nil {
+		endSpan(span, err)
 		return nil, err
 	}
 
-	if _, err := i.infoFromZipReader(zr); err != nil {
+	info, err := i.infoFromZipReader(zr)
+	if err != nil {
+		endSpan(span, err)
 		return nil, err
 	}
 
+	// Only the declared icons are read, if any are, instead of every
+	// image in the .ipa, in case it is being read remotely.
+	iconFiles := slices.Concat(info.CFBundleIcons.CFBundlePrimaryIcon.CFBundleIconFiles, info.CFBundleIconFiles)
+
 	var (
 		pr, pw = io.Pipe()
 		tw     = tar.NewWriter(pw)
@@ -103,7 +140,7 @@ func (i *IPADecoder) Icons(_ context.Context) (io.Reader, error) {
 	go func() {
 		err := func() error {
 			for _, f := range zr.File {
-				if xslice.Includes([]string{".png", ".jpg", ".jpeg"}, strings.ToLower(filepath.Ext(f.Name))) {
+				if xslice.Includes([]string{".png", ".jpg", ".jpeg"}, strings.ToLower(filepath.Ext(f.Name))) && isIconFile(iconFiles, f.Name) {
 					fsf, err := zr.Open(f.Name)
 					if err != nil {
 						return err
@@ -136,13 +173,34 @@ func (i *IPADecoder) Icons(_ context.Context) (io.Reader, error) {
 		}()
 		_ = tw.Close()
 		_ = pw.CloseWithError(err)
+		endSpan(span, err)
 	}()
 
 	return pr, nil
 }
 
+// isIconFile reports whether name is one of iconFiles, which are declared without
+// their scale and extension, e.g. AppIcon60x60 for AppIcon60x60@2x.png. If none
+// are declared, then every image is considered to be one.
+func isIconFile(iconFiles []string, name string) bool {
+	if len(iconFiles) == 0 {
+		return true
+	}
+
+	base := filepath.Base(name)
+
+	return xslice.Some(iconFiles, func(iconFile string, _ int) bool {
+		return strings.HasPrefix(base, strings.TrimSuffix(iconFile, filepath.Ext(iconFile)))
+	})
+}
+
 func (i *IPADecoder) Close() error {
 	i.info = nil
 	i.infoDir = ""
+
+	if i.readerAt != nil {
+		return nil
+	}
+
 	return os.Remove(i.Name)
 }
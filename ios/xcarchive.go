@@ -0,0 +1,395 @@
+package ios
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"cmp"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"howett.net/plist"
+)
+
+const (
+	ExtXCArchive = ".xcarchive"
+	// ArchiveDSYMsDir is the directory in an .xcarchive that holds the debug symbols of its products.
+	ArchiveDSYMsDir = "dSYMs"
+	// ArchiveProductsDir is the directory in an .xcarchive that holds its products.
+	ArchiveProductsDir = "Products"
+	// PayloadDir is the directory in an .ipa that holds the .app.
+	PayloadDir = "Payload"
+)
+
+// ArchiveInfo is the Info.plist at the root of an .xcarchive.
+type ArchiveInfo struct {
+	Name                  string                       `plist:"Name"`
+	SchemeName            string                       `plist:"SchemeName"`
+	CreationDate          time.Time                    `plist:"CreationDate"`
+	ArchiveVersion        int                          `plist:"ArchiveVersion"`
+	ApplicationProperties ArchiveApplicationProperties `plist:"ApplicationProperties"`
+}
+
+// ArchiveApplicationProperties describe the app in an .xcarchive.
+// An .xcarchive of anything but an app, e.g. a framework, does not have them.
+type ArchiveApplicationProperties struct {
+	// ApplicationPath is the path to the .app relative to ArchiveProductsDir, e.g. Applications/App.app.
+	ApplicationPath            string   `plist:"ApplicationPath"`
+	Architectures              []string `plist:"Architectures"`
+	CFBundleIdentifier         string   `plist:"CFBundleIdentifier"`
+	CFBundleShortVersionString string   `plist:"CFBundleShortVersionString"`
+	CFBundleVersion            string   `plist:"CFBundleVersion"`
+	SigningIdentity            string   `plist:"SigningIdentity"`
+	Team                       string   `plist:"Team"`
+}
+
+// Archive is an .xcarchive on disk at Dir.
+type Archive struct {
+	Dir string
+
+	info *ArchiveInfo
+}
+
+// ArchiveRoot returns the path to the .xcarchive that name is in, if it is in one.
+func ArchiveRoot(name string) (string, bool) {
+	name = path.Clean(strings.TrimPrefix(filepath.ToSlash(name), "./"))
+
+	for i, elem := range strings.Split(name, "/") {
+		if strings.EqualFold(path.Ext(elem), ExtXCArchive) {
+			return strings.Join(strings.Split(name, "/")[:i+1], "/"), true
+		}
+	}
+
+	return "", false
+}
+
+// ExtractArchive extracts the .xcarchive at root from tr into dir, starting with hdr,
+// the entry that was last read from tr. Entries that are not in the .xcarchive are skipped.
+// Entries are written through an os.Root opened on dir, so that neither their names
+// nor the links that come before them can lead outside of it.
+func ExtractArchive(tr *tar.Reader, hdr *tar.Header, root, dir string) (*Archive, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+
+	r, err := os.OpenRoot(dir)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = r.Close()
+	}()
+
+	for ; hdr != nil; hdr = next(tr) {
+		name := path.Clean(strings.TrimPrefix(filepath.ToSlash(hdr.Name), "./"))
+
+		rel, ok := strings.CutPrefix(name, root)
+		if !ok || (rel != "" && !strings.HasPrefix(rel, "/")) {
+			continue
+		}
+
+		var (
+			target = filepath.FromSlash(cmp.Or(strings.TrimPrefix(rel, "/"), "."))
+			mode   = hdr.FileInfo().Mode()
+		)
+
+		if !filepath.IsLocal(target) {
+			return nil, fmt.Errorf("%s is outside of %s", hdr.Name, root)
+		}
+
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			if err := r.MkdirAll(target, 0o755); err != nil {
+				return nil, err
+			}
+		case tar.TypeReg:
+			if err := r.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+				return nil, err
+			}
+
+			f, err := r.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, mode.Perm()|0o600)
+			if err != nil {
+				return nil, err
+			}
+
+			_, err = io.Copy(f, tr)
+			_ = f.Close()
+			if err != nil {
+				return nil, err
+			}
+		case tar.TypeSymlink:
+			// Links are followed when the .ipa is written, so they must stay inside of the .xcarchive.
+			// Links that only lead outside of it through other links are caught by r when followed.
+			if resolved := filepath.Join(filepath.Dir(target), filepath.FromSlash(hdr.Linkname)); filepath.IsAbs(hdr.Linkname) || !filepath.IsLocal(resolved) {
+				return nil, fmt.Errorf("%s links outside of %s", hdr.Name, root)
+			}
+
+			if err := r.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+				return nil, err
+			}
+
+			if err := r.Symlink(hdr.Linkname, target); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return &Archive{Dir: dir}, nil
+}
+
+// next returns the next header in tr, or nil when there are no more or it cannot be read.
+func next(tr *tar.Reader) *tar.Header {
+	hdr, err := tr.Next()
+	if err != nil {
+		return nil
+	}
+
+	return hdr
+}
+
+// Info returns the .xcarchive's Info.plist.
+func (a *Archive) Info() (*ArchiveInfo, error) {
+	if a.info != nil {
+		return a.info, nil
+	}
+
+	r, err := os.OpenRoot(a.Dir)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = r.Close()
+	}()
+
+	f, err := r.Open(InfoPlistName)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+
+	info := &ArchiveInfo{}
+	if err := plist.NewDecoder(f).Decode(info); err != nil {
+		return nil, err
+	}
+
+	if info.ApplicationProperties.ApplicationPath == "" {
+		return nil, fmt.Errorf("%s is not an archive of an app", a.Dir)
+	}
+
+	// The .app must be inside of ArchiveProductsDir, not ArchiveProductsDir itself.
+	if appPath := filepath.FromSlash(info.ApplicationProperties.ApplicationPath); !filepath.IsLocal(appPath) || filepath.Clean(appPath) == "." {
+		return nil, fmt.Errorf("%s application path %q is outside of %s", a.Dir, info.ApplicationProperties.ApplicationPath, ArchiveProductsDir)
+	}
+
+	a.info = info
+
+	return info, nil
+}
+
+// WriteIPA writes an .ipa of the .xcarchive's app, i.e. a zip of Payload/<App>.app, to w.
+// The app's Info.plist is written first so that it is found before those of its frameworks.
+func (a *Archive) WriteIPA(w io.Writer) error {
+	info, err := a.Info()
+	if err != nil {
+		return err
+	}
+
+	r, err := os.OpenRoot(a.Dir)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = r.Close()
+	}()
+
+	var (
+		app = path.Join(ArchiveProductsDir, path.Clean(info.ApplicationProperties.ApplicationPath))
+		zw  = zip.NewWriter(w)
+	)
+
+	if err := writeZipFile(zw, r, path.Join(app, InfoPlistName), path.Join(PayloadDir, path.Base(app), InfoPlistName)); err != nil {
+		return err
+	}
+
+	if err := writeZipDir(zw, r, app, path.Join(PayloadDir, path.Base(app)), func(rel string) bool {
+		return rel == InfoPlistName
+	}); err != nil {
+		return err
+	}
+
+	return zw.Close()
+}
+
+// ErrNoDSYMs is returned by WriteDSYMs when the .xcarchive has no debug symbols.
+var ErrNoDSYMs = errors.New("archive has no dSYMs")
+
+// WriteDSYMs writes a zip of the .xcarchive's dSYMs directory to w.
+func (a *Archive) WriteDSYMs(w io.Writer) error {
+	r, err := os.OpenRoot(a.Dir)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = r.Close()
+	}()
+
+	if entries, err := fs.ReadDir(r.FS(), ArchiveDSYMsDir); errors.Is(err, os.ErrNotExist) || (err == nil && len(entries) == 0) {
+		return ErrNoDSYMs
+	} else if err != nil {
+		return err
+	}
+
+	zw := zip.NewWriter(w)
+
+	if err := writeZipDir(zw, r, ArchiveDSYMsDir, "", func(string) bool { return false }); err != nil {
+		return err
+	}
+
+	return zw.Close()
+}
+
+// writeZipDir writes the directory dir in r to zw under prefix, skipping
+// the entries whose names relative to dir skip reports true for.
+// Links are written as links rather than followed.
+func writeZipDir(zw *zip.Writer, r *os.Root, dir, prefix string, skip func(string) bool) error {
+	return fs.WalkDir(r.FS(), dir, func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		rel := strings.TrimPrefix(strings.TrimPrefix(name, dir), "/")
+
+		if rel == "" || skip(rel) {
+			return nil
+		}
+
+		zipName := path.Join(prefix, rel)
+
+		switch {
+		case d.IsDir():
+			_, err := zw.CreateHeader(&zip.FileHeader{Name: zipName + "/", Method: zip.Store})
+			return err
+		case d.Type()&fs.ModeSymlink != 0:
+			link, err := r.Readlink(name)
+			if err != nil {
+				return err
+			}
+
+			hdr := &zip.FileHeader{Name: zipName, Method: zip.Store}
+			hdr.SetMode(fs.ModeSymlink | 0o755)
+
+			w, err := zw.CreateHeader(hdr)
+			if err != nil {
+				return err
+			}
+
+			_, err = io.WriteString(w, link)
+			return err
+		}
+
+		return writeZipFile(zw, r, name, zipName)
+	})
+}
+
+// writeZipFile writes the file name in r to zw as zipName.
+// Links are followed, but only as far as they stay inside of r.
+func writeZipFile(zw *zip.Writer, r *os.Root, name, zipName string) error {
+	f, err := r.Open(filepath.FromSlash(name))
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+
+	fi, err := f.Stat()
+	if err != nil {
+		return err
+	}
+
+	hdr, err := zip.FileInfoHeader(fi)
+	if err != nil {
+		return err
+	}
+	hdr.Name = zipName
+	hdr.Method = zip.Deflate
+
+	w, err := zw.CreateHeader(hdr)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(w, f)
+	return err
+}
+
+// WriteTar writes a tarball of the .xcarchive to w, rooted at the name of its directory.
+func (a *Archive) WriteTar(w io.Writer) error {
+	var (
+		tw   = tar.NewWriter(w)
+		root = filepath.Base(a.Dir)
+	)
+
+	if err := filepath.WalkDir(a.Dir, func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(a.Dir, name)
+		if err != nil {
+			return err
+		}
+
+		fi, err := d.Info()
+		if err != nil {
+			return err
+		}
+
+		link := ""
+		if d.Type()&fs.ModeSymlink != 0 {
+			if link, err = os.Readlink(name); err != nil {
+				return err
+			}
+		}
+
+		hdr, err := tar.FileInfoHeader(fi, link)
+		if err != nil {
+			return err
+		}
+		hdr.Name = path.Join(root, filepath.ToSlash(rel))
+		if d.IsDir() {
+			hdr.Name += "/"
+		}
+
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+
+		if !d.Type().IsRegular() {
+			return nil
+		}
+
+		f, err := os.Open(name)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			_ = f.Close()
+		}()
+
+		_, err = io.Copy(tw, f)
+		return err
+	}); err != nil {
+		return err
+	}
+
+	return tw.Close()
+}
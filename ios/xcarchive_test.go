@@ -0,0 +1,237 @@
+package ios_test
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/frantjc/momo/ios"
+	"howett.net/plist"
+)
+
+func writeTar(t *testing.T, tw *tar.Writer, name string, v any) {
+	t.Helper()
+
+	b, err := plist.Marshal(v, plist.XMLFormat)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = tw.WriteHeader(&tar.Header{Name: name, Mode: 0o644, Size: int64(len(b)), Typeflag: tar.TypeReg}); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = tw.Write(b); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func extractArchive(t *testing.T, build func(*tar.Writer)) (*ios.Archive, error) {
+	t.Helper()
+
+	return extractArchiveTo(t, filepath.Join(t.TempDir(), "App.xcarchive"), build)
+}
+
+func extractArchiveTo(t *testing.T, dir string, build func(*tar.Writer)) (*ios.Archive, error) {
+	t.Helper()
+
+	var (
+		buf = new(bytes.Buffer)
+		tw  = tar.NewWriter(buf)
+	)
+
+	build(tw)
+
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	tr := tar.NewReader(buf)
+
+	hdr, err := tr.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	root, ok := ios.ArchiveRoot(hdr.Name)
+	if !ok {
+		t.Fatalf("expected %s to be in an .xcarchive", hdr.Name)
+	}
+
+	return ios.ExtractArchive(tr, hdr, root, dir)
+}
+
+func TestArchive(t *testing.T) {
+	archive, err := extractArchive(t, func(tw *tar.Writer) {
+		writeTar(t, tw, "build/App.xcarchive/Info.plist", map[string]any{
+			"Name": "App",
+			"ApplicationProperties": map[string]any{
+				"ApplicationPath":            "Applications/App.app",
+				"CFBundleShortVersionString": "1.2.3",
+				"CFBundleVersion":            "45",
+			},
+		})
+		writeTar(t, tw, "build/App.xcarchive/Products/Applications/App.app/Frameworks/A.framework/Info.plist", map[string]any{
+			"CFBundleIdentifier": "com.example.a",
+		})
+		writeTar(t, tw, "build/App.xcarchive/Products/Applications/App.app/Info.plist", map[string]any{
+			"CFBundleIdentifier":         "com.example.app",
+			"CFBundleShortVersionString": "1.2.3",
+		})
+		writeTar(t, tw, "build/App.xcarchive/dSYMs/App.app.dSYM/Contents/Info.plist", map[string]any{})
+		writeTar(t, tw, "build/Other/Info.plist", map[string]any{})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	info, err := archive.Info()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if info.ApplicationProperties.CFBundleVersion != "45" {
+		t.Fatalf("expected CFBundleVersion 45, got %s", info.ApplicationProperties.CFBundleVersion)
+	}
+
+	ipa := filepath.Join(t.TempDir(), "app.ipa")
+
+	f, err := os.Create(ipa)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = archive.WriteIPA(f); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = f.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	appInfo, err := ios.NewIPADecoder(ipa).Info(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if appInfo.CFBundleIdentifier != "com.example.app" {
+		t.Fatalf("expected CFBundleIdentifier com.example.app, got %s", appInfo.CFBundleIdentifier)
+	}
+
+	buf := new(bytes.Buffer)
+	if err = archive.WriteDSYMs(buf); err != nil {
+		t.Fatal(err)
+	}
+
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = zr.Open("App.app.dSYM/Contents/Info.plist"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestArchiveNoDSYMs(t *testing.T) {
+	archive, err := extractArchive(t, func(tw *tar.Writer) {
+		writeTar(t, tw, "App.xcarchive/Info.plist", map[string]any{
+			"ApplicationProperties": map[string]any{"ApplicationPath": "Applications/App.app"},
+		})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = archive.WriteDSYMs(io.Discard); !errors.Is(err, ios.ErrNoDSYMs) {
+		t.Fatalf("expected ErrNoDSYMs, got %v", err)
+	}
+}
+
+func TestArchiveSymlinkOutside(t *testing.T) {
+	if _, err := extractArchive(t, func(tw *tar.Writer) {
+		if err := tw.WriteHeader(&tar.Header{Name: "App.xcarchive/Info.plist", Linkname: "../../../etc/passwd", Typeflag: tar.TypeSymlink}); err != nil {
+			t.Fatal(err)
+		}
+	}); err == nil {
+		t.Fatal("expected an error for a link outside of the archive")
+	}
+}
+
+func writeSymlink(t *testing.T, tw *tar.Writer, name, linkname string) {
+	t.Helper()
+
+	if err := tw.WriteHeader(&tar.Header{Name: name, Linkname: linkname, Typeflag: tar.TypeSymlink}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestArchiveChainedSymlinkOutside(t *testing.T) {
+	var (
+		tmp = t.TempDir()
+		dir = filepath.Join(tmp, "App.xcarchive")
+	)
+
+	// Each link stays inside of the archive on its own, but s/t/a leads to tmp through s/t/b.
+	if _, err := extractArchiveTo(t, dir, func(tw *tar.Writer) {
+		writeSymlink(t, tw, "App.xcarchive/s/t/b", "../..")
+		writeSymlink(t, tw, "App.xcarchive/s/t/a", "b/..")
+		writeTar(t, tw, "App.xcarchive/s/t/a/x", "x")
+	}); err == nil {
+		t.Fatal("expected an error for writing through a link outside of the archive")
+	}
+
+	if _, err := os.Lstat(filepath.Join(tmp, "x")); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("expected nothing to be written outside of the archive, got %v", err)
+	}
+}
+
+func TestArchiveChainedSymlinkInfoPlist(t *testing.T) {
+	var (
+		tmp = t.TempDir()
+		dir = filepath.Join(tmp, "App.xcarchive")
+	)
+
+	if err := os.WriteFile(filepath.Join(tmp, "secret"), []byte("secret"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	archive, err := extractArchiveTo(t, dir, func(tw *tar.Writer) {
+		writeTar(t, tw, "App.xcarchive/Info.plist", map[string]any{
+			"ApplicationProperties": map[string]any{"ApplicationPath": "Applications/App.app"},
+		})
+		writeSymlink(t, tw, "App.xcarchive/Products/Applications/App.app/b", "../..")
+		writeSymlink(t, tw, "App.xcarchive/Products/Applications/App.app/a", "b/..")
+		writeSymlink(t, tw, "App.xcarchive/Products/Applications/App.app/Info.plist", "a/../secret")
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = archive.WriteIPA(io.Discard); err == nil {
+		t.Fatal("expected an error for an Info.plist that links outside of the archive")
+	}
+}
+
+func TestArchiveApplicationPathOutside(t *testing.T) {
+	for _, applicationPath := range []string{"../../App.app", "/App.app", "."} {
+		archive, err := extractArchive(t, func(tw *tar.Writer) {
+			writeTar(t, tw, "App.xcarchive/Info.plist", map[string]any{
+				"ApplicationProperties": map[string]any{"ApplicationPath": applicationPath},
+			})
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err = archive.Info(); err == nil {
+			t.Errorf("expected an error for application path %q", applicationPath)
+		}
+	}
+}